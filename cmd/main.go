@@ -1,50 +1,345 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
 	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/nemopss/go-posts-comments-system/internal/auth"
+	"github.com/nemopss/go-posts-comments-system/internal/config"
+	"github.com/nemopss/go-posts-comments-system/internal/gql"
+	"github.com/nemopss/go-posts-comments-system/internal/metrics"
+	"github.com/nemopss/go-posts-comments-system/internal/migrations"
 	"github.com/nemopss/go-posts-comments-system/internal/repository"
 	"github.com/nemopss/go-posts-comments-system/internal/repository/inmemory"
 	"github.com/nemopss/go-posts-comments-system/internal/repository/postgres"
+	"github.com/nemopss/go-posts-comments-system/internal/repository/sqlite"
 	"github.com/nemopss/go-posts-comments-system/internal/server"
+	"github.com/nemopss/go-posts-comments-system/internal/tracing"
 )
 
 func main() {
-	// Определение флага командной строки для выбора хранилища. true -> Postgres, false -> in-memory storage
-	postgresStorageFlag := flag.Bool("postgres", false, "A flag to determine which storage you want to use. true -> Postgres, false -> in-memory storage")
-	flag.Parse()
+	// Подкоманда migrate управляет версией схемы базы данных и завершает работу
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	// Вывод итоговой конфигурации со скрытыми секретами без запуска сервера
+	printConfigFlag := flags.Bool("print-config", false, "Print the effective configuration with secrets redacted and exit")
+	conf, err := config.Load(flags, os.Args[1:], os.Getenv)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if *printConfigFlag {
+		if err := conf.Write(os.Stdout); err != nil {
+			fatalf("Error printing configuration: %v", err)
+		}
+		return
+	}
+	// Сообщения пакета log записываются с уровнем INFO и отбрасываются при более высоком уровне журнала
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: conf.Log.Level})))
+
+	cfg := auth.Config{
+		Algorithm: conf.Auth.Algorithm,
+		Issuer:    conf.Auth.Issuer,
+		TTL:       conf.Auth.TokenTTL,
+	}
+	switch cfg.Algorithm {
+	case auth.AlgorithmHS256:
+		cfg.Secret = []byte(conf.Auth.Secret)
+		if len(cfg.Secret) == 0 {
+			// Без заданного секрета генерируется случайный, и токены перестают действовать после перезапуска
+			log.Println("Auth secret is not set, generating a random one...")
+			cfg.Secret = make([]byte, 32)
+			if _, err := rand.Read(cfg.Secret); err != nil {
+				fatalf("Error generating auth secret: %v", err)
+			}
+		}
+	case auth.AlgorithmEdDSA:
+		var err error
+		if conf.Auth.PrivateKeyPath != "" {
+			if cfg.PrivateKey, err = auth.LoadEd25519PrivateKey(conf.Auth.PrivateKeyPath); err != nil {
+				fatalf("Error loading Ed25519 private key: %v", err)
+			}
+		}
+		if conf.Auth.PublicKeyPath != "" {
+			if cfg.PublicKey, err = auth.LoadEd25519PublicKey(conf.Auth.PublicKeyPath); err != nil {
+				fatalf("Error loading Ed25519 public key: %v", err)
+			}
+		}
+	}
+	tokens, err := auth.NewTokens(cfg)
+	if err != nil {
+		fatalf("Error configuring access tokens: %v", err)
+	}
+
+	// Трассировка OpenTelemetry: контекст трассы из заголовка traceparent продолжается и без экспорта span
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    conf.Tracing.Exporter,
+		Endpoint:    conf.Tracing.Endpoint,
+		ServiceName: conf.Tracing.ServiceName,
+		SampleRatio: conf.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatalf("Error configuring tracing: %v", err)
+	}
+
+	// Метрики собираются всегда, а флаг server.metrics определяет, доступны ли они по адресу /metrics
+	collector := metrics.New()
+
 	var rep repository.Repository
-	// Если флаг postgresStorageFlag установлен в false, используем хранилище в памяти
-	if !*postgresStorageFlag {
-		rep = inmemory.NewInMemoryRepository()
-		log.Println("In-memory storage active...")
-	} else {
-		// Формирование строки подключения к базе данных PostgreSQL с использованием переменных окружения
-		connStr := "postgres://" + os.Getenv("POSTGRES_USER") + ":" + os.Getenv("POSTGRES_PASSWORD") + "@" + os.Getenv("POSTGRES_HOST") + ":5432/" + os.Getenv("POSTGRES_DB") + "?sslmode=disable"
-
-		// Открытие соединения с базой данных PostgreSQL
-		db, err := sql.Open("postgres", connStr)
+	var closeStorage func() error // Закрывает хранилище после остановки HTTP сервера
+	switch conf.Storage.Backend {
+	case config.StorageMemory:
+		memory := conf.Storage.Memory
+		if memory.DataDir == "" {
+			mem := inmemory.NewInMemoryRepository()
+			rep, closeStorage = mem, mem.Close
+			collector.RegisterInMemory(mem)
+			log.Println("In-memory storage active...")
+			break
+		}
+		policy, err := inmemory.ParseSyncPolicy(memory.FSync)
 		if err != nil {
-			log.Fatalf("Error opening db: %v", err)
+			fatalf("%v", err)
+		}
+		mem, err := inmemory.Open(inmemory.Options{Dir: memory.DataDir, Sync: policy, SnapshotEvery: memory.SnapshotEvery})
+		if err != nil {
+			fatalf("Error opening in-memory storage: %v", err)
+		}
+		rep, closeStorage = mem, mem.Close
+		collector.RegisterInMemory(mem)
+		log.Println("In-memory storage active, persisted to", memory.DataDir)
+	case config.StoragePostgres:
+		db := openDatabase(conf.Storage)
+		if conf.Storage.Migrate {
+			migrateUp(db, migrations.Postgres)
 		}
 		// Инициализация репозитория с использованием PostgreSQL
-		rep = postgres.NewPostgresRepository(db)
+		rep, closeStorage = postgres.NewPostgresRepository(db), db.Close
+		collector.RegisterDB(db, config.StoragePostgres)
 		log.Println("PostgreSQL storage active...")
+	case config.StorageSQLite:
+		// Встроенная база создаётся при первом запуске, поэтому её схема всегда приводится к последней версии
+		db := openDatabase(conf.Storage)
+		migrateUp(db, migrations.SQLite)
+		rep, closeStorage = sqlite.NewSQLiteRepository(db), db.Close
+		collector.RegisterDB(db, config.StorageSQLite)
+		log.Println("SQLite storage active at", conf.Storage.Path)
+	}
+
+	// Каждая операция хранилища ограничивается по времени, а прерванные по сроку операции возвращают ErrTimeout
+	rep = repository.WithTimeout(rep, conf.Storage.QueryTimeout)
+	// Вызовы методов хранилища подсчитываются по результату, включая прерванные по сроку
+	rep = metrics.Instrument(rep, collector)
+
+	// Администратор создаётся до того, как сервер начнёт принимать запросы, чтобы его имя не мог занять другой пользователь
+	if conf.Auth.AdminUsername != "" {
+		if err := gql.EnsureAdmin(context.Background(), rep, conf.Auth.AdminUsername, conf.Auth.AdminPassword); err != nil {
+			fatalf("Error creating admin user: %v", err)
+		}
 	}
 
 	// Создание нового сервера GraphQL
-	srv := server.NewServer(rep)
+	srv := server.NewServer(rep, tokens, gql.Options{
+		SoftDeleteComments: conf.Content.SoftDeleteComments,
+		MaxCommentLength:   conf.Content.MaxCommentLength,
+		MaxPostTitleLength: conf.Content.MaxPostTitleLength,
+		MaxPostLength:      conf.Content.MaxPostLength,
+		Instrumentation:    collector,
+		AdminUsername:      conf.Auth.AdminUsername,
+	})
+	srv.GraphiQL = conf.Server.GraphiQL
+
+	// Регистрация обработчиков GraphQL и проверок состояния
+	mux := http.NewServeMux()
+	mux.Handle("/graphql", server.TracingMiddleware(server.TimeoutMiddleware(conf.Server.RequestTimeout, srv.Handler())))
+	mux.Handle("/healthz", srv.LivenessHandler())
+	mux.Handle("/readyz", srv.ReadinessHandler())
+	if conf.Server.Metrics {
+		mux.Handle("/metrics", collector.Handler())
+	}
+	httpServer := &http.Server{Addr: conf.Server.Addr, Handler: mux}
+
+	// Остановка по SIGINT или SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Регистрация обработчика для маршрута /graphql
-	http.Handle("/graphql", srv.Handler())
+	// Запуск HTTP сервера
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.ListenAndServe()
+	}()
+	log.Printf("Server is running on %s/graphql\n", conf.Server.Addr)
+
+	select {
+	case err := <-serveErr:
+		fatalf("Server stopped: %v", err)
+	case <-ctx.Done():
+	}
+	// Повторный сигнал во время остановки завершает программу сразу
+	stop()
 
-	log.Println("Server is running on http://localhost:8080/graphql")
+	// Проверка готовности начинает отвечать 503, WebSocket соединения закрываются,
+	// а начатые HTTP запросы завершаются не дольше shutdown_timeout
+	srv.Shutdown()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), conf.Server.ShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Println("Error draining HTTP requests:", err)
+	}
+	if err := closeStorage(); err != nil {
+		fatalf("Error closing storage: %v", err)
+	}
+	// Отправка накопленных span
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Println("Error flushing traces:", err)
+	}
+	log.Println("Server stopped")
+}
 
-	// Запуск HTTP сервера на порту 8080
-	log.Fatal(http.ListenAndServe(":8080", nil))
+// fatalf записывает ошибку с уровнем ERROR, который не отбрасывается ни при каком уровне журнала, и завершает программу
+func fatalf(format string, args ...interface{}) {
+	slog.Error(fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+
+// openDatabase открывает базу данных хранилища: PostgreSQL по строке подключения с настройками пула
+// соединений или файл SQLite
+func openDatabase(storage config.StorageConfig) *sql.DB {
+	if storage.Backend == config.StorageSQLite {
+		db, err := sqlite.Open(storage.Path)
+		if err != nil {
+			fatalf("Error opening db: %v", err)
+		}
+		return db
+	}
+
+	// Открытие соединения с базой данных PostgreSQL
+	db, err := sql.Open("postgres", storage.DSN)
+	if err != nil {
+		fatalf("Error opening db: %v", err)
+	}
+	db.SetMaxOpenConns(storage.Pool.MaxOpenConns)
+	db.SetMaxIdleConns(storage.Pool.MaxIdleConns)
+	db.SetConnMaxLifetime(storage.Pool.ConnMaxLifetime)
+	// sql.Open не устанавливает соединение, поэтому недоступная база обнаруживается при запуске, а не на первом запросе
+	if err := pingDatabase(db, storage.ConnectTimeout); err != nil {
+		fatalf("Error connecting to db: %v", err)
+	}
+	return db
+}
+
+// pingDatabase проверяет соединение с базой данных, повторяя попытки раз в секунду в течение timeout,
+// чтобы сервер мог запускаться одновременно с базой данных
+func pingDatabase(db *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := db.PingContext(ctx)
+		cancel()
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		log.Println("Database is not reachable, retrying:", err)
+		time.Sleep(time.Second)
+	}
+}
+
+// migrateUp применяет недостающие миграции схемы
+func migrateUp(db *sql.DB, dialect migrations.Dialect) {
+	migrator, err := migrations.NewMigrator(db, dialect)
+	if err != nil {
+		fatalf("Error loading migrations: %v", err)
+	}
+	if err := migrator.Up(); err != nil {
+		fatalf("Error applying migrations: %v", err)
+	}
+}
+
+// migrateUsage описывает подкоманды migrate
+const migrateUsage = `Usage: main migrate [-config=file] [-storage=postgres|sqlite] [-dsn=url] [-db=path] <command>
+
+Commands:
+  up        apply all pending migrations
+  down      revert the last applied migration
+  status    list migrations and when they were applied
+  to N      migrate up or down to version N (0 reverts everything)
+  baseline N  mark migrations up to version N as applied without running them,
+              for a database whose schema was created before migrations`
+
+// runMigrate выполняет подкоманду migrate
+func runMigrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	conf, err := config.Load(flags, args, os.Getenv)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	args = flags.Args()
+	if len(args) == 0 {
+		fatalf(migrateUsage)
+	}
+
+	var dialect migrations.Dialect
+	switch conf.Storage.Backend {
+	case config.StoragePostgres, config.StorageMemory:
+		// Хранилищу в памяти схема не нужна, поэтому по умолчанию подразумевается PostgreSQL
+		conf.Storage.Backend, dialect = config.StoragePostgres, migrations.Postgres
+		if conf.Storage.DSN == "" {
+			fatalf("storage.dsn: required for %s storage", config.StoragePostgres)
+		}
+	case config.StorageSQLite:
+		dialect = migrations.SQLite
+	}
+	migrator, err := migrations.NewMigrator(openDatabase(conf.Storage), dialect)
+	if err != nil {
+		fatalf("Error loading migrations: %v", err)
+	}
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		err = migrator.Up()
+	case args[0] == "down" && len(args) == 1:
+		err = migrator.Down()
+	case args[0] == "to" && len(args) == 2:
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			fatalf("Invalid version %q", args[1])
+		}
+		err = migrator.To(version)
+	case args[0] == "baseline" && len(args) == 2:
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			fatalf("Invalid version %q", args[1])
+		}
+		err = migrator.Baseline(version)
+	case args[0] == "status" && len(args) == 1:
+		var statuses []*migrations.Status
+		statuses, err = migrator.Status()
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied at " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, state)
+		}
+	default:
+		fatalf(migrateUsage)
+	}
+	if err != nil {
+		fatalf("Migration failed: %v", err)
+	}
 }
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/nemopss/go-posts-comments-system/internal/models"
+)
+
+// Viewer представляет пользователя, выполняющего запрос
+type Viewer struct {
+	UserID string // Идентификатор пользователя
+	Role   string // Роль пользователя из токена доступа
+}
+
+// IsAdmin сообщает, является ли пользователь администратором
+func (v *Viewer) IsAdmin() bool {
+	return v.Role == models.RoleAdmin
+}
+
+// CanModify сообщает, может ли пользователь изменять или удалять объект автора authorId:
+// это разрешено самому автору и администраторам
+func (v *Viewer) CanModify(authorId string) bool {
+	return v.UserID == authorId || v.IsAdmin()
+}
+
+// viewerKey — ключ контекста, под которым хранится текущий пользователь
+type viewerKey struct{}
+
+// WithViewer возвращает контекст, содержащий пользователя, выполняющего запрос
+func WithViewer(ctx context.Context, viewer *Viewer) context.Context {
+	return context.WithValue(ctx, viewerKey{}, viewer)
+}
+
+// ViewerFromContext возвращает пользователя, выполняющего запрос.
+// Второе значение равно false для анонимных запросов.
+func ViewerFromContext(ctx context.Context) (*Viewer, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	viewer, ok := ctx.Value(viewerKey{}).(*Viewer)
+	return viewer, ok && viewer != nil
+}
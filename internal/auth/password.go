@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength — минимальная допустимая длина пароля
+const MinPasswordLength = 8
+
+// ErrInvalidCredentials возвращается, если имя пользователя или пароль не подходят
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// ErrPasswordTooShort возвращается, если пароль короче MinPasswordLength символов
+var ErrPasswordTooShort = fmt.Errorf("password must be at least %d characters long", MinPasswordLength)
+
+// HashPassword возвращает bcrypt-хеш пароля
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", ErrPasswordTooShort
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// dummyHash возвращает хеш с той же стоимостью, что и хеши паролей пользователей. Вычисляется при первом
+// обращении, чтобы не замедлять запуск.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// RejectPassword сравнивает пароль с фиксированным хешем и всегда возвращает ErrInvalidCredentials.
+// Вызывается при входе несуществующего пользователя, чтобы время ответа не выдавало, есть ли пользователь с таким именем.
+func RejectPassword(password string) error {
+	bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+	return ErrInvalidCredentials
+}
+
+// CheckPassword сравнивает пароль с bcrypt-хешем.
+// Возвращает ErrInvalidCredentials, если пароль не совпадает.
+func CheckPassword(hash, password string) error {
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
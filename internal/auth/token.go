@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nemopss/go-posts-comments-system/internal/models"
+)
+
+// Поддерживаемые алгоритмы подписи токенов
+const (
+	AlgorithmHS256 = "HS256" // HMAC-SHA256 с общим секретом
+	AlgorithmEdDSA = "EdDSA" // Ed25519 с парой ключей
+)
+
+var (
+	// ErrInvalidToken возвращается, если токен не удалось проверить
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrSigningDisabled возвращается при попытке выпустить токен без ключа подписи,
+	// например, когда токены выпускает внешний сервис, а сервер знает только открытый ключ
+	ErrSigningDisabled = errors.New("token signing is not configured")
+)
+
+// Config задаёт алгоритм и ключи для выпуска и проверки токенов доступа
+type Config struct {
+	Algorithm  string             // AlgorithmHS256 или AlgorithmEdDSA
+	Secret     []byte             // Общий секрет для HS256
+	PrivateKey ed25519.PrivateKey // Закрытый ключ для подписи EdDSA (может отсутствовать)
+	PublicKey  ed25519.PublicKey  // Открытый ключ для проверки EdDSA
+	Issuer     string             // Издатель токенов (claim iss), проверяется, если задан
+	TTL        time.Duration      // Время жизни выпускаемых токенов
+}
+
+// claims представляет содержимое токена доступа
+type claims struct {
+	jwt.RegisteredClaims
+	Role string `json:"role,omitempty"` // Роль пользователя
+}
+
+// Tokens выпускает и проверяет токены доступа (JWT)
+type Tokens struct {
+	method     jwt.SigningMethod
+	signingKey crypto.PrivateKey // Ключ подписи, nil - выпуск токенов отключён
+	verifyKey  crypto.PublicKey  // Ключ проверки подписи
+	issuer     string
+	ttl        time.Duration
+}
+
+// NewTokens создаёт новый экземпляр Tokens с заданной конфигурацией
+func NewTokens(cfg Config) (*Tokens, error) {
+	t := &Tokens{issuer: cfg.Issuer, ttl: cfg.TTL}
+	switch cfg.Algorithm {
+	case AlgorithmHS256, "":
+		if len(cfg.Secret) == 0 {
+			return nil, errors.New("HS256 requires a non-empty secret")
+		}
+		t.method = jwt.SigningMethodHS256
+		t.signingKey = cfg.Secret
+		t.verifyKey = cfg.Secret
+	case AlgorithmEdDSA:
+		publicKey := cfg.PublicKey
+		if publicKey == nil && cfg.PrivateKey != nil {
+			publicKey = cfg.PrivateKey.Public().(ed25519.PublicKey)
+		}
+		if publicKey == nil {
+			return nil, errors.New("EdDSA requires a public or private key")
+		}
+		t.method = jwt.SigningMethodEdDSA
+		if cfg.PrivateKey != nil {
+			t.signingKey = cfg.PrivateKey
+		}
+		t.verifyKey = publicKey
+	default:
+		return nil, fmt.Errorf("unsupported token algorithm %q", cfg.Algorithm)
+	}
+	return t, nil
+}
+
+// Issue выпускает токен доступа для пользователя
+func (t *Tokens) Issue(user *models.User) (string, error) {
+	if t.signingKey == nil {
+		return "", ErrSigningDisabled
+	}
+	now := time.Now()
+	c := claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID,
+			Issuer:    t.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(t.ttl)),
+		},
+		Role: user.Role,
+	}
+	return jwt.NewWithClaims(t.method, c).SignedString(t.signingKey)
+}
+
+// Verify проверяет подпись, срок действия и издателя токена и возвращает пользователя, которому он выдан
+func (t *Tokens) Verify(token string) (*Viewer, error) {
+	c := &claims{}
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{t.method.Alg()}),
+		jwt.WithExpirationRequired(),
+	}
+	if t.issuer != "" {
+		options = append(options, jwt.WithIssuer(t.issuer))
+	}
+	_, err := jwt.ParseWithClaims(token, c, func(*jwt.Token) (interface{}, error) {
+		return t.verifyKey, nil
+	}, options...)
+	if err != nil || c.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	role := c.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+	return &Viewer{UserID: c.Subject, Role: role}, nil
+}
+
+// LoadEd25519PrivateKey читает закрытый ключ Ed25519 из PEM файла в формате PKCS #8
+func LoadEd25519PrivateKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s does not contain an Ed25519 private key", path)
+	}
+	return privateKey, nil
+}
+
+// LoadEd25519PublicKey читает открытый ключ Ed25519 из PEM файла в формате PKIX
+func LoadEd25519PublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s does not contain an Ed25519 public key", path)
+	}
+	return publicKey, nil
+}
+
+// readPEM читает первый PEM блок из файла
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain PEM data", path)
+	}
+	return block, nil
+}
@@ -0,0 +1,271 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/nemopss/go-posts-comments-system/internal/auth"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+	"github.com/nemopss/go-posts-comments-system/internal/repository/inmemory"
+	"github.com/nemopss/go-posts-comments-system/internal/tracing"
+	"gopkg.in/yaml.v3"
+)
+
+// Виды хранилищ
+const (
+	StorageMemory   = "memory"
+	StoragePostgres = "postgres"
+	StorageSQLite   = "sqlite"
+)
+
+// Config содержит настройки сервиса. Значения собираются по слоям: значения по умолчанию, файл конфигурации,
+// переменные окружения и флаги командной строки; каждый следующий слой переопределяет предыдущий.
+type Config struct {
+	Server  ServerConfig  `yaml:"server"`
+	Storage StorageConfig `yaml:"storage"`
+	Auth    AuthConfig    `yaml:"auth"`
+	Content ContentConfig `yaml:"content"`
+	Log     LogConfig     `yaml:"log"`
+	Tracing TracingConfig `yaml:"tracing"`
+}
+
+// ServerConfig содержит настройки HTTP сервера
+type ServerConfig struct {
+	Addr            string        `yaml:"addr"`             // Адрес, на котором сервер принимает соединения
+	GraphiQL        bool          `yaml:"graphiql"`         // Встроенная среда GraphiQL для запросов из браузера
+	RequestTimeout  time.Duration `yaml:"request_timeout"`  // Наибольшее время HTTP запроса, 0 - без ограничения
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // Наибольшее время ожидания начатых запросов при остановке
+	Metrics         bool          `yaml:"metrics"`          // Метрики Prometheus по адресу /metrics
+}
+
+// StorageConfig содержит настройки хранилища
+type StorageConfig struct {
+	Backend        string        `yaml:"backend"`         // Вид хранилища: memory, postgres или sqlite
+	DSN            string        `yaml:"dsn"`             // Строка подключения к PostgreSQL
+	Path           string        `yaml:"path"`            // Путь к файлу SQLite
+	Migrate        bool          `yaml:"migrate"`         // Применение миграций PostgreSQL при запуске, схема SQLite приводится к последней версии всегда
+	QueryTimeout   time.Duration `yaml:"query_timeout"`   // Наибольшее время одной операции хранилища, 0 - без ограничения
+	ConnectTimeout time.Duration `yaml:"connect_timeout"` // Время ожидания доступности PostgreSQL при запуске, 0 - одна попытка
+	Pool           PoolConfig    `yaml:"pool"`
+	Memory         MemoryConfig  `yaml:"memory"`
+}
+
+// PoolConfig содержит настройки пула соединений с PostgreSQL
+type PoolConfig struct {
+	MaxOpenConns    int           `yaml:"max_open_conns"`    // Наибольшее количество открытых соединений, 0 - без ограничения
+	MaxIdleConns    int           `yaml:"max_idle_conns"`    // Наибольшее количество простаивающих соединений
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"` // Наибольшее время жизни соединения, 0 - без ограничения
+}
+
+// MemoryConfig содержит настройки сохранения хранилища в памяти на диск
+type MemoryConfig struct {
+	DataDir       string `yaml:"data_dir"`       // Каталог снимков и журнала, пустой - данные не сохраняются
+	FSync         string `yaml:"fsync"`          // Сброс журнала на диск: always, interval или never
+	SnapshotEvery int    `yaml:"snapshot_every"` // Количество операций между снимками
+}
+
+// AuthConfig содержит настройки токенов доступа
+type AuthConfig struct {
+	Algorithm      string        `yaml:"algorithm"`        // Алгоритм подписи: HS256 или EdDSA
+	Secret         string        `yaml:"secret"`           // Секрет HS256, пустой - случайный при каждом запуске
+	PrivateKeyPath string        `yaml:"private_key_path"` // Закрытый ключ Ed25519 для подписи токенов EdDSA
+	PublicKeyPath  string        `yaml:"public_key_path"`  // Открытый ключ Ed25519 для проверки токенов EdDSA
+	Issuer         string        `yaml:"issuer"`           // Ожидаемый издатель токенов, пустой - не проверяется
+	TokenTTL       time.Duration `yaml:"token_ttl"`        // Время действия токена
+	AdminUsername  string        `yaml:"admin_username"`   // Администратор, создаваемый при запуске, пустой - не создаётся
+	AdminPassword  string        `yaml:"admin_password"`   // Пароль администратора
+}
+
+// ContentConfig содержит ограничения и правила для постов и комментариев
+type ContentConfig struct {
+	MaxCommentLength   int  `yaml:"max_comment_length"`    // Наибольшая длина комментария в символах Unicode
+	MaxPostTitleLength int  `yaml:"max_post_title_length"` // Наибольшая длина заголовка поста, 0 - без ограничения
+	MaxPostLength      int  `yaml:"max_post_length"`       // Наибольшая длина текста поста, 0 - без ограничения
+	SoftDeleteComments bool `yaml:"soft_delete_comments"`  // Замена удалённых комментариев надгробиями
+}
+
+// LogConfig содержит настройки журнала
+type LogConfig struct {
+	Level slog.Level `yaml:"level"` // Наименьший уровень записываемых сообщений: DEBUG, INFO, WARN или ERROR
+}
+
+// TracingConfig содержит настройки трассировки OpenTelemetry
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`     // Экспорт span: none, stdout или otlp
+	Endpoint    string  `yaml:"endpoint"`     // URL коллектора OTLP/HTTP
+	ServiceName string  `yaml:"service_name"` // Имя сервиса в трассах
+	SampleRatio float64 `yaml:"sample_ratio"` // Доля трасс, начинаемых сервером, от 0 до 1
+}
+
+// Default возвращает конфигурацию со значениями по умолчанию
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			GraphiQL:        true,
+			RequestTimeout:  30 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+			Metrics:         true,
+		},
+		Storage: StorageConfig{
+			Backend:        StorageMemory,
+			Path:           "posts.db",
+			QueryTimeout:   10 * time.Second,
+			ConnectTimeout: 30 * time.Second,
+			Pool:           PoolConfig{MaxIdleConns: 2},
+			Memory:         MemoryConfig{FSync: string(inmemory.SyncAlways), SnapshotEvery: 1000},
+		},
+		Auth: AuthConfig{
+			Algorithm: auth.AlgorithmHS256,
+			TokenTTL:  24 * time.Hour,
+		},
+		Content: ContentConfig{
+			MaxCommentLength: repository.MaxCommentLength,
+		},
+		Log: LogConfig{Level: slog.LevelInfo},
+		Tracing: TracingConfig{
+			Exporter:    tracing.ExporterNone,
+			Endpoint:    "http://localhost:4318",
+			ServiceName: "go-posts-comments-system",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// Validate проверяет конфигурацию и возвращает все найденные ошибки
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		invalid("server.addr", "%v", err)
+	}
+	if c.Server.RequestTimeout < 0 {
+		invalid("server.request_timeout", "must not be negative")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		invalid("server.shutdown_timeout", "must be positive")
+	}
+
+	switch c.Storage.Backend {
+	case StorageMemory:
+		if _, err := inmemory.ParseSyncPolicy(c.Storage.Memory.FSync); err != nil {
+			invalid("storage.memory.fsync", "%v", err)
+		}
+		if c.Storage.Memory.SnapshotEvery < 0 {
+			invalid("storage.memory.snapshot_every", "must not be negative")
+		}
+	case StoragePostgres:
+		if c.Storage.DSN == "" {
+			invalid("storage.dsn", "required for %s storage", StoragePostgres)
+		}
+	case StorageSQLite:
+		if c.Storage.Path == "" {
+			invalid("storage.path", "required for %s storage", StorageSQLite)
+		}
+	default:
+		invalid("storage.backend", "unknown storage %q, expected %s, %s or %s", c.Storage.Backend, StorageMemory, StoragePostgres, StorageSQLite)
+	}
+	if c.Storage.QueryTimeout < 0 {
+		invalid("storage.query_timeout", "must not be negative")
+	}
+	if c.Storage.ConnectTimeout < 0 {
+		invalid("storage.connect_timeout", "must not be negative")
+	}
+	if c.Storage.Pool.MaxOpenConns < 0 {
+		invalid("storage.pool.max_open_conns", "must not be negative")
+	}
+	if c.Storage.Pool.MaxIdleConns < 0 {
+		invalid("storage.pool.max_idle_conns", "must not be negative")
+	}
+	if c.Storage.Pool.ConnMaxLifetime < 0 {
+		invalid("storage.pool.conn_max_lifetime", "must not be negative")
+	}
+
+	if c.Auth.Algorithm != auth.AlgorithmHS256 && c.Auth.Algorithm != auth.AlgorithmEdDSA {
+		invalid("auth.algorithm", "unknown algorithm %q, expected %s or %s", c.Auth.Algorithm, auth.AlgorithmHS256, auth.AlgorithmEdDSA)
+	}
+	if c.Auth.TokenTTL <= 0 {
+		invalid("auth.token_ttl", "must be positive")
+	}
+	if c.Auth.AdminUsername != "" && len(c.Auth.AdminPassword) < auth.MinPasswordLength {
+		invalid("auth.admin_password", "must be at least %d characters long when auth.admin_username is set", auth.MinPasswordLength)
+	}
+
+	// Хранилища не принимают комментарии длиннее repository.MaxCommentLength, поэтому ограничение можно только ужесточить
+	if c.Content.MaxCommentLength <= 0 || c.Content.MaxCommentLength > repository.MaxCommentLength {
+		invalid("content.max_comment_length", "must be between 1 and %d", repository.MaxCommentLength)
+	}
+	if c.Content.MaxPostTitleLength < 0 {
+		invalid("content.max_post_title_length", "must not be negative")
+	}
+	if c.Content.MaxPostLength < 0 {
+		invalid("content.max_post_length", "must not be negative")
+	}
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+	case tracing.ExporterOTLP:
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			invalid("tracing.endpoint", "must be an http or https URL of an OTLP collector")
+		}
+	default:
+		invalid("tracing.exporter", "unknown exporter %q, expected %s, %s or %s", c.Tracing.Exporter, tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		invalid("tracing.sample_ratio", "must be between 0 and 1")
+	}
+	return errors.Join(errs...)
+}
+
+// redactedValue заменяет скрытые значения секретов
+const redactedValue = "xxxxx"
+
+// dsnPassword находит пароль в строке подключения вида "host=... password=..." (в том числе sslpassword)
+var dsnPassword = regexp.MustCompile(`(password=)('[^']*'|\S+)`)
+
+// secretParams - параметры строки подключения в виде URL, содержащие пароли
+var secretParams = []string{"password", "sslpassword"}
+
+// Redacted возвращает копию конфигурации, в которой секреты заменены на xxxxx
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	if redacted.Auth.Secret != "" {
+		redacted.Auth.Secret = redactedValue
+	}
+	if redacted.Auth.AdminPassword != "" {
+		redacted.Auth.AdminPassword = redactedValue
+	}
+	if u, err := url.Parse(redacted.Storage.DSN); err == nil && u.Scheme != "" {
+		// Пароль может быть задан не только в userinfo, но и в параметрах запроса
+		query := u.Query()
+		for _, param := range secretParams {
+			if query.Has(param) {
+				query.Set(param, redactedValue)
+				u.RawQuery = query.Encode()
+			}
+		}
+		redacted.Storage.DSN = u.Redacted()
+	} else {
+		redacted.Storage.DSN = dsnPassword.ReplaceAllString(redacted.Storage.DSN, "${1}"+redactedValue)
+	}
+	return &redacted
+}
+
+// Write записывает конфигурацию в формате YAML со скрытыми секретами
+func (c *Config) Write(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,235 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvConfig - переменная окружения с путём к файлу конфигурации
+const EnvConfig = "CONFIG"
+
+// setting связывает поле конфигурации с флагом командной строки и переменной окружения
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	value value
+}
+
+// value разбирает строковое значение флага или переменной окружения в поле конфигурации
+type value struct {
+	set    func(string) error
+	isBool bool // Флаг можно указать без значения
+}
+
+func stringValue(p *string) value {
+	return value{set: func(s string) error {
+		*p = s
+		return nil
+	}}
+}
+
+func boolValue(p *bool) value {
+	return value{isBool: true, set: func(s string) error {
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		*p = v
+		return nil
+	}}
+}
+
+func intValue(p *int) value {
+	return value{set: func(s string) error {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		*p = v
+		return nil
+	}}
+}
+
+func durationValue(p *time.Duration) value {
+	return value{set: func(s string) error {
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*p = v
+		return nil
+	}}
+}
+
+func floatValue(p *float64) value {
+	return value{set: func(s string) error {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		*p = v
+		return nil
+	}}
+}
+
+func levelValue(p *slog.Level) value {
+	return value{set: func(s string) error {
+		return p.UnmarshalText([]byte(s))
+	}}
+}
+
+// settings возвращает флаги и переменные окружения всех полей конфигурации
+func (c *Config) settings() []setting {
+	return []setting{
+		{"addr", "LISTEN_ADDR", "HTTP listen address", stringValue(&c.Server.Addr)},
+		{"graphiql", "GRAPHIQL", "Serve the GraphiQL IDE to browsers", boolValue(&c.Server.GraphiQL)},
+		{"request-timeout", "REQUEST_TIMEOUT", "Maximum duration of an HTTP request, 0 means no limit", durationValue(&c.Server.RequestTimeout)},
+		{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "Maximum time to wait for in-flight requests on shutdown", durationValue(&c.Server.ShutdownTimeout)},
+		{"metrics", "METRICS", "Serve Prometheus metrics at /metrics", boolValue(&c.Server.Metrics)},
+
+		{"storage", "STORAGE", "Storage backend: memory, postgres or sqlite", stringValue(&c.Storage.Backend)},
+		{"dsn", "DATABASE_URL", "Postgres connection string", stringValue(&c.Storage.DSN)},
+		{"db", "DB_PATH", "Path to the SQLite database file", stringValue(&c.Storage.Path)},
+		{"migrate", "MIGRATE", "Apply pending Postgres migrations on startup (SQLite is always migrated)", boolValue(&c.Storage.Migrate)},
+		{"query-timeout", "QUERY_TIMEOUT", "Maximum duration of a single storage operation, 0 means no limit", durationValue(&c.Storage.QueryTimeout)},
+		{"connect-timeout", "CONNECT_TIMEOUT", "Maximum time to wait for Postgres to become reachable on startup, 0 means a single attempt", durationValue(&c.Storage.ConnectTimeout)},
+		{"db-max-open-conns", "DB_MAX_OPEN_CONNS", "Maximum number of open Postgres connections, 0 means no limit", intValue(&c.Storage.Pool.MaxOpenConns)},
+		{"db-max-idle-conns", "DB_MAX_IDLE_CONNS", "Maximum number of idle Postgres connections", intValue(&c.Storage.Pool.MaxIdleConns)},
+		{"db-conn-max-lifetime", "DB_CONN_MAX_LIFETIME", "Maximum lifetime of a Postgres connection, 0 means no limit", durationValue(&c.Storage.Pool.ConnMaxLifetime)},
+		{"data-dir", "DATA_DIR", "Directory for in-memory storage snapshots and write-ahead log, data is not persisted if empty", stringValue(&c.Storage.Memory.DataDir)},
+		{"fsync", "FSYNC", "Write-ahead log fsync policy: always, interval or never", stringValue(&c.Storage.Memory.FSync)},
+		{"snapshot-every", "SNAPSHOT_EVERY", "Number of logged operations between in-memory storage snapshots", intValue(&c.Storage.Memory.SnapshotEvery)},
+
+		{"jwt-alg", "JWT_ALG", "Access token signing algorithm: HS256 or EdDSA", stringValue(&c.Auth.Algorithm)},
+		{"auth-secret", "AUTH_SECRET", "Secret used to sign HS256 access tokens", stringValue(&c.Auth.Secret)},
+		{"jwt-ed25519-private-key", "JWT_ED25519_PRIVATE_KEY", "Path to a PKCS #8 PEM Ed25519 private key used to sign EdDSA access tokens", stringValue(&c.Auth.PrivateKeyPath)},
+		{"jwt-ed25519-public-key", "JWT_ED25519_PUBLIC_KEY", "Path to a PKIX PEM Ed25519 public key used to verify EdDSA access tokens", stringValue(&c.Auth.PublicKeyPath)},
+		{"jwt-issuer", "JWT_ISSUER", "Expected access token issuer (iss claim), not checked if empty", stringValue(&c.Auth.Issuer)},
+		{"jwt-ttl", "JWT_TTL", "Access token lifetime", durationValue(&c.Auth.TokenTTL)},
+		{"admin-username", "ADMIN_USERNAME", "Name of the admin user created on startup if missing", stringValue(&c.Auth.AdminUsername)},
+		{"admin-password", "ADMIN_PASSWORD", "Password of the admin user", stringValue(&c.Auth.AdminPassword)},
+
+		{"max-comment-length", "MAX_COMMENT_LENGTH", "Maximum comment length in characters", intValue(&c.Content.MaxCommentLength)},
+		{"max-post-title-length", "MAX_POST_TITLE_LENGTH", "Maximum post title length in characters, 0 means no limit", intValue(&c.Content.MaxPostTitleLength)},
+		{"max-post-length", "MAX_POST_LENGTH", "Maximum post content length in characters, 0 means no limit", intValue(&c.Content.MaxPostLength)},
+		{"soft-delete-comments", "SOFT_DELETE_COMMENTS", "Replace deleted comments with tombstones and keep their replies", boolValue(&c.Content.SoftDeleteComments)},
+
+		{"log-level", "LOG_LEVEL", "Minimum log level: debug, info, warn or error", levelValue(&c.Log.Level)},
+
+		{"tracing-exporter", "TRACING_EXPORTER", "Trace exporter: none, stdout or otlp", stringValue(&c.Tracing.Exporter)},
+		{"tracing-endpoint", "TRACING_ENDPOINT", "URL of the OTLP/HTTP collector", stringValue(&c.Tracing.Endpoint)},
+		{"tracing-service-name", "TRACING_SERVICE_NAME", "Service name reported in traces", stringValue(&c.Tracing.ServiceName)},
+		{"tracing-sample-ratio", "TRACING_SAMPLE_RATIO", "Fraction of traces started by the server that are recorded, from 0 to 1", floatValue(&c.Tracing.SampleRatio)},
+	}
+}
+
+// Load собирает конфигурацию из значений по умолчанию, файла конфигурации, переменных окружения
+// и флагов командной строки, в порядке возрастания приоритета, и проверяет результат.
+// Флаги регистрируются в flags, поэтому вызывающий код может добавить в него собственные флаги
+// и после загрузки получить оставшиеся аргументы через flags.Args. Переменные окружения читаются функцией getenv.
+func Load(flags *flag.FlagSet, args []string, getenv func(string) string) (*Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	path := flags.String("config", getenv(EnvConfig), "Path to a YAML configuration file ("+EnvConfig+")")
+	// Значения флагов применяются после файла и переменных окружения, поэтому при разборе они только запоминаются
+	values := map[string]string{}
+	for _, s := range settings {
+		name := s.flag
+		record := func(v string) error {
+			values[name] = v
+			return nil
+		}
+		usage := s.usage + " (" + s.env + ")"
+		if s.value.isBool {
+			flags.BoolFunc(name, usage, record)
+		} else {
+			flags.Func(name, usage, record)
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", *path, err)
+		}
+	}
+
+	// Прежний способ подключения к PostgreSQL по отдельным переменным окружения, DATABASE_URL имеет приоритет
+	if dsn := legacyPostgresDSN(getenv); dsn != "" {
+		cfg.Storage.DSN = dsn
+	}
+	for _, s := range settings {
+		if v := getenv(s.env); v != "" {
+			if err := s.value.set(v); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", s.env, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if v, ok := values[s.flag]; ok {
+			if err := s.value.set(v); err != nil {
+				return nil, fmt.Errorf("invalid -%s: %w", s.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// loadFile загружает значения из файла YAML поверх текущих. Неизвестные ключи считаются ошибкой,
+// чтобы опечатка в имени настройки не оставалась незамеченной.
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// legacyPostgresDSN собирает строку подключения к PostgreSQL из переменных POSTGRES_HOST, POSTGRES_PORT,
+// POSTGRES_USER, POSTGRES_PASSWORD, POSTGRES_DB и POSTGRES_SSLMODE. Если POSTGRES_HOST не задан, возвращает пустую строку.
+func legacyPostgresDSN(getenv func(string) string) string {
+	host := getenv("POSTGRES_HOST")
+	if host == "" {
+		return ""
+	}
+	port := getenv("POSTGRES_PORT")
+	if port == "" {
+		port = "5432"
+	}
+	sslMode := getenv("POSTGRES_SSLMODE")
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(getenv("POSTGRES_USER"), getenv("POSTGRES_PASSWORD")),
+		Host:     net.JoinHostPort(host, port),
+		Path:     "/" + getenv("POSTGRES_DB"),
+		RawQuery: url.Values{"sslmode": {sslMode}}.Encode(),
+	}
+	return dsn.String()
+}
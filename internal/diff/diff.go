@@ -0,0 +1,122 @@
+package diff
+
+import "strings"
+
+// Op обозначает вид изменения строки
+type Op string
+
+// Виды изменений строк
+const (
+	Equal  Op = "equal"  // Строка есть в обеих версиях
+	Insert Op = "insert" // Строка добавлена во второй версии
+	Delete Op = "delete" // Строка удалена из первой версии
+)
+
+// Line представляет строку построчного сравнения двух текстов
+type Line struct {
+	Op   Op     // Вид изменения
+	Text string // Текст строки без перевода строки
+}
+
+// Lines сравнивает два текста построчно и возвращает кратчайший список правок,
+// превращающий from в to. Используется алгоритм Майерса, время работы O((N+M)D),
+// где D - количество отличающихся строк.
+func Lines(from, to string) []Line {
+	a, b := splitLines(from), splitLines(to)
+
+	// Общие начало и конец текстов не участвуют в поиске
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	result := make([]Line, 0, len(a)+len(b))
+	for _, text := range a[:prefix] {
+		result = append(result, Line{Op: Equal, Text: text})
+	}
+	result = append(result, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, text := range a[len(a)-suffix:] {
+		result = append(result, Line{Op: Equal, Text: text})
+	}
+	return result
+}
+
+// splitLines разбивает текст на строки. Пустой текст не содержит строк.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// myers находит кратчайший список правок между a и b.
+// На каждом шаге d сохраняется копия фронта v, по которой затем восстанавливается путь.
+func myers(a, b []string) []Line {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*offset+1)
+	trace := [][]int{}
+
+search:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // Шаг вниз: вставка строки из b
+			} else {
+				x = v[offset+k-1] + 1 // Шаг вправо: удаление строки из a
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Восстановление пути от конца к началу
+	result := []Line{}
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			result = append(result, Line{Op: Equal, Text: a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				result = append(result, Line{Op: Insert, Text: b[y]})
+			} else {
+				x--
+				result = append(result, Line{Op: Delete, Text: a[x]})
+			}
+		}
+	}
+
+	// Путь восстанавливался в обратном порядке
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
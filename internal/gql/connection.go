@@ -0,0 +1,121 @@
+package gql
+
+import (
+	"github.com/graphql-go/graphql"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+)
+
+// Connection представляет список с пагинацией в формате Relay Connections
+type Connection struct {
+	Edges      []*Edge  // Элементы страницы вместе с их курсорами
+	PageInfo   PageInfo // Информация о странице
+	TotalCount int      // Общее количество элементов без учёта пагинации
+}
+
+// Edge представляет элемент страницы и непрозрачный курсор, указывающий на него
+type Edge struct {
+	Cursor string
+	Node   interface{}
+}
+
+// PageInfo содержит информацию о текущей странице
+type PageInfo struct {
+	HasNextPage     bool    // Есть ли элементы после последнего на странице
+	HasPreviousPage bool    // Есть ли элементы перед первым на странице
+	StartCursor     *string // Курсор первого элемента страницы
+	EndCursor       *string // Курсор последнего элемента страницы
+}
+
+// newCommentConnection строит Relay-соединение из страницы комментариев
+func newCommentConnection(page *repository.CommentPage, after *repository.Cursor) *Connection {
+	conn := &Connection{
+		Edges:      make([]*Edge, 0, len(page.Comments)),
+		TotalCount: page.TotalCount,
+		PageInfo: PageInfo{
+			HasNextPage:     page.HasNextPage,
+			HasPreviousPage: after != nil,
+		},
+	}
+	for _, comment := range page.Comments {
+		conn.Edges = append(conn.Edges, &Edge{
+			Cursor: repository.EncodeCursor(repository.CursorOf(comment)),
+			Node:   comment,
+		})
+	}
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.StartCursor = &conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+	return conn
+}
+
+// newPostConnection строит Relay-соединение из страницы постов
+func newPostConnection(page *repository.PostPage, query repository.PostsQuery) *Connection {
+	conn := &Connection{
+		Edges:      make([]*Edge, 0, len(page.Posts)),
+		TotalCount: page.TotalCount,
+		PageInfo: PageInfo{
+			HasNextPage:     page.HasNextPage,
+			HasPreviousPage: query.After != nil,
+		},
+	}
+	for _, post := range page.Posts {
+		conn.Edges = append(conn.Edges, &Edge{
+			Cursor: repository.EncodePostCursor(repository.PostCursorOf(post, query.OrderBy)),
+			Node:   post,
+		})
+	}
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.StartCursor = &conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+	return conn
+}
+
+// pageInfoType — GraphQL тип информации о странице
+var pageInfoType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PageInfo",
+	Fields: graphql.Fields{
+		"hasNextPage": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+		},
+		"hasPreviousPage": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+		},
+		"startCursor": &graphql.Field{
+			Type: graphql.String,
+		},
+		"endCursor": &graphql.Field{
+			Type: graphql.String,
+		},
+	},
+})
+
+// newConnectionType создаёт GraphQL типы соединения и ребра для заданного типа элементов
+func newConnectionType(name string, nodeType graphql.Output) *graphql.Object {
+	edgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: name + "Edge",
+		Fields: graphql.Fields{
+			"cursor": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"node": &graphql.Field{
+				Type: graphql.NewNonNull(nodeType),
+			},
+		},
+	})
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: name + "Connection",
+		Fields: graphql.Fields{
+			"edges": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(edgeType))),
+			},
+			"pageInfo": &graphql.Field{
+				Type: graphql.NewNonNull(pageInfoType),
+			},
+			"totalCount": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+			},
+		},
+	})
+}
@@ -0,0 +1,98 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/nemopss/go-posts-comments-system/internal/auth"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+)
+
+// Коды ошибок, передаваемые клиенту в поле extensions.code
+const (
+	CodeNotFound         = "NOT_FOUND"                 // Объект не найден
+	CodeValidation       = "VALIDATION_FAILED"         // Недопустимые входные данные, поле указано в extensions.field
+	CodeCommentsDisabled = "COMMENTS_DISABLED"         // Комментарии к посту отключены
+	CodeConflict         = "CONFLICT"                  // Операция противоречит текущему состоянию данных
+	CodeForbidden        = "FORBIDDEN"                 // Недостаточно прав
+	CodeUnauthenticated  = "UNAUTHENTICATED"           // Требуется вход или неверные учётные данные
+	CodeTimeout          = "TIMEOUT"                   // Истёк срок запроса или операции хранилища
+	CodeInvalidQuery     = "GRAPHQL_VALIDATION_FAILED" // Запрос не разобран или не прошёл проверку по схеме
+	CodeInternal         = "INTERNAL_SERVER_ERROR"     // Непредвиденная ошибка, подробности только в журнале сервера
+)
+
+// internalErrorMessage заменяет текст непредвиденных ошибок, чтобы не раскрывать клиенту детали хранилища
+const internalErrorMessage = "internal server error"
+
+// FormatError преобразует ошибку выполнения GraphQL запроса в ответ с кодом в extensions.code.
+// Ошибки резолверов классифицируются по категориям ошибок пакета repository.
+func FormatError(err error) gqlerrors.FormattedError {
+	// graphql-go возвращает ошибку контекста вместо результата, если срок запроса истёк во время выполнения
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = repository.ErrTimeout
+	}
+	formatted := gqlerrors.FormatError(err)
+
+	cause := err
+	if located, ok := err.(*gqlerrors.Error); ok {
+		if located.OriginalError == nil {
+			// Ошибки разбора запроса и проверки по схеме не связаны с резолверами
+			formatted.Extensions = map[string]interface{}{"code": CodeInvalidQuery}
+			return formatted
+		}
+		cause = located.OriginalError
+	}
+
+	extensions := map[string]interface{}{"code": errorCode(cause)}
+	var notFound *repository.NotFoundError
+	var invalid *repository.ValidationError
+	switch {
+	case errors.As(cause, &notFound):
+		extensions["entity"] = notFound.Entity
+	case errors.As(cause, &invalid):
+		extensions["field"] = invalid.Field
+	case errors.Is(cause, auth.ErrPasswordTooShort):
+		extensions["field"] = "password"
+	}
+	if extensions["code"] == CodeInternal {
+		log.Println("Internal error:", cause)
+		formatted.Message = internalErrorMessage
+	}
+	formatted.Extensions = extensions
+	return formatted
+}
+
+// FormatErrors преобразует ошибки результата GraphQL запроса с помощью FormatError
+func FormatErrors(errs []gqlerrors.FormattedError) []gqlerrors.FormattedError {
+	formatted := make([]gqlerrors.FormattedError, len(errs))
+	for i, err := range errs {
+		formatted[i] = err
+		if original := err.OriginalError(); original != nil {
+			formatted[i] = FormatError(original)
+		}
+	}
+	return formatted
+}
+
+// errorCode возвращает код ошибки резолвера
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return CodeNotFound
+	case errors.Is(err, repository.ErrValidation), errors.Is(err, auth.ErrPasswordTooShort):
+		return CodeValidation
+	case errors.Is(err, repository.ErrCommentsDisabled):
+		return CodeCommentsDisabled
+	case errors.Is(err, repository.ErrConflict):
+		return CodeConflict
+	case errors.Is(err, repository.ErrForbidden):
+		return CodeForbidden
+	case errors.Is(err, ErrUnauthenticated), errors.Is(err, auth.ErrInvalidCredentials):
+		return CodeUnauthenticated
+	case errors.Is(err, repository.ErrTimeout):
+		return CodeTimeout
+	}
+	return CodeInternal
+}
@@ -0,0 +1,202 @@
+package gql
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName - имя трассировщика GraphQL операций и резолверов
+const tracerName = "github.com/nemopss/go-posts-comments-system/internal/gql"
+
+// Instrumentation получает сведения о выполнении GraphQL операций и резолверов полей, например для сбора метрик.
+// Методы вызываются одновременно из разных запросов.
+type Instrumentation interface {
+	// OperationDone вызывается после выполнения операции вида operationType (query, mutation или subscription)
+	// с именем operationName, пустым для анонимной операции. Для запроса, который не удалось разобрать
+	// или в котором нет запрошенной операции, вид и имя операции пустые. failed сообщает, что результат содержит ошибки.
+	OperationDone(operationType, operationName string, duration time.Duration, failed bool)
+
+	// ResolverDone вызывается после завершения резолвера поля field типа parentType,
+	// включая отложенное вычисление результата загрузчиком комментариев
+	ResolverDone(parentType, field string, duration time.Duration, err error)
+}
+
+// Operation находит в запросе query операцию с именем operationName или единственную операцию запроса,
+// если имя не задано, и возвращает её вид (query, mutation или subscription) и имя.
+// Если запрос не разбирается или операция не найдена, возвращает пустые строки.
+func Operation(query, operationName string) (operationType, name string) {
+	document, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return "", ""
+	}
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName != "" && (operation.Name == nil || operation.Name.Value != operationName) {
+			continue
+		}
+		if operation.Name != nil {
+			name = operation.Name.Value
+		}
+		return operation.Operation, name
+	}
+	return "", ""
+}
+
+// instrument оборачивает резолверы полей схемы и добавляет расширение, измеряющее операции: операции
+// и резолверы выполняются в span трассировки и, если instrumentation не nil, сообщают ей о завершении.
+// Поля без собственного резолвера и служебные типы интроспекции не измеряются.
+func instrument(schema *graphql.Schema, instrumentation Instrumentation) {
+	for name, t := range schema.TypeMap() {
+		object, ok := t.(*graphql.Object)
+		if !ok || strings.HasPrefix(name, "__") {
+			continue
+		}
+		for fieldName, field := range object.Fields() {
+			if field.Resolve != nil {
+				field.Resolve = instrumentResolver(object.Name(), fieldName, field.Resolve, instrumentation)
+			}
+		}
+	}
+	schema.AddExtensions(&operationExtension{instrumentation: instrumentation})
+}
+
+// instrumentResolver возвращает резолвер, выполняющий resolve в span трассировки, дочернем для span операции,
+// и сообщающий instrumentation о его завершении. Если resolve возвращает отложенное вычисление,
+// резолвер считается завершённым после его выполнения.
+func instrumentResolver(parentType, field string, resolve graphql.FieldResolveFn, instrumentation Instrumentation) graphql.FieldResolveFn {
+	name := parentType + "." + field
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		start := time.Now()
+		ctx, span := otel.Tracer(tracerName).Start(p.Context, name, trace.WithAttributes(
+			attribute.String("graphql.field.parent_type", parentType),
+			attribute.String("graphql.field.name", field),
+		))
+		p.Context = ctx
+		done := func(err error) {
+			endSpan(span, err)
+			if instrumentation != nil {
+				instrumentation.ResolverDone(parentType, field, time.Since(start), err)
+			}
+		}
+
+		result, err := resolve(p)
+		if thunk, ok := result.(func() (interface{}, error)); ok && err == nil {
+			return func() (interface{}, error) {
+				value, err := thunk()
+				done(err)
+				return value, err
+			}, nil
+		}
+		done(err)
+		return result, err
+	}
+}
+
+// endSpan завершает span, отмечая его ошибкой err, если она не nil
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// operationKey - ключ контекста для выполняемой операции
+type operationKey struct{}
+
+// operationState описывает выполняемую операцию
+type operationState struct {
+	start         time.Time
+	operationType string
+	operationName string
+	span          trace.Span
+}
+
+// operationExtension измеряет и трассирует выполнение операций, запущенных через graphql.Do.
+// Подписки graphql-go выполняет без расширений, поэтому для них измеряются только резолверы.
+type operationExtension struct {
+	instrumentation Instrumentation
+}
+
+func (e *operationExtension) Init(ctx context.Context, p *graphql.Params) context.Context {
+	state := &operationState{start: time.Now()}
+	// Имя операции берётся только из разобранного запроса: имя, переданное клиентом отдельно, может быть любым
+	state.operationType, state.operationName = Operation(p.RequestString, p.OperationName)
+	// Span операции называется по её виду и имени, например "query Feed"
+	name := strings.TrimSpace(state.operationType + " " + state.operationName)
+	if name == "" {
+		name = "GraphQL operation"
+	}
+	ctx, state.span = otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(
+		attribute.String("graphql.operation.type", state.operationType),
+		attribute.String("graphql.operation.name", state.operationName),
+	))
+	return context.WithValue(ctx, operationKey{}, state)
+}
+
+func (e *operationExtension) Name() string {
+	return "instrumentation"
+}
+
+// done завершает операцию из контекста ctx с ошибками errs
+func (e *operationExtension) done(ctx context.Context, errs []gqlerrors.FormattedError) {
+	state, ok := ctx.Value(operationKey{}).(*operationState)
+	if !ok {
+		return
+	}
+	if len(errs) > 0 {
+		state.span.SetAttributes(attribute.Int("graphql.errors", len(errs)))
+		state.span.SetStatus(codes.Error, errs[0].Message)
+	}
+	state.span.End()
+	if e.instrumentation != nil {
+		e.instrumentation.OperationDone(state.operationType, state.operationName, time.Since(state.start), len(errs) > 0)
+	}
+}
+
+func (e *operationExtension) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	return ctx, func(err error) {
+		if err != nil {
+			e.done(ctx, gqlerrors.FormatErrors(err))
+		}
+	}
+}
+
+func (e *operationExtension) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	return ctx, func(errs []gqlerrors.FormattedError) {
+		if len(errs) > 0 {
+			e.done(ctx, errs)
+		}
+	}
+}
+
+func (e *operationExtension) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	return ctx, func(result *graphql.Result) {
+		e.done(ctx, result.Errors)
+	}
+}
+
+func (e *operationExtension) ResolveFieldDidStart(ctx context.Context, info *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	return ctx, func(interface{}, error) {}
+}
+
+func (e *operationExtension) HasResult() bool {
+	return false
+}
+
+func (e *operationExtension) GetResult(context.Context) interface{} {
+	return nil
+}
@@ -0,0 +1,133 @@
+package gql
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+)
+
+// CommentLoader собирает запросы страниц комментариев, которые резолверы делают на одном уровне
+// вложенности, и выполняет их одним обращением к хранилищу. Резолверы возвращают отложенные
+// значения (thunk), а graphql-go вычисляет их в ширину: к моменту вычисления первого значения
+// все запросы уровня уже зарегистрированы. Загрузчик создаётся на время одного запроса
+// и кэширует полученные страницы до его завершения.
+type CommentLoader struct {
+	repo    repository.Repository
+	mu      sync.Mutex
+	batches map[batchKey]*commentBatch
+}
+
+// batchKey определяет группу запросов, которые выполняются одним обращением к хранилищу
+type batchKey struct {
+	byParent bool   // Дочерние комментарии, иначе комментарии верхнего уровня поста
+	first    int64  // Размер страницы
+	after    string // Закодированный курсор, пустой для первой страницы
+}
+
+// commentBatch хранит ожидающие и выполненные запросы одной группы
+type commentBatch struct {
+	after     *repository.Cursor
+	requested map[string]bool // Идентификаторы, страницы для которых уже запрошены
+	pending   []string        // Идентификаторы, ещё не отправленные в хранилище
+	pages     map[string]*repository.CommentPage
+	errs      map[string]error
+}
+
+// NewCommentLoader создаёт загрузчик комментариев для одного запроса
+func NewCommentLoader(repo repository.Repository) *CommentLoader {
+	return &CommentLoader{repo: repo, batches: make(map[batchKey]*commentBatch)}
+}
+
+// commentLoaderKey - ключ загрузчика комментариев в контексте
+type commentLoaderKey struct{}
+
+// WithCommentLoader возвращает контекст с загрузчиком комментариев
+func WithCommentLoader(ctx context.Context, loader *CommentLoader) context.Context {
+	return context.WithValue(ctx, commentLoaderKey{}, loader)
+}
+
+// commentLoader возвращает загрузчик из контекста запроса. Если загрузчика нет, например при
+// выполнении подписки, создаётся новый: запросы выполняются по одному и не кэшируются.
+func (r *Resolver) commentLoader(ctx context.Context) *CommentLoader {
+	if loader, ok := ctx.Value(commentLoaderKey{}).(*CommentLoader); ok {
+		return loader
+	}
+	return NewCommentLoader(r.repo)
+}
+
+// PostComments регистрирует запрос страницы комментариев верхнего уровня поста.
+// Страница загружается при первом вызове возвращённой функции.
+func (l *CommentLoader) PostComments(ctx context.Context, postId string, first int64, after *repository.Cursor) func() (*repository.CommentPage, error) {
+	return l.load(ctx, false, postId, first, after)
+}
+
+// Children регистрирует запрос страницы дочерних комментариев.
+// Страница загружается при первом вызове возвращённой функции.
+func (l *CommentLoader) Children(ctx context.Context, parentId string, first int64, after *repository.Cursor) func() (*repository.CommentPage, error) {
+	return l.load(ctx, true, parentId, first, after)
+}
+
+// load добавляет идентификатор в группу запросов и возвращает функцию, возвращающую страницу.
+// Если страница ещё не загружена, функция загружает все ожидающие страницы группы одним обращением к хранилищу.
+func (l *CommentLoader) load(ctx context.Context, byParent bool, id string, first int64, after *repository.Cursor) func() (*repository.CommentPage, error) {
+	key := batchKey{byParent: byParent, first: first}
+	if after != nil {
+		key.after = repository.EncodeCursor(*after)
+	}
+
+	l.mu.Lock()
+	batch, ok := l.batches[key]
+	if !ok {
+		batch = &commentBatch{
+			after:     after,
+			requested: make(map[string]bool),
+			pages:     make(map[string]*repository.CommentPage),
+			errs:      make(map[string]error),
+		}
+		l.batches[key] = batch
+	}
+	if !batch.requested[id] {
+		batch.requested[id] = true
+		batch.pending = append(batch.pending, id)
+	}
+	l.mu.Unlock()
+
+	return func() (*repository.CommentPage, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if err, ok := batch.errs[id]; ok {
+			return nil, err
+		}
+		if page, ok := batch.pages[id]; ok {
+			return page, nil
+		}
+		// Страница ещё не загружена: вместе с ней загружаются все ожидающие страницы группы
+		l.fetch(ctx, key, batch)
+		if err, ok := batch.errs[id]; ok {
+			return nil, err
+		}
+		return batch.pages[id], nil
+	}
+}
+
+// fetch загружает страницы для всех ожидающих идентификаторов группы
+func (l *CommentLoader) fetch(ctx context.Context, key batchKey, batch *commentBatch) {
+	ids := batch.pending
+	batch.pending = nil
+
+	var pages map[string]*repository.CommentPage
+	var err error
+	if key.byParent {
+		pages, err = l.repo.GetCommentsByParentIDs(ctx, ids, key.first, batch.after)
+	} else {
+		pages, err = l.repo.GetCommentsByPostIDs(ctx, ids, key.first, batch.after)
+	}
+	for _, id := range ids {
+		if err != nil {
+			batch.errs[id] = err
+			continue
+		}
+		batch.pages[id] = pages[id]
+	}
+}
@@ -1,94 +1,799 @@
 package gql
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"regexp"
+	"time"
+	"unicode/utf8"
 
 	"github.com/graphql-go/graphql"
+	"github.com/nemopss/go-posts-comments-system/internal/auth"
+	"github.com/nemopss/go-posts-comments-system/internal/diff"
 	"github.com/nemopss/go-posts-comments-system/internal/models"
+	"github.com/nemopss/go-posts-comments-system/internal/pubsub"
 	"github.com/nemopss/go-posts-comments-system/internal/repository"
 )
 
+// Options задаёт настраиваемое поведение GraphQL API
+type Options struct {
+	// SoftDeleteComments включает мягкое удаление: deleteComment заменяет комментарий надгробием
+	// и сохраняет ответы на него. Без этого флага комментарий удаляется вместе со всеми ответами.
+	SoftDeleteComments bool
+	// MaxCommentLength ограничивает длину комментария в символах Unicode. 0 - действует только ограничение хранилища
+	MaxCommentLength int
+	// MaxPostTitleLength ограничивает длину заголовка поста в символах Unicode. 0 - без ограничения
+	MaxPostTitleLength int
+	// MaxPostLength ограничивает длину текста поста в символах Unicode. 0 - без ограничения
+	MaxPostLength int
+	// Instrumentation получает длительность операций и резолверов полей, например для метрик. nil - не передаётся.
+	// Span трассировки операций и резолверов создаются независимо от этой настройки.
+	Instrumentation Instrumentation
+	// AdminUsername - имя пользователя, который получает роль администратора независимо от роли в хранилище
+	// (пользователь создаётся при запуске функцией EnsureAdmin). Пустое - роли берутся только из хранилища.
+	AdminUsername string
+}
+
 // Resolver отвечает за реализацию функций, которые будут вызываться.
 // для разрешения запросов и мутаций GraphQL.
 type Resolver struct {
-	repo repository.Repository
+	repo    repository.Repository
+	hub     *pubsub.Hub  // Шина событий для подписок
+	tokens  *auth.Tokens // Выпуск токенов доступа при регистрации и входе
+	options Options
 }
 
-// NewResolver создаёт новый экземпляр Resolver с заданным репозиторием.
-func NewResolver(repo repository.Repository) *Resolver {
+// NewResolver создаёт новый экземпляр Resolver с заданным репозиторием, шиной событий, выпуском токенов и настройками.
+func NewResolver(repo repository.Repository, hub *pubsub.Hub, tokens *auth.Tokens, options Options) *Resolver {
 	log.Println("Creating resolver...")
-	return &Resolver{repo: repo}
+	return &Resolver{repo: repo, hub: hub, tokens: tokens, options: options}
+}
+
+var (
+	// ErrUnauthenticated возвращается, если операция требует входа в систему
+	ErrUnauthenticated = errors.New("authentication required")
+	// ErrForbidden возвращается, если у пользователя нет прав на операцию
+	ErrForbidden = fmt.Errorf("%w: only the author or an admin can do this", repository.ErrForbidden)
+	// ErrAdminOnly возвращается, если операция доступна только администраторам
+	ErrAdminOnly = fmt.Errorf("%w: only an admin can do this", repository.ErrForbidden)
+)
+
+// usernamePattern описывает допустимые имена пользователей
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,32}$`)
+
+// checkLength проверяет, что значение аргумента field не длиннее limit символов Unicode. Ограничение 0 не проверяется.
+func checkLength(field, value string, limit int) error {
+	if limit > 0 && utf8.RuneCountInString(value) > limit {
+		return repository.Invalid(field, fmt.Sprintf("must not exceed %d characters", limit))
+	}
+	return nil
+}
+
+// checkPost проверяет длину заголовка и текста поста
+func (r *Resolver) checkPost(title, content string) error {
+	if err := checkLength("title", title, r.options.MaxPostTitleLength); err != nil {
+		return err
+	}
+	return checkLength("content", content, r.options.MaxPostLength)
+}
+
+// AuthPayload представляет результат регистрации или входа
+type AuthPayload struct {
+	Token string       // Токен доступа для заголовка Authorization
+	User  *models.User // Вошедший пользователь
+}
+
+// viewer возвращает пользователя, выполняющего запрос, или ErrUnauthenticated для анонимных запросов
+func viewer(params graphql.ResolveParams) (*auth.Viewer, error) {
+	v, ok := auth.ViewerFromContext(params.Context)
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	return v, nil
+}
+
+// commentAddedTopic возвращает имя топика событий о новых комментариях к посту
+func commentAddedTopic(postId string) string {
+	return "commentAdded:" + postId
 }
 
-// QueryPosts возвращает список всех постов.
-// Этот метод вызывается при запросе поля `posts` в схеме GraphQL.
+// commentDeletedTopic возвращает имя топика событий об удалении комментариев поста
+func commentDeletedTopic(postId string) string {
+	return "commentDeleted:" + postId
+}
+
+// QueryPosts возвращает страницу постов с учётом фильтра и порядка сортировки.
+// Этот метод вызывается при запросе поля `posts` с аргументами `first`, `after`, `orderBy`, `filter` в схеме GraphQL.
 func (r *Resolver) QueryPosts(params graphql.ResolveParams) (interface{}, error) {
-	return r.repo.GetPosts()
+	first, _ := params.Args["first"].(int)
+	if first < 0 {
+		return nil, repository.Invalid("first", "must not be negative")
+	}
+	query := repository.PostsQuery{
+		First:   int64(first),
+		OrderBy: repository.PostOrderCreatedAt,
+	}
+	if orderBy, ok := params.Args["orderBy"].(string); ok {
+		query.OrderBy = repository.PostOrder(orderBy)
+	}
+	if after, _ := params.Args["after"].(string); after != "" {
+		cursor, err := repository.DecodePostCursor(after, query.OrderBy)
+		if err != nil {
+			return nil, err
+		}
+		query.After = cursor
+	}
+	if filter, ok := params.Args["filter"].(map[string]interface{}); ok {
+		if commentsDisabled, ok := filter["commentsDisabled"].(bool); ok {
+			query.Filter.CommentsDisabled = &commentsDisabled
+		}
+		if createdAfter, ok := filter["createdAfter"].(time.Time); ok {
+			query.Filter.CreatedAfter = &createdAfter
+		}
+		if createdBefore, ok := filter["createdBefore"].(time.Time); ok {
+			query.Filter.CreatedBefore = &createdBefore
+		}
+		if titleContains, ok := filter["titleContains"].(string); ok {
+			query.Filter.TitleContains = &titleContains
+		}
+	}
+
+	page, err := r.repo.ListPosts(params.Context, query)
+	if err != nil {
+		return nil, err
+	}
+	return newPostConnection(page, query), nil
+}
+
+// QuerySearch выполняет полнотекстовый поиск по постам и комментариям.
+// Этот метод вызывается при запросе поля `search` с аргументами `query`, `first`, `after`, `in` в схеме GraphQL.
+func (r *Resolver) QuerySearch(params graphql.ResolveParams) (interface{}, error) {
+	first, _ := params.Args["first"].(int)
+	if first < 0 {
+		return nil, repository.Invalid("first", "must not be negative")
+	}
+	query := repository.SearchQuery{
+		Query: params.Args["query"].(string),
+		First: int64(first),
+	}
+	if after, _ := params.Args["after"].(string); after != "" {
+		cursor, err := repository.DecodeSearchCursor(after)
+		if err != nil {
+			return nil, err
+		}
+		query.After = cursor
+	}
+	if in, ok := params.Args["in"].([]interface{}); ok {
+		// Явно переданный пустой список не содержит объектов для поиска
+		if len(in) == 0 {
+			return newSearchConnection(&repository.SearchPage{}, query.After), nil
+		}
+		for _, kind := range in {
+			query.Kinds = append(query.Kinds, repository.TargetKind(kind.(string)))
+		}
+	}
+
+	page, err := r.repo.Search(params.Context, query)
+	if err != nil {
+		return nil, err
+	}
+	return newSearchConnection(page, query.After), nil
+}
+
+// QueryCommentThread возвращает всё дерево комментариев поста одним списком в прямом порядке обхода.
+// Этот метод вызывается при запросе поля `commentThread` с аргументами `postId`, `maxDepth`, `first` в схеме GraphQL.
+// Без `maxDepth` и `first` глубина и количество комментариев не ограничиваются.
+func (r *Resolver) QueryCommentThread(params graphql.ResolveParams) (interface{}, error) {
+	query := repository.ThreadQuery{
+		PostID:   params.Args["postId"].(string),
+		MaxDepth: repository.NoLimit,
+		First:    repository.NoLimit,
+	}
+	if maxDepth, ok := params.Args["maxDepth"].(int); ok {
+		if maxDepth < 0 {
+			return nil, repository.Invalid("maxDepth", "must not be negative")
+		}
+		query.MaxDepth = maxDepth
+	}
+	if first, ok := params.Args["first"].(int); ok {
+		if first < 0 {
+			return nil, repository.Invalid("first", "must not be negative")
+		}
+		query.First = int64(first)
+	}
+	return r.repo.GetCommentThread(params.Context, query)
 }
 
 // QueryPost возвращает пост по его идентификатору.
 // Этот метод вызывается при запросе поля `post` с идентификатором `id` в схеме GraphQL.
 func (r *Resolver) QueryPost(params graphql.ResolveParams) (interface{}, error) {
 	id := params.Args["id"].(string)
-	return r.repo.GetPost(id)
+	return r.repo.GetPost(params.Context, id)
 }
 
 // Create post создаёт новый пост.
 // Этот метод вызывается при выполнении мутации `createPost` в схеме GraphQl c аргументами `title`, `content`, `commentsDisabled`.
 func (r *Resolver) CreatePost(params graphql.ResolveParams) (interface{}, error) {
+	v, err := viewer(params)
+	if err != nil {
+		return nil, err
+	}
 	title := params.Args["title"].(string)
 	content := params.Args["content"].(string)
 	commentsDisabled := params.Args["commentsDisabled"].(bool)
-	return r.repo.CreatePost(title, content, commentsDisabled)
+	if err := r.checkPost(title, content); err != nil {
+		return nil, err
+	}
+	return r.repo.CreatePost(params.Context, v.UserID, title, content, commentsDisabled)
 }
 
 // CreateComment создаёт новый комментарий.
 // Этот метод вызывается при выполнении мутации `createComment` в схеме GraphQL с аргументами `postId`, `parentId`, `content`
 func (r *Resolver) CreateComment(params graphql.ResolveParams) (interface{}, error) {
+	v, err := viewer(params)
+	if err != nil {
+		return nil, err
+	}
 	postId := params.Args["postId"].(string)
-	parentId := params.Args["parentId"].(string)
+	parentId, _ := params.Args["parentId"].(string) // Без parentId создаётся комментарий верхнего уровня
 	content := params.Args["content"].(string)
-	return r.repo.CreateComment(postId, parentId, content)
+	if err := checkLength("content", content, r.options.MaxCommentLength); err != nil {
+		return nil, err
+	}
+	comment, err := r.repo.CreateComment(params.Context, v.UserID, postId, parentId, content)
+	if err != nil {
+		return nil, err
+	}
+	r.hub.Publish(commentAddedTopic(postId), comment)
+	return comment, nil
+}
+
+// Register регистрирует нового пользователя.
+// Этот метод вызывается при выполнении мутации `register` в схеме GraphQL с аргументами `username`, `password`.
+func (r *Resolver) Register(params graphql.ResolveParams) (interface{}, error) {
+	username := params.Args["username"].(string)
+	password := params.Args["password"].(string)
+	if !usernamePattern.MatchString(username) {
+		return nil, repository.Invalid("username", "must be 3-32 characters long and contain only letters, digits, '_', '.' or '-'")
+	}
+	passwordHash, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user, err := r.repo.CreateUser(params.Context, username, passwordHash)
+	if err != nil {
+		return nil, err
+	}
+	return r.authPayload(user)
+}
+
+// Login выполняет вход пользователя по имени и паролю.
+// Этот метод вызывается при выполнении мутации `login` в схеме GraphQL с аргументами `username`, `password`.
+func (r *Resolver) Login(params graphql.ResolveParams) (interface{}, error) {
+	username := params.Args["username"].(string)
+	password := params.Args["password"].(string)
+	user, err := r.repo.GetUserByUsername(params.Context, username)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Пароль проверяется и для несуществующего пользователя, чтобы по времени ответа нельзя было узнать имена
+		return nil, auth.RejectPassword(password)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := auth.CheckPassword(user.PasswordHash, password); err != nil {
+		return nil, err
+	}
+	return r.authPayload(user)
+}
+
+// EnsureAdmin создаёт пользователя username с паролем password, если его ещё нет, чтобы назначить его
+// администратором через Options.AdminUsername. Если пользователь уже есть, его пароль должен совпадать с password:
+// иначе имя мог занять кто-то другой, и передавать ему роль администратора нельзя.
+func EnsureAdmin(ctx context.Context, repo repository.Repository, username, password string) error {
+	user, err := repo.GetUserByUsername(ctx, username)
+	if errors.Is(err, repository.ErrNotFound) {
+		if !usernamePattern.MatchString(username) {
+			return fmt.Errorf("invalid admin username %q", username)
+		}
+		passwordHash, err := auth.HashPassword(password)
+		if err != nil {
+			return err
+		}
+		log.Println("Creating admin user", username)
+		_, err = repo.CreateUser(ctx, username, passwordHash)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if err := auth.CheckPassword(user.PasswordHash, password); err != nil {
+		return fmt.Errorf("user %s already exists with a different password", username)
+	}
+	return nil
 }
 
-// ResolvePostComments возвращает список комментариев для заданного поста с пагинацией
+// withRole возвращает пользователя с ролью администратора, если он назначен администратором в настройках
+func (r *Resolver) withRole(user *models.User) *models.User {
+	if r.options.AdminUsername == "" || user.Username != r.options.AdminUsername || user.Role == models.RoleAdmin {
+		return user
+	}
+	admin := *user
+	admin.Role = models.RoleAdmin
+	return &admin
+}
+
+// ResolveUserRole возвращает роль пользователя с учётом администратора из настроек
+func (r *Resolver) ResolveUserRole(p graphql.ResolveParams) (interface{}, error) {
+	return r.withRole(p.Source.(*models.User)).Role, nil
+}
+
+// authPayload выпускает токен доступа для пользователя
+func (r *Resolver) authPayload(user *models.User) (*AuthPayload, error) {
+	user = r.withRole(user)
+	token, err := r.tokens.Issue(user)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthPayload{Token: token, User: user}, nil
+}
+
+// QueryMe возвращает пользователя, выполняющего запрос, или null для анонимных запросов.
+// Этот метод вызывается при запросе поля `me` в схеме GraphQL.
+func (r *Resolver) QueryMe(params graphql.ResolveParams) (interface{}, error) {
+	v, ok := auth.ViewerFromContext(params.Context)
+	if !ok {
+		return nil, nil
+	}
+	return r.repo.GetUser(params.Context, v.UserID)
+}
+
+// ResolvePostAuthor возвращает автора поста
+func (r *Resolver) ResolvePostAuthor(p graphql.ResolveParams) (interface{}, error) {
+	post := p.Source.(*models.Post)
+	return r.repo.GetUser(p.Context, post.AuthorID)
+}
+
+// ResolveCommentAuthor возвращает автора комментария
+func (r *Resolver) ResolveCommentAuthor(p graphql.ResolveParams) (interface{}, error) {
+	comment := p.Source.(*models.Comment)
+	return r.repo.GetUser(p.Context, comment.AuthorID)
+}
+
+// ResolvePostComments возвращает страницу комментариев верхнего уровня для заданного поста.
+// Страница загружается отложенно, вместе со страницами других постов того же уровня запроса.
 func (r *Resolver) ResolvePostComments(p graphql.ResolveParams) (interface{}, error) {
 	post := p.Source.(*models.Post)
-	first, _ := p.Args["first"].(int)
-	after, _ := p.Args["after"].(string)
-	if after == "" {
-		return r.repo.GetCommentsByPostID(post.ID, int64(first), nil)
+	first, after, err := pageArgs(p)
+	if err != nil {
+		return nil, err
 	}
-	return r.repo.GetCommentsByPostID(post.ID, int64(first), &after)
+	load := r.commentLoader(p.Context).PostComments(p.Context, post.ID, first, after)
+	return func() (interface{}, error) {
+		page, err := load()
+		if err != nil {
+			return nil, err
+		}
+		return newCommentConnection(page, after), nil
+	}, nil
 }
 
-// ResolveCommentChildren возвращает список дочерних комментариев для заданного комментария с пагинацией
+// ResolveCommentChildren возвращает страницу дочерних комментариев для заданного комментария.
+// Страница загружается отложенно, вместе со страницами других комментариев того же уровня запроса.
 func (r *Resolver) ResolveCommentChildren(p graphql.ResolveParams) (interface{}, error) {
 	comment := p.Source.(*models.Comment)
+	first, after, err := pageArgs(p)
+	if err != nil {
+		return nil, err
+	}
+	load := r.commentLoader(p.Context).Children(p.Context, comment.ID, first, after)
+	return func() (interface{}, error) {
+		page, err := load()
+		if err != nil {
+			return nil, err
+		}
+		return newCommentConnection(page, after), nil
+	}, nil
+}
+
+// ResolveCommentAncestors возвращает цепочку предков комментария от комментария верхнего уровня до родителя
+func (r *Resolver) ResolveCommentAncestors(p graphql.ResolveParams) (interface{}, error) {
+	comment := p.Source.(*models.Comment)
+	return r.repo.GetCommentAncestors(p.Context, comment.ID)
+}
+
+// pageArgs разбирает аргументы пагинации `first` и `after`.
+// Пустая строка в `after` означает запрос первой страницы.
+func pageArgs(p graphql.ResolveParams) (int64, *repository.Cursor, error) {
 	first, _ := p.Args["first"].(int)
+	if first < 0 {
+		return 0, nil, repository.Invalid("first", "must not be negative")
+	}
 	after, _ := p.Args["after"].(string)
 	if after == "" {
-		return r.repo.GetCommentsByParentID(comment.ID, int64(first), nil)
+		return int64(first), nil, nil
+	}
+	cursor, err := repository.DecodeCursor(after)
+	if err != nil {
+		return 0, nil, err
 	}
-	return r.repo.GetCommentsByParentID(comment.ID, int64(first), &after)
+	return int64(first), cursor, nil
 }
 
-// DeletePost удаляет пост по его ID
+// DeletePost удаляет пост по его ID.
+// Удалить пост может только его автор или администратор.
 func (r *Resolver) DeletePost(params graphql.ResolveParams) (interface{}, error) {
+	v, err := viewer(params)
+	if err != nil {
+		return nil, err
+	}
 	id := params.Args["id"].(string)
-	err := r.repo.DeletePost(id)
+	post, err := r.repo.GetPost(params.Context, id)
+	if err != nil {
+		return nil, err
+	}
+	if !v.CanModify(post.AuthorID) {
+		return nil, ErrForbidden
+	}
+	err = r.repo.DeletePost(params.Context, id)
 	if err != nil {
 		return nil, err
 	}
 	return true, nil
 }
 
-// DeleteComment удаляет комментарий по его ID
+// DeleteComment удаляет комментарий по его ID.
+// В режиме мягкого удаления комментарий заменяется надгробием, иначе удаляется вместе с ответами.
+// Удалить комментарий может только его автор или администратор.
 func (r *Resolver) DeleteComment(params graphql.ResolveParams) (interface{}, error) {
+	v, err := viewer(params)
+	if err != nil {
+		return nil, err
+	}
 	id := params.Args["id"].(string)
-	err := r.repo.DeleteComment(id)
+	// Комментарий запрашивается до удаления, чтобы проверить права и знать, подписчикам какого поста отправить событие
+	comment, err := r.repo.GetComment(params.Context, id)
 	if err != nil {
 		return nil, err
 	}
+	if !v.CanModify(comment.AuthorID) {
+		return nil, ErrForbidden
+	}
+	if r.options.SoftDeleteComments {
+		comment, err = r.repo.SoftDeleteComment(params.Context, id)
+	} else {
+		err = r.repo.DeleteComment(params.Context, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.hub.Publish(commentDeletedTopic(comment.PostID), comment)
 	return true, nil
 }
+
+// PurgeComment безвозвратно удаляет комментарий вместе со всеми ответами, в том числе надгробие.
+// Этот метод вызывается при выполнении мутации `purgeComment` в схеме GraphQL с аргументом `id`.
+// Доступен только администраторам.
+func (r *Resolver) PurgeComment(params graphql.ResolveParams) (interface{}, error) {
+	v, err := viewer(params)
+	if err != nil {
+		return nil, err
+	}
+	if !v.IsAdmin() {
+		return nil, ErrAdminOnly
+	}
+	id := params.Args["id"].(string)
+	comment, err := r.repo.GetComment(params.Context, id)
+	if err != nil {
+		return nil, err
+	}
+	err = r.repo.DeleteComment(params.Context, id)
+	if err != nil {
+		return nil, err
+	}
+	r.hub.Publish(commentDeletedTopic(comment.PostID), comment)
+	return true, nil
+}
+
+// ResolveCommentContent возвращает содержимое комментария или null для надгробия
+func (r *Resolver) ResolveCommentContent(p graphql.ResolveParams) (interface{}, error) {
+	comment := p.Source.(*models.Comment)
+	if comment.DeletedAt != nil {
+		return nil, nil
+	}
+	return comment.Content, nil
+}
+
+// ResolveCommentIsDeleted возвращает, заменён ли комментарий надгробием
+func (r *Resolver) ResolveCommentIsDeleted(p graphql.ResolveParams) (interface{}, error) {
+	comment := p.Source.(*models.Comment)
+	return comment.DeletedAt != nil, nil
+}
+
+// UpdatePost изменяет пост.
+// Этот метод вызывается при выполнении мутации `updatePost` в схеме GraphQL с аргументами `id`, `title`, `content`, `commentsDisabled`.
+// Изменить пост может только его автор или администратор.
+func (r *Resolver) UpdatePost(params graphql.ResolveParams) (interface{}, error) {
+	v, err := viewer(params)
+	if err != nil {
+		return nil, err
+	}
+	id := params.Args["id"].(string)
+	if err := r.authorizePost(params.Context, v, id); err != nil {
+		return nil, err
+	}
+	title := params.Args["title"].(string)
+	content := params.Args["content"].(string)
+	commentsDisabled := params.Args["commentsDisabled"].(bool)
+	if err := r.checkPost(title, content); err != nil {
+		return nil, err
+	}
+	return r.repo.UpdatePost(params.Context, v.UserID, id, title, content, commentsDisabled)
+}
+
+// RevertPost возвращает пост к одной из его версий. Возврат сохраняется как новая версия,
+// поэтому история изменений не теряется.
+// Этот метод вызывается при выполнении мутации `revertPost` в схеме GraphQL с аргументами `id`, `revision`.
+func (r *Resolver) RevertPost(params graphql.ResolveParams) (interface{}, error) {
+	v, err := viewer(params)
+	if err != nil {
+		return nil, err
+	}
+	id := params.Args["id"].(string)
+	if err := r.authorizePost(params.Context, v, id); err != nil {
+		return nil, err
+	}
+	revisions, err := r.repo.GetPostRevisions(params.Context, id)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := findPostRevision(revisions, params.Args["revision"].(int))
+	if err != nil {
+		return nil, err
+	}
+	return r.repo.UpdatePost(params.Context, v.UserID, id, revision.Title, revision.Content, revision.CommentsDisabled)
+}
+
+// authorizePost проверяет, что пользователь может изменять пост
+func (r *Resolver) authorizePost(ctx context.Context, v *auth.Viewer, id string) error {
+	post, err := r.repo.GetPost(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !v.CanModify(post.AuthorID) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// UpdateComment изменяет комментарий.
+// Этот метод вызывается при выполнении мутации `updateComment` в схеме GraphQL с аргументами `id`, `content`.
+// Изменить комментарий может только его автор или администратор.
+func (r *Resolver) UpdateComment(params graphql.ResolveParams) (interface{}, error) {
+	v, err := viewer(params)
+	if err != nil {
+		return nil, err
+	}
+	id := params.Args["id"].(string)
+	comment, err := r.repo.GetComment(params.Context, id)
+	if err != nil {
+		return nil, err
+	}
+	if !v.CanModify(comment.AuthorID) {
+		return nil, ErrForbidden
+	}
+	content := params.Args["content"].(string)
+	if err := checkLength("content", content, r.options.MaxCommentLength); err != nil {
+		return nil, err
+	}
+	return r.repo.UpdateComment(params.Context, v.UserID, id, content)
+}
+
+// ResolvePostRevisions возвращает все версии поста
+func (r *Resolver) ResolvePostRevisions(p graphql.ResolveParams) (interface{}, error) {
+	post := p.Source.(*models.Post)
+	return r.repo.GetPostRevisions(p.Context, post.ID)
+}
+
+// ResolveCommentRevisions возвращает все версии комментария
+func (r *Resolver) ResolveCommentRevisions(p graphql.ResolveParams) (interface{}, error) {
+	comment := p.Source.(*models.Comment)
+	return r.repo.GetCommentRevisions(p.Context, comment.ID)
+}
+
+// ResolveRevisionEditor возвращает пользователя, создавшего версию поста или комментария
+func (r *Resolver) ResolveRevisionEditor(p graphql.ResolveParams) (interface{}, error) {
+	switch revision := p.Source.(type) {
+	case *models.PostRevision:
+		return r.repo.GetUser(p.Context, revision.EditorID)
+	case *models.CommentRevision:
+		return r.repo.GetUser(p.Context, revision.EditorID)
+	}
+	return nil, nil
+}
+
+// ResolvePostDiff сравнивает две версии поста с номерами `from` и `to`
+func (r *Resolver) ResolvePostDiff(p graphql.ResolveParams) (interface{}, error) {
+	post := p.Source.(*models.Post)
+	revisions, err := r.repo.GetPostRevisions(p.Context, post.ID)
+	if err != nil {
+		return nil, err
+	}
+	from, err := findPostRevision(revisions, p.Args["from"].(int))
+	if err != nil {
+		return nil, err
+	}
+	to, err := findPostRevision(revisions, p.Args["to"].(int))
+	if err != nil {
+		return nil, err
+	}
+	return &RevisionDiff{
+		From:    from.Revision,
+		To:      to.Revision,
+		Title:   diff.Lines(from.Title, to.Title),
+		Content: diff.Lines(from.Content, to.Content),
+	}, nil
+}
+
+// ResolveCommentDiff сравнивает две версии комментария с номерами `from` и `to`
+func (r *Resolver) ResolveCommentDiff(p graphql.ResolveParams) (interface{}, error) {
+	comment := p.Source.(*models.Comment)
+	revisions, err := r.repo.GetCommentRevisions(p.Context, comment.ID)
+	if err != nil {
+		return nil, err
+	}
+	from, err := findCommentRevision(revisions, p.Args["from"].(int))
+	if err != nil {
+		return nil, err
+	}
+	to, err := findCommentRevision(revisions, p.Args["to"].(int))
+	if err != nil {
+		return nil, err
+	}
+	return &RevisionDiff{
+		From:    from.Revision,
+		To:      to.Revision,
+		Content: diff.Lines(from.Content, to.Content),
+	}, nil
+}
+
+// Vote устанавливает голос пользователя за пост или комментарий.
+// Этот метод вызывается при выполнении мутации `vote` в схеме GraphQL с аргументами `targetId`, `value`.
+func (r *Resolver) Vote(params graphql.ResolveParams) (interface{}, error) {
+	v, err := viewer(params)
+	if err != nil {
+		return nil, err
+	}
+	kind, id, err := r.findTarget(params.Context, params.Args["targetId"].(string))
+	if err != nil {
+		return nil, err
+	}
+	err = r.repo.Vote(params.Context, kind, id, v.UserID, params.Args["value"].(int))
+	if err != nil {
+		return nil, err
+	}
+	return r.getTarget(params.Context, kind, id)
+}
+
+// React добавляет реакцию пользователя на пост или комментарий или снимает её, если она уже есть.
+// Этот метод вызывается при выполнении мутации `react` в схеме GraphQL с аргументами `targetId`, `emoji`.
+func (r *Resolver) React(params graphql.ResolveParams) (interface{}, error) {
+	v, err := viewer(params)
+	if err != nil {
+		return nil, err
+	}
+	emoji := params.Args["emoji"].(string)
+	if !validEmoji(emoji) {
+		return nil, ErrInvalidEmoji
+	}
+	kind, id, err := r.findTarget(params.Context, params.Args["targetId"].(string))
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.repo.React(params.Context, kind, id, v.UserID, emoji)
+	if err != nil {
+		return nil, err
+	}
+	return r.getTarget(params.Context, kind, id)
+}
+
+// findTarget определяет, является ли объект с заданным ID постом или комментарием.
+// Ошибки хранилища, кроме отсутствия объекта, возвращаются как есть.
+func (r *Resolver) findTarget(ctx context.Context, id string) (repository.TargetKind, string, error) {
+	_, err := r.repo.GetPost(ctx, id)
+	if err == nil {
+		return repository.TargetPost, id, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return "", "", err
+	}
+	_, err = r.repo.GetComment(ctx, id)
+	if err == nil {
+		return repository.TargetComment, id, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return "", "", err
+	}
+	return "", "", repository.NotFound(repository.EntityTarget, id)
+}
+
+// getTarget возвращает пост или комментарий с актуальными счётчиками голосов
+func (r *Resolver) getTarget(ctx context.Context, kind repository.TargetKind, id string) (interface{}, error) {
+	if kind == repository.TargetPost {
+		return r.repo.GetPost(ctx, id)
+	}
+	return r.repo.GetComment(ctx, id)
+}
+
+// ResolveScore возвращает рейтинг поста или комментария: разницу голосов за и против
+func (r *Resolver) ResolveScore(p graphql.ResolveParams) (interface{}, error) {
+	upvotes, downvotes := voteCounts(p.Source)
+	return upvotes - downvotes, nil
+}
+
+// ResolveViewerVote возвращает голос пользователя, выполняющего запрос, за пост или комментарий
+func (r *Resolver) ResolveViewerVote(p graphql.ResolveParams) (interface{}, error) {
+	v, ok := auth.ViewerFromContext(p.Context)
+	if !ok {
+		return repository.VoteNone, nil
+	}
+	kind, id := targetOf(p.Source)
+	return r.repo.GetVote(p.Context, kind, id, v.UserID)
+}
+
+// ResolveReactions возвращает сводку реакций на пост или комментарий
+func (r *Resolver) ResolveReactions(p graphql.ResolveParams) (interface{}, error) {
+	viewerId := ""
+	if v, ok := auth.ViewerFromContext(p.Context); ok {
+		viewerId = v.UserID
+	}
+	kind, id := targetOf(p.Source)
+	return r.repo.GetReactions(p.Context, kind, id, viewerId)
+}
+
+// SubscribeCommentAdded подписывает клиента на новые комментарии к посту.
+// Этот метод вызывается при выполнении подписки `commentAdded` с аргументом `postId`.
+func (r *Resolver) SubscribeCommentAdded(params graphql.ResolveParams) (interface{}, error) {
+	postId := params.Args["postId"].(string)
+	return r.subscribe(params.Context, commentAddedTopic(postId)), nil
+}
+
+// SubscribeCommentDeleted подписывает клиента на удаление комментариев поста.
+// Этот метод вызывается при выполнении подписки `commentDeleted` с аргументом `postId`.
+func (r *Resolver) SubscribeCommentDeleted(params graphql.ResolveParams) (interface{}, error) {
+	postId := params.Args["postId"].(string)
+	return r.subscribe(params.Context, commentDeletedTopic(postId)), nil
+}
+
+// ResolveEvent возвращает полезную нагрузку события, пришедшего из подписки
+func (r *Resolver) ResolveEvent(params graphql.ResolveParams) (interface{}, error) {
+	return params.Source, nil
+}
+
+// subscribe подписывается на топик шины событий и пересылает события в канал,
+// который ожидает graphql-go. Подписка снимается, когда контекст запроса завершается.
+func (r *Resolver) subscribe(ctx context.Context, topic string) chan interface{} {
+	events, unsubscribe := r.hub.Subscribe(topic)
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
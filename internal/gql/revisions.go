@@ -0,0 +1,98 @@
+package gql
+
+import (
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"github.com/nemopss/go-posts-comments-system/internal/diff"
+	"github.com/nemopss/go-posts-comments-system/internal/models"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+)
+
+// RevisionDiff представляет построчное сравнение двух версий поста или комментария
+type RevisionDiff struct {
+	From    int         // Номер исходной версии
+	To      int         // Номер конечной версии
+	Title   []diff.Line // Изменения заголовка (только для постов)
+	Content []diff.Line // Изменения содержимого
+}
+
+// findPostRevision возвращает версию поста с заданным номером
+func findPostRevision(revisions []*models.PostRevision, number int) (*models.PostRevision, error) {
+	for _, revision := range revisions {
+		if revision.Revision == number {
+			return revision, nil
+		}
+	}
+	return nil, repository.NotFound(repository.EntityRevision, strconv.Itoa(number))
+}
+
+// findCommentRevision возвращает версию комментария с заданным номером
+func findCommentRevision(revisions []*models.CommentRevision, number int) (*models.CommentRevision, error) {
+	for _, revision := range revisions {
+		if revision.Revision == number {
+			return revision, nil
+		}
+	}
+	return nil, repository.NotFound(repository.EntityRevision, strconv.Itoa(number))
+}
+
+// diffLineType описывает строку сравнения версий
+var diffLineType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "DiffLine",
+	Fields: graphql.Fields{
+		"op": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewEnum(graphql.EnumConfig{
+				Name: "DiffOp",
+				Values: graphql.EnumValueConfigMap{
+					"EQUAL": &graphql.EnumValueConfig{
+						Value:       diff.Equal,
+						Description: "Строка есть в обеих версиях",
+					},
+					"INSERT": &graphql.EnumValueConfig{
+						Value:       diff.Insert,
+						Description: "Строка добавлена",
+					},
+					"DELETE": &graphql.EnumValueConfig{
+						Value:       diff.Delete,
+						Description: "Строка удалена",
+					},
+				},
+			})),
+		},
+		"text": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+	},
+})
+
+// diffArgs описывает аргументы поля сравнения версий
+var diffArgs = graphql.FieldConfigArgument{
+	"from": &graphql.ArgumentConfig{
+		Type: graphql.NewNonNull(graphql.Int),
+	},
+	"to": &graphql.ArgumentConfig{
+		Type: graphql.NewNonNull(graphql.Int),
+	},
+}
+
+// newDiffType создаёт тип сравнения версий с заданными полями текста
+func newDiffType(name string, textFields ...string) *graphql.Object {
+	fields := graphql.Fields{
+		"from": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Int),
+		},
+		"to": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Int),
+		},
+	}
+	for _, field := range textFields {
+		fields[field] = &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(diffLineType))),
+		}
+	}
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name:   name,
+		Fields: fields,
+	})
+}
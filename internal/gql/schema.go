@@ -2,21 +2,125 @@ package gql
 
 import (
 	"github.com/graphql-go/graphql"
+	"github.com/nemopss/go-posts-comments-system/internal/auth"
+	"github.com/nemopss/go-posts-comments-system/internal/models"
+	"github.com/nemopss/go-posts-comments-system/internal/pubsub"
 	"github.com/nemopss/go-posts-comments-system/internal/repository"
 )
 
-// NewSchema создаёт новую GraphQl схему, используя переданный репозиторий
-func NewSchema(repo repository.Repository) (graphql.Schema, error) {
+// NewSchema создаёт новую GraphQl схему, используя переданный репозиторий, шину событий для подписок,
+// выпуск токенов доступа и настройки API
+func NewSchema(repo repository.Repository, hub *pubsub.Hub, tokens *auth.Tokens, options Options) (graphql.Schema, error) {
 	// Создаём новый resolver
-	resolver := NewResolver(repo)
+	resolver := NewResolver(repo, hub, tokens, options)
 
-	var commentType *graphql.Object
+	// Определение типа user для GraphQL схемы
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+			},
+			"username": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"role": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewEnum(graphql.EnumConfig{
+					Name: "Role",
+					Values: graphql.EnumValueConfigMap{
+						"USER": &graphql.EnumValueConfig{
+							Value: models.RoleUser,
+						},
+						"ADMIN": &graphql.EnumValueConfig{
+							Value: models.RoleAdmin,
+						},
+					},
+				})),
+				Resolve: resolver.ResolveUserRole,
+			},
+			"createdAt": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.DateTime),
+			},
+		},
+	})
+
+	// Определение типа результата регистрации и входа
+	authPayloadType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "AuthPayload",
+		Fields: graphql.Fields{
+			"token": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"user": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+			},
+		},
+	})
+
+	// Определение типов версий поста и комментария
+	postRevisionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PostRevision",
+		Fields: graphql.Fields{
+			"revision": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+			},
+			"title": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"content": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"commentsDisabled": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+			},
+			"editor": &graphql.Field{
+				Type:    graphql.NewNonNull(userType),
+				Resolve: resolver.ResolveRevisionEditor,
+			},
+			"createdAt": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.DateTime),
+			},
+		},
+	})
+	commentRevisionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "CommentRevision",
+		Fields: graphql.Fields{
+			"revision": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+			},
+			"content": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"editor": &graphql.Field{
+				Type:    graphql.NewNonNull(userType),
+				Resolve: resolver.ResolveRevisionEditor,
+			},
+			"createdAt": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.DateTime),
+			},
+		},
+	})
+
+	var postType, commentType, commentConnectionType *graphql.Object
+
+	// Определение интерфейса объектов, за которые можно голосовать и на которые можно реагировать
+	votableType := graphql.NewInterface(graphql.InterfaceConfig{
+		Name:   "Votable",
+		Fields: votableFields(resolver),
+		ResolveType: func(p graphql.ResolveTypeParams) *graphql.Object {
+			if _, ok := p.Value.(*models.Post); ok {
+				return postType
+			}
+			return commentType
+		},
+	})
 
 	// Определение типа comment для GraphQL схемы
 	commentType = graphql.NewObject(graphql.ObjectConfig{
-		Name: "Comment",
+		Name:       "Comment",
+		Interfaces: []*graphql.Interface{votableType},
 		Fields: graphql.FieldsThunk(func() graphql.Fields {
-			return graphql.Fields{
+			return withVotableFields(resolver, graphql.Fields{
 				"id": &graphql.Field{
 					Type: graphql.NewNonNull(graphql.ID),
 				},
@@ -26,14 +130,42 @@ func NewSchema(repo repository.Repository) (graphql.Schema, error) {
 				"parentId": &graphql.Field{
 					Type: graphql.ID,
 				},
+				"author": &graphql.Field{
+					Type:    graphql.NewNonNull(userType),
+					Resolve: resolver.ResolveCommentAuthor,
+				},
 				"content": &graphql.Field{
-					Type: graphql.NewNonNull(graphql.String),
+					Type:    graphql.String,
+					Resolve: resolver.ResolveCommentContent,
 				},
 				"createdAt": &graphql.Field{
 					Type: graphql.NewNonNull(graphql.DateTime),
 				},
+				"editedAt": &graphql.Field{
+					Type: graphql.DateTime,
+				},
+				"isDeleted": &graphql.Field{
+					Type:    graphql.NewNonNull(graphql.Boolean),
+					Resolve: resolver.ResolveCommentIsDeleted,
+				},
+				"deletedAt": &graphql.Field{
+					Type: graphql.DateTime,
+				},
+				"revisions": &graphql.Field{
+					Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(commentRevisionType))),
+					Resolve: resolver.ResolveCommentRevisions,
+				},
+				"diff": &graphql.Field{
+					Type:    graphql.NewNonNull(newDiffType("CommentDiff", "content")),
+					Args:    diffArgs,
+					Resolve: resolver.ResolveCommentDiff,
+				},
+				"ancestors": &graphql.Field{
+					Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(commentType))),
+					Resolve: resolver.ResolveCommentAncestors,
+				},
 				"children": &graphql.Field{
-					Type: graphql.NewList(commentType),
+					Type: graphql.NewNonNull(commentConnectionType),
 					Args: graphql.FieldConfigArgument{
 						"first": &graphql.ArgumentConfig{
 							Type: graphql.NewNonNull(graphql.Int),
@@ -45,25 +177,33 @@ func NewSchema(repo repository.Repository) (graphql.Schema, error) {
 
 					Resolve: resolver.ResolveCommentChildren,
 				},
-			}
+			})
 		}),
 	})
 
+	// Определение типа соединения комментариев для пагинации в формате Relay
+	commentConnectionType = newConnectionType("Comment", commentType)
+
 	// Определение типа post для GraphQL схемы
-	postType := graphql.NewObject(graphql.ObjectConfig{
-		Name: "Post",
-		Fields: graphql.Fields{
+	postType = graphql.NewObject(graphql.ObjectConfig{
+		Name:       "Post",
+		Interfaces: []*graphql.Interface{votableType},
+		Fields: withVotableFields(resolver, graphql.Fields{
 			"id": &graphql.Field{
 				Type: graphql.NewNonNull(graphql.ID),
 			},
 			"title": &graphql.Field{
 				Type: graphql.NewNonNull(graphql.String),
 			},
+			"author": &graphql.Field{
+				Type:    graphql.NewNonNull(userType),
+				Resolve: resolver.ResolvePostAuthor,
+			},
 			"content": &graphql.Field{
 				Type: graphql.NewNonNull(graphql.String),
 			},
 			"comments": &graphql.Field{
-				Type: graphql.NewList(commentType),
+				Type: graphql.NewNonNull(commentConnectionType),
 				Args: graphql.FieldConfigArgument{
 					"first": &graphql.ArgumentConfig{
 						Type: graphql.NewNonNull(graphql.Int),
@@ -81,6 +221,65 @@ func NewSchema(repo repository.Repository) (graphql.Schema, error) {
 			"commentsDisabled": &graphql.Field{
 				Type: graphql.NewNonNull(graphql.Boolean),
 			},
+			"commentCount": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+			},
+			"lastActivityAt": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.DateTime),
+			},
+			"editedAt": &graphql.Field{
+				Type: graphql.DateTime,
+			},
+			"revisions": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(postRevisionType))),
+				Resolve: resolver.ResolvePostRevisions,
+			},
+			"diff": &graphql.Field{
+				Type:    graphql.NewNonNull(newDiffType("PostDiff", "title", "content")),
+				Args:    diffArgs,
+				Resolve: resolver.ResolvePostDiff,
+			},
+		}),
+	})
+
+	// Определение типа соединения постов для пагинации в формате Relay
+	postConnectionType := newConnectionType("Post", postType)
+
+	// Определение порядка сортировки постов
+	postOrderType := graphql.NewEnum(graphql.EnumConfig{
+		Name: "PostOrder",
+		Values: graphql.EnumValueConfigMap{
+			"CREATED_AT": &graphql.EnumValueConfig{
+				Value:       string(repository.PostOrderCreatedAt),
+				Description: "Сначала новые посты",
+			},
+			"COMMENT_COUNT": &graphql.EnumValueConfig{
+				Value:       string(repository.PostOrderCommentCount),
+				Description: "Сначала посты с наибольшим количеством комментариев",
+			},
+			"LAST_ACTIVITY": &graphql.EnumValueConfig{
+				Value:       string(repository.PostOrderLastActivity),
+				Description: "Сначала посты с самыми свежими комментариями",
+			},
+		},
+	})
+
+	// Определение фильтра постов
+	postFilterType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "PostFilter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"commentsDisabled": &graphql.InputObjectFieldConfig{
+				Type: graphql.Boolean,
+			},
+			"createdAfter": &graphql.InputObjectFieldConfig{
+				Type: graphql.DateTime,
+			},
+			"createdBefore": &graphql.InputObjectFieldConfig{
+				Type: graphql.DateTime,
+			},
+			"titleContains": &graphql.InputObjectFieldConfig{
+				Type: graphql.String,
+			},
 		},
 	})
 
@@ -89,7 +288,22 @@ func NewSchema(repo repository.Repository) (graphql.Schema, error) {
 		Name: "Query",
 		Fields: graphql.Fields{
 			"posts": &graphql.Field{
-				Type:    graphql.NewList(postType),
+				Type: graphql.NewNonNull(postConnectionType),
+				Args: graphql.FieldConfigArgument{
+					"first": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.Int),
+					},
+					"after": &graphql.ArgumentConfig{
+						Type: graphql.String,
+					},
+					"orderBy": &graphql.ArgumentConfig{
+						Type:         postOrderType,
+						DefaultValue: string(repository.PostOrderCreatedAt),
+					},
+					"filter": &graphql.ArgumentConfig{
+						Type: postFilterType,
+					},
+				},
 				Resolve: resolver.QueryPosts,
 			},
 			"post": &graphql.Field{
@@ -101,6 +315,43 @@ func NewSchema(repo repository.Repository) (graphql.Schema, error) {
 				},
 				Resolve: resolver.QueryPost,
 			},
+			"search": &graphql.Field{
+				Type: graphql.NewNonNull(newSearchConnectionType(postType, commentType)),
+				Args: graphql.FieldConfigArgument{
+					"query": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+					"first": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.Int),
+					},
+					"after": &graphql.ArgumentConfig{
+						Type: graphql.String,
+					},
+					"in": &graphql.ArgumentConfig{
+						Type: graphql.NewList(graphql.NewNonNull(searchTargetType)),
+					},
+				},
+				Resolve: resolver.QuerySearch,
+			},
+			"commentThread": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(newThreadCommentType(commentType)))),
+				Args: graphql.FieldConfigArgument{
+					"postId": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+					"maxDepth": &graphql.ArgumentConfig{
+						Type: graphql.Int,
+					},
+					"first": &graphql.ArgumentConfig{
+						Type: graphql.Int,
+					},
+				},
+				Resolve: resolver.QueryCommentThread,
+			},
+			"me": &graphql.Field{
+				Type:    userType,
+				Resolve: resolver.QueryMe,
+			},
 		},
 	})
 
@@ -108,6 +359,30 @@ func NewSchema(repo repository.Repository) (graphql.Schema, error) {
 	mutationType := graphql.NewObject(graphql.ObjectConfig{
 		Name: "Mutation",
 		Fields: graphql.Fields{
+			"register": &graphql.Field{
+				Type: graphql.NewNonNull(authPayloadType),
+				Args: graphql.FieldConfigArgument{
+					"username": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+					"password": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+				},
+				Resolve: resolver.Register,
+			},
+			"login": &graphql.Field{
+				Type: graphql.NewNonNull(authPayloadType),
+				Args: graphql.FieldConfigArgument{
+					"username": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+					"password": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+				},
+				Resolve: resolver.Login,
+			},
 			"createPost": &graphql.Field{
 				Type: postType,
 				Args: graphql.FieldConfigArgument{
@@ -138,6 +413,48 @@ func NewSchema(repo repository.Repository) (graphql.Schema, error) {
 				},
 				Resolve: resolver.CreateComment,
 			},
+			"updatePost": &graphql.Field{
+				Type: postType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+					"title": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+					"content": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+					"commentsDisabled": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.Boolean),
+					},
+				},
+				Resolve: resolver.UpdatePost,
+			},
+			"revertPost": &graphql.Field{
+				Type: postType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+					"revision": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.Int),
+					},
+				},
+				Resolve: resolver.RevertPost,
+			},
+			"updateComment": &graphql.Field{
+				Type: commentType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+					"content": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+				},
+				Resolve: resolver.UpdateComment,
+			},
 			"deletePost": &graphql.Field{
 				Type: graphql.Boolean,
 				Args: graphql.FieldConfigArgument{
@@ -156,15 +473,81 @@ func NewSchema(repo repository.Repository) (graphql.Schema, error) {
 				},
 				Resolve: resolver.DeleteComment,
 			},
+			"vote": &graphql.Field{
+				Type: votableType,
+				Args: graphql.FieldConfigArgument{
+					"targetId": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+					"value": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(voteValueType),
+					},
+				},
+				Resolve: resolver.Vote,
+			},
+			"react": &graphql.Field{
+				Type: votableType,
+				Args: graphql.FieldConfigArgument{
+					"targetId": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+					"emoji": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+				},
+				Resolve: resolver.React,
+			},
+			"purgeComment": &graphql.Field{
+				Type: graphql.Boolean,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+				},
+				Resolve: resolver.PurgeComment,
+			},
+		},
+	})
+
+	// Определение корневого типа Subscription для GraphQL схемы
+	subscriptionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			"commentAdded": &graphql.Field{
+				Type: graphql.NewNonNull(commentType),
+				Args: graphql.FieldConfigArgument{
+					"postId": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+				},
+				Subscribe: resolver.SubscribeCommentAdded,
+				Resolve:   resolver.ResolveEvent,
+			},
+			"commentDeleted": &graphql.Field{
+				Type: graphql.NewNonNull(commentType),
+				Args: graphql.FieldConfigArgument{
+					"postId": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+				},
+				Subscribe: resolver.SubscribeCommentDeleted,
+				Resolve:   resolver.ResolveEvent,
+			},
 		},
 	})
 
 	// Создание конфигурации схемы
 	schemaConfig := graphql.SchemaConfig{
-		Query:    queryType,
-		Mutation: mutationType,
+		Query:        queryType,
+		Mutation:     mutationType,
+		Subscription: subscriptionType,
 	}
 
 	// Создание и возврат новой схемы GraphQL
-	return graphql.NewSchema(schemaConfig)
+	schema, err := graphql.NewSchema(schemaConfig)
+	if err != nil {
+		return schema, err
+	}
+	instrument(&schema, options.Instrumentation)
+	return schema, nil
 }
@@ -0,0 +1,111 @@
+package gql
+
+import (
+	"github.com/graphql-go/graphql"
+	"github.com/nemopss/go-posts-comments-system/internal/models"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+)
+
+// SearchConnection представляет страницу результатов полнотекстового поиска
+type SearchConnection struct {
+	Edges      []*SearchEdge // Результаты в порядке убывания релевантности
+	PageInfo   PageInfo      // Информация о странице
+	TotalCount int           // Общее количество найденных объектов
+}
+
+// SearchEdge представляет найденный пост или комментарий вместе с курсором
+type SearchEdge struct {
+	Cursor  string
+	Node    interface{} // *models.Post или *models.Comment
+	Rank    float64     // Релевантность объекта запросу
+	Snippet string      // Фрагмент текста с выделенными словами запроса
+}
+
+// newSearchConnection строит соединение из страницы результатов поиска
+func newSearchConnection(page *repository.SearchPage, after *repository.SearchCursor) *SearchConnection {
+	conn := &SearchConnection{
+		Edges:      make([]*SearchEdge, 0, len(page.Hits)),
+		TotalCount: page.TotalCount,
+		PageInfo: PageInfo{
+			HasNextPage:     page.HasNextPage,
+			HasPreviousPage: after != nil,
+		},
+	}
+	for _, hit := range page.Hits {
+		var node interface{} = hit.Post
+		if hit.Kind == repository.TargetComment {
+			node = hit.Comment
+		}
+		conn.Edges = append(conn.Edges, &SearchEdge{
+			Cursor:  repository.EncodeSearchCursor(repository.SearchCursorOf(hit)),
+			Node:    node,
+			Rank:    hit.Rank,
+			Snippet: hit.Snippet,
+		})
+	}
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.StartCursor = &conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+	return conn
+}
+
+// searchTargetType описывает виды объектов, среди которых ведётся поиск
+var searchTargetType = graphql.NewEnum(graphql.EnumConfig{
+	Name: "SearchTarget",
+	Values: graphql.EnumValueConfigMap{
+		"POSTS": &graphql.EnumValueConfig{
+			Value:       string(repository.TargetPost),
+			Description: "Заголовки и тексты постов",
+		},
+		"COMMENTS": &graphql.EnumValueConfig{
+			Value:       string(repository.TargetComment),
+			Description: "Тексты комментариев",
+		},
+	},
+})
+
+// newSearchConnectionType создаёт GraphQL типы результатов поиска для заданных типов поста и комментария
+func newSearchConnectionType(postType, commentType *graphql.Object) *graphql.Object {
+	resultType := graphql.NewUnion(graphql.UnionConfig{
+		Name:  "SearchResult",
+		Types: []*graphql.Object{postType, commentType},
+		ResolveType: func(p graphql.ResolveTypeParams) *graphql.Object {
+			if _, ok := p.Value.(*models.Comment); ok {
+				return commentType
+			}
+			return postType
+		},
+	})
+	edgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "SearchEdge",
+		Fields: graphql.Fields{
+			"cursor": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"node": &graphql.Field{
+				Type: graphql.NewNonNull(resultType),
+			},
+			"rank": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Float),
+			},
+			"snippet": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+		},
+	})
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "SearchConnection",
+		Fields: graphql.Fields{
+			"edges": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(edgeType))),
+			},
+			"pageInfo": &graphql.Field{
+				Type: graphql.NewNonNull(pageInfoType),
+			},
+			"totalCount": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+			},
+		},
+	})
+}
@@ -0,0 +1,21 @@
+package gql
+
+import "github.com/graphql-go/graphql"
+
+// newThreadCommentType создаёт GraphQL тип комментария в развёрнутом дереве обсуждения для заданного типа комментария
+func newThreadCommentType(commentType *graphql.Object) *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "ThreadComment",
+		Fields: graphql.Fields{
+			"depth": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+			},
+			"path": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.ID))),
+			},
+			"comment": &graphql.Field{
+				Type: graphql.NewNonNull(commentType),
+			},
+		},
+	})
+}
@@ -0,0 +1,129 @@
+package gql
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/graphql-go/graphql"
+	"github.com/nemopss/go-posts-comments-system/internal/models"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+)
+
+// ErrInvalidEmoji возвращается, если реакция не является эмодзи
+var ErrInvalidEmoji = repository.Invalid("emoji", "reaction must be a single emoji")
+
+// maxEmojiLength ограничивает длину эмодзи в рунах: составные эмодзи (флаги, семьи, оттенки кожи)
+// состоят из нескольких кодовых точек
+const maxEmojiLength = 16
+
+// validEmoji проверяет, что строка похожа на эмодзи: содержит хотя бы один символ-пиктограмму
+// и только символы, из которых составляются эмодзи
+func validEmoji(emoji string) bool {
+	if emoji == "" || utf8.RuneCountInString(emoji) > maxEmojiLength {
+		return false
+	}
+	hasSymbol := false
+	for _, r := range emoji {
+		switch {
+		case unicode.Is(unicode.So, r):
+			hasSymbol = true
+		case unicode.In(r, unicode.Sk, unicode.Mn, unicode.Me, unicode.Cf):
+			// Модификаторы: оттенки кожи, селекторы вариантов, соединитель ZWJ
+		default:
+			return false
+		}
+	}
+	return hasSymbol
+}
+
+// targetOf возвращает вид и идентификатор поста или комментария
+func targetOf(source interface{}) (repository.TargetKind, string) {
+	switch target := source.(type) {
+	case *models.Post:
+		return repository.TargetPost, target.ID
+	case *models.Comment:
+		return repository.TargetComment, target.ID
+	}
+	return "", ""
+}
+
+// voteCounts возвращает счётчики голосов поста или комментария
+func voteCounts(source interface{}) (upvotes, downvotes int) {
+	switch target := source.(type) {
+	case *models.Post:
+		return target.Upvotes, target.Downvotes
+	case *models.Comment:
+		return target.Upvotes, target.Downvotes
+	}
+	return 0, 0
+}
+
+// voteValueType описывает голос пользователя
+var voteValueType = graphql.NewEnum(graphql.EnumConfig{
+	Name: "VoteValue",
+	Values: graphql.EnumValueConfigMap{
+		"UP": &graphql.EnumValueConfig{
+			Value:       repository.VoteUp,
+			Description: "Голос за",
+		},
+		"DOWN": &graphql.EnumValueConfig{
+			Value:       repository.VoteDown,
+			Description: "Голос против",
+		},
+		"NONE": &graphql.EnumValueConfig{
+			Value:       repository.VoteNone,
+			Description: "Нет голоса",
+		},
+	},
+})
+
+// reactionType описывает сводку реакций одним эмодзи
+var reactionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Reaction",
+	Fields: graphql.Fields{
+		"emoji": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"count": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Int),
+		},
+		"viewerHasReacted": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+		},
+	},
+})
+
+// withVotableFields добавляет к полям типа поля голосов и реакций
+func withVotableFields(resolver *Resolver, fields graphql.Fields) graphql.Fields {
+	for name, field := range votableFields(resolver) {
+		fields[name] = field
+	}
+	return fields
+}
+
+// votableFields возвращает поля голосов и реакций, общие для постов и комментариев
+func votableFields(resolver *Resolver) graphql.Fields {
+	return graphql.Fields{
+		"id": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.ID),
+		},
+		"score": &graphql.Field{
+			Type:    graphql.NewNonNull(graphql.Int),
+			Resolve: resolver.ResolveScore,
+		},
+		"upvotes": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Int),
+		},
+		"downvotes": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Int),
+		},
+		"viewerVote": &graphql.Field{
+			Type:    graphql.NewNonNull(voteValueType),
+			Resolve: resolver.ResolveViewerVote,
+		},
+		"reactions": &graphql.Field{
+			Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(reactionType))),
+			Resolve: resolver.ResolveReactions,
+		},
+	}
+}
@@ -0,0 +1,171 @@
+// Package metrics собирает метрики сервиса в формате Prometheus: длительность GraphQL операций
+// и резолверов полей, вызовы методов хранилища, состояние пула соединений с базой данных
+// и размеры хранилища в памяти.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+	"github.com/nemopss/go-posts-comments-system/internal/repository/inmemory"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Результаты операций в метке status
+const (
+	StatusOK               = "ok"
+	StatusNotFound         = "not_found"
+	StatusInvalid          = "invalid"
+	StatusCommentsDisabled = "comments_disabled"
+	StatusConflict         = "conflict"
+	StatusForbidden        = "forbidden"
+	StatusTimeout          = "timeout"
+	StatusCanceled         = "canceled"
+	StatusError            = "error"
+)
+
+// MaxOperationNames - наибольшее количество различных имён операций в метке name. Имена задают клиенты,
+// поэтому операции с новыми именами сверх этого количества записываются с именем OtherOperationName,
+// чтобы клиенты не могли создавать неограниченное количество рядов.
+const MaxOperationNames = 100
+
+// OtherOperationName - значение метки name для операций, не поместившихся в MaxOperationNames
+const OtherOperationName = "other"
+
+// Metrics содержит метрики сервиса и реестр Prometheus, в котором они зарегистрированы
+type Metrics struct {
+	registry *prometheus.Registry
+
+	operationDuration  *prometheus.HistogramVec // Длительность GraphQL операций
+	resolverDuration   *prometheus.HistogramVec // Длительность резолверов полей
+	repositoryCalls    *prometheus.CounterVec   // Вызовы методов хранилища по результату
+	repositoryDuration *prometheus.HistogramVec // Длительность вызовов методов хранилища
+
+	mu             sync.Mutex
+	operationNames map[string]bool // Имена операций, уже записанные в метке name
+}
+
+// New создаёт метрики сервиса в новом реестре вместе со стандартными метриками среды выполнения Go и процесса
+func New() *Metrics {
+	m := &Metrics{
+		registry:       prometheus.NewRegistry(),
+		operationNames: map[string]bool{},
+		operationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "graphql_operation_duration_seconds",
+			Help:    "Duration of GraphQL operations by operation type, name and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"type", "name", "status"}),
+		resolverDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "graphql_resolver_duration_seconds",
+			Help:    "Duration of GraphQL field resolvers by parent type, field and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"type", "field", "status"}),
+		repositoryCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "repository_calls_total",
+			Help: "Number of storage method calls by method and status.",
+		}, []string{"method", "status"}),
+		repositoryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "repository_call_duration_seconds",
+			Help:    "Duration of storage method calls by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.operationDuration,
+		m.resolverDuration,
+		m.repositoryCalls,
+		m.repositoryDuration,
+	)
+	return m
+}
+
+// Handler возвращает обработчик HTTP, отдающий метрики в текстовом формате Prometheus
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// RegisterDB добавляет метрики пула соединений db (sql.DBStats) с меткой db_name, равной name
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterInMemory добавляет метрики количества постов и комментариев в хранилище в памяти
+func (m *Metrics) RegisterInMemory(repo *inmemory.InMemoryRepository) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "inmemory_posts",
+			Help: "Number of posts in the in-memory storage.",
+		}, func() float64 { return float64(repo.Stats().Posts) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "inmemory_comments",
+			Help: "Number of comments in the in-memory storage.",
+		}, func() float64 { return float64(repo.Stats().Comments) }),
+	)
+}
+
+// OperationDone записывает длительность GraphQL операции. Операции, которые не удалось разобрать,
+// записываются с видом unknown, а количество различных имён ограничено MaxOperationNames.
+// Реализует gql.Instrumentation.
+func (m *Metrics) OperationDone(operationType, operationName string, duration time.Duration, failed bool) {
+	if operationType == "" {
+		operationType = "unknown"
+	}
+	result := StatusOK
+	if failed {
+		result = StatusError
+	}
+	m.operationDuration.WithLabelValues(operationType, m.operationLabel(operationName), result).Observe(duration.Seconds())
+}
+
+// operationLabel возвращает значение метки name для операции с именем name
+func (m *Metrics) operationLabel(name string) string {
+	if name == "" {
+		return name
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.operationNames[name] {
+		if len(m.operationNames) >= MaxOperationNames {
+			return OtherOperationName
+		}
+		m.operationNames[name] = true
+	}
+	return name
+}
+
+// ResolverDone записывает длительность резолвера поля. Реализует gql.Instrumentation.
+func (m *Metrics) ResolverDone(parentType, field string, duration time.Duration, err error) {
+	m.resolverDuration.WithLabelValues(parentType, field, status(err)).Observe(duration.Seconds())
+}
+
+// status возвращает значение метки status для результата операции
+func status(err error) string {
+	switch {
+	case err == nil:
+		return StatusOK
+	case errors.Is(err, repository.ErrNotFound):
+		return StatusNotFound
+	case errors.Is(err, repository.ErrValidation):
+		return StatusInvalid
+	case errors.Is(err, repository.ErrCommentsDisabled):
+		return StatusCommentsDisabled
+	case errors.Is(err, repository.ErrConflict):
+		return StatusConflict
+	case errors.Is(err, repository.ErrForbidden):
+		return StatusForbidden
+	case errors.Is(err, repository.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return StatusTimeout
+	case errors.Is(err, context.Canceled):
+		return StatusCanceled
+	}
+	return StatusError
+}
@@ -0,0 +1,253 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/nemopss/go-posts-comments-system/internal/models"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+)
+
+// InstrumentedRepository подсчитывает вызовы каждого метода хранилища по результату
+// и измеряет их длительность
+type InstrumentedRepository struct {
+	repo    repository.Repository
+	metrics *Metrics
+}
+
+// Instrument оборачивает хранилище так, что вызовы его методов попадают в метрики m.
+// Обёртка должна быть внешней по отношению к repository.WithTimeout, чтобы прерванные по сроку
+// операции учитывались как таймауты.
+func Instrument(repo repository.Repository, m *Metrics) *InstrumentedRepository {
+	return &InstrumentedRepository{repo: repo, metrics: m}
+}
+
+// observe записывает результат и длительность вызова метода method, начатого в start
+func (repo *InstrumentedRepository) observe(method string, start time.Time, err error) {
+	repo.metrics.repositoryCalls.WithLabelValues(method, status(err)).Inc()
+	repo.metrics.repositoryDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// GetPosts возвращает список всех постов
+func (repo *InstrumentedRepository) GetPosts(ctx context.Context) ([]*models.Post, error) {
+	start := time.Now()
+	posts, err := repo.repo.GetPosts(ctx)
+	repo.observe("GetPosts", start, err)
+	return posts, err
+}
+
+// ListPosts возвращает страницу постов
+func (repo *InstrumentedRepository) ListPosts(ctx context.Context, query repository.PostsQuery) (*repository.PostPage, error) {
+	start := time.Now()
+	page, err := repo.repo.ListPosts(ctx, query)
+	repo.observe("ListPosts", start, err)
+	return page, err
+}
+
+// GetPost возвращает пост по его ID
+func (repo *InstrumentedRepository) GetPost(ctx context.Context, id string) (*models.Post, error) {
+	start := time.Now()
+	post, err := repo.repo.GetPost(ctx, id)
+	repo.observe("GetPost", start, err)
+	return post, err
+}
+
+// CreatePost создаёт новый пост
+func (repo *InstrumentedRepository) CreatePost(ctx context.Context, authorId, title, content string, commentsDisabled bool) (*models.Post, error) {
+	start := time.Now()
+	post, err := repo.repo.CreatePost(ctx, authorId, title, content, commentsDisabled)
+	repo.observe("CreatePost", start, err)
+	return post, err
+}
+
+// CreateComment создаёт новый комментарий
+func (repo *InstrumentedRepository) CreateComment(ctx context.Context, authorId, postId, parentId, content string) (*models.Comment, error) {
+	start := time.Now()
+	comment, err := repo.repo.CreateComment(ctx, authorId, postId, parentId, content)
+	repo.observe("CreateComment", start, err)
+	return comment, err
+}
+
+// GetComment возвращает комментарий по его ID
+func (repo *InstrumentedRepository) GetComment(ctx context.Context, id string) (*models.Comment, error) {
+	start := time.Now()
+	comment, err := repo.repo.GetComment(ctx, id)
+	repo.observe("GetComment", start, err)
+	return comment, err
+}
+
+// GetCommentsByPostID возвращает страницу комментариев верхнего уровня
+func (repo *InstrumentedRepository) GetCommentsByPostID(ctx context.Context, postId string, first int64, after *repository.Cursor) (*repository.CommentPage, error) {
+	start := time.Now()
+	page, err := repo.repo.GetCommentsByPostID(ctx, postId, first, after)
+	repo.observe("GetCommentsByPostID", start, err)
+	return page, err
+}
+
+// GetCommentsByParentID возвращает страницу дочерних комментариев
+func (repo *InstrumentedRepository) GetCommentsByParentID(ctx context.Context, parentId string, first int64, after *repository.Cursor) (*repository.CommentPage, error) {
+	start := time.Now()
+	page, err := repo.repo.GetCommentsByParentID(ctx, parentId, first, after)
+	repo.observe("GetCommentsByParentID", start, err)
+	return page, err
+}
+
+// GetCommentsByPostIDs возвращает страницы комментариев верхнего уровня для нескольких постов
+func (repo *InstrumentedRepository) GetCommentsByPostIDs(ctx context.Context, postIds []string, first int64, after *repository.Cursor) (map[string]*repository.CommentPage, error) {
+	start := time.Now()
+	pages, err := repo.repo.GetCommentsByPostIDs(ctx, postIds, first, after)
+	repo.observe("GetCommentsByPostIDs", start, err)
+	return pages, err
+}
+
+// GetCommentsByParentIDs возвращает страницы дочерних комментариев для нескольких комментариев
+func (repo *InstrumentedRepository) GetCommentsByParentIDs(ctx context.Context, parentIds []string, first int64, after *repository.Cursor) (map[string]*repository.CommentPage, error) {
+	start := time.Now()
+	pages, err := repo.repo.GetCommentsByParentIDs(ctx, parentIds, first, after)
+	repo.observe("GetCommentsByParentIDs", start, err)
+	return pages, err
+}
+
+// GetCommentAncestors возвращает цепочку предков комментария
+func (repo *InstrumentedRepository) GetCommentAncestors(ctx context.Context, id string) ([]*models.Comment, error) {
+	start := time.Now()
+	ancestors, err := repo.repo.GetCommentAncestors(ctx, id)
+	repo.observe("GetCommentAncestors", start, err)
+	return ancestors, err
+}
+
+// GetCommentThread возвращает дерево комментариев поста
+func (repo *InstrumentedRepository) GetCommentThread(ctx context.Context, query repository.ThreadQuery) ([]*repository.ThreadComment, error) {
+	start := time.Now()
+	thread, err := repo.repo.GetCommentThread(ctx, query)
+	repo.observe("GetCommentThread", start, err)
+	return thread, err
+}
+
+// UpdatePost изменяет пост
+func (repo *InstrumentedRepository) UpdatePost(ctx context.Context, editorId, id, title, content string, commentsDisabled bool) (*models.Post, error) {
+	start := time.Now()
+	post, err := repo.repo.UpdatePost(ctx, editorId, id, title, content, commentsDisabled)
+	repo.observe("UpdatePost", start, err)
+	return post, err
+}
+
+// UpdateComment изменяет комментарий
+func (repo *InstrumentedRepository) UpdateComment(ctx context.Context, editorId, id, content string) (*models.Comment, error) {
+	start := time.Now()
+	comment, err := repo.repo.UpdateComment(ctx, editorId, id, content)
+	repo.observe("UpdateComment", start, err)
+	return comment, err
+}
+
+// GetPostRevisions возвращает все версии поста
+func (repo *InstrumentedRepository) GetPostRevisions(ctx context.Context, postId string) ([]*models.PostRevision, error) {
+	start := time.Now()
+	revisions, err := repo.repo.GetPostRevisions(ctx, postId)
+	repo.observe("GetPostRevisions", start, err)
+	return revisions, err
+}
+
+// GetCommentRevisions возвращает все версии комментария
+func (repo *InstrumentedRepository) GetCommentRevisions(ctx context.Context, commentId string) ([]*models.CommentRevision, error) {
+	start := time.Now()
+	revisions, err := repo.repo.GetCommentRevisions(ctx, commentId)
+	repo.observe("GetCommentRevisions", start, err)
+	return revisions, err
+}
+
+// DeletePost удаляет пост
+func (repo *InstrumentedRepository) DeletePost(ctx context.Context, id string) error {
+	start := time.Now()
+	err := repo.repo.DeletePost(ctx, id)
+	repo.observe("DeletePost", start, err)
+	return err
+}
+
+// DeleteComment удаляет комментарий вместе с ответами на него
+func (repo *InstrumentedRepository) DeleteComment(ctx context.Context, id string) error {
+	start := time.Now()
+	err := repo.repo.DeleteComment(ctx, id)
+	repo.observe("DeleteComment", start, err)
+	return err
+}
+
+// SoftDeleteComment заменяет комментарий надгробием
+func (repo *InstrumentedRepository) SoftDeleteComment(ctx context.Context, id string) (*models.Comment, error) {
+	start := time.Now()
+	comment, err := repo.repo.SoftDeleteComment(ctx, id)
+	repo.observe("SoftDeleteComment", start, err)
+	return comment, err
+}
+
+// Vote устанавливает голос пользователя за пост или комментарий
+func (repo *InstrumentedRepository) Vote(ctx context.Context, kind repository.TargetKind, targetId, userId string, value int) error {
+	start := time.Now()
+	err := repo.repo.Vote(ctx, kind, targetId, userId, value)
+	repo.observe("Vote", start, err)
+	return err
+}
+
+// GetVote возвращает голос пользователя за пост или комментарий
+func (repo *InstrumentedRepository) GetVote(ctx context.Context, kind repository.TargetKind, targetId, userId string) (int, error) {
+	start := time.Now()
+	value, err := repo.repo.GetVote(ctx, kind, targetId, userId)
+	repo.observe("GetVote", start, err)
+	return value, err
+}
+
+// React добавляет или снимает реакцию пользователя
+func (repo *InstrumentedRepository) React(ctx context.Context, kind repository.TargetKind, targetId, userId, emoji string) (bool, error) {
+	start := time.Now()
+	reacted, err := repo.repo.React(ctx, kind, targetId, userId, emoji)
+	repo.observe("React", start, err)
+	return reacted, err
+}
+
+// GetReactions возвращает сводку реакций на пост или комментарий
+func (repo *InstrumentedRepository) GetReactions(ctx context.Context, kind repository.TargetKind, targetId, viewerId string) ([]*models.Reaction, error) {
+	start := time.Now()
+	reactions, err := repo.repo.GetReactions(ctx, kind, targetId, viewerId)
+	repo.observe("GetReactions", start, err)
+	return reactions, err
+}
+
+// Search выполняет полнотекстовый поиск
+func (repo *InstrumentedRepository) Search(ctx context.Context, query repository.SearchQuery) (*repository.SearchPage, error) {
+	start := time.Now()
+	page, err := repo.repo.Search(ctx, query)
+	repo.observe("Search", start, err)
+	return page, err
+}
+
+// CreateUser создаёт нового пользователя
+func (repo *InstrumentedRepository) CreateUser(ctx context.Context, username, passwordHash string) (*models.User, error) {
+	start := time.Now()
+	user, err := repo.repo.CreateUser(ctx, username, passwordHash)
+	repo.observe("CreateUser", start, err)
+	return user, err
+}
+
+// GetUser возвращает пользователя по его ID
+func (repo *InstrumentedRepository) GetUser(ctx context.Context, id string) (*models.User, error) {
+	start := time.Now()
+	user, err := repo.repo.GetUser(ctx, id)
+	repo.observe("GetUser", start, err)
+	return user, err
+}
+
+// GetUserByUsername возвращает пользователя по его имени
+func (repo *InstrumentedRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	start := time.Now()
+	user, err := repo.repo.GetUserByUsername(ctx, username)
+	repo.observe("GetUserByUsername", start, err)
+	return user, err
+}
+
+// Ping проверяет доступность хранилища
+func (repo *InstrumentedRepository) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := repo.repo.Ping(ctx)
+	repo.observe("Ping", start, err)
+	return err
+}
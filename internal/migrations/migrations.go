@@ -0,0 +1,381 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Dialect обозначает СУБД, для которой написаны миграции
+type Dialect string
+
+// Поддерживаемые СУБД. Миграции каждой из них лежат в каталоге с её названием.
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// files содержит встроенные в бинарный файл миграции всех СУБД
+//
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// Files возвращает файлы миграций для заданной СУБД
+func Files(dialect Dialect) fs.FS {
+	sub, _ := fs.Sub(files, string(dialect))
+	return sub
+}
+
+// advisoryLockID - ключ рекомендательной блокировки, которая не даёт нескольким
+// экземплярам сервиса применять миграции одновременно
+const advisoryLockID = 7294105
+
+// fileName описывает имя файла миграции: <версия>_<название>.<up|down>.sql
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration представляет одну версию схемы
+type Migration struct {
+	Version int    // Номер версии, миграции применяются по возрастанию
+	Name    string // Название из имени файла
+	Up      string // SQL для перехода на эту версию
+	Down    string // SQL для отката этой версии
+}
+
+// Status представляет состояние миграции в базе данных
+type Status struct {
+	Migration
+	AppliedAt *time.Time // Время применения, nil - миграция ещё не применена
+}
+
+// Load читает миграции из файлов и возвращает их в порядке возрастания версий.
+// У каждой миграции должны быть файлы up и down, версии не должны повторяться.
+func Load(fsys fs.FS) ([]*Migration, error) {
+	files, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, file := range files {
+		match := fileName.FindStringSubmatch(file.Name())
+		if match == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(match[1])
+		data, err := fs.ReadFile(fsys, path.Join(".", file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator применяет и откатывает миграции, отмечая применённые версии в таблице schema_migrations
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []*Migration
+}
+
+// NewMigrator создаёт новый экземпляр Migrator со встроенными миграциями заданной СУБД
+func NewMigrator(db *sql.DB, dialect Dialect) (*Migrator, error) {
+	migrations, err := Load(Files(dialect))
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// Latest возвращает номер последней известной версии схемы
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up применяет все ещё не применённые миграции
+func (m *Migrator) Up() error {
+	return m.To(m.Latest())
+}
+
+// Down откатывает последнюю применённую миграцию
+func (m *Migrator) Down() error {
+	return m.withLock(func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			if _, ok := applied[m.migrations[i].Version]; ok {
+				return m.revert(conn, m.migrations[i])
+			}
+		}
+		log.Println("No migrations to revert")
+		return nil
+	})
+}
+
+// To применяет или откатывает миграции так, чтобы версия схемы стала равна version.
+// Версия 0 означает пустую схему. База данных PostgreSQL, созданная до появления миграций
+// скриптом init.sql, сначала отмечается как имеющая версию 1 (см. Baseline).
+func (m *Migrator) To(version int) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+	return m.withLock(func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			if applied, err = m.adoptExisting(conn); err != nil {
+				return err
+			}
+		}
+		// Сначала откатываются версии новее заданной, начиная с последней
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; ok && migration.Version > version {
+				if err := m.revert(conn, migration); err != nil {
+					return err
+				}
+			}
+		}
+		// Затем применяются недостающие версии, начиная с первой
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; !ok && migration.Version <= version {
+				if err := m.apply(conn, migration); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Baseline отмечает миграции до версии version включительно как применённые, не выполняя их.
+// Используется для базы данных, схема которой уже создана без миграций и совпадает с версией version.
+// Если в базе данных уже есть применённые миграции, возвращает ошибку.
+func (m *Migrator) Baseline(version int) error {
+	if m.find(version) == nil {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+	return m.withLock(func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		if len(applied) > 0 {
+			return errors.New("database already has applied migrations")
+		}
+		return m.stamp(conn, version)
+	})
+}
+
+// legacyVersion - версия схемы, которую создавал скрипт init.sql до появления миграций
+const legacyVersion = 1
+
+// adoptExisting отмечает версию legacyVersion как применённую, если в базе данных PostgreSQL
+// без применённых миграций уже есть таблица posts, созданная скриптом init.sql.
+// Если у таблицы posts уже есть столбцы следующих версий, схема создана не скриптом init.sql,
+// и её версию нужно отметить вручную (см. Baseline). Возвращает применённые после этого версии.
+func (m *Migrator) adoptExisting(conn *sql.Conn) (map[int]time.Time, error) {
+	if m.dialect != Postgres {
+		return map[int]time.Time{}, nil
+	}
+	var exists bool
+	if err := conn.QueryRowContext(context.Background(), "SELECT to_regclass('posts') IS NOT NULL").Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return map[int]time.Time{}, nil
+	}
+	var newer bool
+	err := conn.QueryRowContext(context.Background(), `
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'posts' AND column_name = 'comment_count'
+		)
+	`).Scan(&newer)
+	if err != nil {
+		return nil, err
+	}
+	if newer {
+		return nil, errors.New("existing schema is newer than init.sql, mark its version with migrate baseline")
+	}
+	log.Printf("Existing schema without migrations found, marking it as version %d\n", legacyVersion)
+	if err := m.stamp(conn, legacyVersion); err != nil {
+		return nil, err
+	}
+	return appliedVersions(conn)
+}
+
+// stamp отмечает миграции до версии version включительно как применённые в одной транзакции
+func (m *Migrator) stamp(conn *sql.Conn, version int) error {
+	return inTx(conn, func(tx *sql.Tx) error {
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				break
+			}
+			log.Printf("Marking migration %d_%s as applied\n", migration.Version, migration.Name)
+			if _, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Status возвращает состояние всех известных миграций
+func (m *Migrator) Status() ([]*Status, error) {
+	var statuses []*Status
+	err := m.withLock(func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			status := &Status{Migration: *migration}
+			if appliedAt, ok := applied[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// find возвращает миграцию с заданной версией
+func (m *Migrator) find(version int) *Migration {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration
+		}
+	}
+	return nil
+}
+
+// schemaMigrationsTypes задаёт тип столбца applied_at таблицы schema_migrations для каждой СУБД
+var schemaMigrationsTypes = map[Dialect]string{
+	Postgres: "TIMESTAMP WITH TIME ZONE",
+	SQLite:   "TIMESTAMP",
+}
+
+// withLock выполняет функцию на отдельном соединении, предварительно создав таблицу schema_migrations.
+// В PostgreSQL соединение удерживает рекомендательную блокировку, а SQLite сам
+// не допускает одновременных записывающих транзакций.
+func (m *Migrator) withLock(fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.dialect == Postgres {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryLockID); err != nil {
+			return err
+		}
+		defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", advisoryLockID)
+	}
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at `+schemaMigrationsTypes[m.dialect]+` NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// appliedVersions возвращает применённые версии и время их применения
+func appliedVersions(conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(context.Background(), "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// apply применяет миграцию и отмечает её версию в одной транзакции
+func (m *Migrator) apply(conn *sql.Conn, migration *Migration) error {
+	log.Printf("Applying migration %d_%s\n", migration.Version, migration.Name)
+	return inTx(conn, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(migration.Up); err != nil {
+			return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+		}
+		_, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+		return err
+	})
+}
+
+// revert откатывает миграцию и удаляет отметку о её версии в одной транзакции
+func (m *Migrator) revert(conn *sql.Conn, migration *Migration) error {
+	log.Printf("Reverting migration %d_%s\n", migration.Version, migration.Name)
+	return inTx(conn, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(migration.Down); err != nil {
+			return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+		}
+		_, err := tx.Exec("DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+		return err
+	})
+}
+
+// inTx выполняет функцию в транзакции и фиксирует её, если функция завершилась без ошибки
+func inTx(conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
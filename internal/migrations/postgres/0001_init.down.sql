@@ -0,0 +1,4 @@
+-- Удаление начальной схемы в порядке, обратном созданию
+DROP TABLE pairs;
+DROP TABLE comments;
+DROP TABLE posts;
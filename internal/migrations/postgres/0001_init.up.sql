@@ -1,3 +1,5 @@
+-- Начальная схема, которую до появления миграций создавал скрипт init.sql
+
 -- Создание таблицы posts
 CREATE TABLE posts (
     id VARCHAR(100) PRIMARY KEY,
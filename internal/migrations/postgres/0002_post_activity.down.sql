@@ -0,0 +1,8 @@
+DROP INDEX comments_parent_id_idx;
+DROP INDEX comments_post_id_idx;
+DROP INDEX posts_last_activity_at_idx;
+DROP INDEX posts_comment_count_idx;
+DROP INDEX posts_created_at_idx;
+
+ALTER TABLE posts DROP COLUMN last_activity_at;
+ALTER TABLE posts DROP COLUMN comment_count;
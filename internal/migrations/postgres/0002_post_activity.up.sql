@@ -0,0 +1,18 @@
+-- Счётчик комментариев и время последней активности для сортировки постов
+ALTER TABLE posts ADD COLUMN comment_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN last_activity_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+
+-- Заполнение по существующим комментариям на всех уровнях вложенности.
+-- Пост без комментариев последний раз был активен при создании
+UPDATE posts p SET
+    comment_count = (SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id),
+    last_activity_at = GREATEST(p.created_at, (SELECT MAX(c.created_at) FROM comments c WHERE c.post_id = p.id));
+
+-- Индексы для keyset-пагинации постов по каждому из порядков сортировки
+CREATE INDEX posts_created_at_idx ON posts (created_at DESC, id DESC);
+CREATE INDEX posts_comment_count_idx ON posts (comment_count DESC, id DESC);
+CREATE INDEX posts_last_activity_at_idx ON posts (last_activity_at DESC, id DESC);
+
+-- Индексы для keyset-пагинации комментариев поста и дочерних комментариев
+CREATE INDEX comments_post_id_idx ON comments (post_id, created_at, id) WHERE parent_id IS NULL;
+CREATE INDEX comments_parent_id_idx ON comments (parent_id, created_at, id);
@@ -0,0 +1,23 @@
+-- Создание таблицы users
+CREATE TABLE users (
+    id VARCHAR(100) PRIMARY KEY,
+    username VARCHAR(64) NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    role VARCHAR(16) NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Посты и комментарии, созданные до появления пользователей, приписываются служебному автору.
+-- Его имя не проходит проверку при регистрации, а войти под ним нельзя: хеш пароля не соответствует формату bcrypt
+INSERT INTO users (id, username, password_hash)
+SELECT 'legacy', '(legacy)', '!'
+WHERE EXISTS (SELECT 1 FROM posts) OR EXISTS (SELECT 1 FROM comments);
+
+-- Автор поста и комментария
+ALTER TABLE posts ADD COLUMN author_id VARCHAR(100) REFERENCES users(id);
+UPDATE posts SET author_id = 'legacy';
+ALTER TABLE posts ALTER COLUMN author_id SET NOT NULL;
+
+ALTER TABLE comments ADD COLUMN author_id VARCHAR(100) REFERENCES users(id);
+UPDATE comments SET author_id = 'legacy';
+ALTER TABLE comments ALTER COLUMN author_id SET NOT NULL;
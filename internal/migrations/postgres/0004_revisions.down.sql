@@ -0,0 +1,4 @@
+DROP TABLE comment_revisions;
+DROP TABLE post_revisions;
+ALTER TABLE comments DROP COLUMN edited_at;
+ALTER TABLE posts DROP COLUMN edited_at;
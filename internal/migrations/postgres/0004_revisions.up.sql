@@ -0,0 +1,32 @@
+-- Время последнего изменения поста и комментария
+ALTER TABLE posts ADD COLUMN edited_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE comments ADD COLUMN edited_at TIMESTAMP WITH TIME ZONE;
+
+-- Создание таблицы post_revisions для истории изменений постов
+CREATE TABLE post_revisions (
+    post_id VARCHAR(100) REFERENCES posts(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    comments_disabled BOOLEAN NOT NULL,
+    editor_id VARCHAR(100) NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, revision)
+);
+
+-- Создание таблицы comment_revisions для истории изменений комментариев
+CREATE TABLE comment_revisions (
+    comment_id VARCHAR(100) REFERENCES comments(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    editor_id VARCHAR(100) NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (comment_id, revision)
+);
+
+-- У каждого существующего поста и комментария появляется первая версия от имени автора
+INSERT INTO post_revisions (post_id, revision, title, content, comments_disabled, editor_id, created_at)
+SELECT id, 1, title, content, COALESCE(comments_disabled, FALSE), author_id, created_at FROM posts;
+
+INSERT INTO comment_revisions (comment_id, revision, content, editor_id, created_at)
+SELECT id, 1, content, author_id, created_at FROM comments;
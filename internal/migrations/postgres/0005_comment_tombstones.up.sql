@@ -0,0 +1,2 @@
+-- Время мягкого удаления комментария: удалённый комментарий остаётся в дереве как надгробие
+ALTER TABLE comments ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,11 @@
+DROP TABLE comment_reaction_counts;
+DROP TABLE comment_reactions;
+DROP TABLE post_reaction_counts;
+DROP TABLE post_reactions;
+DROP TABLE comment_votes;
+DROP TABLE post_votes;
+
+ALTER TABLE comments DROP COLUMN downvotes;
+ALTER TABLE comments DROP COLUMN upvotes;
+ALTER TABLE posts DROP COLUMN downvotes;
+ALTER TABLE posts DROP COLUMN upvotes;
@@ -0,0 +1,50 @@
+-- Итоговые счётчики голосов постов и комментариев
+ALTER TABLE posts ADD COLUMN upvotes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN downvotes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE comments ADD COLUMN upvotes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE comments ADD COLUMN downvotes INTEGER NOT NULL DEFAULT 0;
+
+-- Создание таблиц голосов: у каждого пользователя один голос за пост или комментарий.
+-- Итоговые счётчики хранятся в столбцах upvotes и downvotes постов и комментариев
+CREATE TABLE post_votes (
+    post_id VARCHAR(100) REFERENCES posts(id) ON DELETE CASCADE,
+    user_id VARCHAR(100) REFERENCES users(id) ON DELETE CASCADE,
+    value SMALLINT NOT NULL CHECK (value IN (-1, 1)),
+    PRIMARY KEY (post_id, user_id)
+);
+
+CREATE TABLE comment_votes (
+    comment_id VARCHAR(100) REFERENCES comments(id) ON DELETE CASCADE,
+    user_id VARCHAR(100) REFERENCES users(id) ON DELETE CASCADE,
+    value SMALLINT NOT NULL CHECK (value IN (-1, 1)),
+    PRIMARY KEY (comment_id, user_id)
+);
+
+-- Создание таблиц реакций и счётчиков реакций по каждому эмодзи
+CREATE TABLE post_reactions (
+    post_id VARCHAR(100) REFERENCES posts(id) ON DELETE CASCADE,
+    user_id VARCHAR(100) REFERENCES users(id) ON DELETE CASCADE,
+    emoji VARCHAR(64) NOT NULL,
+    PRIMARY KEY (post_id, emoji, user_id)
+);
+
+CREATE TABLE post_reaction_counts (
+    post_id VARCHAR(100) REFERENCES posts(id) ON DELETE CASCADE,
+    emoji VARCHAR(64) NOT NULL,
+    count INTEGER NOT NULL,
+    PRIMARY KEY (post_id, emoji)
+);
+
+CREATE TABLE comment_reactions (
+    comment_id VARCHAR(100) REFERENCES comments(id) ON DELETE CASCADE,
+    user_id VARCHAR(100) REFERENCES users(id) ON DELETE CASCADE,
+    emoji VARCHAR(64) NOT NULL,
+    PRIMARY KEY (comment_id, emoji, user_id)
+);
+
+CREATE TABLE comment_reaction_counts (
+    comment_id VARCHAR(100) REFERENCES comments(id) ON DELETE CASCADE,
+    emoji VARCHAR(64) NOT NULL,
+    count INTEGER NOT NULL,
+    PRIMARY KEY (comment_id, emoji)
+);
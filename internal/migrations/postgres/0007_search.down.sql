@@ -0,0 +1,3 @@
+-- Индексы удаляются вместе со столбцами
+ALTER TABLE comments DROP COLUMN search_vector;
+ALTER TABLE posts DROP COLUMN search_vector;
@@ -0,0 +1,11 @@
+-- Поисковый вектор поста: слова заголовка с весом A, слова текста с весом B
+ALTER TABLE posts ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('russian', title), 'A') || setweight(to_tsvector('russian', content), 'B')
+) STORED;
+CREATE INDEX posts_search_vector_idx ON posts USING GIN (search_vector);
+
+-- Поисковый вектор комментария: слова текста с весом B, как у текста поста
+ALTER TABLE comments ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('russian', content), 'B')
+) STORED;
+CREATE INDEX comments_search_vector_idx ON comments USING GIN (search_vector);
@@ -0,0 +1,11 @@
+-- Возврат к таблице pairs с прямыми связями родитель-ответ
+CREATE TABLE pairs (
+    parent_id VARCHAR(100) REFERENCES comments(id) ON DELETE CASCADE,
+    child_id VARCHAR(100) REFERENCES comments(id) ON DELETE CASCADE,
+    PRIMARY KEY (parent_id, child_id)
+);
+
+INSERT INTO pairs (parent_id, child_id)
+SELECT ancestor_id, descendant_id FROM comment_closure WHERE depth = 1;
+
+DROP TABLE comment_closure;
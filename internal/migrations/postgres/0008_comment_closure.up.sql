@@ -0,0 +1,24 @@
+-- Таблица замыкания иерархии комментариев заменяет таблицу pairs, которая хранила только прямые связи.
+-- Для каждого комментария хранятся пары со всеми его предками и с ним самим (глубина 0)
+CREATE TABLE comment_closure (
+    ancestor_id VARCHAR(100) NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    descendant_id VARCHAR(100) NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    depth INTEGER NOT NULL,
+    PRIMARY KEY (ancestor_id, descendant_id)
+);
+
+-- Индекс для выборки цепочки предков комментария
+CREATE INDEX comment_closure_descendant_idx ON comment_closure (descendant_id, depth);
+
+-- Заполнение таблицы замыкания по существующим комментариям
+WITH RECURSIVE closure (ancestor_id, descendant_id, depth) AS (
+    SELECT id, id, 0 FROM comments
+    UNION ALL
+    SELECT cl.ancestor_id, c.id, cl.depth + 1
+    FROM closure cl
+    JOIN comments c ON c.parent_id = cl.descendant_id
+)
+INSERT INTO comment_closure (ancestor_id, descendant_id, depth)
+SELECT ancestor_id, descendant_id, depth FROM closure;
+
+DROP TABLE pairs;
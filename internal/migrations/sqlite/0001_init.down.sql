@@ -0,0 +1,14 @@
+-- Удаление начальной схемы в порядке, обратном созданию
+DROP TABLE search_index;
+DROP TABLE pairs;
+DROP TABLE comment_reaction_counts;
+DROP TABLE comment_reactions;
+DROP TABLE post_reaction_counts;
+DROP TABLE post_reactions;
+DROP TABLE comment_votes;
+DROP TABLE post_votes;
+DROP TABLE comment_revisions;
+DROP TABLE post_revisions;
+DROP TABLE comments;
+DROP TABLE posts;
+DROP TABLE users;
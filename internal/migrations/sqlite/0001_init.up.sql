@@ -0,0 +1,136 @@
+-- Начальная схема: пользователи, посты, комментарии, версии, голоса, реакции и поиск.
+-- Повторяет схему PostgreSQL; время хранится в UTC, логические значения - как 0 и 1
+
+-- Создание таблицы users
+CREATE TABLE users (
+    id VARCHAR(100) PRIMARY KEY,
+    username VARCHAR(64) NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    role VARCHAR(16) NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Создание таблицы posts
+CREATE TABLE posts (
+    id VARCHAR(100) PRIMARY KEY,
+    author_id VARCHAR(100) NOT NULL REFERENCES users(id),
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    comments_disabled BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    comment_count INTEGER NOT NULL DEFAULT 0,
+    last_activity_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    edited_at TIMESTAMP,
+    upvotes INTEGER NOT NULL DEFAULT 0,
+    downvotes INTEGER NOT NULL DEFAULT 0
+);
+
+-- Индексы для keyset-пагинации постов по каждому из порядков сортировки
+CREATE INDEX posts_created_at_idx ON posts (created_at DESC, id DESC);
+CREATE INDEX posts_comment_count_idx ON posts (comment_count DESC, id DESC);
+CREATE INDEX posts_last_activity_at_idx ON posts (last_activity_at DESC, id DESC);
+
+-- Создание таблицы comments
+CREATE TABLE comments (
+    id VARCHAR(100) PRIMARY KEY,
+    post_id VARCHAR(100) REFERENCES posts(id) ON DELETE CASCADE,
+    parent_id VARCHAR(100) REFERENCES comments(id) ON DELETE CASCADE,
+    author_id VARCHAR(100) NOT NULL REFERENCES users(id),
+    content TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    edited_at TIMESTAMP,
+    deleted_at TIMESTAMP,
+    upvotes INTEGER NOT NULL DEFAULT 0,
+    downvotes INTEGER NOT NULL DEFAULT 0
+);
+
+-- Индексы для keyset-пагинации комментариев поста и дочерних комментариев
+CREATE INDEX comments_post_id_idx ON comments (post_id, created_at, id) WHERE parent_id IS NULL;
+CREATE INDEX comments_parent_id_idx ON comments (parent_id, created_at, id);
+
+-- Создание таблицы post_revisions для истории изменений постов
+CREATE TABLE post_revisions (
+    post_id VARCHAR(100) REFERENCES posts(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    comments_disabled BOOLEAN NOT NULL,
+    editor_id VARCHAR(100) NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, revision)
+);
+
+-- Создание таблицы comment_revisions для истории изменений комментариев
+CREATE TABLE comment_revisions (
+    comment_id VARCHAR(100) REFERENCES comments(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    editor_id VARCHAR(100) NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (comment_id, revision)
+);
+
+-- Создание таблиц голосов: у каждого пользователя один голос за пост или комментарий.
+-- Итоговые счётчики хранятся в столбцах upvotes и downvotes постов и комментариев
+CREATE TABLE post_votes (
+    post_id VARCHAR(100) REFERENCES posts(id) ON DELETE CASCADE,
+    user_id VARCHAR(100) REFERENCES users(id) ON DELETE CASCADE,
+    value SMALLINT NOT NULL CHECK (value IN (-1, 1)),
+    PRIMARY KEY (post_id, user_id)
+);
+
+CREATE TABLE comment_votes (
+    comment_id VARCHAR(100) REFERENCES comments(id) ON DELETE CASCADE,
+    user_id VARCHAR(100) REFERENCES users(id) ON DELETE CASCADE,
+    value SMALLINT NOT NULL CHECK (value IN (-1, 1)),
+    PRIMARY KEY (comment_id, user_id)
+);
+
+-- Создание таблиц реакций и счётчиков реакций по каждому эмодзи
+CREATE TABLE post_reactions (
+    post_id VARCHAR(100) REFERENCES posts(id) ON DELETE CASCADE,
+    user_id VARCHAR(100) REFERENCES users(id) ON DELETE CASCADE,
+    emoji VARCHAR(64) NOT NULL,
+    PRIMARY KEY (post_id, emoji, user_id)
+);
+
+CREATE TABLE post_reaction_counts (
+    post_id VARCHAR(100) REFERENCES posts(id) ON DELETE CASCADE,
+    emoji VARCHAR(64) NOT NULL,
+    count INTEGER NOT NULL,
+    PRIMARY KEY (post_id, emoji)
+);
+
+CREATE TABLE comment_reactions (
+    comment_id VARCHAR(100) REFERENCES comments(id) ON DELETE CASCADE,
+    user_id VARCHAR(100) REFERENCES users(id) ON DELETE CASCADE,
+    emoji VARCHAR(64) NOT NULL,
+    PRIMARY KEY (comment_id, emoji, user_id)
+);
+
+CREATE TABLE comment_reaction_counts (
+    comment_id VARCHAR(100) REFERENCES comments(id) ON DELETE CASCADE,
+    emoji VARCHAR(64) NOT NULL,
+    count INTEGER NOT NULL,
+    PRIMARY KEY (comment_id, emoji)
+);
+
+-- Создание таблицы pairs для иерархии комментариев
+CREATE TABLE pairs (
+    parent_id VARCHAR(100) REFERENCES comments(id) ON DELETE CASCADE,
+    child_id VARCHAR(100) REFERENCES comments(id) ON DELETE CASCADE,
+    PRIMARY KEY (parent_id, child_id)
+);
+
+-- Поисковый индекс постов и комментариев. Текст приводится к основам слов приложением
+-- (пакет search), поэтому FTS5 хранит готовые термы через пробел
+CREATE VIRTUAL TABLE search_index USING fts5(kind UNINDEXED, id UNINDEXED, terms);
+
+-- Удалённые посты и комментарии, в том числе каскадно, удаляются из поискового индекса
+CREATE TRIGGER posts_search_index_delete AFTER DELETE ON posts BEGIN
+    DELETE FROM search_index WHERE kind = 'post' AND id = old.id;
+END;
+
+CREATE TRIGGER comments_search_index_delete AFTER DELETE ON comments BEGIN
+    DELETE FROM search_index WHERE kind = 'comment' AND id = old.id;
+END;
@@ -7,7 +7,12 @@ type Comment struct {
 	ID        string     // Уникальный идентификатор комментария
 	PostID    string     // Идентификатор поста, к которому относится комментарий
 	ParentID  *string    // Идентификатор родительского комментария (если есть)
+	AuthorID  string     // Идентификатор автора комментария
 	Content   string     // Содержимое комментария
 	Children  []*Comment // Список дочерних комментариев
 	CreatedAt time.Time  // Время создания комментария
+	EditedAt  *time.Time // Время последнего изменения комментария (nil, если комментарий не изменялся)
+	DeletedAt *time.Time // Время удаления комментария, если он заменён надгробием (nil для обычного комментария)
+	Upvotes   int        // Количество голосов за комментарий
+	Downvotes int        // Количество голосов против комментария
 }
@@ -5,9 +5,15 @@ import "time"
 // Post представляет собой структуру поста
 type Post struct {
 	ID               string     // Уникальный идентификатор поста
+	AuthorID         string     // Идентификатор автора поста
 	Title            string     // Заголовок поста
 	Content          string     // Содержимое поста
 	Comments         []*Comment // Список комментариев к посту
 	CommentsDisabled bool       // Флаг, указывающий, отключены ли комментарии к посту
 	CreatedAt        time.Time  // Время создания поста
+	CommentCount     int        // Количество комментариев к посту на всех уровнях вложенности
+	LastActivityAt   time.Time  // Время последней активности: создания поста или последнего комментария
+	EditedAt         *time.Time // Время последнего изменения поста (nil, если пост не изменялся)
+	Upvotes          int        // Количество голосов за пост
+	Downvotes        int        // Количество голосов против поста
 }
@@ -0,0 +1,8 @@
+package models
+
+// Reaction представляет собой сводку реакций одним эмодзи на пост или комментарий
+type Reaction struct {
+	Emoji            string // Эмодзи реакции
+	Count            int    // Количество пользователей, отреагировавших этим эмодзи
+	ViewerHasReacted bool   // Отреагировал ли этим эмодзи пользователь, выполняющий запрос
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// PostRevision представляет собой версию поста.
+// Первая версия сохраняется при создании поста, каждая следующая - при его изменении.
+type PostRevision struct {
+	PostID           string    // Идентификатор поста
+	Revision         int       // Номер версии, начиная с 1
+	Title            string    // Заголовок поста в этой версии
+	Content          string    // Содержимое поста в этой версии
+	CommentsDisabled bool      // Флаг отключения комментариев в этой версии
+	EditorID         string    // Идентификатор пользователя, создавшего версию
+	CreatedAt        time.Time // Время создания версии
+}
+
+// CommentRevision представляет собой версию комментария.
+// Первая версия сохраняется при создании комментария, каждая следующая - при его изменении.
+type CommentRevision struct {
+	CommentID string    // Идентификатор комментария
+	Revision  int       // Номер версии, начиная с 1
+	Content   string    // Содержимое комментария в этой версии
+	EditorID  string    // Идентификатор пользователя, создавшего версию
+	CreatedAt time.Time // Время создания версии
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Роли пользователей
+const (
+	RoleUser  = "user"  // Обычный пользователь
+	RoleAdmin = "admin" // Администратор, может удалять чужие посты и комментарии
+)
+
+// User представляет собой структуру пользователя
+type User struct {
+	ID           string    // Уникальный идентификатор пользователя
+	Username     string    // Уникальное имя пользователя
+	PasswordHash string    // Хеш пароля (bcrypt)
+	Role         string    // Роль пользователя: RoleUser или RoleAdmin
+	CreatedAt    time.Time // Время регистрации пользователя
+}
@@ -0,0 +1,86 @@
+package pubsub
+
+import (
+	"log"
+	"sync"
+)
+
+// subscriberBuffer — размер буфера канала одного подписчика.
+// Если подписчик не успевает вычитывать события, новые события для него отбрасываются,
+// чтобы медленный клиент не блокировал публикацию для остальных.
+const subscriberBuffer = 16
+
+// Hub представляет собой внутрипроцессную шину событий (pub/sub).
+// Не зависит от конкретного хранилища, поэтому работает одинаково
+// и с in-memory, и с Postgres репозиторием.
+type Hub struct {
+	mu     sync.RWMutex
+	topics map[string]map[*subscriber]struct{} // Подписчики, сгруппированные по топикам
+}
+
+// subscriber представляет одну подписку на топик
+type subscriber struct {
+	ch     chan interface{}
+	closed bool
+}
+
+// NewHub создаёт новую шину событий
+func NewHub() *Hub {
+	return &Hub{
+		topics: make(map[string]map[*subscriber]struct{}),
+	}
+}
+
+// Subscribe подписывается на топик.
+// Возвращает канал событий и функцию отписки, которая закрывает канал.
+// Функцию отписки можно вызывать несколько раз.
+func (h *Hub) Subscribe(topic string) (<-chan interface{}, func()) {
+	sub := &subscriber{ch: make(chan interface{}, subscriberBuffer)}
+
+	h.mu.Lock()
+	if h.topics[topic] == nil {
+		h.topics[topic] = make(map[*subscriber]struct{})
+	}
+	h.topics[topic][sub] = struct{}{}
+	h.mu.Unlock()
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if sub.closed {
+			return
+		}
+		sub.closed = true
+		delete(h.topics[topic], sub)
+		if len(h.topics[topic]) == 0 {
+			delete(h.topics, topic)
+		}
+		close(sub.ch)
+	}
+	return sub.ch, unsubscribe
+}
+
+// Subscribers возвращает общее число активных подписок на всех топиках
+func (h *Hub) Subscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	n := 0
+	for _, subs := range h.topics {
+		n += len(subs)
+	}
+	return n
+}
+
+// Publish отправляет событие всем подписчикам топика.
+// Публикация не блокируется: если буфер подписчика заполнен, событие для него теряется.
+func (h *Hub) Publish(topic string, payload interface{}) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.topics[topic] {
+		select {
+		case sub.ch <- payload:
+		default:
+			log.Println("Dropping event for slow subscriber on topic:", topic)
+		}
+	}
+}
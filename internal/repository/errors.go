@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Категории ошибок, которые возвращают все хранилища. Конкретные ошибки оборачивают одну из категорий,
+// поэтому категорию проверяют с помощью errors.Is, а подробности получают с помощью errors.As.
+var (
+	// ErrNotFound возвращается, если пост, комментарий или пользователь не существует
+	ErrNotFound = errors.New("not found")
+	// ErrValidation возвращается для недопустимых входных данных
+	ErrValidation = errors.New("validation failed")
+	// ErrCommentsDisabled возвращается при попытке прокомментировать пост с отключёнными комментариями
+	ErrCommentsDisabled = errors.New("comments are disabled on this post")
+	// ErrConflict возвращается, если операция противоречит текущему состоянию данных
+	ErrConflict = errors.New("conflict")
+	// ErrForbidden возвращается, если у пользователя нет прав на операцию
+	ErrForbidden = errors.New("forbidden")
+)
+
+var (
+	// ErrUsernameTaken возвращается при попытке зарегистрировать уже занятое имя пользователя
+	ErrUsernameTaken = fmt.Errorf("%w: username is already taken", ErrConflict)
+	// ErrCommentDeleted возвращается при попытке изменить удалённый комментарий или ответить на него
+	ErrCommentDeleted = fmt.Errorf("%w: comment is deleted", ErrConflict)
+	// ErrCommentTooLong возвращается для комментария длиннее MaxCommentLength символов
+	ErrCommentTooLong = Invalid("content", fmt.Sprintf("comment must not exceed %d characters", MaxCommentLength))
+)
+
+// MaxCommentLength - наибольшая длина комментария
+const MaxCommentLength = 2000
+
+// Виды объектов в ошибках NotFoundError
+const (
+	EntityPost     = "post"
+	EntityComment  = "comment"
+	EntityUser     = "user"
+	EntityRevision = "revision"
+	EntityTarget   = "post or comment" // Объект голосования или реакции
+)
+
+// NotFoundError описывает объект, который не удалось найти
+type NotFoundError struct {
+	Entity string // Вид объекта: одна из констант Entity*
+	ID     string // Идентификатор, по которому искали объект
+}
+
+// NotFound возвращает ошибку ненайденного объекта, относящуюся к категории ErrNotFound
+func NotFound(entity, id string) error {
+	return &NotFoundError{Entity: entity, ID: id}
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %s not found", e.Entity, e.ID)
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return ErrNotFound
+}
+
+// ValidationError описывает недопустимое значение поля входных данных
+type ValidationError struct {
+	Field   string // Имя поля или аргумента
+	Message string // Описание нарушенного ограничения
+}
+
+// Invalid возвращает ошибку недопустимого значения поля, относящуюся к категории ErrValidation
+func Invalid(field, message string) error {
+	return &ValidationError{Field: field, Message: message}
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
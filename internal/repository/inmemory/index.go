@@ -0,0 +1,99 @@
+package inmemory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/nemopss/go-posts-comments-system/internal/models"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+)
+
+// orderedComments хранит комментарии, упорядоченные по времени создания, при совпадении времени - по ID.
+// Комментарии создаются в порядке возрастания времени, поэтому вставка почти всегда сводится к добавлению
+// в конец, а страница находится двоичным поиском курсора.
+type orderedComments []*models.Comment
+
+// less сообщает, предшествует ли комментарий позиции (createdAt, id)
+func less(comment *models.Comment, createdAt time.Time, id string) bool {
+	if !comment.CreatedAt.Equal(createdAt) {
+		return comment.CreatedAt.Before(createdAt)
+	}
+	return comment.ID < id
+}
+
+// search возвращает позицию, на которой стоит или должен стоять комментарий
+func (list orderedComments) search(comment *models.Comment) int {
+	return sort.Search(len(list), func(i int) bool {
+		return !less(list[i], comment.CreatedAt, comment.ID)
+	})
+}
+
+// insert добавляет комментарий с сохранением порядка и возвращает изменённый список
+func (list orderedComments) insert(comment *models.Comment) orderedComments {
+	i := list.search(comment)
+	list = append(list, nil)
+	copy(list[i+1:], list[i:])
+	list[i] = comment
+	return list
+}
+
+// remove удаляет комментарий и возвращает изменённый список
+func (list orderedComments) remove(comment *models.Comment) orderedComments {
+	i := list.search(comment)
+	if i == len(list) || list[i].ID != comment.ID {
+		return list
+	}
+	copy(list[i:], list[i+1:])
+	list[len(list)-1] = nil
+	return list[:len(list)-1]
+}
+
+// page возвращает не более first комментариев, следующих строго после курсора after
+func (list orderedComments) page(first int64, after *repository.Cursor) *repository.CommentPage {
+	// Поиск первого комментария после курсора
+	startIndex := 0
+	if after != nil {
+		startIndex = sort.Search(len(list), func(i int) bool {
+			return after.After(list[i].CreatedAt, list[i].ID)
+		})
+	}
+
+	if first < 0 {
+		first = 0
+	}
+	endIndex := int64(startIndex) + first
+	if endIndex > int64(len(list)) {
+		endIndex = int64(len(list))
+	}
+
+	return &repository.CommentPage{
+		Comments:    copyComments(list[startIndex:endIndex]),
+		HasNextPage: endIndex < int64(len(list)),
+		TotalCount:  len(list),
+	}
+}
+
+// siblings возвращает индекс, в котором хранится комментарий, и ключ его списка:
+// комментарии верхнего уровня индексируются по ID поста, ответы - по ID родителя
+func (repo *InMemoryRepository) siblings(comment *models.Comment) (map[string]orderedComments, string) {
+	if comment.ParentID == nil {
+		return repo.topLevel, comment.PostID
+	}
+	return repo.replies, *comment.ParentID
+}
+
+// link добавляет комментарий в упорядоченный список его поста или родителя
+func (repo *InMemoryRepository) link(comment *models.Comment) {
+	lists, key := repo.siblings(comment)
+	lists[key] = lists[key].insert(comment)
+}
+
+// unlink удаляет комментарий из упорядоченного списка его поста или родителя
+func (repo *InMemoryRepository) unlink(comment *models.Comment) {
+	lists, key := repo.siblings(comment)
+	if list := lists[key].remove(comment); len(list) > 0 {
+		lists[key] = list
+	} else {
+		delete(lists, key)
+	}
+}
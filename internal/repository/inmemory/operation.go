@@ -0,0 +1,111 @@
+package inmemory
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+)
+
+// Виды операций, изменяющих состояние репозитория
+const (
+	opCreatePost        = "createPost"
+	opCreateComment     = "createComment"
+	opUpdatePost        = "updatePost"
+	opUpdateComment     = "updateComment"
+	opDeletePost        = "deletePost"
+	opDeleteComment     = "deleteComment"
+	opSoftDeleteComment = "softDeleteComment"
+	opVote              = "vote"
+	opReact             = "react"
+	opCreateUser        = "createUser"
+)
+
+// operation представляет собой проверенное изменение состояния репозитория.
+// Операция содержит все сгенерированные значения (ID, время), поэтому повторное применение
+// операций из журнала восстанавливает в точности то же состояние.
+type operation struct {
+	Seq              uint64                `json:"seq"`                        // Номер операции в журнале
+	Kind             string                `json:"op"`                         // Вид операции
+	ID               string                `json:"id"`                         // ID создаваемого или изменяемого объекта
+	At               time.Time             `json:"at"`                         // Время выполнения операции
+	UserID           string                `json:"userId,omitempty"`           // ID автора, редактора или голосующего пользователя
+	PostID           string                `json:"postId,omitempty"`           // ID поста комментария
+	ParentID         string                `json:"parentId,omitempty"`         // ID родительского комментария
+	Title            string                `json:"title,omitempty"`            // Заголовок поста
+	Content          string                `json:"content,omitempty"`          // Содержимое поста или комментария
+	CommentsDisabled bool                  `json:"commentsDisabled,omitempty"` // Флаг отключения комментариев
+	Target           repository.TargetKind `json:"target,omitempty"`           // Вид объекта голосования или реакции
+	Value            int                   `json:"value,omitempty"`            // Значение голоса
+	Emoji            string                `json:"emoji,omitempty"`            // Эмодзи реакции
+	Username         string                `json:"username,omitempty"`         // Имя пользователя
+	PasswordHash     string                `json:"passwordHash,omitempty"`     // Хеш пароля
+}
+
+// commit записывает операцию в журнал, если он включён, и применяет её к состоянию репозитория.
+// Если запись в журнал не удалась или репозиторий уже закрыт, состояние не изменяется: иначе операция,
+// выполненная после закрытия журнала, была бы подтверждена, но не сохранена. Вызывается под блокировкой writeMu,
+// а блокировку состояния захватывает только на время применения операции.
+func (repo *InMemoryRepository) commit(op *operation) error {
+	if repo.closed {
+		return ErrClosed
+	}
+	op.Seq = repo.seq + 1
+	if repo.wal != nil {
+		payload, err := json.Marshal(op)
+		if err != nil {
+			return fmt.Errorf("encode operation: %w", err)
+		}
+		if err := repo.wal.append(payload); err != nil {
+			return fmt.Errorf("write-ahead log: %w", err)
+		}
+	}
+	repo.mu.Lock()
+	err := repo.apply(op)
+	repo.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if repo.wal != nil {
+		repo.sinceSnapshot++
+		if repo.snapshotEvery > 0 && repo.sinceSnapshot >= repo.snapshotEvery {
+			// Операция уже сохранена в журнале, поэтому ошибка снимка не отменяет её
+			if err := repo.snapshot(); err != nil {
+				log.Println("Failed to write snapshot:", err)
+			}
+		}
+	}
+	return nil
+}
+
+// apply применяет операцию к состоянию репозитория
+func (repo *InMemoryRepository) apply(op *operation) error {
+	switch op.Kind {
+	case opCreatePost:
+		repo.createPost(op)
+	case opCreateComment:
+		repo.createComment(op)
+	case opUpdatePost:
+		repo.updatePost(op)
+	case opUpdateComment:
+		repo.updateComment(op)
+	case opDeletePost:
+		repo.deletePost(op)
+	case opDeleteComment:
+		repo.deleteComment(op)
+	case opSoftDeleteComment:
+		repo.softDeleteComment(op)
+	case opVote:
+		repo.vote(op)
+	case opReact:
+		repo.react(op)
+	case opCreateUser:
+		repo.createUser(op)
+	default:
+		return fmt.Errorf("unknown operation %q", op.Kind)
+	}
+	repo.seq = op.Seq
+	return nil
+}
@@ -0,0 +1,269 @@
+package inmemory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/nemopss/go-posts-comments-system/internal/models"
+)
+
+// Имена файлов в каталоге данных
+const (
+	snapshotFile = "snapshot"
+	walFile      = "wal"
+)
+
+// ErrClosed возвращается проверкой доступности и операциями изменения закрытого репозитория с сохранением на диск
+var ErrClosed = errors.New("in-memory storage is closed")
+
+// Options задаёт параметры сохранения репозитория на диск
+type Options struct {
+	Dir           string        // Каталог со снимком и журналом, создаётся при необходимости
+	Sync          SyncPolicy    // Политика сброса журнала на диск, по умолчанию SyncAlways
+	SyncInterval  time.Duration // Интервал сброса для SyncInterval, по умолчанию секунда
+	SnapshotEvery int           // Количество операций между снимками, 0 - снимки только при закрытии
+}
+
+// snapshot представляет собой полное состояние репозитория после операции с номером Seq
+type snapshot struct {
+	Seq              uint64                                `json:"seq"`
+	Posts            []*models.Post                        `json:"posts"`
+	Comments         []*models.Comment                     `json:"comments"`
+	Users            []*models.User                        `json:"users"`
+	PostRevisions    map[string][]*models.PostRevision     `json:"postRevisions"`
+	CommentRevisions map[string][]*models.CommentRevision  `json:"commentRevisions"`
+	Votes            map[string]map[string]int             `json:"votes"`
+	Reactions        map[string]map[string]map[string]bool `json:"reactions"`
+}
+
+// Open открывает репозиторий в памяти, сохраняющий изменения в каталоге options.Dir.
+// Каждая операция перед применением записывается в журнал, а состояние периодически
+// сохраняется в снимок, после чего журнал очищается. При открытии состояние восстанавливается
+// из последнего снимка и операций журнала, записанных после него.
+// Чтение по-прежнему выполняется только из памяти.
+func Open(options Options) (*InMemoryRepository, error) {
+	log.Println("Opening in-memory storage at", options.Dir)
+	if options.Sync == "" {
+		options.Sync = SyncAlways
+	}
+	if options.SyncInterval <= 0 {
+		options.SyncInterval = time.Second
+	}
+	if err := os.MkdirAll(options.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	repo := NewInMemoryRepository()
+	repo.dir = options.Dir
+	repo.snapshotEvery = options.SnapshotEvery
+	if err := repo.loadSnapshot(); err != nil {
+		return nil, fmt.Errorf("load snapshot: %w", err)
+	}
+
+	replayed := 0
+	wal, err := openWAL(filepath.Join(options.Dir, walFile), options.Sync, options.SyncInterval, func(payload []byte) error {
+		op := &operation{}
+		if err := json.Unmarshal(payload, op); err != nil {
+			return err
+		}
+		// Операции, уже сохранённые в снимке, пропускаются:
+		// журнал мог не успеть очиститься после записи снимка
+		if op.Seq <= repo.seq {
+			return nil
+		}
+		if op.Seq != repo.seq+1 {
+			return fmt.Errorf("operation %d follows operation %d", op.Seq, repo.seq)
+		}
+		replayed++
+		return repo.apply(op)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("open write-ahead log: %w", err)
+	}
+	repo.wal = wal
+	repo.sinceSnapshot = replayed
+	log.Printf("Restored in-memory storage at operation %d, %d operations replayed from the log\n", repo.seq, replayed)
+	return repo, nil
+}
+
+// Snapshot сохраняет полное состояние репозитория в снимок и очищает журнал.
+// Снимок записывается во временный файл, который затем атомарно заменяет предыдущий снимок.
+func (repo *InMemoryRepository) Snapshot() error {
+	repo.writeMu.Lock()
+	defer repo.writeMu.Unlock()
+	return repo.snapshot()
+}
+
+// snapshot сохраняет снимок под уже захваченной блокировкой writeMu. Состояние изменяется только
+// под этой блокировкой, поэтому запись снимка не мешает одновременному чтению.
+func (repo *InMemoryRepository) snapshot() error {
+	log.Println("Writing snapshot at operation", repo.seq)
+	if repo.wal == nil {
+		return errors.New("repository is not persistent")
+	}
+	payload, err := json.Marshal(repo.state())
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(repo.dir, snapshotFile)
+	tmp := path + ".tmp"
+	if err := writeFileSync(tmp, encodeRecord(payload)); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	if err := syncDir(repo.dir); err != nil {
+		return err
+	}
+	repo.sinceSnapshot = 0
+	return repo.wal.reset()
+}
+
+// Close сохраняет снимок и закрывает журнал. Для репозитория без сохранения на диск ничего не делает.
+func (repo *InMemoryRepository) Close() error {
+	repo.writeMu.Lock()
+	defer repo.writeMu.Unlock()
+	if repo.wal == nil {
+		return nil
+	}
+	log.Println("Closing in-memory storage at", repo.dir)
+	var err error
+	if repo.sinceSnapshot > 0 {
+		err = repo.snapshot()
+	}
+	if closeErr := repo.wal.close(); err == nil {
+		err = closeErr
+	}
+	repo.wal = nil
+	repo.mu.Lock()
+	repo.closed = true
+	repo.mu.Unlock()
+	return err
+}
+
+// state возвращает текущее состояние репозитория для записи в снимок
+func (repo *InMemoryRepository) state() *snapshot {
+	state := &snapshot{
+		Seq:              repo.seq,
+		Posts:            make([]*models.Post, 0, len(repo.posts)),
+		Comments:         make([]*models.Comment, 0, len(repo.comments)),
+		Users:            make([]*models.User, 0, len(repo.users)),
+		PostRevisions:    repo.postRevisions,
+		CommentRevisions: repo.commentRevisions,
+		Votes:            repo.votes,
+		Reactions:        repo.reactions,
+	}
+	for _, post := range repo.posts {
+		state.Posts = append(state.Posts, post)
+	}
+	for _, comment := range repo.comments {
+		// Дерево ответов восстанавливается по ParentID, поэтому дети в снимок не записываются
+		flat := *comment
+		flat.Children = nil
+		state.Comments = append(state.Comments, &flat)
+	}
+	for _, user := range repo.users {
+		state.Users = append(state.Users, user)
+	}
+	return state
+}
+
+// loadSnapshot восстанавливает состояние репозитория из снимка, если он есть
+func (repo *InMemoryRepository) loadSnapshot() error {
+	file, err := os.Open(filepath.Join(repo.dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	payload, err := readRecord(file)
+	if err == io.EOF {
+		return errCorruptRecord
+	}
+	if err != nil {
+		return err
+	}
+	state := &snapshot{}
+	if err := json.Unmarshal(payload, state); err != nil {
+		return err
+	}
+
+	repo.seq = state.Seq
+	for _, post := range state.Posts {
+		repo.posts[post.ID] = post
+		repo.indexPost(post)
+	}
+	for _, comment := range state.Comments {
+		// В снимках прежних версий у комментариев верхнего уровня был пустой ParentID
+		if comment.ParentID != nil && *comment.ParentID == "" {
+			comment.ParentID = nil
+		}
+		repo.comments[comment.ID] = comment
+		if comment.DeletedAt == nil {
+			repo.indexComment(comment)
+		}
+	}
+	// Комментарии добавляются в списки в порядке создания, поэтому каждый из них попадает в конец списка
+	sort.Slice(state.Comments, func(i, j int) bool {
+		return less(state.Comments[i], state.Comments[j].CreatedAt, state.Comments[j].ID)
+	})
+	for _, comment := range state.Comments {
+		repo.link(comment)
+	}
+	for _, user := range state.Users {
+		repo.users[user.ID] = user
+		repo.usernames[user.Username] = user.ID
+	}
+	if state.PostRevisions != nil {
+		repo.postRevisions = state.PostRevisions
+	}
+	if state.CommentRevisions != nil {
+		repo.commentRevisions = state.CommentRevisions
+	}
+	if state.Votes != nil {
+		repo.votes = state.Votes
+	}
+	if state.Reactions != nil {
+		repo.reactions = state.Reactions
+	}
+	return nil
+}
+
+// writeFileSync записывает данные в файл и сбрасывает его на диск
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// syncDir сбрасывает на диск каталог, чтобы переименование файла пережило сбой ОС
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
@@ -1,205 +1,985 @@
 package inmemory
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"log"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/nemopss/go-posts-comments-system/internal/models"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+	"github.com/nemopss/go-posts-comments-system/internal/search"
 )
 
 // InMemoryRepository представляет репозиторий, хранящий данные в памяти.
+// Операции изменения выполняются по одной под блокировкой writeMu: проверка, запись в журнал и снимки
+// не мешают чтению. Исключительная блокировка состояния mu захватывается только на время применения
+// операции к картам в памяти, а чтение выполняется под разделяемой блокировкой mu.
+// Методы возвращают копии постов, комментариев и пользователей, поэтому вызывающий код может читать
+// и изменять их после снятия блокировки, не затрагивая состояние репозитория.
 type InMemoryRepository struct {
-	posts    map[string]*models.Post    // Карта постов, где ключ - ID поста, а значение - пост
-	comments map[string]*models.Comment // Карта комментариев, где ключ - ID комментария, а значение - комментарий
+	writeMu sync.Mutex   // Упорядочивает операции изменения, запись журнала и снимков
+	mu      sync.RWMutex // Защищает состояние репозитория от чтения во время применения операции
+
+	posts     map[string]*models.Post    // Карта постов, где ключ - ID поста, а значение - пост
+	comments  map[string]*models.Comment // Карта комментариев, где ключ - ID комментария, а значение - комментарий
+	users     map[string]*models.User    // Карта пользователей, где ключ - ID пользователя, а значение - пользователь
+	usernames map[string]string          // Индекс имён пользователей, где ключ - имя, а значение - ID пользователя
+
+	topLevel map[string]orderedComments // Упорядоченные комментарии верхнего уровня, где ключ - ID поста
+	replies  map[string]orderedComments // Упорядоченные ответы, где ключ - ID родительского комментария
+
+	postRevisions    map[string][]*models.PostRevision    // Версии постов, где ключ - ID поста
+	commentRevisions map[string][]*models.CommentRevision // Версии комментариев, где ключ - ID комментария
+
+	votes     map[string]map[string]int             // Голоса, где ключ - объект голосования, а значение - голоса пользователей по их ID
+	reactions map[string]map[string]map[string]bool // Реакции, где ключ - объект, а значение - множества ID пользователей по эмодзи
+
+	postIndex    *search.Index // Полнотекстовый индекс постов
+	commentIndex *search.Index // Полнотекстовый индекс комментариев
+
+	wal           *writeAheadLog // Журнал операций, nil для репозитория без сохранения на диск
+	dir           string         // Каталог со снимком и журналом
+	seq           uint64         // Номер последней применённой операции
+	snapshotEvery int            // Количество операций между снимками, 0 - снимки только при закрытии
+	sinceSnapshot int            // Количество операций после последнего снимка
+	closed        bool           // Репозиторий с сохранением на диск закрыт, изменяется под блокировками writeMu и mu
 }
 
 // NewInMemoryRepository создает новый репозиторий в памяти.
 func NewInMemoryRepository() *InMemoryRepository {
 	return &InMemoryRepository{
-		posts:    make(map[string]*models.Post),    // Инициализация карты постов
-		comments: make(map[string]*models.Comment), // Инициализация карты комментариев
+		posts:     make(map[string]*models.Post),    // Инициализация карты постов
+		comments:  make(map[string]*models.Comment), // Инициализация карты комментариев
+		users:     make(map[string]*models.User),    // Инициализация карты пользователей
+		usernames: make(map[string]string),          // Инициализация индекса имён пользователей
+
+		topLevel: make(map[string]orderedComments), // Инициализация комментариев верхнего уровня
+		replies:  make(map[string]orderedComments), // Инициализация ответов
+
+		postRevisions:    make(map[string][]*models.PostRevision),    // Инициализация версий постов
+		commentRevisions: make(map[string][]*models.CommentRevision), // Инициализация версий комментариев
+
+		votes:     make(map[string]map[string]int),             // Инициализация голосов
+		reactions: make(map[string]map[string]map[string]bool), // Инициализация реакций
+
+		postIndex:    search.NewIndex(),
+		commentIndex: search.NewIndex(),
+	}
+}
+
+// now возвращает текущее время с точностью до микросекунд, как его хранит PostgreSQL,
+// чтобы курсоры пагинации вели себя одинаково во всех хранилищах
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// copyPost возвращает копию поста
+func copyPost(post *models.Post) *models.Post {
+	copied := *post
+	return &copied
+}
+
+// copyComment возвращает копию комментария без списка детей: ответы хранятся в упорядоченных списках репозитория
+func copyComment(comment *models.Comment) *models.Comment {
+	copied := *comment
+	copied.Children = nil
+	return &copied
+}
+
+// copyComments возвращает копии комментариев
+func copyComments(comments []*models.Comment) []*models.Comment {
+	copied := make([]*models.Comment, len(comments))
+	for i, comment := range comments {
+		copied[i] = copyComment(comment)
 	}
+	return copied
+}
+
+// copyUser возвращает копию пользователя
+func copyUser(user *models.User) *models.User {
+	copied := *user
+	return &copied
 }
 
 // GetPosts возвращает все посты из репозитория.
-func (repo *InMemoryRepository) GetPosts() ([]*models.Post, error) {
+func (repo *InMemoryRepository) GetPosts(ctx context.Context) ([]*models.Post, error) {
 	log.Println("Querying posts...")
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
 	posts := []*models.Post{}
 	for _, post := range repo.posts {
-		posts = append(posts, post) // Добавление поста в список
+		posts = append(posts, copyPost(post)) // Добавление копии поста в список
 	}
 	return posts, nil
 }
 
+// ListPosts возвращает страницу постов, отобранных и упорядоченных согласно параметрам запроса
+func (repo *InMemoryRepository) ListPosts(ctx context.Context, query repository.PostsQuery) (*repository.PostPage, error) {
+	log.Println("Listing posts ordered by", query.OrderBy)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	if query.OrderBy == "" {
+		query.OrderBy = repository.PostOrderCreatedAt
+	}
+
+	// Отбор постов по фильтру
+	posts := []*models.Post{}
+	for _, post := range repo.posts {
+		if matchesFilter(post, query.Filter) {
+			posts = append(posts, post)
+		}
+	}
+
+	// Сортировка по убыванию ключа сортировки, при совпадении ключа - по убыванию ID
+	sort.Slice(posts, func(i, j int) bool {
+		return repository.PostCursorOf(posts[i], query.OrderBy).Precedes(posts[j])
+	})
+
+	// Поиск первого поста после курсора
+	startIndex := 0
+	if query.After != nil {
+		startIndex = sort.Search(len(posts), func(i int) bool {
+			return query.After.Precedes(posts[i])
+		})
+	}
+
+	first := query.First
+	if first < 0 {
+		first = 0
+	}
+	endIndex := int64(startIndex) + first
+	if endIndex > int64(len(posts)) {
+		endIndex = int64(len(posts))
+	}
+
+	page := make([]*models.Post, 0, endIndex-int64(startIndex))
+	for _, post := range posts[startIndex:endIndex] {
+		page = append(page, copyPost(post))
+	}
+	return &repository.PostPage{
+		Posts:       page,
+		HasNextPage: endIndex < int64(len(posts)),
+		TotalCount:  len(posts),
+	}, nil
+}
+
+// matchesFilter проверяет, удовлетворяет ли пост условиям фильтра
+func matchesFilter(post *models.Post, filter repository.PostFilter) bool {
+	if filter.CommentsDisabled != nil && post.CommentsDisabled != *filter.CommentsDisabled {
+		return false
+	}
+	if filter.CreatedAfter != nil && !post.CreatedAt.After(*filter.CreatedAfter) {
+		return false
+	}
+	if filter.CreatedBefore != nil && !post.CreatedAt.Before(*filter.CreatedBefore) {
+		return false
+	}
+	if filter.TitleContains != nil && !strings.Contains(strings.ToLower(post.Title), strings.ToLower(*filter.TitleContains)) {
+		return false
+	}
+	return true
+}
+
 // GetPost возвращает пост по его ID. Если пост не найден, возвращает ошибку.
-func (repo *InMemoryRepository) GetPost(id string) (*models.Post, error) {
+func (repo *InMemoryRepository) GetPost(ctx context.Context, id string) (*models.Post, error) {
 	log.Println("Querying post with ID:", id)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
 	post, ok := repo.posts[id]
 	if !ok {
-		return nil, errors.New("Post not found")
+		return nil, repository.NotFound(repository.EntityPost, id)
 	}
-	return post, nil
+	return copyPost(post), nil
 }
 
 // CreatePost создает новый пост и добавляет его в репозиторий.
-func (repo *InMemoryRepository) CreatePost(title, content string, commentsDisabled bool) (*models.Post, error) {
+func (repo *InMemoryRepository) CreatePost(ctx context.Context, authorId, title, content string, commentsDisabled bool) (*models.Post, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.writeMu.Lock()
+	defer repo.writeMu.Unlock()
 	id := uuid.New().String() // Генерация нового уникального ID для поста
 	log.Println("Creating post with ID:", id)
-	createdAt := time.Now() // Текущее время как время создания поста
-	post := &models.Post{
+	err := repo.commit(&operation{
+		Kind:             opCreatePost,
 		ID:               id,
+		At:               now(), // Текущее время как время создания поста
+		UserID:           authorId,
 		Title:            title,
 		Content:          content,
 		CommentsDisabled: commentsDisabled,
-		CreatedAt:        createdAt,
+	})
+	if err != nil {
+		return nil, err
 	}
-	repo.posts[id] = post // Добавление поста в карту постов
-	return post, nil
+	return copyPost(repo.posts[id]), nil
+}
+
+// createPost применяет операцию создания поста
+func (repo *InMemoryRepository) createPost(op *operation) {
+	post := &models.Post{
+		ID:               op.ID,
+		AuthorID:         op.UserID,
+		Title:            op.Title,
+		Content:          op.Content,
+		CommentsDisabled: op.CommentsDisabled,
+		CreatedAt:        op.At,
+		LastActivityAt:   op.At,
+	}
+	repo.posts[op.ID] = post // Добавление поста в карту постов
+	repo.addPostRevision(post, op.UserID, op.At)
+	repo.indexPost(post)
+}
+
+// addPostRevision сохраняет текущее состояние поста как его новую версию
+func (repo *InMemoryRepository) addPostRevision(post *models.Post, editorId string, createdAt time.Time) {
+	repo.postRevisions[post.ID] = append(repo.postRevisions[post.ID], &models.PostRevision{
+		PostID:           post.ID,
+		Revision:         len(repo.postRevisions[post.ID]) + 1,
+		Title:            post.Title,
+		Content:          post.Content,
+		CommentsDisabled: post.CommentsDisabled,
+		EditorID:         editorId,
+		CreatedAt:        createdAt,
+	})
 }
 
 // CreateComment создает новый комментарий и добавляет его в репозиторий.
-func (repo *InMemoryRepository) CreateComment(postId, parentId, content string) (*models.Comment, error) {
-	if repo.posts[postId].CommentsDisabled == true {
-		return nil, errors.New("Comments are disabled on this post!")
+func (repo *InMemoryRepository) CreateComment(ctx context.Context, authorId, postId, parentId, content string) (*models.Comment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	// Ограничение в 2000 символов на комментарий
-	if len(content) > 2000 {
-		return nil, errors.New("комментарий не может превышать 2000 символов")
+	repo.writeMu.Lock()
+	defer repo.writeMu.Unlock()
+	if err := repository.ValidateNewComment(postId, parentId, content, repo.commentTarget(postId, parentId)); err != nil {
+		return nil, err
 	}
 	id := uuid.New().String() // Генерация нового уникального ID для комментария
 	log.Println("Creating comment with ID:", id)
-	createdAt := time.Now() // Текущее время как время создания комментария
+	err := repo.commit(&operation{
+		Kind:     opCreateComment,
+		ID:       id,
+		At:       now(), // Текущее время как время создания комментария
+		UserID:   authorId,
+		PostID:   postId,
+		ParentID: parentId,
+		Content:  content,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copyComment(repo.comments[id]), nil
+}
+
+// commentTarget собирает сведения о посте и родительском комментарии для проверки нового комментария
+func (repo *InMemoryRepository) commentTarget(postId, parentId string) repository.CommentTarget {
+	target := repository.CommentTarget{}
+	if post, ok := repo.posts[postId]; ok {
+		target.PostFound = true
+		target.CommentsDisabled = post.CommentsDisabled
+	}
+	if parent, ok := repo.comments[parentId]; ok {
+		target.ParentFound = true
+		target.ParentPostID = parent.PostID
+		target.ParentDeleted = parent.DeletedAt != nil
+	}
+	return target
+}
+
+// createComment применяет операцию создания комментария
+func (repo *InMemoryRepository) createComment(op *operation) {
 	comment := &models.Comment{
-		ID:        id,
-		PostID:    postId,
-		ParentID:  &parentId,
-		Content:   content,
+		ID:        op.ID,
+		PostID:    op.PostID,
+		AuthorID:  op.UserID,
+		Content:   op.Content,
+		CreatedAt: op.At,
+	}
+	if op.ParentID != "" {
+		parentId := op.ParentID
+		comment.ParentID = &parentId
+	}
+	repo.comments[op.ID] = comment // Добавление комментария в карту комментариев
+	repo.addCommentRevision(comment, op.UserID, op.At)
+	repo.indexComment(comment)
+	repo.link(comment) // Добавление комментария в список его поста или родительского комментария
+	// Обновление счётчика комментариев и времени последней активности поста
+	repo.posts[op.PostID].CommentCount++
+	repo.posts[op.PostID].LastActivityAt = op.At
+}
+
+// addCommentRevision сохраняет текущее состояние комментария как его новую версию
+func (repo *InMemoryRepository) addCommentRevision(comment *models.Comment, editorId string, createdAt time.Time) {
+	repo.commentRevisions[comment.ID] = append(repo.commentRevisions[comment.ID], &models.CommentRevision{
+		CommentID: comment.ID,
+		Revision:  len(repo.commentRevisions[comment.ID]) + 1,
+		Content:   comment.Content,
+		EditorID:  editorId,
 		CreatedAt: createdAt,
+	})
+}
+
+// UpdatePost изменяет пост и сохраняет его новую версию
+func (repo *InMemoryRepository) UpdatePost(ctx context.Context, editorId, id, title, content string, commentsDisabled bool) (*models.Post, error) {
+	log.Println("Updating post with ID:", id)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.writeMu.Lock()
+	defer repo.writeMu.Unlock()
+	if _, ok := repo.posts[id]; !ok {
+		return nil, repository.NotFound(repository.EntityPost, id)
 	}
-	repo.comments[id] = comment // Добавление комментария в карту комментариев
-	if parentId != "" {
-		repo.comments[parentId].Children = append(repo.comments[parentId].Children, comment) // Добавление комментария в список детей родительского комментария
+	err := repo.commit(&operation{
+		Kind:             opUpdatePost,
+		ID:               id,
+		At:               now(),
+		UserID:           editorId,
+		Title:            title,
+		Content:          content,
+		CommentsDisabled: commentsDisabled,
+	})
+	if err != nil {
+		return nil, err
 	}
-	return comment, nil
+	return copyPost(repo.posts[id]), nil
 }
 
-// GetCommentsByPostID возвращает список комментариев для указанного поста с пагинацией
-func (repo *InMemoryRepository) GetCommentsByPostID(postId string, first int64, after *string) ([]*models.Comment, error) {
-	log.Println("Getting comments on post with ID:", postId)
-	var allComments []*models.Comment
+// updatePost применяет операцию изменения поста
+func (repo *InMemoryRepository) updatePost(op *operation) {
+	post := repo.posts[op.ID]
+	editedAt := op.At
+	post.Title = op.Title
+	post.Content = op.Content
+	post.CommentsDisabled = op.CommentsDisabled
+	post.EditedAt = &editedAt
+	repo.addPostRevision(post, op.UserID, editedAt)
+	repo.indexPost(post)
+}
 
-	comments := []*models.Comment{}
-	for _, comment := range repo.comments {
-		if comment.PostID == postId && (comment.ParentID == nil || *comment.ParentID == "") {
-			comments = append(comments, comment) // Добавление комментария в список
-		}
+// UpdateComment изменяет комментарий и сохраняет его новую версию
+func (repo *InMemoryRepository) UpdateComment(ctx context.Context, editorId, id, content string) (*models.Comment, error) {
+	log.Println("Updating comment with ID:", id)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.writeMu.Lock()
+	defer repo.writeMu.Unlock()
+	// Ограничение длины комментария
+	if err := repository.ValidateCommentContent(content); err != nil {
+		return nil, err
+	}
+	comment, ok := repo.comments[id]
+	if !ok {
+		return nil, repository.NotFound(repository.EntityComment, id)
+	}
+	if comment.DeletedAt != nil {
+		return nil, repository.ErrCommentDeleted
 	}
+	err := repo.commit(&operation{Kind: opUpdateComment, ID: id, At: now(), UserID: editorId, Content: content})
+	if err != nil {
+		return nil, err
+	}
+	return copyComment(comment), nil
+}
 
-	// Сортировка комментариев по времени создания
-	sort.Slice(allComments, func(i, j int) bool {
-		return allComments[i].CreatedAt.Before(allComments[j].CreatedAt)
-	})
+// updateComment применяет операцию изменения комментария
+func (repo *InMemoryRepository) updateComment(op *operation) {
+	comment := repo.comments[op.ID]
+	editedAt := op.At
+	comment.Content = op.Content
+	comment.EditedAt = &editedAt
+	repo.addCommentRevision(comment, op.UserID, editedAt)
+	repo.indexComment(comment)
+}
 
-	// Применение пагинации
-	startIndex := 0
-	if after != nil {
-		for i, comment := range comments {
-			if comment.ID > *after {
-				startIndex = i + 1
-				break
-			}
-		}
+// GetPostRevisions возвращает все версии поста в порядке возрастания номера
+func (repo *InMemoryRepository) GetPostRevisions(ctx context.Context, postId string) ([]*models.PostRevision, error) {
+	log.Println("Getting revisions of post with ID:", postId)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	if _, ok := repo.posts[postId]; !ok {
+		return nil, repository.NotFound(repository.EntityPost, postId)
 	}
+	// Версии не изменяются после создания, поэтому копируется только список
+	revisions := make([]*models.PostRevision, len(repo.postRevisions[postId]))
+	copy(revisions, repo.postRevisions[postId])
+	return revisions, nil
+}
 
-	endIndex := int64(startIndex) + first
-	if endIndex > int64(len(comments)) {
-		endIndex = int64(len(comments))
+// GetCommentRevisions возвращает все версии комментария в порядке возрастания номера
+func (repo *InMemoryRepository) GetCommentRevisions(ctx context.Context, commentId string) ([]*models.CommentRevision, error) {
+	log.Println("Getting revisions of comment with ID:", commentId)
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	if _, ok := repo.comments[commentId]; !ok {
+		return nil, repository.NotFound(repository.EntityComment, commentId)
+	}
+	// Версии не изменяются после создания, поэтому копируется только список
+	revisions := make([]*models.CommentRevision, len(repo.commentRevisions[commentId]))
+	copy(revisions, repo.commentRevisions[commentId])
+	return revisions, nil
+}
 
-	return comments[startIndex:endIndex], nil
+// GetComment возвращает комментарий по его ID. Если комментарий не найден, возвращает ошибку.
+func (repo *InMemoryRepository) GetComment(ctx context.Context, id string) (*models.Comment, error) {
+	log.Println("Querying comment with ID:", id)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	comment, ok := repo.comments[id]
+	if !ok {
+		return nil, repository.NotFound(repository.EntityComment, id)
+	}
+	return copyComment(comment), nil
+}
 
+// GetCommentsByPostID возвращает страницу комментариев верхнего уровня для указанного поста
+func (repo *InMemoryRepository) GetCommentsByPostID(ctx context.Context, postId string, first int64, after *repository.Cursor) (*repository.CommentPage, error) {
+	log.Println("Getting comments on post with ID:", postId)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	return repo.topLevel[postId].page(first, after), nil
 }
 
-// GetCommentsByParentID возвращает список дочерних комментариев для указанного комментария с пагинацией
-func (repo *InMemoryRepository) GetCommentsByParentID(parentId string, first int64, after *string) ([]*models.Comment, error) {
+// GetCommentsByParentID возвращает страницу дочерних комментариев для указанного комментария
+func (repo *InMemoryRepository) GetCommentsByParentID(ctx context.Context, parentId string, first int64, after *repository.Cursor) (*repository.CommentPage, error) {
 	log.Println("Getting comments from parent with ID:", parentId)
-	parentComment, ok := repo.comments[parentId]
-	if !ok {
-		return nil, fmt.Errorf("comment with id %s not found", parentId)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	if _, ok := repo.comments[parentId]; !ok {
+		return nil, repository.NotFound(repository.EntityComment, parentId)
 	}
+	return repo.replies[parentId].page(first, after), nil
+}
 
-	// Применение пагинации
-	startIndex := 0
-	if after != nil {
-		for i, comment := range parentComment.Children {
-			if comment.ID > *after {
-				startIndex = i + 1
-				break
-			}
-		}
+// GetCommentsByPostIDs возвращает страницы комментариев верхнего уровня для нескольких постов
+func (repo *InMemoryRepository) GetCommentsByPostIDs(ctx context.Context, postIds []string, first int64, after *repository.Cursor) (map[string]*repository.CommentPage, error) {
+	log.Println("Getting comments on posts with IDs:", postIds)
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	pages := make(map[string]*repository.CommentPage, len(postIds))
+	for _, id := range postIds {
+		pages[id] = repo.topLevel[id].page(first, after)
+	}
+	return pages, nil
+}
 
-	endIndex := int64(startIndex) + first
-	if int(endIndex) > len(parentComment.Children) {
-		endIndex = int64(len(parentComment.Children))
+// GetCommentsByParentIDs возвращает страницы дочерних комментариев для нескольких комментариев.
+// Для несуществующего комментария возвращается пустая страница.
+func (repo *InMemoryRepository) GetCommentsByParentIDs(ctx context.Context, parentIds []string, first int64, after *repository.Cursor) (map[string]*repository.CommentPage, error) {
+	log.Println("Getting comments from parents with IDs:", parentIds)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	pages := make(map[string]*repository.CommentPage, len(parentIds))
+	for _, id := range parentIds {
+		pages[id] = repo.replies[id].page(first, after)
+	}
+	return pages, nil
+}
+
+// GetCommentAncestors возвращает цепочку предков комментария, поднимаясь от родителя к комментарию верхнего уровня
+func (repo *InMemoryRepository) GetCommentAncestors(ctx context.Context, id string) ([]*models.Comment, error) {
+	log.Println("Getting ancestors of comment with ID:", id)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	comment, ok := repo.comments[id]
+	if !ok {
+		return nil, repository.NotFound(repository.EntityComment, id)
+	}
+	ancestors := []*models.Comment{}
+	for comment.ParentID != nil {
+		comment = repo.comments[*comment.ParentID]
+		ancestors = append(ancestors, copyComment(comment))
 	}
+	// Цепочка собрана от родителя, а возвращается от комментария верхнего уровня
+	for i, j := 0, len(ancestors)-1; i < j; i, j = i+1, j-1 {
+		ancestors[i], ancestors[j] = ancestors[j], ancestors[i]
+	}
+	return ancestors, nil
+}
 
-	return parentComment.Children[startIndex:endIndex], nil
+// GetCommentThread возвращает дерево комментариев поста, обходя его в глубину от комментариев верхнего уровня
+func (repo *InMemoryRepository) GetCommentThread(ctx context.Context, query repository.ThreadQuery) ([]*repository.ThreadComment, error) {
+	log.Println("Getting comment thread of post with ID:", query.PostID)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	if _, ok := repo.posts[query.PostID]; !ok {
+		return nil, repository.NotFound(repository.EntityPost, query.PostID)
+	}
+
+	thread := []*repository.ThreadComment{}
+	var walk func(comments orderedComments, depth int, path []string)
+	walk = func(comments orderedComments, depth int, path []string) {
+		for _, comment := range comments {
+			if query.First >= 0 && int64(len(thread)) >= query.First {
+				return
+			}
+			// Путь копируется, чтобы ответы не перезаписывали пути друг друга
+			commentPath := append(append(make([]string, 0, len(path)+1), path...), comment.ID)
+			thread = append(thread, &repository.ThreadComment{Comment: copyComment(comment), Depth: depth, Path: commentPath})
+			if query.MaxDepth < 0 || depth < query.MaxDepth {
+				walk(repo.replies[comment.ID], depth+1, commentPath)
+			}
+		}
+	}
+	walk(repo.topLevel[query.PostID], 0, nil)
+	return thread, nil
 }
 
 // DeletePost удаляет пост по его ID
-func (repo *InMemoryRepository) DeletePost(id string) error {
+func (repo *InMemoryRepository) DeletePost(ctx context.Context, id string) error {
 	log.Println("Deleting post with ID:", id)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	repo.writeMu.Lock()
+	defer repo.writeMu.Unlock()
 	_, ok := repo.posts[id]
 	if !ok {
-		return errors.New("Post not found")
+		return repository.NotFound(repository.EntityPost, id)
 	}
+	return repo.commit(&operation{Kind: opDeletePost, ID: id, At: now()})
+}
 
-	// Удаление всех комментариев к этому посту
-	for commentID, comment := range repo.comments {
-		if comment.PostID == id {
-			delete(repo.comments, commentID)
-		}
+// deletePost применяет операцию удаления поста
+func (repo *InMemoryRepository) deletePost(op *operation) {
+	id := op.ID
+
+	// Удаление всех комментариев к этому посту: каждый из них входит в дерево одного из комментариев верхнего уровня
+	for _, comment := range repo.topLevel[id] {
+		repo.removeSubtree(comment)
 	}
+	delete(repo.topLevel, id)
 
-	// Удаление самого поста
+	// Удаление самого поста, его версий, голосов и реакций
 	delete(repo.posts, id)
-	return nil
+	delete(repo.postRevisions, id)
+	repo.deleteVotesAndReactions(repository.TargetPost, id)
+	repo.postIndex.Remove(id)
 }
 
 // DeleteComment удаляет комментарий по его ID
-func (repo *InMemoryRepository) DeleteComment(id string) error {
+func (repo *InMemoryRepository) DeleteComment(ctx context.Context, id string) error {
 	log.Println("Deleting comment with ID:", id)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	repo.writeMu.Lock()
+	defer repo.writeMu.Unlock()
+	if _, ok := repo.comments[id]; !ok {
+		return repository.NotFound(repository.EntityComment, id)
+	}
+	return repo.commit(&operation{Kind: opDeleteComment, ID: id, At: now()})
+}
+
+// deleteComment применяет операцию удаления комментария
+func (repo *InMemoryRepository) deleteComment(op *operation) {
+	id := op.ID
+	comment := repo.comments[id]
+
+	// Удаление комментария вместе с ответами на любой глубине
+	deleted := repo.removeSubtree(comment)
+	// Удаление комментария из списка его поста или родительского комментария
+	repo.unlink(comment)
+
+	// Обновление счётчика комментариев поста
+	if post, ok := repo.posts[comment.PostID]; ok {
+		post.CommentCount -= deleted
+	}
+}
+
+// removeSubtree удаляет комментарий и все ответы на него вместе с их версиями, голосами и реакциями.
+// Сам комментарий остаётся в списке своего поста или родителя. Возвращает количество удалённых комментариев.
+func (repo *InMemoryRepository) removeSubtree(comment *models.Comment) int {
+	deleted := 1
+	for _, child := range repo.replies[comment.ID] {
+		deleted += repo.removeSubtree(child)
+	}
+	delete(repo.replies, comment.ID)
+	delete(repo.comments, comment.ID)
+	delete(repo.commentRevisions, comment.ID)
+	repo.deleteVotesAndReactions(repository.TargetComment, comment.ID)
+	repo.commentIndex.Remove(comment.ID)
+	return deleted
+}
+
+// SoftDeleteComment заменяет комментарий надгробием, сохраняя ответы на него
+func (repo *InMemoryRepository) SoftDeleteComment(ctx context.Context, id string) (*models.Comment, error) {
+	log.Println("Soft deleting comment with ID:", id)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.writeMu.Lock()
+	defer repo.writeMu.Unlock()
 	comment, ok := repo.comments[id]
 	if !ok {
-		return errors.New("Comment not found")
+		return nil, repository.NotFound(repository.EntityComment, id)
+	}
+	if comment.DeletedAt == nil {
+		if err := repo.commit(&operation{Kind: opSoftDeleteComment, ID: id, At: now()}); err != nil {
+			return nil, err
+		}
+	}
+	return copyComment(comment), nil
+}
+
+// softDeleteComment применяет операцию замены комментария надгробием
+func (repo *InMemoryRepository) softDeleteComment(op *operation) {
+	comment := repo.comments[op.ID]
+	deletedAt := op.At
+	comment.Content = ""
+	comment.DeletedAt = &deletedAt
+	delete(repo.commentRevisions, op.ID)
+	repo.commentIndex.Remove(op.ID)
+}
+
+// targetKey возвращает ключ объекта голосования в картах голосов и реакций
+func targetKey(kind repository.TargetKind, id string) string {
+	return string(kind) + ":" + id
+}
+
+// voteCounters возвращает счётчики голосов за и против поста или комментария.
+// За удалённый комментарий голосовать и реагировать на него нельзя.
+func (repo *InMemoryRepository) voteCounters(kind repository.TargetKind, id string) (*int, *int, error) {
+	switch kind {
+	case repository.TargetPost:
+		post, ok := repo.posts[id]
+		if !ok {
+			return nil, nil, repository.NotFound(repository.EntityPost, id)
+		}
+		return &post.Upvotes, &post.Downvotes, nil
+	case repository.TargetComment:
+		comment, ok := repo.comments[id]
+		if !ok {
+			return nil, nil, repository.NotFound(repository.EntityComment, id)
+		}
+		if comment.DeletedAt != nil {
+			return nil, nil, repository.ErrCommentDeleted
+		}
+		return &comment.Upvotes, &comment.Downvotes, nil
+	}
+	return nil, nil, fmt.Errorf("unknown target kind %q", kind)
+}
+
+// Vote устанавливает голос пользователя за пост или комментарий и обновляет счётчики голосов
+func (repo *InMemoryRepository) Vote(ctx context.Context, kind repository.TargetKind, targetId, userId string, value int) error {
+	log.Printf("Voting %d for %s with ID: %s\n", value, kind, targetId)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	repo.writeMu.Lock()
+	defer repo.writeMu.Unlock()
+	if value < repository.VoteDown || value > repository.VoteUp {
+		return repository.ErrInvalidVote
+	}
+	if _, _, err := repo.voteCounters(kind, targetId); err != nil {
+		return err
+	}
+	return repo.commit(&operation{Kind: opVote, ID: targetId, At: now(), UserID: userId, Target: kind, Value: value})
+}
+
+// vote применяет операцию голосования
+func (repo *InMemoryRepository) vote(op *operation) {
+	upvotes, downvotes, _ := repo.voteCounters(op.Target, op.ID)
+	key := targetKey(op.Target, op.ID)
+	if repo.votes[key] == nil {
+		repo.votes[key] = make(map[string]int)
+	}
+	upDelta, downDelta := repository.VoteDelta(repo.votes[key][op.UserID], op.Value)
+	*upvotes += upDelta
+	*downvotes += downDelta
+	if op.Value == repository.VoteNone {
+		delete(repo.votes[key], op.UserID)
+	} else {
+		repo.votes[key][op.UserID] = op.Value
+	}
+}
+
+// GetVote возвращает голос пользователя за пост или комментарий
+func (repo *InMemoryRepository) GetVote(ctx context.Context, kind repository.TargetKind, targetId, userId string) (int, error) {
+	log.Printf("Querying vote for %s with ID: %s\n", kind, targetId)
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	return repo.votes[targetKey(kind, targetId)][userId], nil
+}
+
+// React добавляет или снимает реакцию пользователя на пост или комментарий
+func (repo *InMemoryRepository) React(ctx context.Context, kind repository.TargetKind, targetId, userId, emoji string) (bool, error) {
+	log.Printf("Reacting %s to %s with ID: %s\n", emoji, kind, targetId)
+	if err := ctx.Err(); err != nil {
+		return false, err
 	}
+	repo.writeMu.Lock()
+	defer repo.writeMu.Unlock()
+	if _, _, err := repo.voteCounters(kind, targetId); err != nil {
+		return false, err
+	}
+	err := repo.commit(&operation{Kind: opReact, ID: targetId, At: now(), UserID: userId, Target: kind, Emoji: emoji})
+	if err != nil {
+		return false, err
+	}
+	return repo.reactions[targetKey(kind, targetId)][emoji][userId], nil
+}
+
+// react применяет операцию добавления или снятия реакции
+func (repo *InMemoryRepository) react(op *operation) {
+	key := targetKey(op.Target, op.ID)
+	if repo.reactions[key] == nil {
+		repo.reactions[key] = make(map[string]map[string]bool)
+	}
+	users := repo.reactions[key][op.Emoji]
+	if users[op.UserID] {
+		delete(users, op.UserID)
+		if len(users) == 0 {
+			delete(repo.reactions[key], op.Emoji)
+		}
+		return
+	}
+	if users == nil {
+		users = make(map[string]bool)
+		repo.reactions[key][op.Emoji] = users
+	}
+	users[op.UserID] = true
+}
+
+// GetReactions возвращает сводку реакций на пост или комментарий
+func (repo *InMemoryRepository) GetReactions(ctx context.Context, kind repository.TargetKind, targetId, viewerId string) ([]*models.Reaction, error) {
+	log.Printf("Querying reactions to %s with ID: %s\n", kind, targetId)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	reactions := []*models.Reaction{}
+	for emoji, users := range repo.reactions[targetKey(kind, targetId)] {
+		reactions = append(reactions, &models.Reaction{
+			Emoji:            emoji,
+			Count:            len(users),
+			ViewerHasReacted: users[viewerId],
+		})
+	}
+	// Сортировка по убыванию количества, при равенстве - по эмодзи
+	sort.Slice(reactions, func(i, j int) bool {
+		if reactions[i].Count != reactions[j].Count {
+			return reactions[i].Count > reactions[j].Count
+		}
+		return reactions[i].Emoji < reactions[j].Emoji
+	})
+	return reactions, nil
+}
 
-	// Удаление всех дочерних комментариев
-	for _, childComment := range repo.comments {
-		if childComment.ParentID != nil && *childComment.ParentID == id {
-			delete(repo.comments, childComment.ID)
+// deleteVotesAndReactions удаляет голоса и реакции удалённого поста или комментария
+func (repo *InMemoryRepository) deleteVotesAndReactions(kind repository.TargetKind, id string) {
+	key := targetKey(kind, id)
+	delete(repo.votes, key)
+	delete(repo.reactions, key)
+}
+
+// indexPost добавляет пост в полнотекстовый индекс или обновляет его
+func (repo *InMemoryRepository) indexPost(post *models.Post) {
+	repo.postIndex.Add(post.ID,
+		search.Field{Text: post.Title, Weight: search.WeightTitle},
+		search.Field{Text: post.Content, Weight: search.WeightContent},
+	)
+}
+
+// indexComment добавляет комментарий в полнотекстовый индекс или обновляет его
+func (repo *InMemoryRepository) indexComment(comment *models.Comment) {
+	repo.commentIndex.Add(comment.ID, search.Field{Text: comment.Content, Weight: search.WeightContent})
+}
+
+// Search выполняет полнотекстовый поиск по инвертированным индексам постов и комментариев
+func (repo *InMemoryRepository) Search(ctx context.Context, query repository.SearchQuery) (*repository.SearchPage, error) {
+	log.Println("Searching for", query.Query)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	hits := []*repository.SearchHit{}
+	if query.Includes(repository.TargetPost) {
+		for _, match := range repo.postIndex.Search(query.Query) {
+			hits = append(hits, &repository.SearchHit{Kind: repository.TargetPost, Post: copyPost(repo.posts[match.ID]), Rank: match.Rank})
 		}
 	}
-	// Удаление самого комментария
-	delete(repo.comments, id)
-
-	// Обновление списка детей родительского комментария, если он есть
-	if comment.ParentID != nil {
-		parentComment, ok := repo.comments[*comment.ParentID]
-		if ok {
-			for i, child := range parentComment.Children {
-				if child.ID == id {
-					parentComment.Children = append(parentComment.Children[:i], parentComment.Children[i+1:]...)
-					break
-				}
+	if query.Includes(repository.TargetComment) {
+		for _, match := range repo.commentIndex.Search(query.Query) {
+			hits = append(hits, &repository.SearchHit{Kind: repository.TargetComment, Comment: copyComment(repo.comments[match.ID]), Rank: match.Rank})
+		}
+	}
+
+	// Сортировка по убыванию релевантности, при равной релевантности - по убыванию ID
+	sort.Slice(hits, func(i, j int) bool {
+		return repository.SearchCursorOf(hits[i]).Precedes(hits[j].Rank, hits[j].ID())
+	})
+
+	// Поиск первого результата после курсора
+	startIndex := 0
+	if query.After != nil {
+		startIndex = sort.Search(len(hits), func(i int) bool {
+			return query.After.Precedes(hits[i].Rank, hits[i].ID())
+		})
+	}
+
+	first := query.First
+	if first < 0 {
+		first = 0
+	}
+	endIndex := int64(startIndex) + first
+	if endIndex > int64(len(hits)) {
+		endIndex = int64(len(hits))
+	}
+
+	// Фрагменты строятся только для результатов страницы
+	page := hits[startIndex:endIndex]
+	for _, hit := range page {
+		if hit.Kind == repository.TargetPost {
+			// У поста выделяется текст, а если слов запроса в нём нет - заголовок
+			text := hit.Post.Content
+			if !search.Matches(text, query.Query) {
+				text = hit.Post.Title
 			}
+			hit.Snippet = search.Snippet(text, query.Query)
+		} else {
+			hit.Snippet = search.Snippet(hit.Comment.Content, query.Query)
 		}
 	}
 
+	return &repository.SearchPage{
+		Hits:        page,
+		HasNextPage: endIndex < int64(len(hits)),
+		TotalCount:  len(hits),
+	}, nil
+}
+
+// CreateUser создает нового пользователя и добавляет его в репозиторий
+func (repo *InMemoryRepository) CreateUser(ctx context.Context, username, passwordHash string) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.writeMu.Lock()
+	defer repo.writeMu.Unlock()
+	if _, ok := repo.usernames[username]; ok {
+		return nil, repository.ErrUsernameTaken
+	}
+	id := uuid.New().String() // Генерация нового уникального ID для пользователя
+	log.Println("Creating user with ID:", id)
+	err := repo.commit(&operation{Kind: opCreateUser, ID: id, At: now(), Username: username, PasswordHash: passwordHash})
+	if err != nil {
+		return nil, err
+	}
+	return copyUser(repo.users[id]), nil
+}
+
+// createUser применяет операцию регистрации пользователя
+func (repo *InMemoryRepository) createUser(op *operation) {
+	repo.users[op.ID] = &models.User{
+		ID:           op.ID,
+		Username:     op.Username,
+		PasswordHash: op.PasswordHash,
+		Role:         models.RoleUser,
+		CreatedAt:    op.At,
+	}
+	repo.usernames[op.Username] = op.ID
+}
+
+// GetUser возвращает пользователя по его ID. Если пользователь не найден, возвращает ошибку.
+func (repo *InMemoryRepository) GetUser(ctx context.Context, id string) (*models.User, error) {
+	log.Println("Querying user with ID:", id)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	user, ok := repo.users[id]
+	if !ok {
+		return nil, repository.NotFound(repository.EntityUser, id)
+	}
+	return copyUser(user), nil
+}
+
+// GetUserByUsername возвращает пользователя по его имени. Если пользователь не найден, возвращает ошибку.
+func (repo *InMemoryRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	log.Println("Querying user with username:", username)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	id, ok := repo.usernames[username]
+	if !ok {
+		return nil, repository.NotFound(repository.EntityUser, username)
+	}
+	return copyUser(repo.users[id]), nil
+}
+
+// Ping проверяет доступность хранилища. Репозиторий с сохранением на диск недоступен после закрытия.
+func (repo *InMemoryRepository) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	if repo.closed {
+		return ErrClosed
+	}
 	return nil
 }
+
+// Stats содержит размеры хранилища в памяти
+type Stats struct {
+	Posts    int // Количество постов
+	Comments int // Количество комментариев, включая заменённые надгробиями
+}
+
+// Stats возвращает количество постов и комментариев в хранилище
+func (repo *InMemoryRepository) Stats() Stats {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	return Stats{Posts: len(repo.posts), Comments: len(repo.comments)}
+}
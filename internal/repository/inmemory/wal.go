@@ -0,0 +1,266 @@
+package inmemory
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// SyncPolicy определяет, когда записи журнала сбрасываются на диск вызовом fsync
+type SyncPolicy string
+
+// Политики сброса журнала на диск
+const (
+	SyncAlways   SyncPolicy = "always"   // После каждой операции: ни одна подтверждённая операция не теряется
+	SyncInterval SyncPolicy = "interval" // Периодически в фоне: при сбое ОС теряются операции за последний интервал
+	SyncNever    SyncPolicy = "never"    // Сброс на диск выполняет ОС: при падении процесса данные сохраняются, при сбое ОС - нет
+)
+
+// ParseSyncPolicy разбирает название политики сброса журнала на диск
+func ParseSyncPolicy(name string) (SyncPolicy, error) {
+	switch policy := SyncPolicy(name); policy {
+	case SyncAlways, SyncInterval, SyncNever:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown fsync policy %q, expected %s, %s or %s", name, SyncAlways, SyncInterval, SyncNever)
+}
+
+// Формат записи журнала и снимка: длина данных (4 байта), контрольная сумма CRC-32C данных (4 байта), данные.
+// Числа записываются в порядке little-endian.
+const (
+	recordHeaderSize = 8
+	maxRecordSize    = 1 << 30
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errCorruptRecord возвращается, если запись обрезана или её контрольная сумма не совпадает
+var errCorruptRecord = errors.New("corrupt record")
+
+// encodeRecord добавляет к данным заголовок записи
+func encodeRecord(payload []byte) []byte {
+	record := make([]byte, recordHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.Checksum(payload, crcTable))
+	copy(record[recordHeaderSize:], payload)
+	return record
+}
+
+// readRecord читает одну запись. В конце данных возвращает io.EOF,
+// для обрезанной или повреждённой записи - errCorruptRecord.
+func readRecord(reader io.Reader) ([]byte, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errCorruptRecord
+		}
+		return nil, err
+	}
+	size := binary.LittleEndian.Uint32(header[0:4])
+	if size > maxRecordSize {
+		return nil, errCorruptRecord
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errCorruptRecord
+		}
+		return nil, err
+	}
+	if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(header[4:8]) {
+		return nil, errCorruptRecord
+	}
+	return payload, nil
+}
+
+// writeAheadLog представляет собой журнал операций, в который записи только добавляются
+type writeAheadLog struct {
+	mu     sync.Mutex
+	file   *os.File
+	policy SyncPolicy
+	size   int64         // Размер журнала после последней целой записи
+	dirty  bool          // Есть записи, ещё не сброшенные на диск
+	err    error         // Ошибка, после которой журнал не принимает записи
+	done   chan struct{} // Закрывается при закрытии журнала, чтобы остановить фоновый сброс
+	wg     sync.WaitGroup
+}
+
+// openWAL открывает журнал по пути path, вызывая replay для каждой целой записи.
+// Обрезанная или повреждённая последняя запись (например, после сбоя во время записи) отбрасывается,
+// а файл укорачивается до последней целой записи. Повреждённая запись в середине журнала считается
+// порчей данных: следующие за ней подтверждённые операции отбросить нельзя, поэтому журнал не открывается.
+func openWAL(path string, policy SyncPolicy, interval time.Duration, replay func(payload []byte) error) (*writeAheadLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	var offset int64
+	for {
+		payload, err := readRecord(file)
+		if err == io.EOF {
+			break
+		}
+		if err == errCorruptRecord {
+			torn, err := tornTail(file, offset)
+			if err != nil {
+				file.Close()
+				return nil, err
+			}
+			if !torn {
+				file.Close()
+				return nil, fmt.Errorf("corrupt record at offset %d is followed by other records", offset)
+			}
+			log.Printf("Discarding corrupt tail of write-ahead log %s at offset %d\n", path, offset)
+			if err := file.Truncate(offset); err != nil {
+				file.Close()
+				return nil, err
+			}
+			break
+		}
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if err := replay(payload); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("replay record at offset %d: %w", offset, err)
+		}
+		offset += recordHeaderSize + int64(len(payload))
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	wal := &writeAheadLog{file: file, policy: policy, size: offset, done: make(chan struct{})}
+	if policy == SyncInterval {
+		wal.wg.Add(1)
+		go wal.syncEvery(interval)
+	}
+	return wal, nil
+}
+
+// tornTail проверяет, что повреждённая запись по смещению offset - последняя в журнале,
+// то есть доходит до конца файла или не помещается в него
+func tornTail(file *os.File, offset int64) (bool, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	var header [recordHeaderSize]byte
+	if _, err := file.ReadAt(header[:], offset); err != nil {
+		if err == io.EOF {
+			return true, nil
+		}
+		return false, err
+	}
+	size := int64(binary.LittleEndian.Uint32(header[0:4]))
+	return offset+recordHeaderSize+size >= info.Size(), nil
+}
+
+// append добавляет запись в конец журнала. Если запись или сброс на диск не удались, операция
+// не подтверждается, поэтому журнал укорачивается до последней целой записи: иначе следующие записи
+// оказались бы после обрезанной записи или операции, о неудаче которой уже сообщено.
+func (wal *writeAheadLog) append(payload []byte) error {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+	if wal.err != nil {
+		return wal.err
+	}
+	record := encodeRecord(payload)
+	_, err := wal.file.Write(record)
+	if err == nil && wal.policy == SyncAlways {
+		err = wal.file.Sync()
+	}
+	if err != nil {
+		wal.rollback(err)
+		return err
+	}
+	wal.size += int64(len(record))
+	if wal.policy != SyncAlways {
+		wal.dirty = true
+	}
+	return nil
+}
+
+// rollback отбрасывает неподтверждённую запись после ошибки cause. Если восстановить журнал не удалось,
+// он больше не принимает записи, чтобы подтверждённые операции не оказались после повреждённой записи.
+func (wal *writeAheadLog) rollback(cause error) {
+	err := wal.file.Truncate(wal.size)
+	if err == nil {
+		_, err = wal.file.Seek(wal.size, io.SeekStart)
+	}
+	if err == nil {
+		err = wal.file.Sync()
+	}
+	if err != nil {
+		wal.err = fmt.Errorf("write-ahead log is unusable after %v: %w", cause, err)
+		log.Println("Failed to roll back write-ahead log:", err)
+	}
+}
+
+// reset очищает журнал после того, как все его записи сохранены в снимке
+func (wal *writeAheadLog) reset() error {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+	if err := wal.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := wal.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	wal.size = 0
+	wal.dirty = false
+	return wal.file.Sync()
+}
+
+// sync сбрасывает на диск записи, добавленные после предыдущего сброса. После неудачного сброса
+// ОС может отбросить несохранённые записи, не сообщив об этом повторно, поэтому журнал больше не принимает записи.
+func (wal *writeAheadLog) sync() error {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+	if !wal.dirty {
+		return nil
+	}
+	wal.dirty = false
+	if err := wal.file.Sync(); err != nil {
+		wal.err = fmt.Errorf("write-ahead log is unusable after failed sync: %w", err)
+		return err
+	}
+	return nil
+}
+
+// syncEvery периодически сбрасывает журнал на диск до закрытия журнала
+func (wal *writeAheadLog) syncEvery(interval time.Duration) {
+	defer wal.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := wal.sync(); err != nil {
+				log.Println("Failed to sync write-ahead log:", err)
+			}
+		case <-wal.done:
+			return
+		}
+	}
+}
+
+// close сбрасывает журнал на диск и закрывает его
+func (wal *writeAheadLog) close() error {
+	close(wal.done)
+	wal.wg.Wait()
+	if err := wal.file.Sync(); err != nil {
+		wal.file.Close()
+		return err
+	}
+	return wal.file.Close()
+}
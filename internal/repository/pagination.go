@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/nemopss/go-posts-comments-system/internal/models"
+)
+
+// ErrInvalidCursor возвращается, если курсор пагинации не удалось разобрать
+var ErrInvalidCursor = Invalid("after", "invalid cursor")
+
+// Cursor представляет позицию элемента в списке, упорядоченном по (created_at, id).
+// Пагинация по курсору выполняется по ключу (keyset): следующая страница начинается
+// строго после элемента, на который указывает курсор.
+type Cursor struct {
+	CreatedAt time.Time // Время создания элемента
+	ID        string    // Идентификатор элемента, разрешающий совпадения по времени
+}
+
+// cursorPayload — сериализуемое представление курсора
+type cursorPayload struct {
+	CreatedAt int64  `json:"t"`
+	ID        string `json:"id"`
+}
+
+// CursorOf возвращает курсор, указывающий на комментарий
+func CursorOf(comment *models.Comment) Cursor {
+	return Cursor{CreatedAt: comment.CreatedAt, ID: comment.ID}
+}
+
+// EncodeCursor кодирует курсор в непрозрачную строку для передачи клиенту
+func EncodeCursor(c Cursor) string {
+	data, _ := json.Marshal(cursorPayload{CreatedAt: c.CreatedAt.UnixNano(), ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor разбирает строку, полученную от клиента, в курсор
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var payload cursorPayload
+	if err := json.Unmarshal(data, &payload); err != nil || payload.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{CreatedAt: time.Unix(0, payload.CreatedAt).UTC(), ID: payload.ID}, nil
+}
+
+// After сообщает, находится ли элемент с ключом (createdAt, id) строго после курсора
+func (c Cursor) After(createdAt time.Time, id string) bool {
+	if !createdAt.Equal(c.CreatedAt) {
+		return createdAt.After(c.CreatedAt)
+	}
+	return id > c.ID
+}
+
+// CommentPage представляет страницу комментариев
+type CommentPage struct {
+	Comments    []*models.Comment // Комментарии страницы в порядке (created_at, id)
+	HasNextPage bool              // Есть ли комментарии после последнего на странице
+	TotalCount  int               // Общее количество комментариев без учёта пагинации
+}
+
+// PostCursor представляет позицию поста в списке, упорядоченном по выбранному ключу сортировки.
+// Курсор привязан к порядку сортировки, для которого он был выдан.
+type PostCursor struct {
+	OrderBy PostOrder // Порядок сортировки, для которого выдан курсор
+	Time    time.Time // Значение временного ключа сортировки (CREATED_AT, LAST_ACTIVITY)
+	Count   int       // Значение числового ключа сортировки (COMMENT_COUNT)
+	ID      string    // Идентификатор поста, разрешающий совпадения ключа
+}
+
+// postCursorPayload — сериализуемое представление курсора поста
+type postCursorPayload struct {
+	OrderBy PostOrder `json:"o"`
+	Time    int64     `json:"t,omitempty"`
+	Count   int       `json:"n,omitempty"`
+	ID      string    `json:"id"`
+}
+
+// PostCursorOf возвращает курсор, указывающий на пост в заданном порядке сортировки
+func PostCursorOf(post *models.Post, orderBy PostOrder) PostCursor {
+	cursor := PostCursor{OrderBy: orderBy, ID: post.ID}
+	switch orderBy {
+	case PostOrderCommentCount:
+		cursor.Count = post.CommentCount
+	case PostOrderLastActivity:
+		cursor.Time = post.LastActivityAt
+	default:
+		cursor.Time = post.CreatedAt
+	}
+	return cursor
+}
+
+// EncodePostCursor кодирует курсор поста в непрозрачную строку для передачи клиенту
+func EncodePostCursor(c PostCursor) string {
+	payload := postCursorPayload{OrderBy: c.OrderBy, Count: c.Count, ID: c.ID}
+	if !c.Time.IsZero() {
+		payload.Time = c.Time.UnixNano()
+	}
+	data, _ := json.Marshal(payload)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodePostCursor разбирает строку, полученную от клиента, в курсор поста.
+// Курсор, выданный для другого порядка сортировки, считается некорректным.
+func DecodePostCursor(s string, orderBy PostOrder) (*PostCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var payload postCursorPayload
+	if err := json.Unmarshal(data, &payload); err != nil || payload.ID == "" || payload.OrderBy != orderBy {
+		return nil, ErrInvalidCursor
+	}
+	cursor := &PostCursor{OrderBy: payload.OrderBy, Count: payload.Count, ID: payload.ID}
+	if payload.Time != 0 {
+		cursor.Time = time.Unix(0, payload.Time).UTC()
+	}
+	return cursor, nil
+}
+
+// Precedes сообщает, предшествует ли курсор посту, то есть находится ли пост строго после курсора
+// в порядке убывания ключа сортировки
+func (c PostCursor) Precedes(post *models.Post) bool {
+	other := PostCursorOf(post, c.OrderBy)
+	switch {
+	case c.OrderBy == PostOrderCommentCount && other.Count != c.Count:
+		return other.Count < c.Count
+	case c.OrderBy != PostOrderCommentCount && !other.Time.Equal(c.Time):
+		return other.Time.Before(c.Time)
+	}
+	return other.ID < c.ID
+}
+
+// PostPage представляет страницу постов
+type PostPage struct {
+	Posts       []*models.Post // Посты страницы в выбранном порядке
+	HasNextPage bool           // Есть ли посты после последнего на странице
+	TotalCount  int            // Общее количество постов, удовлетворяющих фильтру
+}
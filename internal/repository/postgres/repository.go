@@ -1,29 +1,95 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/nemopss/go-posts-comments-system/internal/models"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+	"github.com/nemopss/go-posts-comments-system/internal/search"
 )
 
 // PostgresRepository представляет собой хранилище данных в PostgreSQL
 type PostgresRepository struct {
-	db *sql.DB
+	db tracedDB // Каждый запрос выполняется в отдельном span трассировки
 }
 
 // NewPostgresRepository создает новый экземпляр PostgresRepository
 func NewPostgresRepository(db *sql.DB) *PostgresRepository {
-	return &PostgresRepository{db: db}
+	return &PostgresRepository{db: tracedDB{db}}
+}
+
+// now возвращает текущее время с точностью до микросекунд, с которой PostgreSQL хранит timestamp,
+// чтобы возвращаемые после создания значения совпадали с прочитанными из базы
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// postColumns — список столбцов таблицы posts в порядке, ожидаемом scanPost
+const postColumns = "id, author_id, title, content, comments_disabled, created_at, comment_count, last_activity_at, edited_at, upvotes, downvotes"
+
+// commentColumns — список столбцов таблицы comments в порядке, ожидаемом scanComment
+const commentColumns = "c.id, c.post_id, c.parent_id, c.author_id, c.content, c.created_at, c.edited_at, c.deleted_at, c.upvotes, c.downvotes"
+
+// rowScanner обобщает *sql.Row и *sql.Rows для сканирования одной строки
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPost сканирует строку с postColumns в модель Post
+func scanPost(row rowScanner) (*models.Post, error) {
+	post := &models.Post{}
+	var editedAt sql.NullTime
+	err := row.Scan(&post.ID, &post.AuthorID, &post.Title, &post.Content, &post.CommentsDisabled, &post.CreatedAt, &post.CommentCount, &post.LastActivityAt, &editedAt, &post.Upvotes, &post.Downvotes)
+	if err != nil {
+		return nil, err
+	}
+	if editedAt.Valid {
+		post.EditedAt = &editedAt.Time
+	}
+	return post, nil
+}
+
+// scanComment сканирует строку с commentColumns в модель Comment
+func scanComment(row rowScanner) (*models.Comment, error) {
+	comment := &models.Comment{}
+	var parentId sql.NullString
+	var editedAt, deletedAt sql.NullTime
+	err := row.Scan(&comment.ID, &comment.PostID, &parentId, &comment.AuthorID, &comment.Content, &comment.CreatedAt, &editedAt, &deletedAt, &comment.Upvotes, &comment.Downvotes)
+	if err != nil {
+		return nil, err
+	}
+	if parentId.Valid {
+		comment.ParentID = &parentId.String
+	}
+	if editedAt.Valid {
+		comment.EditedAt = &editedAt.Time
+	}
+	if deletedAt.Valid {
+		comment.DeletedAt = &deletedAt.Time
+	}
+	return comment, nil
+}
+
+// notFound заменяет sql.ErrNoRows на ошибку ненайденного объекта
+func notFound(err error, entity, id string) error {
+	if err == sql.ErrNoRows {
+		return repository.NotFound(entity, id)
+	}
+	return err
 }
 
 // GetPosts возвращает список всех постов
-func (repo *PostgresRepository) GetPosts() ([]*models.Post, error) {
+func (repo *PostgresRepository) GetPosts(ctx context.Context) ([]*models.Post, error) {
 	log.Println("Querying posts...")
-	rows, err := repo.db.Query("SELECT id, title, content, comments_disabled, created_at FROM posts")
+	rows, err := repo.db.QueryContext(ctx, "SELECT "+postColumns+" FROM posts")
 	if err != nil {
 		return nil, err
 	}
@@ -32,213 +98,658 @@ func (repo *PostgresRepository) GetPosts() ([]*models.Post, error) {
 	posts := []*models.Post{}
 
 	for rows.Next() {
-		post := &models.Post{}
-		err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.CommentsDisabled, &post.CreatedAt)
+		post, err := scanPost(rows)
 		if err != nil {
 			return nil, err
 		}
+		posts = append(posts, post)
+	}
+
+	return posts, rows.Err()
+}
+
+// postOrderColumns сопоставляет порядку сортировки столбец таблицы posts
+var postOrderColumns = map[repository.PostOrder]string{
+	repository.PostOrderCreatedAt:    "created_at",
+	repository.PostOrderCommentCount: "comment_count",
+	repository.PostOrderLastActivity: "last_activity_at",
+}
+
+// ListPosts возвращает страницу постов, отобранных и упорядоченных согласно параметрам запроса
+func (repo *PostgresRepository) ListPosts(ctx context.Context, query repository.PostsQuery) (*repository.PostPage, error) {
+	log.Println("Listing posts ordered by", query.OrderBy)
+	if query.OrderBy == "" {
+		query.OrderBy = repository.PostOrderCreatedAt
+	}
+	orderColumn, ok := postOrderColumns[query.OrderBy]
+	if !ok {
+		return nil, repository.Invalid("orderBy", fmt.Sprintf("unknown post order %q", query.OrderBy))
+	}
+	first := query.First
+	if first < 0 {
+		first = 0
+	}
+
+	// Построение условий фильтра
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	filter := query.Filter
+	if filter.CommentsDisabled != nil {
+		addCondition("comments_disabled = $%d", *filter.CommentsDisabled)
+	}
+	if filter.CreatedAfter != nil {
+		addCondition("created_at > $%d", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		addCondition("created_at < $%d", *filter.CreatedBefore)
+	}
+	if filter.TitleContains != nil {
+		addCondition(`title ILIKE $%d ESCAPE '\'`, "%"+escapeLike(*filter.TitleContains)+"%")
+	}
+
+	page := &repository.PostPage{}
+	err := repo.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM posts WHERE "+strings.Join(conditions, " AND "), args...).Scan(&page.TotalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	// Условие keyset-пагинации: посты строго после курсора в порядке убывания
+	if query.After != nil {
+		var key interface{} = query.After.Time
+		if query.OrderBy == repository.PostOrderCommentCount {
+			key = query.After.Count
+		}
+		args = append(args, key, query.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, id) < ($%d, $%d)", orderColumn, len(args)-1, len(args)))
+	}
+	args = append(args, first+1)
+
+	rows, err := repo.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT %s FROM posts WHERE %s ORDER BY %s DESC, id DESC LIMIT $%d",
+		postColumns, strings.Join(conditions, " AND "), orderColumn, len(args),
+	), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-		// Получение комментариев для данного поста
-		comments, err := repo.GetCommentsByPostID(post.ID, 0, nil)
+	posts := []*models.Post{}
+	for rows.Next() {
+		post, err := scanPost(rows)
 		if err != nil {
 			return nil, err
 		}
-		post.Comments = comments
-
 		posts = append(posts, post)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return posts, nil
+	// Лишний пост означает, что за страницей есть продолжение
+	if int64(len(posts)) > first {
+		page.HasNextPage = true
+		posts = posts[:first]
+	}
+	page.Posts = posts
+	return page, nil
+}
+
+// escapeLike экранирует специальные символы шаблона LIKE
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
 
 // GetPost возвращает пост по его ID
-func (repo *PostgresRepository) GetPost(id string) (*models.Post, error) {
+func (repo *PostgresRepository) GetPost(ctx context.Context, id string) (*models.Post, error) {
 	log.Println("Querying post with ID:", id)
-	row := repo.db.QueryRow("SELECT id, title, content, comments_disabled FROM posts WHERE id=$1", id)
-	post := &models.Post{}
-	err := row.Scan(&post.ID, &post.Title, &post.Content, &post.CommentsDisabled)
-	if err != nil {
-		return nil, err
-	}
-	return post, nil
+	post, err := scanPost(repo.db.QueryRowContext(ctx, "SELECT "+postColumns+" FROM posts WHERE id=$1", id))
+	return post, notFound(err, repository.EntityPost, id)
 }
 
 // CreatePost создает новый пост
-func (repo *PostgresRepository) CreatePost(title, content string, commentsDisabled bool) (*models.Post, error) {
+func (repo *PostgresRepository) CreatePost(ctx context.Context, authorId, title, content string, commentsDisabled bool) (*models.Post, error) {
 	id := uuid.New().String() // Генерация нового уникального ID для поста
 	log.Println("Creating post with ID:", id)
-	createdAt := time.Now() // Текущее время как время создания поста
-	_, err := repo.db.Exec("INSERT INTO posts (id, title, content, comments_disabled, created_at) VALUES ($1, $2, $3, $4, $5)", id, title, content, commentsDisabled, createdAt)
+	createdAt := now() // Текущее время как время создания поста
+	tx, err := repo.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
-	log.Println("Created post", id)
-	return &models.Post{ID: id, Title: title, Content: content, CommentsDisabled: commentsDisabled, CreatedAt: createdAt}, nil
-}
+	defer tx.Rollback()
 
-// CreateComment создает новый комментарий
-func (repo *PostgresRepository) CreateComment(postId, parentId, content string) (*models.Comment, error) {
-	if len(content) > 2000 {
-		return nil, errors.New("комментарий не может превышать 2000 символов")
+	_, err = tx.ExecContext(ctx, "INSERT INTO posts (id, author_id, title, content, comments_disabled, created_at, last_activity_at) VALUES ($1, $2, $3, $4, $5, $6, $6)", id, authorId, title, content, commentsDisabled, createdAt)
+	if err != nil {
+		return nil, err
 	}
-	var commentsDisabled bool
-	err := repo.db.QueryRow("SELECT comments_disabled FROM posts WHERE id = $1", postId).Scan(&commentsDisabled)
+
+	// Сохранение исходной версии поста
+	_, err = tx.ExecContext(ctx, "INSERT INTO post_revisions (post_id, revision, title, content, comments_disabled, editor_id, created_at) VALUES ($1, 1, $2, $3, $4, $5, $6)", id, title, content, commentsDisabled, authorId, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("Post not found!")
-		}
 		return nil, err
 	}
+	log.Println("Created post", id)
+	return &models.Post{ID: id, AuthorID: authorId, Title: title, Content: content, CommentsDisabled: commentsDisabled, CreatedAt: createdAt, LastActivityAt: createdAt}, nil
+}
 
-	// Проверка, отключены ли комментарии для поста
-	if commentsDisabled {
-		return nil, errors.New("Comments are disabled on this post!")
+// CreateComment создает новый комментарий
+func (repo *PostgresRepository) CreateComment(ctx context.Context, authorId, postId, parentId, content string) (*models.Comment, error) {
+	if err := repository.ValidateCommentContent(content); err != nil {
+		return nil, err
 	}
 	id := uuid.New().String() // Генерация нового уникального ID для комментария
 	log.Println("Creating comment with ID:", id)
-	createdAt := time.Now() // Текущее время как время создания комментария
-	tx, err := repo.db.Begin()
+	createdAt := now() // Текущее время как время создания комментария
+	tx, err := repo.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
+	target := repository.CommentTarget{}
+	err = tx.QueryRowContext(ctx, "SELECT comments_disabled FROM posts WHERE id = $1", postId).Scan(&target.CommentsDisabled)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	target.PostFound = err == nil
+
 	var parentIdSQL interface{}
-	if parentId == "" {
-		parentIdSQL = nil
-	} else {
+	if parentId != "" {
 		parentIdSQL = parentId
+		// FOR SHARE не даёт удалить родителя до конца транзакции
+		err = tx.QueryRowContext(ctx, "SELECT post_id, deleted_at IS NOT NULL FROM comments WHERE id = $1 FOR SHARE", parentId).Scan(&target.ParentPostID, &target.ParentDeleted)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		target.ParentFound = err == nil
+	}
+	if err := repository.ValidateNewComment(postId, parentId, content, target); err != nil {
+		return nil, err
 	}
 
-	_, err = tx.Exec("INSERT INTO comments (id, post_id, parent_id, content, created_at) VALUES ($1, $2, $3, $4, $5)", id, postId, parentIdSQL, content, createdAt)
+	_, err = tx.ExecContext(ctx, "INSERT INTO comments (id, post_id, parent_id, author_id, content, created_at) VALUES ($1, $2, $3, $4, $5, $6)", id, postId, parentIdSQL, authorId, content, createdAt)
 	if err != nil {
 		return nil, err
 	}
 
+	// Пары комментария с самим собой и со всеми предками родителя в таблице замыкания
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO comment_closure (ancestor_id, descendant_id, depth)
+		SELECT $1::varchar, $1::varchar, 0
+		UNION ALL
+		SELECT ancestor_id, $1::varchar, depth + 1 FROM comment_closure WHERE descendant_id = $2
+	`, id, parentId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Сохранение исходной версии комментария
+	_, err = tx.ExecContext(ctx, "INSERT INTO comment_revisions (comment_id, revision, content, editor_id, created_at) VALUES ($1, 1, $2, $3, $4)", id, content, authorId, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Обновление счётчика комментариев и времени последней активности поста
+	_, err = tx.ExecContext(ctx, "UPDATE posts SET comment_count = comment_count + 1, last_activity_at = $2 WHERE id = $1", postId, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Created comment on post %v...\n", postId)
+	comment := &models.Comment{ID: id, PostID: postId, AuthorID: authorId, Content: content, CreatedAt: createdAt}
 	if parentId != "" {
-		_, err = tx.Exec("INSERT INTO pairs (parent_id, child_id) VALUES ($1, $2)", parentId, id)
-		if err != nil {
-			return nil, err
+		comment.ParentID = &parentId
+	}
+	return comment, nil
+}
+
+// UpdatePost изменяет пост и сохраняет его новую версию
+func (repo *PostgresRepository) UpdatePost(ctx context.Context, editorId, id, title, content string, commentsDisabled bool) (*models.Post, error) {
+	log.Println("Updating post with ID:", id)
+	editedAt := now()
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// UPDATE блокирует строку поста до конца транзакции, поэтому номера версий не конфликтуют
+	post, err := scanPost(tx.QueryRowContext(ctx, "UPDATE posts SET title = $2, content = $3, comments_disabled = $4, edited_at = $5 WHERE id = $1 RETURNING "+postColumns, id, title, content, commentsDisabled, editedAt))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.NotFound(repository.EntityPost, id)
 		}
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO post_revisions (post_id, revision, title, content, comments_disabled, editor_id, created_at)
+		SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4, $5, $6 FROM post_revisions WHERE post_id = $1
+	`, id, title, content, commentsDisabled, editorId, editedAt)
+	if err != nil {
+		return nil, err
 	}
 
 	err = tx.Commit()
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Created comment on post %v...\n", postId)
-	return &models.Comment{ID: id, PostID: postId, ParentID: &parentId, Content: content, CreatedAt: createdAt}, nil
+	return post, nil
 }
 
-// GetCommentsByPostID возвращает список комментариев для указанного поста с пагинацией
-func (repo *PostgresRepository) GetCommentsByPostID(postId string, first int64, after *string) ([]*models.Comment, error) {
-	log.Println("Getting comments on post with ID:", postId)
-	query := `
-        SELECT c.id, c.post_id, c.parent_id, c.content, c.created_at
-        FROM comments c
-        LEFT JOIN pairs p ON c.id = p.child_id
-        WHERE c.post_id = $1 AND c.parent_id IS NULL
-        ORDER BY c.created_at
-        LIMIT $2
-    `
-	args := []interface{}{postId, first}
+// UpdateComment изменяет комментарий и сохраняет его новую версию
+func (repo *PostgresRepository) UpdateComment(ctx context.Context, editorId, id, content string) (*models.Comment, error) {
+	log.Println("Updating comment with ID:", id)
+	if err := repository.ValidateCommentContent(content); err != nil {
+		return nil, err
+	}
+	editedAt := now()
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
-	if after != nil {
-		query = `
-        SELECT c.id, c.post_id, c.parent_id, c.content, c.created_at
-        FROM comments c
-        LEFT JOIN pairs p ON c.id = p.child_id
-        WHERE c.post_id = $1 AND c.parent_id IS NULL AND c.id > $3
-        ORDER BY c.created_at
-        LIMIT $2
-    `
-		args = append(args, *after)
+	comment, err := scanComment(tx.QueryRowContext(ctx, "UPDATE comments c SET content = $2, edited_at = $3 WHERE c.id = $1 AND c.deleted_at IS NULL RETURNING "+commentColumns, id, content, editedAt))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// Комментарий либо не существует, либо удалён
+			var deleted bool
+			if tx.QueryRowContext(ctx, "SELECT deleted_at IS NOT NULL FROM comments WHERE id = $1", id).Scan(&deleted) == nil && deleted {
+				return nil, repository.ErrCommentDeleted
+			}
+			return nil, repository.NotFound(repository.EntityComment, id)
+		}
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO comment_revisions (comment_id, revision, content, editor_id, created_at)
+		SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4 FROM comment_revisions WHERE comment_id = $1
+	`, id, content, editorId, editedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
 	}
-	rows, err := repo.db.Query(query, args...)
+	return comment, nil
+}
+
+// GetPostRevisions возвращает все версии поста в порядке возрастания номера
+func (repo *PostgresRepository) GetPostRevisions(ctx context.Context, postId string) ([]*models.PostRevision, error) {
+	log.Println("Getting revisions of post with ID:", postId)
+	rows, err := repo.db.QueryContext(ctx, "SELECT post_id, revision, title, content, comments_disabled, editor_id, created_at FROM post_revisions WHERE post_id = $1 ORDER BY revision", postId)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	comments := []*models.Comment{}
+	revisions := []*models.PostRevision{}
+	for rows.Next() {
+		revision := &models.PostRevision{}
+		err := rows.Scan(&revision.PostID, &revision.Revision, &revision.Title, &revision.Content, &revision.CommentsDisabled, &revision.EditorID, &revision.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// У существующего поста всегда есть хотя бы одна версия
+	if len(revisions) == 0 {
+		return nil, repository.NotFound(repository.EntityPost, postId)
+	}
+	return revisions, nil
+}
+
+// GetCommentRevisions возвращает все версии комментария в порядке возрастания номера
+func (repo *PostgresRepository) GetCommentRevisions(ctx context.Context, commentId string) ([]*models.CommentRevision, error) {
+	log.Println("Getting revisions of comment with ID:", commentId)
+	rows, err := repo.db.QueryContext(ctx, "SELECT comment_id, revision, content, editor_id, created_at FROM comment_revisions WHERE comment_id = $1 ORDER BY revision", commentId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
+	revisions := []*models.CommentRevision{}
 	for rows.Next() {
-		comment := &models.Comment{}
-		var parentId sql.NullString
-		err := rows.Scan(&comment.ID, &comment.PostID, &parentId, &comment.Content, &comment.CreatedAt)
+		revision := &models.CommentRevision{}
+		err := rows.Scan(&revision.CommentID, &revision.Revision, &revision.Content, &revision.EditorID, &revision.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
-		if parentId.Valid {
-			comment.ParentID = &parentId.String
-		} else {
-			comment.ParentID = nil
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Версий нет у удалённого комментария и у несуществующего
+	if len(revisions) == 0 {
+		var exists bool
+		err := repo.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM comments WHERE id = $1)", commentId).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, repository.NotFound(repository.EntityComment, commentId)
 		}
-		comments = append(comments, comment)
 	}
-	return comments, nil
+	return revisions, nil
+}
+
+// GetComment возвращает комментарий по его ID
+func (repo *PostgresRepository) GetComment(ctx context.Context, id string) (*models.Comment, error) {
+	log.Println("Querying comment with ID:", id)
+	comment, err := scanComment(repo.db.QueryRowContext(ctx, "SELECT "+commentColumns+" FROM comments c WHERE c.id=$1", id))
+	return comment, notFound(err, repository.EntityComment, id)
+}
+
+// GetCommentsByPostID возвращает страницу комментариев верхнего уровня для указанного поста
+func (repo *PostgresRepository) GetCommentsByPostID(ctx context.Context, postId string, first int64, after *repository.Cursor) (*repository.CommentPage, error) {
+	log.Println("Getting comments on post with ID:", postId)
+	pages, err := repo.getCommentPages(ctx, postKey, []string{postId}, first, after)
+	if err != nil {
+		return nil, err
+	}
+	return pages[postId], nil
 }
 
-// GetCommentsByParentID возвращает список дочерних комментариев для указанного комментария с пагинацией
-func (repo *PostgresRepository) GetCommentsByParentID(parentId string, first int64, after *string) ([]*models.Comment, error) {
+// GetCommentsByParentID возвращает страницу дочерних комментариев для указанного комментария
+func (repo *PostgresRepository) GetCommentsByParentID(ctx context.Context, parentId string, first int64, after *repository.Cursor) (*repository.CommentPage, error) {
 	log.Println("Getting comments from parent with ID:", parentId)
-	query := `
-		SELECT c.id, c.post_id, c.parent_id, c.content, c.created_at
-		FROM comments c
-		WHERE c.parent_id = $1
-		ORDER BY c.created_at
-		LIMIT $2
-	`
-	args := []interface{}{parentId, first}
+	pages, err := repo.getCommentPages(ctx, parentKey, []string{parentId}, first, after)
+	if err != nil {
+		return nil, err
+	}
+	return pages[parentId], nil
+}
+
+// GetCommentsByPostIDs возвращает страницы комментариев верхнего уровня для нескольких постов
+func (repo *PostgresRepository) GetCommentsByPostIDs(ctx context.Context, postIds []string, first int64, after *repository.Cursor) (map[string]*repository.CommentPage, error) {
+	log.Println("Getting comments on posts with IDs:", postIds)
+	return repo.getCommentPages(ctx, postKey, postIds, first, after)
+}
 
+// GetCommentsByParentIDs возвращает страницы дочерних комментариев для нескольких комментариев
+func (repo *PostgresRepository) GetCommentsByParentIDs(ctx context.Context, parentIds []string, first int64, after *repository.Cursor) (map[string]*repository.CommentPage, error) {
+	log.Println("Getting comments from parents with IDs:", parentIds)
+	return repo.getCommentPages(ctx, parentKey, parentIds, first, after)
+}
+
+// pageKey описывает, по какому столбцу комментарии группируются в страницы
+type pageKey struct {
+	column string                       // Столбец, значения которого перечислены в запросе
+	where  string                       // Дополнительное условие отбора
+	of     func(*models.Comment) string // Значение столбца у выбранного комментария
+}
+
+var (
+	// postKey группирует комментарии верхнего уровня по постам
+	postKey = pageKey{
+		column: "c.post_id",
+		where:  " AND c.parent_id IS NULL",
+		of:     func(c *models.Comment) string { return c.PostID },
+	}
+	// parentKey группирует ответы по родительским комментариям
+	parentKey = pageKey{
+		column: "c.parent_id",
+		of:     func(c *models.Comment) string { return *c.ParentID },
+	}
+)
+
+// getCommentPages выбирает страницы комментариев для каждого из значений ids столбца key.
+// Используется keyset-пагинация по (created_at, id) внутри каждой группы: оконная функция нумерует
+// комментарии группы, и для каждой группы выбирается на один комментарий больше, чтобы определить,
+// есть ли следующая страница. Идентификаторы передаются массивом в параметре $1.
+func (repo *PostgresRepository) getCommentPages(ctx context.Context, key pageKey, ids []string, first int64, after *repository.Cursor) (map[string]*repository.CommentPage, error) {
+	if first < 0 {
+		first = 0
+	}
+	pages := make(map[string]*repository.CommentPage, len(ids))
+	for _, id := range ids {
+		pages[id] = &repository.CommentPage{Comments: []*models.Comment{}}
+	}
+	if len(ids) == 0 {
+		return pages, nil
+	}
+
+	counts, err := repo.db.QueryContext(ctx, "SELECT "+key.column+", COUNT(*) FROM comments c WHERE "+key.column+" = ANY($1)"+key.where+" GROUP BY "+key.column, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer counts.Close()
+	for counts.Next() {
+		var id string
+		var count int
+		if err := counts.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+		pages[id].TotalCount = count
+	}
+	if err := counts.Err(); err != nil {
+		return nil, err
+	}
+
+	condition := key.column + " = ANY($1)" + key.where
+	args := []interface{}{pq.Array(ids), first + 1}
 	if after != nil {
-		query += "AND c.id > $3::uuid"
-		args = append(args, *after)
+		condition += " AND (c.created_at, c.id) > ($3, $4)"
+		args = append(args, after.CreatedAt, after.ID)
 	}
 
-	rows, err := repo.db.Query(query, args...)
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT `+commentColumns+`
+		FROM (
+			SELECT c.*, ROW_NUMBER() OVER (PARTITION BY `+key.column+` ORDER BY c.created_at, c.id) AS position
+			FROM comments c
+			WHERE `+condition+`
+		) c
+		WHERE c.position <= $2
+		ORDER BY c.created_at, c.id
+	`, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	comments := []*models.Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		page := pages[key.of(comment)]
+		page.Comments = append(page.Comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
+	// Лишний комментарий означает, что за страницей есть продолжение
+	for _, page := range pages {
+		if int64(len(page.Comments)) > first {
+			page.HasNextPage = true
+			page.Comments = page.Comments[:first]
+		}
+	}
+	return pages, nil
+}
+
+// GetCommentAncestors возвращает цепочку предков комментария по таблице замыкания
+func (repo *PostgresRepository) GetCommentAncestors(ctx context.Context, id string) ([]*models.Comment, error) {
+	log.Println("Getting ancestors of comment with ID:", id)
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT `+commentColumns+`
+		FROM comment_closure cl
+		JOIN comments c ON c.id = cl.ancestor_id
+		WHERE cl.descendant_id = $1 AND cl.depth > 0
+		ORDER BY cl.depth DESC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ancestors := []*models.Comment{}
 	for rows.Next() {
-		comment := &models.Comment{}
-		err := rows.Scan(&comment.ID, &comment.PostID, &comment.ParentID, &comment.Content, &comment.CreatedAt)
+		comment, err := scanComment(rows)
 		if err != nil {
 			return nil, err
 		}
-		comments = append(comments, comment)
+		ancestors = append(ancestors, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Пустая цепочка может означать, что комментария нет
+	if len(ancestors) == 0 {
+		if _, err := repo.GetComment(ctx, id); err != nil {
+			return nil, err
+		}
 	}
+	return ancestors, nil
+}
 
-	return comments, nil
+// threadScanner дополняет сканирование строки столбцами глубины и пути комментария в дереве
+type threadScanner struct {
+	row   rowScanner
+	depth *int
+	path  interface{}
+}
+
+func (s threadScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.depth, s.path)...)
+}
+
+// GetCommentThread возвращает дерево комментариев поста одним рекурсивным запросом.
+// Ключ сортировки - массив пар (created_at, id) от комментария верхнего уровня до текущего:
+// сравнение таких массивов даёт прямой порядок обхода дерева.
+func (repo *PostgresRepository) GetCommentThread(ctx context.Context, query repository.ThreadQuery) ([]*repository.ThreadComment, error) {
+	log.Println("Getting comment thread of post with ID:", query.PostID)
+	// LIMIT NULL не ограничивает количество строк
+	var limit interface{}
+	if query.First >= 0 {
+		limit = query.First
+	}
+	rows, err := repo.db.QueryContext(ctx, `
+		WITH RECURSIVE thread AS (
+			SELECT c.id, 0 AS depth, ARRAY[c.id]::varchar[] AS path,
+				ARRAY[to_char(c.created_at AT TIME ZONE 'UTC', 'YYYYMMDDHH24MISSUS') || c.id] AS sort_key
+			FROM comments c
+			WHERE c.post_id = $1 AND c.parent_id IS NULL
+			UNION ALL
+			SELECT c.id, t.depth + 1, t.path || c.id,
+				t.sort_key || (to_char(c.created_at AT TIME ZONE 'UTC', 'YYYYMMDDHH24MISSUS') || c.id)
+			FROM comments c
+			JOIN thread t ON c.parent_id = t.id
+			WHERE $2::int < 0 OR t.depth < $2::int
+		)
+		SELECT `+commentColumns+`, t.depth, t.path
+		FROM thread t
+		JOIN comments c ON c.id = t.id
+		ORDER BY t.sort_key
+		LIMIT $3
+	`, query.PostID, query.MaxDepth, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	thread := []*repository.ThreadComment{}
+	for rows.Next() {
+		entry := &repository.ThreadComment{}
+		entry.Comment, err = scanComment(threadScanner{row: rows, depth: &entry.Depth, path: pq.Array(&entry.Path)})
+		if err != nil {
+			return nil, err
+		}
+		thread = append(thread, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Пустое дерево может означать, что поста нет
+	if len(thread) == 0 {
+		if _, err := repo.GetPost(ctx, query.PostID); err != nil {
+			return nil, err
+		}
+	}
+	return thread, nil
 }
 
 // DeletePost удаляет пост по его ID
-func (repo *PostgresRepository) DeletePost(id string) error {
+func (repo *PostgresRepository) DeletePost(ctx context.Context, id string) error {
 	log.Println("Deleting post with ID:", id)
-	tx, err := repo.db.Begin()
+	tx, err := repo.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// Сначала удаляем все связи комментариев этого поста
-	_, err = tx.Exec("DELETE FROM pairs WHERE child_id IN (SELECT id FROM comments WHERE post_id = $1)", id)
+	// Сначала удаляем все комментарии к этому посту, их связи в таблице замыкания удаляются каскадно
+	_, err = tx.ExecContext(ctx, "DELETE FROM comments WHERE post_id = $1", id)
 	if err != nil {
 		return err
 	}
 
-	// Сначала удаляем все комментарии к этому посту
-	_, err = tx.Exec("DELETE FROM comments WHERE post_id = $1", id)
+	// Теперь удаляем сам пост
+	result, err := tx.ExecContext(ctx, "DELETE FROM posts WHERE id = $1", id)
 	if err != nil {
 		return err
 	}
+	if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+		return repository.NotFound(repository.EntityPost, id)
+	}
 
-	// Теперь удаляем сам пост
-	_, err = tx.Exec("DELETE FROM posts WHERE id = $1", id)
+	return tx.Commit()
+}
+
+// DeleteComment удаляет комментарий по его ID вместе со всеми ответами на него
+func (repo *PostgresRepository) DeleteComment(ctx context.Context, id string) error {
+	log.Println("Deleting comment with ID:", id)
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var postId string
+	err = tx.QueryRowContext(ctx, "SELECT post_id FROM comments WHERE id = $1", id).Scan(&postId)
+	if err != nil {
+		return notFound(err, repository.EntityComment, id)
+	}
+
+	// Подсчёт комментария и всех вложенных в него ответов по таблице замыкания
+	var deleted int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM comment_closure WHERE ancestor_id = $1", id).Scan(&deleted)
+	if err != nil {
+		return err
+	}
+
+	// Удаление всего поддерева одним запросом, связи в таблице замыкания удаляются каскадно
+	_, err = tx.ExecContext(ctx, "DELETE FROM comments WHERE id IN (SELECT descendant_id FROM comment_closure WHERE ancestor_id = $1)", id)
+	if err != nil {
+		return err
+	}
+
+	// Обновление счётчика комментариев поста с учётом всех удалённых ответов
+	_, err = tx.ExecContext(ctx, "UPDATE posts SET comment_count = comment_count - $2 WHERE id = $1", postId, deleted)
 	if err != nil {
 		return err
 	}
@@ -246,30 +757,135 @@ func (repo *PostgresRepository) DeletePost(id string) error {
 	return tx.Commit()
 }
 
-// DeleteComment удаляет комментарий по его ID
-func (repo *PostgresRepository) DeleteComment(id string) error {
+// SoftDeleteComment заменяет комментарий надгробием, сохраняя ответы на него
+func (repo *PostgresRepository) SoftDeleteComment(ctx context.Context, id string) (*models.Comment, error) {
+	log.Println("Soft deleting comment with ID:", id)
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// COALESCE сохраняет время первого удаления при повторном вызове
+	comment, err := scanComment(tx.QueryRowContext(ctx, "UPDATE comments c SET content = '', deleted_at = COALESCE(c.deleted_at, $2) WHERE c.id = $1 RETURNING "+commentColumns, id, now()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.NotFound(repository.EntityComment, id)
+		}
+		return nil, err
+	}
+
+	// История изменений удаляется вместе с содержимым
+	_, err = tx.ExecContext(ctx, "DELETE FROM comment_revisions WHERE comment_id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// voteTables описывает таблицы голосов и реакций одного вида объектов
+type voteTables struct {
+	target         string // Таблица объектов
+	column         string // Столбец с идентификатором объекта в таблицах голосов и реакций
+	votes          string // Таблица голосов
+	reactions      string // Таблица реакций
+	reactionCounts string // Таблица счётчиков реакций
+	deleted        string // Выражение, истинное для удалённого объекта
+	entity         string // Вид объекта в ошибке NotFoundError
+}
+
+// targetTables сопоставляет виду объекта его таблицы голосов и реакций
+var targetTables = map[repository.TargetKind]voteTables{
+	repository.TargetPost: {
+		target:         "posts",
+		column:         "post_id",
+		votes:          "post_votes",
+		reactions:      "post_reactions",
+		reactionCounts: "post_reaction_counts",
+		deleted:        "FALSE",
+		entity:         repository.EntityPost,
+	},
+	repository.TargetComment: {
+		target:         "comments",
+		column:         "comment_id",
+		votes:          "comment_votes",
+		reactions:      "comment_reactions",
+		reactionCounts: "comment_reaction_counts",
+		deleted:        "deleted_at IS NOT NULL",
+		entity:         repository.EntityComment,
+	},
+}
 
-	log.Println("Deleting comment with ID:", id)
-	tx, err := repo.db.Begin()
+// tablesFor возвращает таблицы голосов и реакций для вида объекта
+func tablesFor(kind repository.TargetKind) (voteTables, error) {
+	tables, ok := targetTables[kind]
+	if !ok {
+		return voteTables{}, fmt.Errorf("unknown target kind %q", kind)
+	}
+	return tables, nil
+}
+
+// lockTarget блокирует строку поста или комментария до конца транзакции, чтобы голоса и реакции
+// за один объект применялись последовательно. За удалённый комментарий голосовать нельзя.
+func lockTarget(ctx context.Context, tx tracedTx, tables voteTables, id string) error {
+	var deleted bool
+	err := tx.QueryRowContext(ctx, "SELECT "+tables.deleted+" FROM "+tables.target+" WHERE id = $1 FOR UPDATE", id).Scan(&deleted)
+	if err == sql.ErrNoRows {
+		return repository.NotFound(tables.entity, id)
+	}
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
+	if deleted {
+		return repository.ErrCommentDeleted
+	}
+	return nil
+}
 
-	// Удаление всех вложенных комментариев
-	err = repo.deleteChildComments(tx, id)
+// Vote устанавливает голос пользователя за пост или комментарий и обновляет счётчики голосов
+func (repo *PostgresRepository) Vote(ctx context.Context, kind repository.TargetKind, targetId, userId string, value int) error {
+	log.Printf("Voting %d for %s with ID: %s\n", value, kind, targetId)
+	if value < repository.VoteDown || value > repository.VoteUp {
+		return repository.ErrInvalidVote
+	}
+	tables, err := tablesFor(kind)
+	if err != nil {
+		return err
+	}
+	tx, err := repo.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	// Сначала удаляем связи этого комментария с дочерними комментариями
-	_, err = tx.Exec("DELETE FROM pairs WHERE parent_id = $1 OR child_id = $1", id)
+	if err := lockTarget(ctx, tx, tables, targetId); err != nil {
+		return err
+	}
+
+	// Прежний голос пользователя
+	oldValue := repository.VoteNone
+	err = tx.QueryRowContext(ctx, "SELECT value FROM "+tables.votes+" WHERE "+tables.column+" = $1 AND user_id = $2", targetId, userId).Scan(&oldValue)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if value == repository.VoteNone {
+		_, err = tx.ExecContext(ctx, "DELETE FROM "+tables.votes+" WHERE "+tables.column+" = $1 AND user_id = $2", targetId, userId)
+	} else {
+		_, err = tx.ExecContext(ctx, "INSERT INTO "+tables.votes+" ("+tables.column+", user_id, value) VALUES ($1, $2, $3) ON CONFLICT ("+tables.column+", user_id) DO UPDATE SET value = EXCLUDED.value", targetId, userId, value)
+	}
 	if err != nil {
 		return err
 	}
 
-	// Удаление самого комментарий
-	_, err = tx.Exec("DELETE FROM comments WHERE id = $1", id)
+	// Счётчики изменяются на разницу между прежним и новым голосом
+	upDelta, downDelta := repository.VoteDelta(oldValue, value)
+	_, err = tx.ExecContext(ctx, "UPDATE "+tables.target+" SET upvotes = upvotes + $2, downvotes = downvotes + $3 WHERE id = $1", targetId, upDelta, downDelta)
 	if err != nil {
 		return err
 	}
@@ -277,39 +893,328 @@ func (repo *PostgresRepository) DeleteComment(id string) error {
 	return tx.Commit()
 }
 
-// deleteChildComments рекурсивно удаляет все дочерние комментарии
-func (repo *PostgresRepository) deleteChildComments(tx *sql.Tx, parentId string) error {
-	log.Println("Deleting child comments from parent with ID:", parentId)
-	childComments := []string{}
-	rows, err := tx.Query("SELECT id FROM comments WHERE parent_id = $1", parentId)
+// GetVote возвращает голос пользователя за пост или комментарий
+func (repo *PostgresRepository) GetVote(ctx context.Context, kind repository.TargetKind, targetId, userId string) (int, error) {
+	log.Printf("Querying vote for %s with ID: %s\n", kind, targetId)
+	tables, err := tablesFor(kind)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	value := repository.VoteNone
+	err = repo.db.QueryRowContext(ctx, "SELECT value FROM "+tables.votes+" WHERE "+tables.column+" = $1 AND user_id = $2", targetId, userId).Scan(&value)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	return value, nil
+}
+
+// React добавляет или снимает реакцию пользователя на пост или комментарий
+func (repo *PostgresRepository) React(ctx context.Context, kind repository.TargetKind, targetId, userId, emoji string) (bool, error) {
+	log.Printf("Reacting %s to %s with ID: %s\n", emoji, kind, targetId)
+	tables, err := tablesFor(kind)
+	if err != nil {
+		return false, err
+	}
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if err := lockTarget(ctx, tx, tables, targetId); err != nil {
+		return false, err
+	}
+
+	// Если реакция уже есть, она снимается
+	result, err := tx.ExecContext(ctx, "DELETE FROM "+tables.reactions+" WHERE "+tables.column+" = $1 AND user_id = $2 AND emoji = $3", targetId, userId, emoji)
+	if err != nil {
+		return false, err
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if removed > 0 {
+		_, err = tx.ExecContext(ctx, "UPDATE "+tables.reactionCounts+" SET count = count - 1 WHERE "+tables.column+" = $1 AND emoji = $2", targetId, emoji)
+		if err != nil {
+			return false, err
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM "+tables.reactionCounts+" WHERE "+tables.column+" = $1 AND emoji = $2 AND count <= 0", targetId, emoji)
+		if err != nil {
+			return false, err
+		}
+	} else {
+		_, err = tx.ExecContext(ctx, "INSERT INTO "+tables.reactions+" ("+tables.column+", user_id, emoji) VALUES ($1, $2, $3)", targetId, userId, emoji)
+		if err != nil {
+			return false, err
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO "+tables.reactionCounts+" ("+tables.column+", emoji, count) VALUES ($1, $2, 1) ON CONFLICT ("+tables.column+", emoji) DO UPDATE SET count = "+tables.reactionCounts+".count + 1", targetId, emoji)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	return removed == 0, nil
+}
+
+// GetReactions возвращает сводку реакций на пост или комментарий
+func (repo *PostgresRepository) GetReactions(ctx context.Context, kind repository.TargetKind, targetId, viewerId string) ([]*models.Reaction, error) {
+	log.Printf("Querying reactions to %s with ID: %s\n", kind, targetId)
+	tables, err := tablesFor(kind)
+	if err != nil {
+		return nil, err
+	}
+	// Эмодзи сравниваются побайтно (COLLATE "C"), как и в in-memory хранилище
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT rc.emoji, rc.count, EXISTS (
+			SELECT 1 FROM `+tables.reactions+` r
+			WHERE r.`+tables.column+` = rc.`+tables.column+` AND r.emoji = rc.emoji AND r.user_id = $2
+		)
+		FROM `+tables.reactionCounts+` rc
+		WHERE rc.`+tables.column+` = $1
+		ORDER BY rc.count DESC, rc.emoji COLLATE "C"
+	`, targetId, viewerId)
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
 
+	reactions := []*models.Reaction{}
 	for rows.Next() {
-		var childId string
-		err := rows.Scan(&childId)
-		if err != nil {
-			return err
+		reaction := &models.Reaction{}
+		if err := rows.Scan(&reaction.Emoji, &reaction.Count, &reaction.ViewerHasReacted); err != nil {
+			return nil, err
 		}
-		childComments = append(childComments, childId)
+		reactions = append(reactions, reaction)
 	}
+	return reactions, rows.Err()
+}
 
-	for _, childId := range childComments {
-		err := repo.deleteChildComments(tx, childId)
-		if err != nil {
-			return err
+// headlineOptions задаёт маркеры выделения и размер фрагментов ts_headline, совпадающие с фрагментами
+// in-memory хранилища. ts_headline не экранирует текст, поэтому слова выделяются маркерами, которые заменяются
+// на теги после экранирования фрагмента, а сами маркеры предварительно удаляются из текста (headlineMarkers).
+const headlineOptions = "StartSel=" + search.RawHighlightStart + ", StopSel=" + search.RawHighlightStop + ", MaxWords=35, MinWords=15"
+
+// headlineMarkers - символы маркеров для удаления из текста функцией translate
+const headlineMarkers = search.RawHighlightStart + search.RawHighlightStop
+
+// Search выполняет полнотекстовый поиск по столбцам search_vector постов и комментариев.
+// Найденные документы содержат все слова запроса (plainto_tsquery), а релевантность
+// считается ts_rank по тем же словам, объединённым через ИЛИ, без учёта их близости,
+// как и в in-memory хранилище.
+func (repo *PostgresRepository) Search(ctx context.Context, query repository.SearchQuery) (*repository.SearchPage, error) {
+	log.Println("Searching for", query.Query)
+	first := query.First
+	if first < 0 {
+		first = 0
+	}
+
+	args := []interface{}{query.Query}
+	selects := []string{}
+	if query.Includes(repository.TargetPost) {
+		selects = append(selects, `
+			SELECT 'post' AS kind, p.id, ts_rank(p.search_vector, q.ranking) AS rank
+			FROM posts p, q WHERE p.search_vector @@ q.matching`)
+	}
+	if query.Includes(repository.TargetComment) {
+		selects = append(selects, `
+			SELECT 'comment' AS kind, c.id, ts_rank(c.search_vector, q.ranking) AS rank
+			FROM comments c, q WHERE c.deleted_at IS NULL AND c.search_vector @@ q.matching`)
+	}
+	hits := `
+		WITH q AS (
+			SELECT plainto_tsquery('russian', $1) AS matching,
+				replace(plainto_tsquery('russian', $1)::text, ' & ', ' | ')::tsquery AS ranking
+		), hits AS (` + strings.Join(selects, " UNION ALL ") + `
+		)`
+
+	page := &repository.SearchPage{Hits: []*repository.SearchHit{}}
+	if len(selects) == 0 {
+		return page, nil
+	}
+	if err := repo.db.QueryRowContext(ctx, hits+" SELECT COUNT(*) FROM hits", args...).Scan(&page.TotalCount); err != nil {
+		return nil, err
+	}
+
+	// Условие keyset-пагинации: результаты строго после курсора в порядке убывания (rank, id)
+	condition := "TRUE"
+	if query.After != nil {
+		args = append(args, query.After.Rank, query.After.ID)
+		condition = fmt.Sprintf(`(rank, id COLLATE "C") < ($%d::real, $%d::text COLLATE "C")`, len(args)-1, len(args))
+	}
+	args = append(args, first+1)
+	rows, err := repo.db.QueryContext(ctx, fmt.Sprintf(
+		`%s SELECT kind, id, rank FROM hits WHERE %s ORDER BY rank DESC, id COLLATE "C" DESC LIMIT $%d`,
+		hits, condition, len(args),
+	), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	postIds, commentIds := []string{}, []string{}
+	for rows.Next() {
+		hit := &repository.SearchHit{}
+		var id string
+		if err := rows.Scan(&hit.Kind, &id, &hit.Rank); err != nil {
+			return nil, err
+		}
+		if hit.Kind == repository.TargetPost {
+			hit.Post = &models.Post{ID: id}
+			postIds = append(postIds, id)
+		} else {
+			hit.Comment = &models.Comment{ID: id}
+			commentIds = append(commentIds, id)
+		}
+		page.Hits = append(page.Hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Лишний результат означает, что за страницей есть продолжение
+	if int64(len(page.Hits)) > first {
+		page.HasNextPage = true
+		page.Hits = page.Hits[:first]
+	}
+
+	// Загрузка найденных объектов вместе с фрагментами текста
+	snippets := map[string]string{}
+	posts, err := repo.searchPosts(ctx, postIds, query.Query, snippets)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := repo.searchComments(ctx, commentIds, query.Query, snippets)
+	if err != nil {
+		return nil, err
+	}
+	for _, hit := range page.Hits {
+		if hit.Kind == repository.TargetPost {
+			hit.Post = posts[hit.ID()]
+		} else {
+			hit.Comment = comments[hit.ID()]
 		}
-		_, err = tx.Exec("DELETE FROM pairs WHERE parent_id = $1 OR child_id = $1", childId)
+		hit.Snippet = snippets[hit.ID()]
+	}
+	return page, nil
+}
+
+// snippetScanner дополняет сканирование строки столбцом с фрагментом текста
+type snippetScanner struct {
+	row     rowScanner
+	snippet *string
+}
+
+func (s snippetScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.snippet)...)
+}
+
+// searchPosts загружает найденные посты и их фрагменты. У поста выделяется текст,
+// а если слов запроса в нём нет - заголовок.
+func (repo *PostgresRepository) searchPosts(ctx context.Context, ids []string, query string, snippets map[string]string) (map[string]*models.Post, error) {
+	posts := map[string]*models.Post{}
+	if len(ids) == 0 {
+		return posts, nil
+	}
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT `+postColumns+`, ts_headline('russian',
+			translate(CASE WHEN to_tsvector('russian', content) @@ q THEN content ELSE title END, $4, ''), q, $3)
+		FROM posts, plainto_tsquery('russian', $2) q
+		WHERE id = ANY($1)
+	`, pq.Array(ids), query, headlineOptions, headlineMarkers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var snippet string
+		post, err := scanPost(snippetScanner{row: rows, snippet: &snippet})
 		if err != nil {
-			return err
+			return nil, err
 		}
-		_, err = tx.Exec("DELETE FROM comments WHERE id = $1", childId)
+		posts[post.ID] = post
+		snippets[post.ID] = search.EscapeHighlighted(snippet)
+	}
+	return posts, rows.Err()
+}
+
+// searchComments загружает найденные комментарии и их фрагменты
+func (repo *PostgresRepository) searchComments(ctx context.Context, ids []string, query string, snippets map[string]string) (map[string]*models.Comment, error) {
+	comments := map[string]*models.Comment{}
+	if len(ids) == 0 {
+		return comments, nil
+	}
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT `+commentColumns+`, ts_headline('russian', translate(c.content, $4, ''), q, $3)
+		FROM comments c, plainto_tsquery('russian', $2) q
+		WHERE c.id = ANY($1)
+	`, pq.Array(ids), query, headlineOptions, headlineMarkers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var snippet string
+		comment, err := scanComment(snippetScanner{row: rows, snippet: &snippet})
 		if err != nil {
-			return err
+			return nil, err
 		}
+		comments[comment.ID] = comment
+		snippets[comment.ID] = search.EscapeHighlighted(snippet)
 	}
+	return comments, rows.Err()
+}
 
-	return nil
+// userColumns — список столбцов таблицы users в порядке, ожидаемом scanUser
+const userColumns = "id, username, password_hash, role, created_at"
+
+// scanUser сканирует строку с userColumns в модель User
+func scanUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// CreateUser создает нового пользователя
+func (repo *PostgresRepository) CreateUser(ctx context.Context, username, passwordHash string) (*models.User, error) {
+	id := uuid.New().String() // Генерация нового уникального ID для пользователя
+	log.Println("Creating user with ID:", id)
+	createdAt := now()
+	_, err := repo.db.ExecContext(ctx, "INSERT INTO users (id, username, password_hash, role, created_at) VALUES ($1, $2, $3, $4, $5)", id, username, passwordHash, models.RoleUser, createdAt)
+	if err != nil {
+		// Нарушение ограничения уникальности означает, что имя пользователя уже занято
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, repository.ErrUsernameTaken
+		}
+		return nil, err
+	}
+	return &models.User{ID: id, Username: username, PasswordHash: passwordHash, Role: models.RoleUser, CreatedAt: createdAt}, nil
+}
+
+// GetUser возвращает пользователя по его ID
+func (repo *PostgresRepository) GetUser(ctx context.Context, id string) (*models.User, error) {
+	log.Println("Querying user with ID:", id)
+	user, err := scanUser(repo.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id))
+	return user, notFound(err, repository.EntityUser, id)
+}
+
+// GetUserByUsername возвращает пользователя по его имени
+func (repo *PostgresRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	log.Println("Querying user with username:", username)
+	user, err := scanUser(repo.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE username = $1", username))
+	return user, notFound(err, repository.EntityUser, username)
+}
+
+// Ping проверяет соединение с базой данных
+func (repo *PostgresRepository) Ping(ctx context.Context) error {
+	return repo.db.PingContext(ctx)
 }
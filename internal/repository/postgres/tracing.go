@@ -0,0 +1,161 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName - имя трассировщика запросов к PostgreSQL
+const tracerName = "github.com/nemopss/go-posts-comments-system/internal/repository/postgres"
+
+// Атрибуты span запроса
+const (
+	attrReturnedRows = "db.response.returned_rows" // Количество прочитанных строк результата
+	attrAffectedRows = "db.response.affected_rows" // Количество изменённых строк
+)
+
+// tracedDB выполняет каждый запрос пула соединений в отдельном span трассировки
+type tracedDB struct {
+	*sql.DB
+}
+
+// tracedTx выполняет каждый запрос транзакции в отдельном span трассировки
+type tracedTx struct {
+	*sql.Tx
+}
+
+func (db tracedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*tracedRows, error) {
+	return tracedQuery(ctx, db.DB.QueryContext, query, args)
+}
+
+func (db tracedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) tracedRow {
+	return tracedQueryRow(ctx, db.DB.QueryRowContext, query, args)
+}
+
+func (db tracedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tracedExec(ctx, db.DB.ExecContext, query, args)
+}
+
+// BeginTx начинает транзакцию, запросы которой тоже выполняются в отдельных span
+func (db tracedDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (tracedTx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	return tracedTx{tx}, err
+}
+
+func (tx tracedTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*tracedRows, error) {
+	return tracedQuery(ctx, tx.Tx.QueryContext, query, args)
+}
+
+func (tx tracedTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) tracedRow {
+	return tracedQueryRow(ctx, tx.Tx.QueryRowContext, query, args)
+}
+
+func (tx tracedTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tracedExec(ctx, tx.Tx.ExecContext, query, args)
+}
+
+// startQuery начинает span запроса query. Span называется по первому ключевому слову запроса,
+// а текст запроса записывается без значений параметров, чтобы в трассы не попадали пользовательские данные.
+func startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	statement := strings.Join(strings.Fields(query), " ")
+	operation, _, _ := strings.Cut(statement, " ")
+	operation = strings.ToUpper(operation)
+	return otel.Tracer(tracerName).Start(ctx, operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation.name", operation),
+		attribute.String("db.query.text", statement),
+	))
+}
+
+// endQuery завершает span запроса, отмечая его ошибкой err. Отсутствие строк ошибкой не считается.
+func endQuery(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func tracedQuery(ctx context.Context, query func(context.Context, string, ...interface{}) (*sql.Rows, error), statement string, args []interface{}) (*tracedRows, error) {
+	ctx, span := startQuery(ctx, statement)
+	rows, err := query(ctx, statement, args...)
+	if err != nil {
+		endQuery(span, err)
+		return nil, err
+	}
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+func tracedQueryRow(ctx context.Context, query func(context.Context, string, ...interface{}) *sql.Row, statement string, args []interface{}) tracedRow {
+	ctx, span := startQuery(ctx, statement)
+	return tracedRow{row: query(ctx, statement, args...), span: span}
+}
+
+func tracedExec(ctx context.Context, exec func(context.Context, string, ...interface{}) (sql.Result, error), statement string, args []interface{}) (sql.Result, error) {
+	ctx, span := startQuery(ctx, statement)
+	result, err := exec(ctx, statement, args...)
+	if err == nil {
+		if affected, err := result.RowsAffected(); err == nil {
+			span.SetAttributes(attribute.Int64(attrAffectedRows, affected))
+		}
+	}
+	endQuery(span, err)
+	return result, err
+}
+
+// tracedRows подсчитывает прочитанные строки результата и завершает span запроса
+// после чтения последней строки или закрытия результата
+type tracedRows struct {
+	*sql.Rows
+	span  trace.Span
+	count int
+}
+
+func (rows *tracedRows) Next() bool {
+	if rows.Rows.Next() {
+		rows.count++
+		return true
+	}
+	rows.end()
+	return false
+}
+
+func (rows *tracedRows) Close() error {
+	err := rows.Rows.Close()
+	rows.end()
+	return err
+}
+
+// end завершает span запроса один раз
+func (rows *tracedRows) end() {
+	if rows.span == nil {
+		return
+	}
+	rows.span.SetAttributes(attribute.Int(attrReturnedRows, rows.count))
+	endQuery(rows.span, rows.Rows.Err())
+	rows.span = nil
+}
+
+// tracedRow завершает span запроса одной строки после её чтения
+type tracedRow struct {
+	row  *sql.Row
+	span trace.Span
+}
+
+func (row tracedRow) Scan(dest ...interface{}) error {
+	err := row.row.Scan(dest...)
+	returned := 1
+	if err != nil {
+		returned = 0
+	}
+	row.span.SetAttributes(attribute.Int(attrReturnedRows, returned))
+	endQuery(row.span, err)
+	return err
+}
@@ -0,0 +1,29 @@
+package repository
+
+import "time"
+
+// PostOrder задаёт порядок сортировки постов.
+// Все порядки убывающие: сначала новые, самые обсуждаемые или недавно активные посты.
+type PostOrder string
+
+const (
+	PostOrderCreatedAt    PostOrder = "CREATED_AT"    // По времени создания поста
+	PostOrderCommentCount PostOrder = "COMMENT_COUNT" // По количеству комментариев
+	PostOrderLastActivity PostOrder = "LAST_ACTIVITY" // По времени последнего комментария
+)
+
+// PostFilter задаёт условия отбора постов. Незаданные (nil) условия не применяются.
+type PostFilter struct {
+	CommentsDisabled *bool      // Отбор по флагу отключения комментариев
+	CreatedAfter     *time.Time // Посты, созданные строго после указанного времени
+	CreatedBefore    *time.Time // Посты, созданные строго до указанного времени
+	TitleContains    *string    // Подстрока заголовка без учёта регистра
+}
+
+// PostsQuery описывает параметры выборки постов
+type PostsQuery struct {
+	First   int64       // Максимальное количество постов на странице
+	After   *PostCursor // Курсор, после которого начинается страница
+	OrderBy PostOrder   // Порядок сортировки, по умолчанию PostOrderCreatedAt
+	Filter  PostFilter  // Условия отбора
+}
@@ -1,38 +1,129 @@
 package repository
 
-import "github.com/nemopss/go-posts-comments-system/internal/models"
+import (
+	"context"
+
+	"github.com/nemopss/go-posts-comments-system/internal/models"
+)
 
 // Repository представляет интерфейс для работы с постами и комментариями
 // и позволяет абстрагироваться от конкретной реализации хранилища данных
-// будь то Postgres или in-memory хранилище
+// будь то Postgres или in-memory хранилище.
+// Все методы принимают контекст запроса: при его отмене или истечении срока
+// хранилище прекращает выполнение операции и возвращает ошибку контекста.
 type Repository interface {
 
 	// GetPosts возвращает список всех постов
 	// Возвращается слайс указателей на модели Post и ошибку в случае неудачи
-	GetPosts() ([]*models.Post, error)
+	GetPosts(ctx context.Context) ([]*models.Post, error)
+
+	// ListPosts возвращает страницу постов, отобранных и упорядоченных согласно параметрам запроса.
+	// Пагинация выполняется по ключу сортировки и ID поста.
+	ListPosts(ctx context.Context, query PostsQuery) (*PostPage, error)
 
 	// GetPost возвращает пост по его идентификатору uuid
 	// Принимает строковый идентификатор поста и возвращает указатель на модель Post и ошибку в случае неудачи
-	GetPost(id string) (*models.Post, error)
+	GetPost(ctx context.Context, id string) (*models.Post, error)
 
 	// CreatePost создаёт новый пост
-	// Принимает заголовок (title), содержание (content) и флаг отключения комментариев (commentsDisabled).
+	// Принимает идентификатор автора (authorId), заголовок (title), содержание (content) и флаг отключения комментариев (commentsDisabled).
 	// Возвращает указатель на созданную модель Post и ошибку в случае неудачи.
-	CreatePost(title, content string, commentsDisabled bool) (*models.Post, error)
+	CreatePost(ctx context.Context, authorId, title, content string, commentsDisabled bool) (*models.Post, error)
 
 	// CreateComment создает новый комментарий к посту.
-	// Принимает идентификатор поста (postId), идентификатор родительского комментария (parentId)
+	// Принимает идентификатор автора (authorId), идентификатор поста (postId), идентификатор родительского комментария (parentId)
 	// и содержание комментария (content). ParentID может быть пустым, если комментарий не является ответом.
 	// Возвращает указатель на созданную модель Comment и ошибку в случае неудачи.
-	CreateComment(postId, parentId, content string) (*models.Comment, error)
+	CreateComment(ctx context.Context, authorId, postId, parentId, content string) (*models.Comment, error)
+
+	// GetComment возвращает комментарий по его идентификатору.
+	GetComment(ctx context.Context, id string) (*models.Comment, error)
+
+	// GetCommentsByPostID возвращает страницу комментариев верхнего уровня для указанного поста.
+	// Комментарии упорядочены по (created_at, id), страница содержит не более first комментариев,
+	// следующих строго после курсора after. Если after равен nil, возвращается первая страница.
+	GetCommentsByPostID(ctx context.Context, postId string, first int64, after *Cursor) (*CommentPage, error)
+
+	// GetCommentsByParentID возвращает страницу дочерних комментариев для указанного комментария.
+	// Порядок и семантика пагинации такие же, как у GetCommentsByPostID.
+	GetCommentsByParentID(ctx context.Context, parentId string, first int64, after *Cursor) (*CommentPage, error)
+
+	// GetCommentsByPostIDs возвращает страницы комментариев верхнего уровня сразу для нескольких постов.
+	// Параметры first и after применяются к каждому посту отдельно, как в GetCommentsByPostID.
+	// Результат содержит страницу для каждого запрошенного поста, в том числе пустую.
+	GetCommentsByPostIDs(ctx context.Context, postIds []string, first int64, after *Cursor) (map[string]*CommentPage, error)
+
+	// GetCommentsByParentIDs возвращает страницы дочерних комментариев сразу для нескольких комментариев.
+	// Параметры first и after применяются к каждому комментарию отдельно, как в GetCommentsByParentID.
+	// Результат содержит страницу для каждого запрошенного комментария, в том числе пустую.
+	GetCommentsByParentIDs(ctx context.Context, parentIds []string, first int64, after *Cursor) (map[string]*CommentPage, error)
+
+	// GetCommentAncestors возвращает цепочку предков комментария от комментария верхнего уровня до родителя.
+	// Для комментария верхнего уровня возвращается пустой список.
+	GetCommentAncestors(ctx context.Context, id string) ([]*models.Comment, error)
+
+	// GetCommentThread возвращает всё дерево комментариев поста одним списком в прямом порядке обхода:
+	// за каждым комментарием следуют ответы на него, а комментарии одного уровня упорядочены по (created_at, id).
+	// Глубина и количество комментариев ограничиваются параметрами запроса. Надгробия включаются в список.
+	GetCommentThread(ctx context.Context, query ThreadQuery) ([]*ThreadComment, error)
+
+	// UpdatePost изменяет заголовок, содержание и флаг отключения комментариев поста
+	// и сохраняет новую версию поста от имени редактора (editorId).
+	// Возвращает изменённый пост.
+	UpdatePost(ctx context.Context, editorId, id, title, content string, commentsDisabled bool) (*models.Post, error)
+
+	// UpdateComment изменяет содержание комментария и сохраняет новую версию комментария от имени редактора (editorId).
+	// Возвращает изменённый комментарий.
+	UpdateComment(ctx context.Context, editorId, id, content string) (*models.Comment, error)
+
+	// GetPostRevisions возвращает все версии поста в порядке возрастания номера.
+	// Первая версия - исходный пост, последняя совпадает с текущим состоянием поста.
+	GetPostRevisions(ctx context.Context, postId string) ([]*models.PostRevision, error)
+
+	// GetCommentRevisions возвращает все версии комментария в порядке возрастания номера.
+	GetCommentRevisions(ctx context.Context, commentId string) ([]*models.CommentRevision, error)
+
+	DeletePost(ctx context.Context, id string) error
+
+	// DeleteComment удаляет комментарий вместе со всеми ответами на него.
+	DeleteComment(ctx context.Context, id string) error
+
+	// SoftDeleteComment заменяет комментарий надгробием: содержимое и история изменений удаляются,
+	// а ответы на комментарий остаются доступными. Повторное удаление надгробия ничего не меняет.
+	// Возвращает надгробие.
+	SoftDeleteComment(ctx context.Context, id string) (*models.Comment, error)
+
+	// Vote устанавливает голос пользователя (userId) за пост или комментарий: VoteUp, VoteDown или VoteNone, чтобы отменить голос.
+	// У каждого пользователя один голос за объект, повторное голосование заменяет прежний голос.
+	// Счётчики голосов объекта обновляются в той же операции.
+	Vote(ctx context.Context, kind TargetKind, targetId, userId string, value int) error
+
+	// GetVote возвращает голос пользователя за пост или комментарий, VoteNone, если пользователь не голосовал.
+	GetVote(ctx context.Context, kind TargetKind, targetId, userId string) (int, error)
+
+	// React добавляет реакцию пользователя эмодзи на пост или комментарий, а если она уже есть - снимает её.
+	// Возвращает true, если после вызова реакция есть.
+	React(ctx context.Context, kind TargetKind, targetId, userId, emoji string) (bool, error)
+
+	// GetReactions возвращает сводку реакций на пост или комментарий в порядке убывания количества,
+	// отмечая реакции пользователя viewerId (пустой для анонимных запросов).
+	GetReactions(ctx context.Context, kind TargetKind, targetId, viewerId string) ([]*models.Reaction, error)
+
+	// Search выполняет полнотекстовый поиск по постам и комментариям с учётом морфологии русского и английского языков.
+	// Результаты упорядочены по убыванию релевантности, удалённые комментарии не ищутся.
+	Search(ctx context.Context, query SearchQuery) (*SearchPage, error)
 
-	// GetCommentsByPostID возвращает список комментариев для указанного поста.
-	GetCommentsByPostID(postId string, first int64, after *string) ([]*models.Comment, error)
+	// CreateUser создаёт нового пользователя с заданным именем и хешем пароля.
+	// Возвращает ErrUsernameTaken, если имя пользователя уже занято.
+	CreateUser(ctx context.Context, username, passwordHash string) (*models.User, error)
 
-	// GetCommentsByParentID возвращает список дочерних комментариев для указанного комментария.
-	GetCommentsByParentID(parentId string, first int64, after *string) ([]*models.Comment, error)
+	// GetUser возвращает пользователя по его идентификатору.
+	GetUser(ctx context.Context, id string) (*models.User, error)
 
-	DeletePost(id string) error
+	// GetUserByUsername возвращает пользователя по его имени.
+	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
 
-	DeleteComment(id string) error
+	// Ping проверяет, что хранилище доступно и может выполнять операции.
+	// Используется проверкой готовности сервера.
+	Ping(ctx context.Context) error
 }
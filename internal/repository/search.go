@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/nemopss/go-posts-comments-system/internal/models"
+)
+
+// SearchQuery описывает параметры полнотекстового поиска
+type SearchQuery struct {
+	Query string        // Текст запроса, найденные документы содержат все его значимые слова
+	First int64         // Максимальное количество результатов на странице
+	After *SearchCursor // Курсор, после которого начинается страница
+	Kinds []TargetKind  // Виды искомых объектов, пустой список означает посты и комментарии
+}
+
+// Includes сообщает, ищутся ли объекты заданного вида
+func (q SearchQuery) Includes(kind TargetKind) bool {
+	if len(q.Kinds) == 0 {
+		return true
+	}
+	for _, k := range q.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchHit представляет найденный пост или комментарий
+type SearchHit struct {
+	Kind    TargetKind      // Вид найденного объекта
+	Post    *models.Post    // Найденный пост (для TargetPost)
+	Comment *models.Comment // Найденный комментарий (для TargetComment)
+	Rank    float64         // Релевантность объекта запросу
+	Snippet string          // Фрагмент текста с выделенными словами запроса
+}
+
+// ID возвращает идентификатор найденного объекта
+func (h *SearchHit) ID() string {
+	if h.Kind == TargetPost {
+		return h.Post.ID
+	}
+	return h.Comment.ID
+}
+
+// SearchPage представляет страницу результатов поиска
+type SearchPage struct {
+	Hits        []*SearchHit // Результаты в порядке убывания релевантности
+	HasNextPage bool         // Есть ли результаты после последнего на странице
+	TotalCount  int          // Общее количество найденных объектов
+}
+
+// SearchCursor представляет позицию результата в списке, упорядоченном по убыванию (rank, id)
+type SearchCursor struct {
+	Rank float64 `json:"r"`
+	ID   string  `json:"id"`
+}
+
+// SearchCursorOf возвращает курсор, указывающий на результат поиска
+func SearchCursorOf(hit *SearchHit) SearchCursor {
+	return SearchCursor{Rank: hit.Rank, ID: hit.ID()}
+}
+
+// EncodeSearchCursor кодирует курсор поиска в непрозрачную строку для передачи клиенту
+func EncodeSearchCursor(c SearchCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeSearchCursor разбирает строку, полученную от клиента, в курсор поиска
+func DecodeSearchCursor(s string) (*SearchCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor SearchCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// Precedes сообщает, находится ли результат с ключом (rank, id) строго после курсора
+func (c SearchCursor) Precedes(rank float64, id string) bool {
+	if rank != c.Rank {
+		return rank < c.Rank
+	}
+	return id < c.ID
+}
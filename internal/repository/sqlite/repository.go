@@ -0,0 +1,1208 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nemopss/go-posts-comments-system/internal/models"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+	"github.com/nemopss/go-posts-comments-system/internal/search"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+func init() {
+	// casefold приводит строку к нижнему регистру с учётом Unicode:
+	// встроенные lower() и LIKE в SQLite не различают регистр только у латиницы
+	sqlite.MustRegisterDeterministicScalarFunction("casefold", 1, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return args[0], nil
+		}
+		return strings.ToLower(s), nil
+	})
+}
+
+// SQLiteRepository представляет собой хранилище данных во встроенной базе SQLite
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteRepository создает новый экземпляр SQLiteRepository
+func NewSQLiteRepository(db *sql.DB) *SQLiteRepository {
+	return &SQLiteRepository{db: db}
+}
+
+// Open открывает файл базы данных SQLite, создавая его при необходимости.
+// Включаются внешние ключи (без них не работает каскадное удаление) и журнал WAL,
+// а транзакции сразу захватывают блокировку записи, заменяя SELECT ... FOR UPDATE.
+func Open(path string) (*sql.DB, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// now возвращает текущее время в UTC с точностью до микросекунд, как и в PostgreSQL.
+// Время хранится текстом, поэтому для правильного сравнения все значения должны быть в UTC.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// postColumns — список столбцов таблицы posts в порядке, ожидаемом scanPost
+const postColumns = "id, author_id, title, content, comments_disabled, created_at, comment_count, last_activity_at, edited_at, upvotes, downvotes"
+
+// commentColumns — список столбцов таблицы comments в порядке, ожидаемом scanComment
+const commentColumns = "c.id, c.post_id, c.parent_id, c.author_id, c.content, c.created_at, c.edited_at, c.deleted_at, c.upvotes, c.downvotes"
+
+// commentReturning — те же столбцы без псевдонима таблицы: SQLite не допускает его в RETURNING
+const commentReturning = "id, post_id, parent_id, author_id, content, created_at, edited_at, deleted_at, upvotes, downvotes"
+
+// rowScanner обобщает *sql.Row и *sql.Rows для сканирования одной строки
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPost сканирует строку с postColumns в модель Post
+func scanPost(row rowScanner) (*models.Post, error) {
+	post := &models.Post{}
+	var editedAt sql.NullTime
+	err := row.Scan(&post.ID, &post.AuthorID, &post.Title, &post.Content, &post.CommentsDisabled, &post.CreatedAt, &post.CommentCount, &post.LastActivityAt, &editedAt, &post.Upvotes, &post.Downvotes)
+	if err != nil {
+		return nil, err
+	}
+	if editedAt.Valid {
+		post.EditedAt = &editedAt.Time
+	}
+	return post, nil
+}
+
+// scanComment сканирует строку с commentColumns в модель Comment
+func scanComment(row rowScanner) (*models.Comment, error) {
+	comment := &models.Comment{}
+	var parentId sql.NullString
+	var editedAt, deletedAt sql.NullTime
+	err := row.Scan(&comment.ID, &comment.PostID, &parentId, &comment.AuthorID, &comment.Content, &comment.CreatedAt, &editedAt, &deletedAt, &comment.Upvotes, &comment.Downvotes)
+	if err != nil {
+		return nil, err
+	}
+	if parentId.Valid {
+		comment.ParentID = &parentId.String
+	}
+	if editedAt.Valid {
+		comment.EditedAt = &editedAt.Time
+	}
+	if deletedAt.Valid {
+		comment.DeletedAt = &deletedAt.Time
+	}
+	return comment, nil
+}
+
+// notFound заменяет sql.ErrNoRows на ошибку ненайденного объекта
+func notFound(err error, entity, id string) error {
+	if err == sql.ErrNoRows {
+		return repository.NotFound(entity, id)
+	}
+	return err
+}
+
+// GetPosts возвращает список всех постов
+func (repo *SQLiteRepository) GetPosts(ctx context.Context) ([]*models.Post, error) {
+	log.Println("Querying posts...")
+	rows, err := repo.db.QueryContext(ctx, "SELECT "+postColumns+" FROM posts")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []*models.Post{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// postOrderColumns сопоставляет порядку сортировки столбец таблицы posts
+var postOrderColumns = map[repository.PostOrder]string{
+	repository.PostOrderCreatedAt:    "created_at",
+	repository.PostOrderCommentCount: "comment_count",
+	repository.PostOrderLastActivity: "last_activity_at",
+}
+
+// ListPosts возвращает страницу постов, отобранных и упорядоченных согласно параметрам запроса
+func (repo *SQLiteRepository) ListPosts(ctx context.Context, query repository.PostsQuery) (*repository.PostPage, error) {
+	log.Println("Listing posts ordered by", query.OrderBy)
+	if query.OrderBy == "" {
+		query.OrderBy = repository.PostOrderCreatedAt
+	}
+	orderColumn, ok := postOrderColumns[query.OrderBy]
+	if !ok {
+		return nil, repository.Invalid("orderBy", fmt.Sprintf("unknown post order %q", query.OrderBy))
+	}
+	first := query.First
+	if first < 0 {
+		first = 0
+	}
+
+	// Построение условий фильтра
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	filter := query.Filter
+	if filter.CommentsDisabled != nil {
+		addCondition("comments_disabled = $%d", *filter.CommentsDisabled)
+	}
+	if filter.CreatedAfter != nil {
+		addCondition("created_at > $%d", filter.CreatedAfter.UTC())
+	}
+	if filter.CreatedBefore != nil {
+		addCondition("created_at < $%d", filter.CreatedBefore.UTC())
+	}
+	if filter.TitleContains != nil {
+		addCondition(`casefold(title) LIKE casefold($%d) ESCAPE '\'`, "%"+escapeLike(*filter.TitleContains)+"%")
+	}
+
+	page := &repository.PostPage{}
+	err := repo.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM posts WHERE "+strings.Join(conditions, " AND "), args...).Scan(&page.TotalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	// Условие keyset-пагинации: посты строго после курсора в порядке убывания
+	if query.After != nil {
+		var key interface{} = query.After.Time.UTC()
+		if query.OrderBy == repository.PostOrderCommentCount {
+			key = query.After.Count
+		}
+		args = append(args, key, query.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, id) < ($%d, $%d)", orderColumn, len(args)-1, len(args)))
+	}
+	args = append(args, first+1)
+
+	rows, err := repo.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT %s FROM posts WHERE %s ORDER BY %s DESC, id DESC LIMIT $%d",
+		postColumns, strings.Join(conditions, " AND "), orderColumn, len(args),
+	), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []*models.Post{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Лишний пост означает, что за страницей есть продолжение
+	if int64(len(posts)) > first {
+		page.HasNextPage = true
+		posts = posts[:first]
+	}
+	page.Posts = posts
+	return page, nil
+}
+
+// escapeLike экранирует специальные символы шаблона LIKE
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// GetPost возвращает пост по его ID
+func (repo *SQLiteRepository) GetPost(ctx context.Context, id string) (*models.Post, error) {
+	log.Println("Querying post with ID:", id)
+	post, err := scanPost(repo.db.QueryRowContext(ctx, "SELECT "+postColumns+" FROM posts WHERE id=$1", id))
+	return post, notFound(err, repository.EntityPost, id)
+}
+
+// CreatePost создает новый пост
+func (repo *SQLiteRepository) CreatePost(ctx context.Context, authorId, title, content string, commentsDisabled bool) (*models.Post, error) {
+	id := uuid.New().String() // Генерация нового уникального ID для поста
+	log.Println("Creating post with ID:", id)
+	createdAt := now() // Текущее время как время создания поста
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO posts (id, author_id, title, content, comments_disabled, created_at, last_activity_at) VALUES ($1, $2, $3, $4, $5, $6, $6)", id, authorId, title, content, commentsDisabled, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Сохранение исходной версии поста
+	_, err = tx.ExecContext(ctx, "INSERT INTO post_revisions (post_id, revision, title, content, comments_disabled, editor_id, created_at) VALUES ($1, 1, $2, $3, $4, $5, $6)", id, title, content, commentsDisabled, authorId, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := indexDocument(ctx, tx, repository.TargetPost, id, title, content); err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Created post", id)
+	return &models.Post{ID: id, AuthorID: authorId, Title: title, Content: content, CommentsDisabled: commentsDisabled, CreatedAt: createdAt, LastActivityAt: createdAt}, nil
+}
+
+// CreateComment создает новый комментарий
+func (repo *SQLiteRepository) CreateComment(ctx context.Context, authorId, postId, parentId, content string) (*models.Comment, error) {
+	if err := repository.ValidateCommentContent(content); err != nil {
+		return nil, err
+	}
+	id := uuid.New().String() // Генерация нового уникального ID для комментария
+	log.Println("Creating comment with ID:", id)
+	createdAt := now() // Текущее время как время создания комментария
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	target := repository.CommentTarget{}
+	err = tx.QueryRowContext(ctx, "SELECT comments_disabled FROM posts WHERE id = $1", postId).Scan(&target.CommentsDisabled)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	target.PostFound = err == nil
+
+	var parentIdSQL interface{}
+	if parentId != "" {
+		parentIdSQL = parentId
+		err = tx.QueryRowContext(ctx, "SELECT post_id, deleted_at IS NOT NULL FROM comments WHERE id = $1", parentId).Scan(&target.ParentPostID, &target.ParentDeleted)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		target.ParentFound = err == nil
+	}
+	if err := repository.ValidateNewComment(postId, parentId, content, target); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO comments (id, post_id, parent_id, author_id, content, created_at) VALUES ($1, $2, $3, $4, $5, $6)", id, postId, parentIdSQL, authorId, content, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Пары комментария с самим собой и со всеми предками родителя в таблице замыкания
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO comment_closure (ancestor_id, descendant_id, depth)
+		SELECT $1, $1, 0
+		UNION ALL
+		SELECT ancestor_id, $1, depth + 1 FROM comment_closure WHERE descendant_id = $2
+	`, id, parentId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Сохранение исходной версии комментария
+	_, err = tx.ExecContext(ctx, "INSERT INTO comment_revisions (comment_id, revision, content, editor_id, created_at) VALUES ($1, 1, $2, $3, $4)", id, content, authorId, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := indexDocument(ctx, tx, repository.TargetComment, id, content); err != nil {
+		return nil, err
+	}
+
+	// Обновление счётчика комментариев и времени последней активности поста
+	_, err = tx.ExecContext(ctx, "UPDATE posts SET comment_count = comment_count + 1, last_activity_at = $2 WHERE id = $1", postId, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Created comment on post %v...\n", postId)
+	comment := &models.Comment{ID: id, PostID: postId, AuthorID: authorId, Content: content, CreatedAt: createdAt}
+	if parentId != "" {
+		comment.ParentID = &parentId
+	}
+	return comment, nil
+}
+
+// UpdatePost изменяет пост и сохраняет его новую версию
+func (repo *SQLiteRepository) UpdatePost(ctx context.Context, editorId, id, title, content string, commentsDisabled bool) (*models.Post, error) {
+	log.Println("Updating post with ID:", id)
+	editedAt := now()
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	post, err := scanPost(tx.QueryRowContext(ctx, "UPDATE posts SET title = $2, content = $3, comments_disabled = $4, edited_at = $5 WHERE id = $1 RETURNING "+postColumns, id, title, content, commentsDisabled, editedAt))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.NotFound(repository.EntityPost, id)
+		}
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO post_revisions (post_id, revision, title, content, comments_disabled, editor_id, created_at)
+		SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4, $5, $6 FROM post_revisions WHERE post_id = $1
+	`, id, title, content, commentsDisabled, editorId, editedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := indexDocument(ctx, tx, repository.TargetPost, id, title, content); err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+// UpdateComment изменяет комментарий и сохраняет его новую версию
+func (repo *SQLiteRepository) UpdateComment(ctx context.Context, editorId, id, content string) (*models.Comment, error) {
+	log.Println("Updating comment with ID:", id)
+	if err := repository.ValidateCommentContent(content); err != nil {
+		return nil, err
+	}
+	editedAt := now()
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	comment, err := scanComment(tx.QueryRowContext(ctx, "UPDATE comments SET content = $2, edited_at = $3 WHERE id = $1 AND deleted_at IS NULL RETURNING "+commentReturning, id, content, editedAt))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// Комментарий либо не существует, либо удалён
+			var deleted bool
+			if tx.QueryRowContext(ctx, "SELECT deleted_at IS NOT NULL FROM comments WHERE id = $1", id).Scan(&deleted) == nil && deleted {
+				return nil, repository.ErrCommentDeleted
+			}
+			return nil, repository.NotFound(repository.EntityComment, id)
+		}
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO comment_revisions (comment_id, revision, content, editor_id, created_at)
+		SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4 FROM comment_revisions WHERE comment_id = $1
+	`, id, content, editorId, editedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := indexDocument(ctx, tx, repository.TargetComment, id, content); err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// GetPostRevisions возвращает все версии поста в порядке возрастания номера
+func (repo *SQLiteRepository) GetPostRevisions(ctx context.Context, postId string) ([]*models.PostRevision, error) {
+	log.Println("Getting revisions of post with ID:", postId)
+	rows, err := repo.db.QueryContext(ctx, "SELECT post_id, revision, title, content, comments_disabled, editor_id, created_at FROM post_revisions WHERE post_id = $1 ORDER BY revision", postId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.PostRevision{}
+	for rows.Next() {
+		revision := &models.PostRevision{}
+		err := rows.Scan(&revision.PostID, &revision.Revision, &revision.Title, &revision.Content, &revision.CommentsDisabled, &revision.EditorID, &revision.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// У существующего поста всегда есть хотя бы одна версия
+	if len(revisions) == 0 {
+		return nil, repository.NotFound(repository.EntityPost, postId)
+	}
+	return revisions, nil
+}
+
+// GetCommentRevisions возвращает все версии комментария в порядке возрастания номера
+func (repo *SQLiteRepository) GetCommentRevisions(ctx context.Context, commentId string) ([]*models.CommentRevision, error) {
+	log.Println("Getting revisions of comment with ID:", commentId)
+	rows, err := repo.db.QueryContext(ctx, "SELECT comment_id, revision, content, editor_id, created_at FROM comment_revisions WHERE comment_id = $1 ORDER BY revision", commentId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.CommentRevision{}
+	for rows.Next() {
+		revision := &models.CommentRevision{}
+		err := rows.Scan(&revision.CommentID, &revision.Revision, &revision.Content, &revision.EditorID, &revision.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Версий нет у удалённого комментария и у несуществующего
+	if len(revisions) == 0 {
+		var exists bool
+		err := repo.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM comments WHERE id = $1)", commentId).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, repository.NotFound(repository.EntityComment, commentId)
+		}
+	}
+	return revisions, nil
+}
+
+// GetComment возвращает комментарий по его ID
+func (repo *SQLiteRepository) GetComment(ctx context.Context, id string) (*models.Comment, error) {
+	log.Println("Querying comment with ID:", id)
+	comment, err := scanComment(repo.db.QueryRowContext(ctx, "SELECT "+commentColumns+" FROM comments c WHERE c.id=$1", id))
+	return comment, notFound(err, repository.EntityComment, id)
+}
+
+// GetCommentsByPostID возвращает страницу комментариев верхнего уровня для указанного поста
+func (repo *SQLiteRepository) GetCommentsByPostID(ctx context.Context, postId string, first int64, after *repository.Cursor) (*repository.CommentPage, error) {
+	log.Println("Getting comments on post with ID:", postId)
+	pages, err := repo.getCommentPages(ctx, postKey, []string{postId}, first, after)
+	if err != nil {
+		return nil, err
+	}
+	return pages[postId], nil
+}
+
+// GetCommentsByParentID возвращает страницу дочерних комментариев для указанного комментария
+func (repo *SQLiteRepository) GetCommentsByParentID(ctx context.Context, parentId string, first int64, after *repository.Cursor) (*repository.CommentPage, error) {
+	log.Println("Getting comments from parent with ID:", parentId)
+	pages, err := repo.getCommentPages(ctx, parentKey, []string{parentId}, first, after)
+	if err != nil {
+		return nil, err
+	}
+	return pages[parentId], nil
+}
+
+// GetCommentsByPostIDs возвращает страницы комментариев верхнего уровня для нескольких постов
+func (repo *SQLiteRepository) GetCommentsByPostIDs(ctx context.Context, postIds []string, first int64, after *repository.Cursor) (map[string]*repository.CommentPage, error) {
+	log.Println("Getting comments on posts with IDs:", postIds)
+	return repo.getCommentPages(ctx, postKey, postIds, first, after)
+}
+
+// GetCommentsByParentIDs возвращает страницы дочерних комментариев для нескольких комментариев
+func (repo *SQLiteRepository) GetCommentsByParentIDs(ctx context.Context, parentIds []string, first int64, after *repository.Cursor) (map[string]*repository.CommentPage, error) {
+	log.Println("Getting comments from parents with IDs:", parentIds)
+	return repo.getCommentPages(ctx, parentKey, parentIds, first, after)
+}
+
+// pageKey описывает, по какому столбцу комментарии группируются в страницы
+type pageKey struct {
+	column string                       // Столбец, значения которого перечислены в запросе
+	where  string                       // Дополнительное условие отбора
+	of     func(*models.Comment) string // Значение столбца у выбранного комментария
+}
+
+var (
+	// postKey группирует комментарии верхнего уровня по постам
+	postKey = pageKey{
+		column: "c.post_id",
+		where:  " AND c.parent_id IS NULL",
+		of:     func(c *models.Comment) string { return c.PostID },
+	}
+	// parentKey группирует ответы по родительским комментариям
+	parentKey = pageKey{
+		column: "c.parent_id",
+		of:     func(c *models.Comment) string { return *c.ParentID },
+	}
+)
+
+// getCommentPages выбирает страницы комментариев для каждого из значений ids столбца key.
+// Используется keyset-пагинация по (created_at, id) внутри каждой группы: оконная функция нумерует
+// комментарии группы, и для каждой группы выбирается на один комментарий больше, чтобы определить,
+// есть ли следующая страница.
+func (repo *SQLiteRepository) getCommentPages(ctx context.Context, key pageKey, ids []string, first int64, after *repository.Cursor) (map[string]*repository.CommentPage, error) {
+	if first < 0 {
+		first = 0
+	}
+	pages := make(map[string]*repository.CommentPage, len(ids))
+	for _, id := range ids {
+		pages[id] = &repository.CommentPage{Comments: []*models.Comment{}}
+	}
+	if len(ids) == 0 {
+		return pages, nil
+	}
+
+	// Идентификаторы передаются одним параметром в виде JSON массива
+	idList, err := json.Marshal(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := repo.db.QueryContext(ctx, "SELECT "+key.column+", COUNT(*) FROM comments c WHERE "+key.column+" IN (SELECT value FROM json_each($1))"+key.where+" GROUP BY "+key.column, string(idList))
+	if err != nil {
+		return nil, err
+	}
+	defer counts.Close()
+	for counts.Next() {
+		var id string
+		var count int
+		if err := counts.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+		pages[id].TotalCount = count
+	}
+	if err := counts.Err(); err != nil {
+		return nil, err
+	}
+
+	condition := key.column + " IN (SELECT value FROM json_each($1))" + key.where
+	args := []interface{}{string(idList), first + 1}
+	if after != nil {
+		condition += " AND (c.created_at, c.id) > ($3, $4)"
+		args = append(args, after.CreatedAt.UTC(), after.ID)
+	}
+
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT `+commentColumns+`
+		FROM (
+			SELECT c.*, ROW_NUMBER() OVER (PARTITION BY `+key.column+` ORDER BY c.created_at, c.id) AS position
+			FROM comments c
+			WHERE `+condition+`
+		) c
+		WHERE c.position <= $2
+		ORDER BY c.created_at, c.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		page := pages[key.of(comment)]
+		page.Comments = append(page.Comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Лишний комментарий означает, что за страницей есть продолжение
+	for _, page := range pages {
+		if int64(len(page.Comments)) > first {
+			page.HasNextPage = true
+			page.Comments = page.Comments[:first]
+		}
+	}
+	return pages, nil
+}
+
+// GetCommentAncestors возвращает цепочку предков комментария по таблице замыкания
+func (repo *SQLiteRepository) GetCommentAncestors(ctx context.Context, id string) ([]*models.Comment, error) {
+	log.Println("Getting ancestors of comment with ID:", id)
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT `+commentColumns+`
+		FROM comment_closure cl
+		JOIN comments c ON c.id = cl.ancestor_id
+		WHERE cl.descendant_id = $1 AND cl.depth > 0
+		ORDER BY cl.depth DESC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ancestors := []*models.Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		ancestors = append(ancestors, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Пустая цепочка может означать, что комментария нет
+	if len(ancestors) == 0 {
+		if _, err := repo.GetComment(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	return ancestors, nil
+}
+
+// threadScanner дополняет сканирование строки столбцами глубины и пути комментария в дереве
+type threadScanner struct {
+	row   rowScanner
+	depth *int
+	path  *string
+}
+
+func (s threadScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.depth, s.path)...)
+}
+
+// GetCommentThread возвращает дерево комментариев поста одним рекурсивным запросом.
+// Ключ сортировки - строка из пар (created_at, id) от комментария верхнего уровня до текущего,
+// разделённых символами с кодами меньше любого символа пары: сравнение таких строк даёт прямой порядок обхода дерева.
+func (repo *SQLiteRepository) GetCommentThread(ctx context.Context, query repository.ThreadQuery) ([]*repository.ThreadComment, error) {
+	log.Println("Getting comment thread of post with ID:", query.PostID)
+	// LIMIT -1 не ограничивает количество строк
+	limit := query.First
+	if limit < 0 {
+		limit = -1
+	}
+	rows, err := repo.db.QueryContext(ctx, `
+		WITH RECURSIVE thread (id, depth, path, sort_key) AS (
+			SELECT c.id, 0, c.id, c.created_at || char(2) || c.id
+			FROM comments c
+			WHERE c.post_id = $1 AND c.parent_id IS NULL
+			UNION ALL
+			SELECT c.id, t.depth + 1, t.path || ',' || c.id, t.sort_key || char(1) || c.created_at || char(2) || c.id
+			FROM comments c
+			JOIN thread t ON c.parent_id = t.id
+			WHERE $2 < 0 OR t.depth < $2
+		)
+		SELECT `+commentColumns+`, t.depth, t.path
+		FROM thread t
+		JOIN comments c ON c.id = t.id
+		ORDER BY t.sort_key
+		LIMIT $3
+	`, query.PostID, query.MaxDepth, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	thread := []*repository.ThreadComment{}
+	for rows.Next() {
+		entry := &repository.ThreadComment{}
+		var path string
+		entry.Comment, err = scanComment(threadScanner{row: rows, depth: &entry.Depth, path: &path})
+		if err != nil {
+			return nil, err
+		}
+		entry.Path = strings.Split(path, ",")
+		thread = append(thread, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Пустое дерево может означать, что поста нет
+	if len(thread) == 0 {
+		if _, err := repo.GetPost(ctx, query.PostID); err != nil {
+			return nil, err
+		}
+	}
+	return thread, nil
+}
+
+// DeletePost удаляет пост по его ID. Комментарии, их связи, версии, голоса и реакции
+// удаляются каскадно, а записи поискового индекса - триггерами.
+func (repo *SQLiteRepository) DeletePost(ctx context.Context, id string) error {
+	log.Println("Deleting post with ID:", id)
+	result, err := repo.db.ExecContext(ctx, "DELETE FROM posts WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+		return repository.NotFound(repository.EntityPost, id)
+	}
+	return nil
+}
+
+// DeleteComment удаляет комментарий по его ID вместе со всеми ответами на него
+func (repo *SQLiteRepository) DeleteComment(ctx context.Context, id string) error {
+	log.Println("Deleting comment with ID:", id)
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var postId string
+	err = tx.QueryRowContext(ctx, "SELECT post_id FROM comments WHERE id = $1", id).Scan(&postId)
+	if err != nil {
+		return notFound(err, repository.EntityComment, id)
+	}
+
+	// Подсчёт комментария и всех вложенных в него ответов по таблице замыкания
+	var deleted int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM comment_closure WHERE ancestor_id = $1", id).Scan(&deleted)
+	if err != nil {
+		return err
+	}
+
+	// Удаление всего поддерева одним запросом, связи в таблице замыкания удаляются каскадно
+	_, err = tx.ExecContext(ctx, "DELETE FROM comments WHERE id IN (SELECT descendant_id FROM comment_closure WHERE ancestor_id = $1)", id)
+	if err != nil {
+		return err
+	}
+
+	// Обновление счётчика комментариев поста с учётом всех удалённых ответов
+	_, err = tx.ExecContext(ctx, "UPDATE posts SET comment_count = comment_count - $2 WHERE id = $1", postId, deleted)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SoftDeleteComment заменяет комментарий надгробием, сохраняя ответы на него
+func (repo *SQLiteRepository) SoftDeleteComment(ctx context.Context, id string) (*models.Comment, error) {
+	log.Println("Soft deleting comment with ID:", id)
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// COALESCE сохраняет время первого удаления при повторном вызове
+	comment, err := scanComment(tx.QueryRowContext(ctx, "UPDATE comments SET content = '', deleted_at = COALESCE(deleted_at, $2) WHERE id = $1 RETURNING "+commentReturning, id, now()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.NotFound(repository.EntityComment, id)
+		}
+		return nil, err
+	}
+
+	// История изменений и поисковые термы удаляются вместе с содержимым
+	_, err = tx.ExecContext(ctx, "DELETE FROM comment_revisions WHERE comment_id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM search_index WHERE kind = $1 AND id = $2", repository.TargetComment, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// voteTables описывает таблицы голосов и реакций одного вида объектов
+type voteTables struct {
+	target         string // Таблица объектов
+	column         string // Столбец с идентификатором объекта в таблицах голосов и реакций
+	votes          string // Таблица голосов
+	reactions      string // Таблица реакций
+	reactionCounts string // Таблица счётчиков реакций
+	deleted        string // Выражение, истинное для удалённого объекта
+	entity         string // Вид объекта в ошибке NotFoundError
+}
+
+// targetTables сопоставляет виду объекта его таблицы голосов и реакций
+var targetTables = map[repository.TargetKind]voteTables{
+	repository.TargetPost: {
+		target:         "posts",
+		column:         "post_id",
+		votes:          "post_votes",
+		reactions:      "post_reactions",
+		reactionCounts: "post_reaction_counts",
+		deleted:        "FALSE",
+		entity:         repository.EntityPost,
+	},
+	repository.TargetComment: {
+		target:         "comments",
+		column:         "comment_id",
+		votes:          "comment_votes",
+		reactions:      "comment_reactions",
+		reactionCounts: "comment_reaction_counts",
+		deleted:        "deleted_at IS NOT NULL",
+		entity:         repository.EntityComment,
+	},
+}
+
+// tablesFor возвращает таблицы голосов и реакций для вида объекта
+func tablesFor(kind repository.TargetKind) (voteTables, error) {
+	tables, ok := targetTables[kind]
+	if !ok {
+		return voteTables{}, fmt.Errorf("unknown target kind %q", kind)
+	}
+	return tables, nil
+}
+
+// checkTarget проверяет, что пост или комментарий существует и не удалён. Транзакции
+// открываются с блокировкой записи, поэтому голоса и реакции применяются последовательно.
+func checkTarget(ctx context.Context, tx *sql.Tx, tables voteTables, id string) error {
+	var deleted bool
+	err := tx.QueryRowContext(ctx, "SELECT "+tables.deleted+" FROM "+tables.target+" WHERE id = $1", id).Scan(&deleted)
+	if err == sql.ErrNoRows {
+		return repository.NotFound(tables.entity, id)
+	}
+	if err != nil {
+		return err
+	}
+	if deleted {
+		return repository.ErrCommentDeleted
+	}
+	return nil
+}
+
+// Vote устанавливает голос пользователя за пост или комментарий и обновляет счётчики голосов
+func (repo *SQLiteRepository) Vote(ctx context.Context, kind repository.TargetKind, targetId, userId string, value int) error {
+	log.Printf("Voting %d for %s with ID: %s\n", value, kind, targetId)
+	if value < repository.VoteDown || value > repository.VoteUp {
+		return repository.ErrInvalidVote
+	}
+	tables, err := tablesFor(kind)
+	if err != nil {
+		return err
+	}
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkTarget(ctx, tx, tables, targetId); err != nil {
+		return err
+	}
+
+	// Прежний голос пользователя
+	oldValue := repository.VoteNone
+	err = tx.QueryRowContext(ctx, "SELECT value FROM "+tables.votes+" WHERE "+tables.column+" = $1 AND user_id = $2", targetId, userId).Scan(&oldValue)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if value == repository.VoteNone {
+		_, err = tx.ExecContext(ctx, "DELETE FROM "+tables.votes+" WHERE "+tables.column+" = $1 AND user_id = $2", targetId, userId)
+	} else {
+		_, err = tx.ExecContext(ctx, "INSERT INTO "+tables.votes+" ("+tables.column+", user_id, value) VALUES ($1, $2, $3) ON CONFLICT ("+tables.column+", user_id) DO UPDATE SET value = excluded.value", targetId, userId, value)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Счётчики изменяются на разницу между прежним и новым голосом
+	upDelta, downDelta := repository.VoteDelta(oldValue, value)
+	_, err = tx.ExecContext(ctx, "UPDATE "+tables.target+" SET upvotes = upvotes + $2, downvotes = downvotes + $3 WHERE id = $1", targetId, upDelta, downDelta)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetVote возвращает голос пользователя за пост или комментарий
+func (repo *SQLiteRepository) GetVote(ctx context.Context, kind repository.TargetKind, targetId, userId string) (int, error) {
+	log.Printf("Querying vote for %s with ID: %s\n", kind, targetId)
+	tables, err := tablesFor(kind)
+	if err != nil {
+		return 0, err
+	}
+	value := repository.VoteNone
+	err = repo.db.QueryRowContext(ctx, "SELECT value FROM "+tables.votes+" WHERE "+tables.column+" = $1 AND user_id = $2", targetId, userId).Scan(&value)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	return value, nil
+}
+
+// React добавляет или снимает реакцию пользователя на пост или комментарий
+func (repo *SQLiteRepository) React(ctx context.Context, kind repository.TargetKind, targetId, userId, emoji string) (bool, error) {
+	log.Printf("Reacting %s to %s with ID: %s\n", emoji, kind, targetId)
+	tables, err := tablesFor(kind)
+	if err != nil {
+		return false, err
+	}
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if err := checkTarget(ctx, tx, tables, targetId); err != nil {
+		return false, err
+	}
+
+	// Если реакция уже есть, она снимается
+	result, err := tx.ExecContext(ctx, "DELETE FROM "+tables.reactions+" WHERE "+tables.column+" = $1 AND user_id = $2 AND emoji = $3", targetId, userId, emoji)
+	if err != nil {
+		return false, err
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if removed > 0 {
+		_, err = tx.ExecContext(ctx, "UPDATE "+tables.reactionCounts+" SET count = count - 1 WHERE "+tables.column+" = $1 AND emoji = $2", targetId, emoji)
+		if err != nil {
+			return false, err
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM "+tables.reactionCounts+" WHERE "+tables.column+" = $1 AND emoji = $2 AND count <= 0", targetId, emoji)
+		if err != nil {
+			return false, err
+		}
+	} else {
+		_, err = tx.ExecContext(ctx, "INSERT INTO "+tables.reactions+" ("+tables.column+", user_id, emoji) VALUES ($1, $2, $3)", targetId, userId, emoji)
+		if err != nil {
+			return false, err
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO "+tables.reactionCounts+" ("+tables.column+", emoji, count) VALUES ($1, $2, 1) ON CONFLICT ("+tables.column+", emoji) DO UPDATE SET count = count + 1", targetId, emoji)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	return removed == 0, nil
+}
+
+// GetReactions возвращает сводку реакций на пост или комментарий
+func (repo *SQLiteRepository) GetReactions(ctx context.Context, kind repository.TargetKind, targetId, viewerId string) ([]*models.Reaction, error) {
+	log.Printf("Querying reactions to %s with ID: %s\n", kind, targetId)
+	tables, err := tablesFor(kind)
+	if err != nil {
+		return nil, err
+	}
+	// Строки в SQLite по умолчанию сравниваются побайтно, как и в in-memory хранилище
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT rc.emoji, rc.count, EXISTS (
+			SELECT 1 FROM `+tables.reactions+` r
+			WHERE r.`+tables.column+` = rc.`+tables.column+` AND r.emoji = rc.emoji AND r.user_id = $2
+		)
+		FROM `+tables.reactionCounts+` rc
+		WHERE rc.`+tables.column+` = $1
+		ORDER BY rc.count DESC, rc.emoji
+	`, targetId, viewerId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reactions := []*models.Reaction{}
+	for rows.Next() {
+		reaction := &models.Reaction{}
+		if err := rows.Scan(&reaction.Emoji, &reaction.Count, &reaction.ViewerHasReacted); err != nil {
+			return nil, err
+		}
+		reactions = append(reactions, reaction)
+	}
+	return reactions, rows.Err()
+}
+
+// indexDocument заменяет термы поста или комментария в поисковом индексе
+func indexDocument(ctx context.Context, tx *sql.Tx, kind repository.TargetKind, id string, texts ...string) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM search_index WHERE kind = $1 AND id = $2", kind, id)
+	if err != nil {
+		return err
+	}
+	terms := []string{}
+	for _, text := range texts {
+		terms = append(terms, search.Analyze(text)...)
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO search_index (kind, id, terms) VALUES ($1, $2, $3)", kind, id, strings.Join(terms, " "))
+	return err
+}
+
+// Search выполняет полнотекстовый поиск. Индекс FTS5 отбирает документы, содержащие
+// все термы запроса, а релевантность и фрагменты вычисляются пакетом search так же,
+// как в in-memory хранилище.
+func (repo *SQLiteRepository) Search(ctx context.Context, query repository.SearchQuery) (*repository.SearchPage, error) {
+	log.Println("Searching for", query.Query)
+	page := &repository.SearchPage{Hits: []*repository.SearchHit{}}
+
+	// Термы запроса заключаются в кавычки и по умолчанию объединяются через И
+	terms := search.Analyze(query.Query)
+	if len(terms) == 0 {
+		return page, nil
+	}
+	match := `"` + strings.Join(terms, `" "`) + `"`
+
+	hits := []*repository.SearchHit{}
+	if query.Includes(repository.TargetPost) {
+		rows, err := repo.db.QueryContext(ctx, `
+			SELECT `+postColumns+` FROM posts
+			WHERE id IN (SELECT id FROM search_index WHERE search_index MATCH $1 AND kind = $2)
+		`, match, repository.TargetPost)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			post, err := scanPost(rows)
+			if err != nil {
+				return nil, err
+			}
+			rank, ok := search.Rank(query.Query,
+				search.Field{Text: post.Title, Weight: search.WeightTitle},
+				search.Field{Text: post.Content, Weight: search.WeightContent},
+			)
+			if ok {
+				hits = append(hits, &repository.SearchHit{Kind: repository.TargetPost, Post: post, Rank: rank})
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if query.Includes(repository.TargetComment) {
+		rows, err := repo.db.QueryContext(ctx, `
+			SELECT `+commentColumns+` FROM comments c
+			WHERE c.deleted_at IS NULL AND c.id IN (SELECT id FROM search_index WHERE search_index MATCH $1 AND kind = $2)
+		`, match, repository.TargetComment)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			comment, err := scanComment(rows)
+			if err != nil {
+				return nil, err
+			}
+			rank, ok := search.Rank(query.Query, search.Field{Text: comment.Content, Weight: search.WeightContent})
+			if ok {
+				hits = append(hits, &repository.SearchHit{Kind: repository.TargetComment, Comment: comment, Rank: rank})
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Сортировка по убыванию релевантности, при равной релевантности - по убыванию ID
+	sort.Slice(hits, func(i, j int) bool {
+		return repository.SearchCursorOf(hits[i]).Precedes(hits[j].Rank, hits[j].ID())
+	})
+
+	// Поиск первого результата после курсора
+	startIndex := 0
+	if query.After != nil {
+		startIndex = sort.Search(len(hits), func(i int) bool {
+			return query.After.Precedes(hits[i].Rank, hits[i].ID())
+		})
+	}
+	first := query.First
+	if first < 0 {
+		first = 0
+	}
+	endIndex := int64(startIndex) + first
+	if endIndex > int64(len(hits)) {
+		endIndex = int64(len(hits))
+	}
+
+	// Фрагменты строятся только для результатов страницы
+	page.Hits = hits[startIndex:endIndex]
+	for _, hit := range page.Hits {
+		if hit.Kind == repository.TargetPost {
+			// У поста выделяется текст, а если слов запроса в нём нет - заголовок
+			text := hit.Post.Content
+			if !search.Matches(text, query.Query) {
+				text = hit.Post.Title
+			}
+			hit.Snippet = search.Snippet(text, query.Query)
+		} else {
+			hit.Snippet = search.Snippet(hit.Comment.Content, query.Query)
+		}
+	}
+	page.HasNextPage = endIndex < int64(len(hits))
+	page.TotalCount = len(hits)
+	return page, nil
+}
+
+// userColumns — список столбцов таблицы users в порядке, ожидаемом scanUser
+const userColumns = "id, username, password_hash, role, created_at"
+
+// scanUser сканирует строку с userColumns в модель User
+func scanUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// CreateUser создает нового пользователя
+func (repo *SQLiteRepository) CreateUser(ctx context.Context, username, passwordHash string) (*models.User, error) {
+	id := uuid.New().String() // Генерация нового уникального ID для пользователя
+	log.Println("Creating user with ID:", id)
+	createdAt := now()
+	_, err := repo.db.ExecContext(ctx, "INSERT INTO users (id, username, password_hash, role, created_at) VALUES ($1, $2, $3, $4, $5)", id, username, passwordHash, models.RoleUser, createdAt)
+	if err != nil {
+		// Нарушение ограничения уникальности означает, что имя пользователя уже занято
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+			return nil, repository.ErrUsernameTaken
+		}
+		return nil, err
+	}
+	return &models.User{ID: id, Username: username, PasswordHash: passwordHash, Role: models.RoleUser, CreatedAt: createdAt}, nil
+}
+
+// GetUser возвращает пользователя по его ID
+func (repo *SQLiteRepository) GetUser(ctx context.Context, id string) (*models.User, error) {
+	log.Println("Querying user with ID:", id)
+	user, err := scanUser(repo.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id))
+	return user, notFound(err, repository.EntityUser, id)
+}
+
+// GetUserByUsername возвращает пользователя по его имени
+func (repo *SQLiteRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	log.Println("Querying user with username:", username)
+	user, err := scanUser(repo.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE username = $1", username))
+	return user, notFound(err, repository.EntityUser, username)
+}
+
+// Ping проверяет соединение с базой данных
+func (repo *SQLiteRepository) Ping(ctx context.Context) error {
+	return repo.db.PingContext(ctx)
+}
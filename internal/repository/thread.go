@@ -0,0 +1,20 @@
+package repository
+
+import "github.com/nemopss/go-posts-comments-system/internal/models"
+
+// NoLimit снимает ограничение глубины или количества комментариев в ThreadQuery
+const NoLimit = -1
+
+// ThreadQuery описывает параметры выборки всего дерева комментариев поста
+type ThreadQuery struct {
+	PostID   string // Пост, комментарии которого выбираются
+	MaxDepth int    // Наибольшая глубина комментариев: 0 - только верхний уровень, NoLimit - без ограничения
+	First    int64  // Наибольшее количество комментариев, NoLimit - без ограничения
+}
+
+// ThreadComment представляет комментарий в развёрнутом дереве обсуждения
+type ThreadComment struct {
+	Comment *models.Comment // Комментарий
+	Depth   int             // Глубина: 0 для комментария верхнего уровня, 1 для ответа на него и т.д.
+	Path    []string        // ID комментариев от комментария верхнего уровня до этого комментария включительно
+}
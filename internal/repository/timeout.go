@@ -0,0 +1,263 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nemopss/go-posts-comments-system/internal/models"
+)
+
+// ErrTimeout возвращается, если операция хранилища не завершилась до истечения срока запроса или самой операции
+var ErrTimeout = errors.New("storage operation timed out")
+
+// contextError заменяет ошибку операции, прерванной по истечении срока контекста, на ErrTimeout.
+// Драйверы баз данных сообщают о прерванном запросе по-разному, поэтому проверяется сам контекст.
+func contextError(ctx context.Context, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return err
+}
+
+// TimeoutRepository ограничивает время каждой операции хранилища
+// и возвращает ErrTimeout для операций, прерванных по истечении срока
+type TimeoutRepository struct {
+	repo    Repository
+	timeout time.Duration // Срок одной операции, 0 - ограничен только сроком запроса
+}
+
+// WithTimeout оборачивает хранилище так, что каждая операция выполняется не дольше timeout
+// и не дольше срока контекста запроса. При timeout, равном 0, время операции ограничивается
+// только контекстом запроса.
+func WithTimeout(repo Repository, timeout time.Duration) *TimeoutRepository {
+	return &TimeoutRepository{repo: repo, timeout: timeout}
+}
+
+// withTimeout возвращает контекст одной операции
+func (repo *TimeoutRepository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.timeout)
+}
+
+// GetPosts возвращает список всех постов
+func (repo *TimeoutRepository) GetPosts(ctx context.Context) ([]*models.Post, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	posts, err := repo.repo.GetPosts(ctx)
+	return posts, contextError(ctx, err)
+}
+
+// ListPosts возвращает страницу постов
+func (repo *TimeoutRepository) ListPosts(ctx context.Context, query PostsQuery) (*PostPage, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	page, err := repo.repo.ListPosts(ctx, query)
+	return page, contextError(ctx, err)
+}
+
+// GetPost возвращает пост по его ID
+func (repo *TimeoutRepository) GetPost(ctx context.Context, id string) (*models.Post, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	post, err := repo.repo.GetPost(ctx, id)
+	return post, contextError(ctx, err)
+}
+
+// CreatePost создаёт новый пост
+func (repo *TimeoutRepository) CreatePost(ctx context.Context, authorId, title, content string, commentsDisabled bool) (*models.Post, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	post, err := repo.repo.CreatePost(ctx, authorId, title, content, commentsDisabled)
+	return post, contextError(ctx, err)
+}
+
+// CreateComment создаёт новый комментарий
+func (repo *TimeoutRepository) CreateComment(ctx context.Context, authorId, postId, parentId, content string) (*models.Comment, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	comment, err := repo.repo.CreateComment(ctx, authorId, postId, parentId, content)
+	return comment, contextError(ctx, err)
+}
+
+// GetComment возвращает комментарий по его ID
+func (repo *TimeoutRepository) GetComment(ctx context.Context, id string) (*models.Comment, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	comment, err := repo.repo.GetComment(ctx, id)
+	return comment, contextError(ctx, err)
+}
+
+// GetCommentsByPostID возвращает страницу комментариев верхнего уровня
+func (repo *TimeoutRepository) GetCommentsByPostID(ctx context.Context, postId string, first int64, after *Cursor) (*CommentPage, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	page, err := repo.repo.GetCommentsByPostID(ctx, postId, first, after)
+	return page, contextError(ctx, err)
+}
+
+// GetCommentsByParentID возвращает страницу дочерних комментариев
+func (repo *TimeoutRepository) GetCommentsByParentID(ctx context.Context, parentId string, first int64, after *Cursor) (*CommentPage, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	page, err := repo.repo.GetCommentsByParentID(ctx, parentId, first, after)
+	return page, contextError(ctx, err)
+}
+
+// GetCommentsByPostIDs возвращает страницы комментариев верхнего уровня для нескольких постов
+func (repo *TimeoutRepository) GetCommentsByPostIDs(ctx context.Context, postIds []string, first int64, after *Cursor) (map[string]*CommentPage, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	pages, err := repo.repo.GetCommentsByPostIDs(ctx, postIds, first, after)
+	return pages, contextError(ctx, err)
+}
+
+// GetCommentsByParentIDs возвращает страницы дочерних комментариев для нескольких комментариев
+func (repo *TimeoutRepository) GetCommentsByParentIDs(ctx context.Context, parentIds []string, first int64, after *Cursor) (map[string]*CommentPage, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	pages, err := repo.repo.GetCommentsByParentIDs(ctx, parentIds, first, after)
+	return pages, contextError(ctx, err)
+}
+
+// GetCommentAncestors возвращает цепочку предков комментария
+func (repo *TimeoutRepository) GetCommentAncestors(ctx context.Context, id string) ([]*models.Comment, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	ancestors, err := repo.repo.GetCommentAncestors(ctx, id)
+	return ancestors, contextError(ctx, err)
+}
+
+// GetCommentThread возвращает дерево комментариев поста
+func (repo *TimeoutRepository) GetCommentThread(ctx context.Context, query ThreadQuery) ([]*ThreadComment, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	thread, err := repo.repo.GetCommentThread(ctx, query)
+	return thread, contextError(ctx, err)
+}
+
+// UpdatePost изменяет пост
+func (repo *TimeoutRepository) UpdatePost(ctx context.Context, editorId, id, title, content string, commentsDisabled bool) (*models.Post, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	post, err := repo.repo.UpdatePost(ctx, editorId, id, title, content, commentsDisabled)
+	return post, contextError(ctx, err)
+}
+
+// UpdateComment изменяет комментарий
+func (repo *TimeoutRepository) UpdateComment(ctx context.Context, editorId, id, content string) (*models.Comment, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	comment, err := repo.repo.UpdateComment(ctx, editorId, id, content)
+	return comment, contextError(ctx, err)
+}
+
+// GetPostRevisions возвращает все версии поста
+func (repo *TimeoutRepository) GetPostRevisions(ctx context.Context, postId string) ([]*models.PostRevision, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	revisions, err := repo.repo.GetPostRevisions(ctx, postId)
+	return revisions, contextError(ctx, err)
+}
+
+// GetCommentRevisions возвращает все версии комментария
+func (repo *TimeoutRepository) GetCommentRevisions(ctx context.Context, commentId string) ([]*models.CommentRevision, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	revisions, err := repo.repo.GetCommentRevisions(ctx, commentId)
+	return revisions, contextError(ctx, err)
+}
+
+// DeletePost удаляет пост
+func (repo *TimeoutRepository) DeletePost(ctx context.Context, id string) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, repo.repo.DeletePost(ctx, id))
+}
+
+// DeleteComment удаляет комментарий вместе с ответами на него
+func (repo *TimeoutRepository) DeleteComment(ctx context.Context, id string) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, repo.repo.DeleteComment(ctx, id))
+}
+
+// SoftDeleteComment заменяет комментарий надгробием
+func (repo *TimeoutRepository) SoftDeleteComment(ctx context.Context, id string) (*models.Comment, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	comment, err := repo.repo.SoftDeleteComment(ctx, id)
+	return comment, contextError(ctx, err)
+}
+
+// Vote устанавливает голос пользователя за пост или комментарий
+func (repo *TimeoutRepository) Vote(ctx context.Context, kind TargetKind, targetId, userId string, value int) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, repo.repo.Vote(ctx, kind, targetId, userId, value))
+}
+
+// GetVote возвращает голос пользователя за пост или комментарий
+func (repo *TimeoutRepository) GetVote(ctx context.Context, kind TargetKind, targetId, userId string) (int, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	value, err := repo.repo.GetVote(ctx, kind, targetId, userId)
+	return value, contextError(ctx, err)
+}
+
+// React добавляет или снимает реакцию пользователя
+func (repo *TimeoutRepository) React(ctx context.Context, kind TargetKind, targetId, userId, emoji string) (bool, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	reacted, err := repo.repo.React(ctx, kind, targetId, userId, emoji)
+	return reacted, contextError(ctx, err)
+}
+
+// GetReactions возвращает сводку реакций на пост или комментарий
+func (repo *TimeoutRepository) GetReactions(ctx context.Context, kind TargetKind, targetId, viewerId string) ([]*models.Reaction, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	reactions, err := repo.repo.GetReactions(ctx, kind, targetId, viewerId)
+	return reactions, contextError(ctx, err)
+}
+
+// Search выполняет полнотекстовый поиск
+func (repo *TimeoutRepository) Search(ctx context.Context, query SearchQuery) (*SearchPage, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	page, err := repo.repo.Search(ctx, query)
+	return page, contextError(ctx, err)
+}
+
+// CreateUser создаёт нового пользователя
+func (repo *TimeoutRepository) CreateUser(ctx context.Context, username, passwordHash string) (*models.User, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	user, err := repo.repo.CreateUser(ctx, username, passwordHash)
+	return user, contextError(ctx, err)
+}
+
+// GetUser возвращает пользователя по его ID
+func (repo *TimeoutRepository) GetUser(ctx context.Context, id string) (*models.User, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	user, err := repo.repo.GetUser(ctx, id)
+	return user, contextError(ctx, err)
+}
+
+// GetUserByUsername возвращает пользователя по его имени
+func (repo *TimeoutRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	user, err := repo.repo.GetUserByUsername(ctx, username)
+	return user, contextError(ctx, err)
+}
+
+// Ping проверяет доступность хранилища
+func (repo *TimeoutRepository) Ping(ctx context.Context) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, repo.repo.Ping(ctx))
+}
@@ -0,0 +1,53 @@
+package repository
+
+import "unicode/utf8"
+
+// ErrParentOnAnotherPost возвращается, если родительский комментарий относится к другому посту
+var ErrParentOnAnotherPost = Invalid("parentId", "parent comment belongs to another post")
+
+// CommentTarget описывает пост и родительский комментарий, к которым добавляется новый комментарий.
+// Хранилище заполняет его по данным, прочитанным под той же блокировкой или в той же транзакции,
+// что и добавление комментария, и проверяет с помощью ValidateNewComment.
+type CommentTarget struct {
+	PostFound        bool   // Пост существует
+	CommentsDisabled bool   // Комментарии к посту отключены
+	ParentFound      bool   // Родительский комментарий существует, не используется для комментария верхнего уровня
+	ParentPostID     string // ID поста родительского комментария
+	ParentDeleted    bool   // Родительский комментарий удалён
+}
+
+// ValidateCommentContent проверяет, что длина текста комментария в символах Unicode не превышает MaxCommentLength
+func ValidateCommentContent(content string) error {
+	if utf8.RuneCountInString(content) > MaxCommentLength {
+		return ErrCommentTooLong
+	}
+	return nil
+}
+
+// ValidateNewComment проверяет, что комментарий можно добавить к посту postId в ответ на комментарий parentId
+// (пустой parentId - комментарий верхнего уровня): пост и родитель существуют, родитель относится к тому же
+// посту и не удалён, комментарии к посту не отключены, а текст не превышает допустимую длину
+func ValidateNewComment(postId, parentId, content string, target CommentTarget) error {
+	if err := ValidateCommentContent(content); err != nil {
+		return err
+	}
+	if !target.PostFound {
+		return NotFound(EntityPost, postId)
+	}
+	if parentId != "" {
+		if !target.ParentFound {
+			return NotFound(EntityComment, parentId)
+		}
+		if target.ParentPostID != postId {
+			return ErrParentOnAnotherPost
+		}
+	}
+	if target.CommentsDisabled {
+		return ErrCommentsDisabled
+	}
+	// На удалённый комментарий нельзя ответить
+	if parentId != "" && target.ParentDeleted {
+		return ErrCommentDeleted
+	}
+	return nil
+}
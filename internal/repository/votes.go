@@ -0,0 +1,35 @@
+package repository
+
+// TargetKind задаёт вид объекта, за который голосуют или на который реагируют
+type TargetKind string
+
+const (
+	TargetPost    TargetKind = "post"    // Пост
+	TargetComment TargetKind = "comment" // Комментарий
+)
+
+// Значения голоса пользователя
+const (
+	VoteDown = -1 // Голос против
+	VoteNone = 0  // Голоса нет
+	VoteUp   = 1  // Голос за
+)
+
+// ErrInvalidVote возвращается для значения голоса, отличного от VoteDown, VoteNone и VoteUp
+var ErrInvalidVote = Invalid("value", "vote must be -1, 0 or 1")
+
+// VoteDelta возвращает изменения счётчиков голосов за и против при замене голоса oldValue на newValue
+func VoteDelta(oldValue, newValue int) (upvotes, downvotes int) {
+	count := func(value int) (int, int) {
+		switch value {
+		case VoteUp:
+			return 1, 0
+		case VoteDown:
+			return 0, 1
+		}
+		return 0, 0
+	}
+	oldUp, oldDown := count(oldValue)
+	newUp, newDown := count(newValue)
+	return newUp - oldUp, newDown - oldDown
+}
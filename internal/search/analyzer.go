@@ -0,0 +1,74 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/kljensen/snowball/english"
+	"github.com/kljensen/snowball/russian"
+)
+
+// Token представляет слово текста и его положение в тексте
+type Token struct {
+	Text  string // Слово в исходном написании
+	Start int    // Смещение начала слова в байтах
+	End   int    // Смещение конца слова в байтах
+}
+
+// Tokenize разбивает текст на слова: последовательности букв и цифр
+func Tokenize(text string) []Token {
+	tokens := []Token{}
+	start := -1
+	for i, r := range text {
+		wordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case wordRune && start < 0:
+			start = i
+		case !wordRune && start >= 0:
+			tokens = append(tokens, Token{Text: text[start:i], Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, Token{Text: text[start:], Start: start, End: len(text)})
+	}
+	return tokens
+}
+
+// Term приводит слово к нормальной форме для индекса: нижний регистр и основа слова.
+// Как и конфигурация полнотекстового поиска russian в PostgreSQL, слова из латинских букв
+// обрабатываются английским стеммером, остальные - русским. Для стоп-слов возвращается пустая строка.
+func Term(word string) string {
+	word = strings.ToLower(word)
+	if isASCII(word) {
+		if english.IsStopWord(word) {
+			return ""
+		}
+		return english.Stem(word, true)
+	}
+	if russian.IsStopWord(word) {
+		return ""
+	}
+	return russian.Stem(word, true)
+}
+
+// Analyze возвращает термы текста в порядке следования слов, пропуская стоп-слова
+func Analyze(text string) []string {
+	terms := []string{}
+	for _, token := range Tokenize(text) {
+		if term := Term(token.Text); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// isASCII сообщает, состоит ли строка только из ASCII символов
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
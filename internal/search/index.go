@@ -0,0 +1,156 @@
+package search
+
+import "sort"
+
+// Веса полей документа. Совпадают с весами A и B функции ts_rank в PostgreSQL,
+// поэтому совпадения в заголовке важнее совпадений в тексте.
+const (
+	WeightTitle   = 1.0 // Вес слов заголовка
+	WeightContent = 0.4 // Вес слов текста
+)
+
+// Field представляет поле документа с весом его слов
+type Field struct {
+	Text   string
+	Weight float64
+}
+
+// Match представляет найденный документ
+type Match struct {
+	ID   string  // Идентификатор документа
+	Rank float64 // Релевантность документа запросу
+}
+
+// Index представляет инвертированный индекс: для каждого терма хранятся документы,
+// в которых он встречается, и веса всех его вхождений
+type Index struct {
+	postings map[string]map[string][]float64 // Терм -> документ -> веса вхождений в порядке следования
+	terms    map[string][]string             // Документ -> его различные термы, для удаления из индекса
+}
+
+// NewIndex создаёт пустой индекс
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[string][]float64),
+		terms:    make(map[string][]string),
+	}
+}
+
+// Add добавляет документ в индекс. Если документ уже проиндексирован, он заменяется.
+func (ix *Index) Add(id string, fields ...Field) {
+	ix.Remove(id)
+	for _, field := range fields {
+		for _, term := range Analyze(field.Text) {
+			docs, ok := ix.postings[term]
+			if !ok {
+				docs = make(map[string][]float64)
+				ix.postings[term] = docs
+			}
+			if _, ok := docs[id]; !ok {
+				ix.terms[id] = append(ix.terms[id], term)
+			}
+			docs[id] = append(docs[id], field.Weight)
+		}
+	}
+}
+
+// Remove удаляет документ из индекса
+func (ix *Index) Remove(id string) {
+	for _, term := range ix.terms[id] {
+		delete(ix.postings[term], id)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	delete(ix.terms, id)
+}
+
+// Search находит документы, содержащие все термы запроса, и возвращает их
+// в порядке убывания релевантности, при равной релевантности - в порядке убывания ID.
+// Запрос без значимых слов ничего не находит.
+func (ix *Index) Search(query string) []Match {
+	terms := uniqueTerms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	matches := []Match{}
+	for id := range ix.postings[terms[0]] {
+		occurrences := make([][]float64, 0, len(terms))
+		for _, term := range terms {
+			weights, ok := ix.postings[term][id]
+			if !ok {
+				break
+			}
+			occurrences = append(occurrences, weights)
+		}
+		if len(occurrences) == len(terms) {
+			matches = append(matches, Match{ID: id, Rank: rank(occurrences)})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Rank != matches[j].Rank {
+			return matches[i].Rank > matches[j].Rank
+		}
+		return matches[i].ID > matches[j].ID
+	})
+	return matches
+}
+
+// Rank вычисляет релевантность документа запросу так же, как Search, без построения индекса.
+// Второе значение ложно, если документ содержит не все термы запроса.
+func Rank(query string, fields ...Field) (float64, bool) {
+	terms := uniqueTerms(query)
+	if len(terms) == 0 {
+		return 0, false
+	}
+	weights := map[string][]float64{}
+	for _, field := range fields {
+		for _, term := range Analyze(field.Text) {
+			weights[term] = append(weights[term], field.Weight)
+		}
+	}
+	occurrences := make([][]float64, 0, len(terms))
+	for _, term := range terms {
+		if len(weights[term]) == 0 {
+			return 0, false
+		}
+		occurrences = append(occurrences, weights[term])
+	}
+	return rank(occurrences), true
+}
+
+// uniqueTerms возвращает различные термы запроса
+func uniqueTerms(query string) []string {
+	seen := map[string]bool{}
+	terms := []string{}
+	for _, term := range Analyze(query) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// rank вычисляет релевантность документа по весам вхождений каждого терма запроса
+// так же, как ts_rank в PostgreSQL для запроса без учёта близости слов: каждое следующее
+// вхождение терма добавляет всё меньший вклад, а итог усредняется по термам.
+func rank(occurrences [][]float64) float64 {
+	// Сумма ряда 1/n², которой нормируется вклад терма
+	const zeta2 = 1.64493406685
+
+	total := 0.0
+	for _, weights := range occurrences {
+		sum, maxWeight, maxIndex := 0.0, -1.0, 0
+		for j, weight := range weights {
+			sum += weight / float64((j+1)*(j+1))
+			if weight > maxWeight {
+				maxWeight, maxIndex = weight, j
+			}
+		}
+		total += (maxWeight + sum - maxWeight/float64((maxIndex+1)*(maxIndex+1))) / zeta2
+	}
+	return total / float64(len(occurrences))
+}
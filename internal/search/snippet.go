@@ -0,0 +1,101 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Маркеры выделения найденных слов во фрагменте, как в ts_headline
+const (
+	HighlightStart = "<b>"
+	HighlightStop  = "</b>"
+)
+
+// Маркеры, которыми ts_headline выделяет найденные слова до экранирования фрагмента.
+// Это символы из области частного использования Unicode, которые не изменяются при экранировании HTML.
+const (
+	RawHighlightStart = "\uE000"
+	RawHighlightStop  = "\uE001"
+)
+
+// rawHighlights заменяет маркеры RawHighlightStart и RawHighlightStop на HighlightStart и HighlightStop
+var rawHighlights = strings.NewReplacer(RawHighlightStart, HighlightStart, RawHighlightStop, HighlightStop)
+
+// EscapeHighlighted экранирует фрагмент, в котором найденные слова выделены маркерами
+// RawHighlightStart и RawHighlightStop, как HTML и заменяет маркеры на HighlightStart и HighlightStop.
+// Сами маркеры в исходном тексте должны быть удалены до выделения слов.
+func EscapeHighlighted(snippet string) string {
+	return rawHighlights.Replace(html.EscapeString(snippet))
+}
+
+// snippetWords - максимальное количество слов во фрагменте
+const snippetWords = 35
+
+// snippetContext - количество слов перед первым найденным словом
+const snippetContext = 5
+
+// Matches сообщает, содержит ли текст все термы запроса
+func Matches(text, query string) bool {
+	terms := uniqueTerms(query)
+	if len(terms) == 0 {
+		return false
+	}
+	present := map[string]bool{}
+	for _, term := range Analyze(text) {
+		present[term] = true
+	}
+	for _, term := range terms {
+		if !present[term] {
+			return false
+		}
+	}
+	return true
+}
+
+// Snippet возвращает фрагмент текста вокруг первого найденного слова в виде HTML: текст экранируется,
+// а слова запроса обрамляются маркерами HighlightStart и HighlightStop.
+// Если слов запроса в тексте нет, возвращается начало текста.
+func Snippet(text, query string) string {
+	terms := map[string]bool{}
+	for _, term := range uniqueTerms(query) {
+		terms[term] = true
+	}
+	tokens := Tokenize(text)
+	if len(tokens) == 0 {
+		return ""
+	}
+
+	// Фрагмент начинается за несколько слов до первого найденного слова
+	first := 0
+	for i, token := range tokens {
+		if terms[Term(token.Text)] {
+			first = i - snippetContext
+			break
+		}
+	}
+	if first < 0 {
+		first = 0
+	}
+	last := first + snippetWords - 1
+	if last >= len(tokens) {
+		last = len(tokens) - 1
+	}
+
+	var b strings.Builder
+	position := tokens[first].Start
+	for _, token := range tokens[first : last+1] {
+		b.WriteString(html.EscapeString(text[position:token.Start]))
+		if terms[Term(token.Text)] {
+			b.WriteString(HighlightStart + html.EscapeString(token.Text) + HighlightStop)
+		} else {
+			b.WriteString(html.EscapeString(token.Text))
+		}
+		position = token.End
+	}
+	// Если фрагмент доходит до конца текста, сохраняются завершающие знаки препинания
+	if last == len(tokens)-1 {
+		b.WriteString(html.EscapeString(strings.TrimRightFunc(text[position:], unicode.IsSpace)))
+	}
+	return b.String()
+}
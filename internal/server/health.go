@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout ограничивает время проверки хранилища при проверке готовности
+const readinessTimeout = 2 * time.Second
+
+// LivenessHandler возвращает обработчик проверки жизнеспособности (/healthz).
+// Он отвечает 200, пока процесс способен обрабатывать HTTP запросы, и не обращается к хранилищу,
+// чтобы недоступность базы данных не приводила к перезапуску сервиса.
+func (s *Server) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// ReadinessHandler возвращает обработчик проверки готовности (/readyz).
+// Он отвечает 200, если хранилище доступно, и 503, если проверка хранилища не прошла
+// или сервер останавливается и больше не должен получать новые запросы.
+func (s *Server) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-s.shutdown:
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		default:
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+		if err := s.repo.Ping(ctx); err != nil {
+			log.Println("Readiness check failed:", err)
+			http.Error(w, "storage unavailable: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// Shutdown начинает остановку сервера: проверка готовности отвечает 503, а WebSocket соединения
+// закрываются с кодом 1001 (Going Away), чтобы клиенты переподключились к другому экземпляру.
+// Обычные HTTP запросы дожидается http.Server.Shutdown, который вызывается после этого метода.
+func (s *Server) Shutdown() {
+	s.shutdownOnce.Do(func() {
+		log.Println("Shutting down server...")
+		close(s.shutdown)
+	})
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nemopss/go-posts-comments-system/internal/auth"
+	"github.com/nemopss/go-posts-comments-system/internal/gql"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName - имя трассировщика HTTP сервера
+const tracerName = "github.com/nemopss/go-posts-comments-system/internal/server"
+
+// AuthMiddleware проверяет токен доступа из заголовка `Authorization: Bearer <токен>`
+// и помещает пользователя, выполняющего запрос, в контекст запроса.
+// Запросы без заголовка выполняются анонимно, запросы с недействительным токеном отклоняются с кодом 401.
+func AuthMiddleware(tokens *auth.Tokens, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		viewer, err := authenticate(tokens, header)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithViewer(r.Context(), viewer)))
+	})
+}
+
+// authenticate проверяет значение заголовка Authorization со схемой Bearer
+func authenticate(tokens *auth.Tokens, header string) (*auth.Viewer, error) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, auth.ErrInvalidToken
+	}
+	return tokens.Verify(strings.TrimSpace(token))
+}
+
+// TimeoutMiddleware ограничивает время выполнения запроса: по истечении timeout контекст запроса
+// завершается, и обращения к хранилищу прерываются. WebSocket соединения живут дольше одного запроса
+// и не ограничиваются. При timeout, равном 0, время запроса не ограничено.
+func TimeoutMiddleware(timeout time.Duration, next http.Handler) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// LoaderMiddleware помещает в контекст запроса новый загрузчик комментариев, чтобы вложенные
+// комментарии всех постов и ответов одного уровня запрашивались из хранилища одним обращением.
+// Кэш загрузчика живёт до конца запроса. WebSocket соединение выполняет много операций,
+// поэтому загрузчик для него создаётся на каждую операцию отдельно.
+func LoaderMiddleware(repo repository.Repository, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		loader := gql.NewCommentLoader(repo)
+		next.ServeHTTP(w, r.WithContext(gql.WithCommentLoader(r.Context(), loader)))
+	})
+}
+
+// statusRecorder запоминает код ответа для span запроса
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// TracingMiddleware выполняет запрос в span трассировки HTTP сервера. Контекст трассировки
+// из заголовка traceparent продолжается, поэтому span запроса становится частью трассы клиента.
+// WebSocket соединения живут дольше одного запроса, поэтому их операции трассируются без span HTTP запроса.
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := otel.Tracer(tracerName).Start(ctx, r.Method+" "+r.URL.Path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("user_agent.original", r.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		span.SetAttributes(attribute.Int("http.response.status_code", recorder.status))
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	})
+}
@@ -3,34 +3,63 @@ package server
 import (
 	"log"
 	"net/http"
+	"sync"
 
+	"github.com/gorilla/websocket"
 	"github.com/graphql-go/graphql"
 	"github.com/graphql-go/handler"
+	"github.com/nemopss/go-posts-comments-system/internal/auth"
 	"github.com/nemopss/go-posts-comments-system/internal/gql"
+	"github.com/nemopss/go-posts-comments-system/internal/pubsub"
 	"github.com/nemopss/go-posts-comments-system/internal/repository"
 )
 
 // Server представляет сервер GraphQL
 type Server struct {
+	repo   repository.Repository
 	schema *graphql.Schema
+	hub    *pubsub.Hub  // Шина событий для GraphQL подписок
+	tokens *auth.Tokens // Выпуск и проверка токенов доступа
+
+	shutdown     chan struct{} // Закрывается при остановке сервера
+	shutdownOnce sync.Once
+
+	// GraphiQL включает встроенную среду GraphiQL для запросов из браузера. По умолчанию включена.
+	GraphiQL bool
 }
 
 // NewServer создает новый экземпляр Server
-func NewServer(repo repository.Repository) *Server {
-	schema, err := gql.NewSchema(repo)
+func NewServer(repo repository.Repository, tokens *auth.Tokens, options gql.Options) *Server {
+	hub := pubsub.NewHub()
+	schema, err := gql.NewSchema(repo, hub, tokens, options)
 	if err != nil {
 		log.Fatalf("Failed to create GraphQL schema: %v", err)
 	}
 	log.Println("Starting server...")
-	return &Server{schema: &schema}
+	return &Server{repo: repo, schema: &schema, hub: hub, tokens: tokens, shutdown: make(chan struct{}), GraphiQL: true}
+}
+
+// Subscribers возвращает число активных GraphQL подписок
+func (s *Server) Subscribers() int {
+	return s.hub.Subscribers()
 }
 
-// Handler возвращает обработчик HTTP для GraphQL запросов
+// Handler возвращает обработчик HTTP для GraphQL запросов.
+// Запросы на установку WebSocket соединения обслуживаются по протоколу graphql-transport-ws,
+// остальные запросы передаются обычному обработчику GraphQL.
 func (s *Server) Handler() http.Handler {
 	log.Println("Handling server...")
-	return handler.New(&handler.Config{
-		Schema:   s.schema,
-		Pretty:   true,
-		GraphiQL: true,
+	h := handler.New(&handler.Config{
+		Schema:        s.schema,
+		Pretty:        true,
+		GraphiQL:      s.GraphiQL,
+		FormatErrorFn: gql.FormatError,
 	})
+	return AuthMiddleware(s.tokens, LoaderMiddleware(s.repo, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			s.serveWebSocket(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})))
 }
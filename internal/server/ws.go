@@ -0,0 +1,298 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/nemopss/go-posts-comments-system/internal/auth"
+	"github.com/nemopss/go-posts-comments-system/internal/gql"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+)
+
+// graphqlTransportWSProtocol — имя подпротокола WebSocket для GraphQL подписок
+// (https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md)
+const graphqlTransportWSProtocol = "graphql-transport-ws"
+
+// connectionInitTimeout — время, за которое клиент должен прислать connection_init
+const connectionInitTimeout = 10 * time.Second
+
+// Типы сообщений протокола graphql-transport-ws
+const (
+	msgConnectionInit = "connection_init"
+	msgConnectionAck  = "connection_ack"
+	msgPing           = "ping"
+	msgPong           = "pong"
+	msgSubscribe      = "subscribe"
+	msgNext           = "next"
+	msgError          = "error"
+	msgComplete       = "complete"
+)
+
+// Коды закрытия соединения, определённые протоколом graphql-transport-ws
+const (
+	closeBadRequest          = 4400
+	closeUnauthorized        = 4401
+	closeForbidden           = 4403
+	closeSubprotocolNotFound = 4406
+	closeInitTimeout         = 4408
+	closeSubscriberExists    = 4409
+	closeTooManyInitRequests = 4429
+)
+
+// wsMessage представляет сообщение протокола graphql-transport-ws
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// subscribePayload представляет полезную нагрузку сообщения subscribe
+type subscribePayload struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+var upgrader = websocket.Upgrader{
+	Subprotocols: []string{graphqlTransportWSProtocol},
+}
+
+// connectionInitPayload представляет полезную нагрузку сообщения connection_init.
+// Браузеры не позволяют задать заголовки WebSocket запроса, поэтому токен доступа
+// можно передать здесь в том же формате, что и в заголовке Authorization.
+type connectionInitPayload struct {
+	Authorization string `json:"Authorization"`
+}
+
+// wsConnection хранит состояние одного WebSocket соединения
+type wsConnection struct {
+	conn   *websocket.Conn
+	repo   repository.Repository // Хранилище для загрузчиков комментариев операций
+	schema *graphql.Schema
+	tokens *auth.Tokens
+	ctx    context.Context // Контекст соединения, содержит пользователя после инициализации
+
+	writeMu sync.Mutex // gorilla/websocket не допускает конкурентную запись
+
+	mu            sync.Mutex
+	initialized   bool                          // Получено ли сообщение connection_init
+	subscriptions map[string]context.CancelFunc // Активные операции, где ключ - ID операции
+}
+
+// serveWebSocket обслуживает WebSocket соединение по протоколу graphql-transport-ws
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("WebSocket upgrade failed:", err)
+		return
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	c := &wsConnection{
+		conn:          conn,
+		repo:          s.repo,
+		schema:        s.schema,
+		tokens:        s.tokens,
+		ctx:           ctx,
+		subscriptions: make(map[string]context.CancelFunc),
+	}
+	defer conn.Close()
+
+	if conn.Subprotocol() != graphqlTransportWSProtocol {
+		c.close(closeSubprotocolNotFound, "Subprotocol not acceptable")
+		return
+	}
+
+	// Закрытие соединения, если клиент не инициализировал его вовремя
+	initTimer := time.AfterFunc(connectionInitTimeout, func() {
+		c.mu.Lock()
+		initialized := c.initialized
+		c.mu.Unlock()
+		if !initialized {
+			c.close(closeInitTimeout, "Connection initialisation timeout")
+		}
+	})
+	defer initTimer.Stop()
+
+	// http.Server.Shutdown не закрывает перехваченные соединения, поэтому при остановке сервера они закрываются здесь
+	go func() {
+		select {
+		case <-s.shutdown:
+			c.close(websocket.CloseGoingAway, "Server is shutting down")
+		case <-ctx.Done():
+		}
+	}()
+
+	log.Println("WebSocket connection established")
+	c.readLoop()
+	log.Println("WebSocket connection closed")
+}
+
+// readLoop читает и обрабатывает сообщения клиента до закрытия соединения
+func (c *wsConnection) readLoop() {
+	for {
+		var msg wsMessage
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.close(closeBadRequest, "Invalid message received")
+			return
+		}
+
+		switch msg.Type {
+		case msgConnectionInit:
+			c.mu.Lock()
+			alreadyInitialized := c.initialized
+			c.initialized = true
+			c.mu.Unlock()
+			if alreadyInitialized {
+				c.close(closeTooManyInitRequests, "Too many initialisation requests")
+				return
+			}
+			if code, reason := c.authenticate(msg.Payload); code != 0 {
+				c.close(code, reason)
+				return
+			}
+			c.write(wsMessage{Type: msgConnectionAck})
+		case msgPing:
+			c.write(wsMessage{Type: msgPong})
+		case msgPong:
+		case msgSubscribe:
+			if !c.startOperation(msg) {
+				return
+			}
+		case msgComplete:
+			c.stopOperation(msg.ID)
+		default:
+			c.close(closeBadRequest, "Invalid message received")
+			return
+		}
+	}
+}
+
+// authenticate проверяет токен доступа из полезной нагрузки connection_init, если он передан,
+// и запоминает пользователя в контексте соединения. Возвращает код и причину закрытия соединения:
+// 4400 для полезной нагрузки, которая не является объектом, и 4403 для недействительного токена;
+// 0, если соединение инициализировано.
+func (c *wsConnection) authenticate(raw json.RawMessage) (int, string) {
+	var payload connectionInitPayload
+	// Неразобранная полезная нагрузка не должна молча превращать соединение в анонимное
+	if len(raw) > 0 && json.Unmarshal(raw, &payload) != nil {
+		return closeBadRequest, "Invalid connection_init payload"
+	}
+	if payload.Authorization == "" {
+		return 0, ""
+	}
+	viewer, err := authenticate(c.tokens, payload.Authorization)
+	if err != nil {
+		return closeForbidden, "Forbidden"
+	}
+	c.ctx = auth.WithViewer(c.ctx, viewer)
+	return 0, ""
+}
+
+// startOperation запускает операцию из сообщения subscribe.
+// Возвращает false, если соединение было закрыто из-за нарушения протокола.
+func (c *wsConnection) startOperation(msg wsMessage) bool {
+	var payload subscribePayload
+	if msg.ID == "" || json.Unmarshal(msg.Payload, &payload) != nil {
+		c.close(closeBadRequest, "Invalid message received")
+		return false
+	}
+
+	c.mu.Lock()
+	if !c.initialized {
+		c.mu.Unlock()
+		c.close(closeUnauthorized, "Unauthorized")
+		return false
+	}
+	if _, exists := c.subscriptions[msg.ID]; exists {
+		c.mu.Unlock()
+		c.close(closeSubscriberExists, "Subscriber for "+msg.ID+" already exists")
+		return false
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.subscriptions[msg.ID] = cancel
+	c.mu.Unlock()
+
+	go func() {
+		defer c.stopOperation(msg.ID)
+		for result := range c.execute(ctx, payload) {
+			if ctx.Err() != nil {
+				continue
+			}
+			result.Errors = gql.FormatErrors(result.Errors)
+			// Ошибки без данных означают, что операция не была выполнена (например, не прошла валидацию)
+			if result.Data == nil && len(result.Errors) > 0 {
+				errorsPayload, _ := json.Marshal(result.Errors)
+				c.write(wsMessage{ID: msg.ID, Type: msgError, Payload: errorsPayload})
+				return
+			}
+			resultPayload, _ := json.Marshal(result)
+			c.write(wsMessage{ID: msg.ID, Type: msgNext, Payload: resultPayload})
+		}
+		if ctx.Err() == nil {
+			c.write(wsMessage{ID: msg.ID, Type: msgComplete})
+		}
+	}()
+	return true
+}
+
+// stopOperation отменяет операцию с заданным ID, если она ещё выполняется
+func (c *wsConnection) stopOperation(id string) {
+	c.mu.Lock()
+	cancel, ok := c.subscriptions[id]
+	delete(c.subscriptions, id)
+	c.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// execute выполняет GraphQL операцию и возвращает канал результатов.
+// Для подписок результатов может быть много, для запросов и мутаций — ровно один.
+func (c *wsConnection) execute(ctx context.Context, payload subscribePayload) <-chan *graphql.Result {
+	params := graphql.Params{
+		Schema:         *c.schema,
+		RequestString:  payload.Query,
+		VariableValues: payload.Variables,
+		OperationName:  payload.OperationName,
+		Context:        ctx,
+	}
+	if operationType, _ := gql.Operation(payload.Query, payload.OperationName); operationType == ast.OperationTypeSubscription {
+		// Подписка выполняется заново на каждое событие, поэтому кэш загрузчика устарел бы
+		return graphql.Subscribe(params)
+	}
+	params.Context = gql.WithCommentLoader(ctx, gql.NewCommentLoader(c.repo))
+	results := make(chan *graphql.Result, 1)
+	results <- graphql.Do(params)
+	close(results)
+	return results
+}
+
+// write отправляет сообщение клиенту
+func (c *wsConnection) write(msg wsMessage) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.conn.WriteJSON(msg); err != nil {
+		log.Println("WebSocket write failed:", err)
+	}
+}
+
+// close закрывает соединение с кодом и причиной, определёнными протоколом
+func (c *wsConnection) close(code int, reason string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	c.conn.Close()
+}
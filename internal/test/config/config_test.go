@@ -0,0 +1,168 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nemopss/go-posts-comments-system/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// load загружает конфигурацию с аргументами args и переменными окружения env
+func load(args []string, env map[string]string) (*config.Config, error) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return config.Load(flags, args, func(key string) string { return env[key] })
+}
+
+// writeFile создаёт файл конфигурации с содержимым content
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+// Тест значений по умолчанию
+func TestDefaults(t *testing.T) {
+	cfg, err := load(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, config.Default(), cfg)
+	assert.Equal(t, ":8080", cfg.Server.Addr)
+	assert.Equal(t, config.StorageMemory, cfg.Storage.Backend)
+	assert.True(t, cfg.Server.GraphiQL)
+	assert.Equal(t, slog.LevelInfo, cfg.Log.Level)
+}
+
+// Тест порядка слоёв: файл переопределяет значения по умолчанию, переменные окружения - файл, флаги - переменные окружения
+func TestPrecedence(t *testing.T) {
+	path := writeFile(t, `
+server:
+  addr: ":9000"
+  request_timeout: 5s
+storage:
+  backend: sqlite
+  path: file.db
+content:
+  max_comment_length: 100
+log:
+  level: warn
+`)
+	env := map[string]string{
+		config.EnvConfig:     path,
+		"DB_PATH":            "env.db",
+		"MAX_COMMENT_LENGTH": "200",
+		"GRAPHIQL":           "false",
+	}
+	cfg, err := load([]string{"-max-comment-length=300", "-soft-delete-comments"}, env)
+	require.NoError(t, err)
+
+	assert.Equal(t, ":9000", cfg.Server.Addr)                  // Файл
+	assert.Equal(t, 5*time.Second, cfg.Server.RequestTimeout)  // Файл
+	assert.Equal(t, config.StorageSQLite, cfg.Storage.Backend) // Файл
+	assert.Equal(t, "env.db", cfg.Storage.Path)                // Окружение поверх файла
+	assert.False(t, cfg.Server.GraphiQL)                       // Окружение поверх значения по умолчанию
+	assert.Equal(t, 300, cfg.Content.MaxCommentLength)         // Флаг поверх окружения и файла
+	assert.True(t, cfg.Content.SoftDeleteComments)             // Логический флаг без значения
+	assert.Equal(t, slog.LevelWarn, cfg.Log.Level)
+	assert.Equal(t, 10*time.Second, cfg.Storage.QueryTimeout) // Значение по умолчанию
+
+	// Флаг -config имеет приоритет над переменной окружения CONFIG
+	other := writeFile(t, "server:\n  addr: \":9100\"\n")
+	cfg, err = load([]string{"-config", other}, env)
+	require.NoError(t, err)
+	assert.Equal(t, ":9100", cfg.Server.Addr)
+	assert.Equal(t, config.StorageMemory, cfg.Storage.Backend)
+}
+
+// Тест строки подключения из прежних переменных POSTGRES_*
+func TestLegacyPostgresEnvironment(t *testing.T) {
+	env := map[string]string{
+		"STORAGE":           "postgres",
+		"POSTGRES_HOST":     "db",
+		"POSTGRES_USER":     "user",
+		"POSTGRES_PASSWORD": "p@ss word",
+		"POSTGRES_DB":       "posts",
+	}
+	cfg, err := load(nil, env)
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://user:p%40ss%20word@db:5432/posts?sslmode=disable", cfg.Storage.DSN)
+
+	// DATABASE_URL имеет приоритет над отдельными переменными
+	env["DATABASE_URL"] = "postgres://other@db/posts"
+	cfg, err = load(nil, env)
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://other@db/posts", cfg.Storage.DSN)
+}
+
+// Тест проверки конфигурации при запуске
+func TestValidation(t *testing.T) {
+	_, err := load([]string{"-storage=postgres", "-max-comment-length=5000", "-fsync=sometimes", "-addr=8080", "-shutdown-timeout=0", "-connect-timeout=-1s", "-tracing-exporter=jaeger", "-tracing-sample-ratio=2", "-admin-username=root", "-admin-password=short"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "storage.dsn")
+	assert.Contains(t, err.Error(), "content.max_comment_length")
+	assert.Contains(t, err.Error(), "server.addr")
+	assert.Contains(t, err.Error(), "server.shutdown_timeout")
+	assert.Contains(t, err.Error(), "storage.connect_timeout")
+	assert.Contains(t, err.Error(), "tracing.exporter")
+	assert.Contains(t, err.Error(), "tracing.sample_ratio")
+	assert.Contains(t, err.Error(), "auth.admin_password")
+	// Настройки хранилища в памяти не проверяются для другого хранилища
+	assert.NotContains(t, err.Error(), "storage.memory.fsync")
+
+	_, err = load(nil, map[string]string{"QUERY_TIMEOUT": "soon"})
+	assert.ErrorContains(t, err, "QUERY_TIMEOUT")
+	_, err = load([]string{"-log-level=loud"}, nil)
+	assert.ErrorContains(t, err, "-log-level")
+	_, err = load([]string{"-config", writeFile(t, "server:\n  adress: \":9000\"\n")}, nil)
+	assert.ErrorContains(t, err, "adress")
+	_, err = load([]string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}, nil)
+	assert.Error(t, err)
+}
+
+// Тест вывода конфигурации со скрытыми секретами
+func TestRedacted(t *testing.T) {
+	env := map[string]string{
+		"STORAGE":        "postgres",
+		"DATABASE_URL":   "postgres://user:secret-password@db:5432/posts",
+		"AUTH_SECRET":    "secret-key",
+		"ADMIN_USERNAME": "root",
+		"ADMIN_PASSWORD": "admin-password",
+	}
+	cfg, err := load(nil, env)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, cfg.Write(&out))
+	assert.NotContains(t, out.String(), "secret-password")
+	assert.NotContains(t, out.String(), "secret-key")
+	assert.NotContains(t, out.String(), "admin-password")
+	assert.Contains(t, out.String(), "postgres://user:xxxxx@db:5432/posts")
+	// Исходная конфигурация не изменяется
+	assert.Equal(t, "secret-key", cfg.Auth.Secret)
+
+	cfg.Storage.DSN = "host=db user=user password=secret-password dbname=posts"
+	assert.Equal(t, "host=db user=user password=xxxxx dbname=posts", cfg.Redacted().Storage.DSN)
+
+	// Пароли в параметрах строки подключения в виде URL
+	cfg.Storage.DSN = "postgres://user@db/posts?password=secret-password&sslmode=verify-full&sslpassword=secret-key"
+	assert.Equal(t, "postgres://user@db/posts?password=xxxxx&sslmode=verify-full&sslpassword=xxxxx", cfg.Redacted().Storage.DSN)
+	cfg.Storage.DSN = "host=db sslpassword=secret-key"
+	assert.Equal(t, "host=db sslpassword=xxxxx", cfg.Redacted().Storage.DSN)
+
+	// Выведенная конфигурация без секретов снова загружается
+	out.Reset()
+	cfg.Storage.DSN = "postgres://user@db/posts"
+	cfg.Auth.AdminUsername, cfg.Auth.AdminPassword = "", ""
+	require.NoError(t, cfg.Write(&out))
+	reloaded, err := load([]string{"-config", writeFile(t, out.String())}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, cfg.Storage, reloaded.Storage)
+	assert.Equal(t, cfg.Log, reloaded.Log)
+}
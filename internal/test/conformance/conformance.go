@@ -0,0 +1,605 @@
+// Package conformance содержит общий набор тестов контракта repository.Repository.
+// Набор запускается для каждой реализации хранилища, чтобы все они вели себя одинаково.
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nemopss/go-posts-comments-system/internal/models"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory возвращает новое пустое хранилище. Вызывается отдельно для каждого теста набора.
+type Factory func(t *testing.T) repository.Repository
+
+// Run проверяет хранилища, создаваемые newRepository, на соответствие контракту repository.Repository:
+// создание и чтение объектов, иерархию комментариев, порядок пагинации, пакетную выборку страниц, дерево обсуждения, каскадное удаление,
+// категории ошибок, одновременный доступ и проверку доступности.
+func Run(t *testing.T, newRepository Factory) {
+	t.Run("Creation", func(t *testing.T) { testCreation(t, newRepository(t)) })
+	t.Run("Hierarchy", func(t *testing.T) { testHierarchy(t, newRepository(t)) })
+	t.Run("CommentPagination", func(t *testing.T) { testCommentPagination(t, newRepository(t)) })
+	t.Run("BatchedCommentPages", func(t *testing.T) { testBatchedCommentPages(t, newRepository(t)) })
+	t.Run("PostPagination", func(t *testing.T) { testPostPagination(t, newRepository(t)) })
+	t.Run("CommentThread", func(t *testing.T) { testCommentThread(t, newRepository(t)) })
+	t.Run("CascadingDeletes", func(t *testing.T) { testCascadingDeletes(t, newRepository(t)) })
+	t.Run("Errors", func(t *testing.T) { testErrors(t, newRepository(t)) })
+	t.Run("ConcurrentAccess", func(t *testing.T) { testConcurrentAccess(t, newRepository(t)) })
+	t.Run("Ping", func(t *testing.T) { testPing(t, newRepository(t)) })
+}
+
+// fixture создаёт объекты хранилища, завершая тест при ошибке
+type fixture struct {
+	t    *testing.T
+	ctx  context.Context
+	repo repository.Repository
+}
+
+func newFixture(t *testing.T, repo repository.Repository) *fixture {
+	return &fixture{t: t, ctx: context.Background(), repo: repo}
+}
+
+func (f *fixture) user(username string) *models.User {
+	user, err := f.repo.CreateUser(f.ctx, username, "password-hash")
+	require.NoError(f.t, err)
+	return user
+}
+
+func (f *fixture) post(authorId, title string, commentsDisabled bool) *models.Post {
+	post, err := f.repo.CreatePost(f.ctx, authorId, title, "Content of "+title, commentsDisabled)
+	require.NoError(f.t, err)
+	return post
+}
+
+func (f *fixture) comment(authorId, postId, parentId, content string) *models.Comment {
+	comment, err := f.repo.CreateComment(f.ctx, authorId, postId, parentId, content)
+	require.NoError(f.t, err)
+	return comment
+}
+
+// Тест создания: созданные объекты возвращаются при чтении с теми же полями
+func testCreation(t *testing.T, repo repository.Repository) {
+	f := newFixture(t, repo)
+	author := f.user("author")
+	assert.NotEmpty(t, author.ID)
+	assert.Equal(t, models.RoleUser, author.Role)
+	assert.False(t, author.CreatedAt.IsZero())
+
+	user, err := repo.GetUser(f.ctx, author.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "author", user.Username)
+	assert.Equal(t, "password-hash", user.PasswordHash)
+	user, err = repo.GetUserByUsername(f.ctx, "author")
+	require.NoError(t, err)
+	assert.Equal(t, author.ID, user.ID)
+
+	created := f.post(author.ID, "Title", false)
+	assert.NotEmpty(t, created.ID)
+	assert.False(t, created.CreatedAt.IsZero())
+	post, err := repo.GetPost(f.ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, post.ID)
+	assert.Equal(t, author.ID, post.AuthorID)
+	assert.Equal(t, "Title", post.Title)
+	assert.Equal(t, "Content of Title", post.Content)
+	assert.False(t, post.CommentsDisabled)
+	assert.True(t, created.CreatedAt.Equal(post.CreatedAt), "expected created at %v, got %v", created.CreatedAt, post.CreatedAt)
+	assert.Nil(t, post.EditedAt)
+	assert.Equal(t, 0, post.CommentCount)
+
+	comment := f.comment(author.ID, post.ID, "", "Hello")
+	assert.Nil(t, comment.ParentID)
+	stored, err := repo.GetComment(f.ctx, comment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, post.ID, stored.PostID)
+	assert.Nil(t, stored.ParentID)
+	assert.Equal(t, author.ID, stored.AuthorID)
+	assert.Equal(t, "Hello", stored.Content)
+	assert.True(t, comment.CreatedAt.Equal(stored.CreatedAt), "expected created at %v, got %v", comment.CreatedAt, stored.CreatedAt)
+	assert.Nil(t, stored.DeletedAt)
+
+	post, err = repo.GetPost(f.ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, post.CommentCount)
+	assert.False(t, post.LastActivityAt.Before(comment.CreatedAt))
+
+	posts, err := repo.GetPosts(f.ctx)
+	require.NoError(t, err)
+	assert.Len(t, posts, 1)
+}
+
+// Тест иерархии: ответы доступны через родителя, не попадают в комментарии верхнего уровня и знают своих предков
+func testHierarchy(t *testing.T, repo repository.Repository) {
+	f := newFixture(t, repo)
+	author := f.user("author")
+	post := f.post(author.ID, "Title", false)
+	other := f.post(author.ID, "Other", false)
+
+	root := f.comment(author.ID, post.ID, "", "Root")
+	child := f.comment(author.ID, post.ID, root.ID, "Child")
+	grandchild := f.comment(author.ID, post.ID, child.ID, "Grandchild")
+	f.comment(author.ID, other.ID, "", "Other root")
+
+	require.NotNil(t, child.ParentID)
+	assert.Equal(t, root.ID, *child.ParentID)
+	stored, err := repo.GetComment(f.ctx, grandchild.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored.ParentID)
+	assert.Equal(t, child.ID, *stored.ParentID)
+	assert.Equal(t, post.ID, stored.PostID)
+
+	top, err := repo.GetCommentsByPostID(f.ctx, post.ID, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{root.ID}, commentIDs(top.Comments))
+	assert.Equal(t, 1, top.TotalCount)
+
+	children, err := repo.GetCommentsByParentID(f.ctx, root.ID, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{child.ID}, commentIDs(children.Comments))
+	children, err = repo.GetCommentsByParentID(f.ctx, child.ID, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{grandchild.ID}, commentIDs(children.Comments))
+	children, err = repo.GetCommentsByParentID(f.ctx, grandchild.ID, 10, nil)
+	require.NoError(t, err)
+	assert.Empty(t, children.Comments)
+	assert.Equal(t, 0, children.TotalCount)
+
+	// Цепочка предков начинается с комментария верхнего уровня
+	ancestors, err := repo.GetCommentAncestors(f.ctx, grandchild.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{root.ID, child.ID}, commentIDs(ancestors))
+	ancestors, err = repo.GetCommentAncestors(f.ctx, root.ID)
+	require.NoError(t, err)
+	assert.Empty(t, ancestors)
+	_, err = repo.GetCommentAncestors(f.ctx, "missing")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	// Ответы учитываются в счётчике комментариев поста
+	stats, err := repo.GetPost(f.ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.CommentCount)
+}
+
+// Тест пагинации комментариев: страницы следуют в порядке (created_at, id) без пропусков и повторов
+func testCommentPagination(t *testing.T, repo repository.Repository) {
+	f := newFixture(t, repo)
+	author := f.user("author")
+	post := f.post(author.ID, "Title", false)
+
+	expected := []*models.Comment{}
+	for i := 0; i < 7; i++ {
+		expected = append(expected, f.comment(author.ID, post.ID, "", fmt.Sprintf("Comment %d", i)))
+	}
+	sortComments(expected)
+
+	var after *repository.Cursor
+	fetched := []*models.Comment{}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, len(expected), "pagination does not terminate")
+		page, err := repo.GetCommentsByPostID(f.ctx, post.ID, 3, after)
+		require.NoError(t, err)
+		assert.Equal(t, len(expected), page.TotalCount)
+		assert.LessOrEqual(t, len(page.Comments), 3)
+		fetched = append(fetched, page.Comments...)
+		if !page.HasNextPage {
+			break
+		}
+		cursor := repository.CursorOf(page.Comments[len(page.Comments)-1])
+		after = &cursor
+	}
+	assert.Equal(t, commentIDs(expected), commentIDs(fetched))
+
+	// Курсор последнего комментария даёт пустую страницу
+	last := repository.CursorOf(expected[len(expected)-1])
+	page, err := repo.GetCommentsByPostID(f.ctx, post.ID, 3, &last)
+	require.NoError(t, err)
+	assert.Empty(t, page.Comments)
+	assert.False(t, page.HasNextPage)
+
+	// Нулевой размер страницы возвращает только общее количество
+	page, err = repo.GetCommentsByPostID(f.ctx, post.ID, 0, nil)
+	require.NoError(t, err)
+	assert.Empty(t, page.Comments)
+	assert.True(t, page.HasNextPage)
+	assert.Equal(t, len(expected), page.TotalCount)
+}
+
+// Тест пакетной выборки: страницы для нескольких постов и комментариев совпадают с постраничной выборкой
+func testBatchedCommentPages(t *testing.T, repo repository.Repository) {
+	f := newFixture(t, repo)
+	author := f.user("author")
+	busy := f.post(author.ID, "Busy", false)
+	quiet := f.post(author.ID, "Quiet", false)
+	empty := f.post(author.ID, "Empty", false)
+
+	roots := []*models.Comment{}
+	for i := 0; i < 4; i++ {
+		roots = append(roots, f.comment(author.ID, busy.ID, "", fmt.Sprintf("Busy %d", i)))
+	}
+	lone := f.comment(author.ID, quiet.ID, "", "Quiet")
+	for i := 0; i < 3; i++ {
+		f.comment(author.ID, busy.ID, roots[0].ID, fmt.Sprintf("Reply %d", i))
+	}
+	f.comment(author.ID, quiet.ID, lone.ID, "Lone reply")
+
+	postIds := []string{busy.ID, quiet.ID, empty.ID, "missing"}
+	pages, err := repo.GetCommentsByPostIDs(f.ctx, postIds, 2, nil)
+	require.NoError(t, err)
+	require.Len(t, pages, len(postIds))
+	for _, id := range postIds {
+		expected, err := repo.GetCommentsByPostID(f.ctx, id, 2, nil)
+		require.NoError(t, err)
+		require.Contains(t, pages, id)
+		assert.Equal(t, commentIDs(expected.Comments), commentIDs(pages[id].Comments), "post %s", id)
+		assert.Equal(t, expected.HasNextPage, pages[id].HasNextPage, "post %s", id)
+		assert.Equal(t, expected.TotalCount, pages[id].TotalCount, "post %s", id)
+	}
+	assert.Len(t, pages[busy.ID].Comments, 2)
+	assert.True(t, pages[busy.ID].HasNextPage)
+	assert.Equal(t, 4, pages[busy.ID].TotalCount)
+	assert.Empty(t, pages[empty.ID].Comments)
+	assert.Equal(t, 0, pages[empty.ID].TotalCount)
+
+	// Курсор применяется к каждому родителю отдельно
+	parentIds := []string{roots[0].ID, lone.ID, roots[1].ID}
+	first, err := repo.GetCommentsByParentIDs(f.ctx, parentIds, 2, nil)
+	require.NoError(t, err)
+	require.Len(t, first, len(parentIds))
+	assert.Len(t, first[roots[0].ID].Comments, 2)
+	assert.True(t, first[roots[0].ID].HasNextPage)
+	assert.Len(t, first[lone.ID].Comments, 1)
+	assert.False(t, first[lone.ID].HasNextPage)
+	assert.Empty(t, first[roots[1].ID].Comments)
+
+	cursor := repository.CursorOf(first[roots[0].ID].Comments[1])
+	next, err := repo.GetCommentsByParentIDs(f.ctx, parentIds, 2, &cursor)
+	require.NoError(t, err)
+	for _, id := range parentIds {
+		expected, err := repo.GetCommentsByParentID(f.ctx, id, 2, &cursor)
+		require.NoError(t, err)
+		assert.Equal(t, commentIDs(expected.Comments), commentIDs(next[id].Comments), "parent %s", id)
+		assert.Equal(t, expected.HasNextPage, next[id].HasNextPage, "parent %s", id)
+		assert.Equal(t, expected.TotalCount, next[id].TotalCount, "parent %s", id)
+	}
+	assert.Len(t, next[roots[0].ID].Comments, 1)
+	assert.Equal(t, 3, next[roots[0].ID].TotalCount)
+
+	pages, err = repo.GetCommentsByPostIDs(f.ctx, nil, 2, nil)
+	require.NoError(t, err)
+	assert.Empty(t, pages)
+}
+
+// Тест пагинации постов: по умолчанию посты упорядочены от новых к старым
+func testPostPagination(t *testing.T, repo repository.Repository) {
+	f := newFixture(t, repo)
+	author := f.user("author")
+
+	expected := []*models.Post{}
+	for i := 0; i < 5; i++ {
+		expected = append(expected, f.post(author.ID, fmt.Sprintf("Post %d", i), false))
+	}
+	sort.Slice(expected, func(i, j int) bool {
+		return repository.PostCursorOf(expected[i], repository.PostOrderCreatedAt).Precedes(expected[j])
+	})
+
+	query := repository.PostsQuery{First: 2}
+	fetched := []string{}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, len(expected), "pagination does not terminate")
+		page, err := repo.ListPosts(f.ctx, query)
+		require.NoError(t, err)
+		assert.Equal(t, len(expected), page.TotalCount)
+		for _, post := range page.Posts {
+			fetched = append(fetched, post.ID)
+		}
+		if !page.HasNextPage {
+			break
+		}
+		cursor := repository.PostCursorOf(page.Posts[len(page.Posts)-1], repository.PostOrderCreatedAt)
+		query.After = &cursor
+	}
+	ids := []string{}
+	for _, post := range expected {
+		ids = append(ids, post.ID)
+	}
+	assert.Equal(t, ids, fetched)
+}
+
+// Тест дерева комментариев: прямой порядок обхода, глубина, путь и ограничения выборки
+func testCommentThread(t *testing.T, repo repository.Repository) {
+	f := newFixture(t, repo)
+	author := f.user("author")
+	post := f.post(author.ID, "Title", false)
+	empty := f.post(author.ID, "Empty", false)
+
+	first := f.comment(author.ID, post.ID, "", "First")
+	second := f.comment(author.ID, post.ID, "", "Second")
+	answer := f.comment(author.ID, post.ID, first.ID, "Answer")
+	other := f.comment(author.ID, post.ID, first.ID, "Other answer")
+	nested := f.comment(author.ID, post.ID, answer.ID, "Nested")
+	reply := f.comment(author.ID, post.ID, second.ID, "Reply")
+	_, err := repo.SoftDeleteComment(f.ctx, answer.ID)
+	require.NoError(t, err)
+
+	// Ожидаемый порядок: комментарии одного уровня по (created_at, id), ответы сразу за родителем
+	children := map[string][]*models.Comment{
+		"":        {first, second},
+		first.ID:  {answer, other},
+		answer.ID: {nested},
+		second.ID: {reply},
+	}
+	type entry struct {
+		ID    string
+		Depth int
+		Path  []string
+	}
+	var expected []entry
+	var walk func(parentId string, depth int, path []string)
+	walk = func(parentId string, depth int, path []string) {
+		sortComments(children[parentId])
+		for _, comment := range children[parentId] {
+			commentPath := append(append([]string{}, path...), comment.ID)
+			expected = append(expected, entry{ID: comment.ID, Depth: depth, Path: commentPath})
+			walk(comment.ID, depth+1, commentPath)
+		}
+	}
+	walk("", 0, nil)
+
+	fetch := func(maxDepth int, first int64) []entry {
+		thread, err := repo.GetCommentThread(f.ctx, repository.ThreadQuery{PostID: post.ID, MaxDepth: maxDepth, First: first})
+		require.NoError(t, err)
+		entries := []entry{}
+		for _, comment := range thread {
+			assert.Equal(t, post.ID, comment.Comment.PostID)
+			entries = append(entries, entry{ID: comment.Comment.ID, Depth: comment.Depth, Path: comment.Path})
+		}
+		return entries
+	}
+	assert.Equal(t, expected, fetch(repository.NoLimit, repository.NoLimit))
+	assert.Equal(t, expected[:3], fetch(repository.NoLimit, 3))
+	assert.Empty(t, fetch(repository.NoLimit, 0))
+
+	shallow := []entry{}
+	for _, e := range expected {
+		if e.Depth <= 1 {
+			shallow = append(shallow, e)
+		}
+	}
+	assert.Equal(t, shallow, fetch(1, repository.NoLimit))
+	assert.Len(t, fetch(0, repository.NoLimit), 2)
+
+	// Надгробие остаётся в дереве, чтобы ответы на него не потеряли родителя
+	thread, err := repo.GetCommentThread(f.ctx, repository.ThreadQuery{PostID: post.ID, MaxDepth: repository.NoLimit, First: repository.NoLimit})
+	require.NoError(t, err)
+	for _, comment := range thread {
+		if comment.Comment.ID == answer.ID {
+			assert.NotNil(t, comment.Comment.DeletedAt)
+		}
+	}
+
+	thread, err = repo.GetCommentThread(f.ctx, repository.ThreadQuery{PostID: empty.ID, MaxDepth: repository.NoLimit, First: repository.NoLimit})
+	require.NoError(t, err)
+	assert.Empty(t, thread)
+	_, err = repo.GetCommentThread(f.ctx, repository.ThreadQuery{PostID: "00000000-0000-0000-0000-000000000000", MaxDepth: repository.NoLimit, First: repository.NoLimit})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+// Тест каскадного удаления: вместе с комментарием удаляются ответы на любой глубине,
+// вместе с постом - все его комментарии
+func testCascadingDeletes(t *testing.T, repo repository.Repository) {
+	f := newFixture(t, repo)
+	author := f.user("author")
+	post := f.post(author.ID, "Title", false)
+
+	root := f.comment(author.ID, post.ID, "", "Root")
+	child := f.comment(author.ID, post.ID, root.ID, "Child")
+	grandchild := f.comment(author.ID, post.ID, child.ID, "Grandchild")
+	sibling := f.comment(author.ID, post.ID, "", "Sibling")
+	require.NoError(t, repo.Vote(f.ctx, repository.TargetComment, grandchild.ID, author.ID, repository.VoteUp))
+
+	require.NoError(t, repo.DeleteComment(f.ctx, root.ID))
+	for _, id := range []string{root.ID, child.ID, grandchild.ID} {
+		_, err := repo.GetComment(f.ctx, id)
+		assert.ErrorIs(t, err, repository.ErrNotFound, "comment %s", id)
+	}
+	stats, err := repo.GetPost(f.ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.CommentCount)
+	top, err := repo.GetCommentsByPostID(f.ctx, post.ID, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{sibling.ID}, commentIDs(top.Comments))
+
+	reply := f.comment(author.ID, post.ID, sibling.ID, "Reply")
+	require.NoError(t, repo.DeletePost(f.ctx, post.ID))
+	_, err = repo.GetPost(f.ctx, post.ID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	for _, id := range []string{sibling.ID, reply.ID} {
+		_, err := repo.GetComment(f.ctx, id)
+		assert.ErrorIs(t, err, repository.ErrNotFound, "comment %s", id)
+	}
+	posts, err := repo.GetPosts(f.ctx)
+	require.NoError(t, err)
+	assert.Empty(t, posts)
+}
+
+// Тест категорий ошибок: все хранилища сообщают об одной и той же ситуации одной и той же ошибкой
+func testErrors(t *testing.T, repo repository.Repository) {
+	f := newFixture(t, repo)
+	author := f.user("author")
+	post := f.post(author.ID, "Title", false)
+	closed := f.post(author.ID, "Closed", true)
+	comment := f.comment(author.ID, post.ID, "", "Hello")
+	const missing = "00000000-0000-0000-0000-000000000000"
+
+	notFound := func(t *testing.T, err error, entity string) {
+		t.Helper()
+		var target *repository.NotFoundError
+		if assert.ErrorAs(t, err, &target) {
+			assert.Equal(t, entity, target.Entity)
+		}
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	}
+	_, err := repo.GetPost(f.ctx, missing)
+	notFound(t, err, repository.EntityPost)
+	_, err = repo.GetComment(f.ctx, missing)
+	notFound(t, err, repository.EntityComment)
+	_, err = repo.GetUser(f.ctx, missing)
+	notFound(t, err, repository.EntityUser)
+	_, err = repo.GetUserByUsername(f.ctx, "nobody")
+	notFound(t, err, repository.EntityUser)
+	_, err = repo.UpdatePost(f.ctx, author.ID, missing, "Title", "Content", false)
+	notFound(t, err, repository.EntityPost)
+	_, err = repo.UpdateComment(f.ctx, author.ID, missing, "Content")
+	notFound(t, err, repository.EntityComment)
+	notFound(t, repo.DeletePost(f.ctx, missing), repository.EntityPost)
+	notFound(t, repo.DeleteComment(f.ctx, missing), repository.EntityComment)
+	_, err = repo.SoftDeleteComment(f.ctx, missing)
+	notFound(t, err, repository.EntityComment)
+	_, err = repo.CreateComment(f.ctx, author.ID, missing, "", "Hello")
+	notFound(t, err, repository.EntityPost)
+	notFound(t, repo.Vote(f.ctx, repository.TargetPost, missing, author.ID, repository.VoteUp), repository.EntityPost)
+	_, err = repo.React(f.ctx, repository.TargetComment, missing, author.ID, "👍")
+	notFound(t, err, repository.EntityComment)
+
+	_, err = repo.CreateComment(f.ctx, author.ID, closed.ID, "", "Hello")
+	assert.ErrorIs(t, err, repository.ErrCommentsDisabled)
+
+	_, err = repo.CreateComment(f.ctx, author.ID, post.ID, "", strings.Repeat("a", repository.MaxCommentLength+1))
+	var invalid *repository.ValidationError
+	if assert.ErrorAs(t, err, &invalid) {
+		assert.Equal(t, "content", invalid.Field)
+	}
+	assert.ErrorIs(t, err, repository.ErrValidation)
+
+	// Длина считается в символах Unicode, а не в байтах
+	_, err = repo.CreateComment(f.ctx, author.ID, post.ID, "", strings.Repeat("я", repository.MaxCommentLength))
+	assert.NoError(t, err)
+	_, err = repo.CreateComment(f.ctx, author.ID, post.ID, "", strings.Repeat("я", repository.MaxCommentLength+1))
+	assert.ErrorIs(t, err, repository.ErrCommentTooLong)
+	_, err = repo.UpdateComment(f.ctx, author.ID, comment.ID, strings.Repeat("я", repository.MaxCommentLength+1))
+	assert.ErrorIs(t, err, repository.ErrCommentTooLong)
+
+	// Родитель должен существовать и относиться к тому же посту
+	_, err = repo.CreateComment(f.ctx, author.ID, post.ID, missing, "Reply")
+	notFound(t, err, repository.EntityComment)
+	other := f.post(author.ID, "Other", false)
+	_, err = repo.CreateComment(f.ctx, author.ID, other.ID, comment.ID, "Reply")
+	assert.ErrorIs(t, err, repository.ErrParentOnAnotherPost)
+	if assert.ErrorAs(t, err, &invalid) {
+		assert.Equal(t, "parentId", invalid.Field)
+	}
+	page, err := repo.GetCommentsByParentID(f.ctx, comment.ID, 10, nil)
+	require.NoError(t, err)
+	assert.Empty(t, page.Comments)
+	stored, err := repo.GetPost(f.ctx, other.ID)
+	require.NoError(t, err)
+	assert.Zero(t, stored.CommentCount)
+	assert.ErrorIs(t, repo.Vote(f.ctx, repository.TargetPost, post.ID, author.ID, 2), repository.ErrValidation)
+
+	_, err = repo.CreateUser(f.ctx, "author", "password-hash")
+	assert.ErrorIs(t, err, repository.ErrUsernameTaken)
+	assert.ErrorIs(t, err, repository.ErrConflict)
+
+	_, err = repo.SoftDeleteComment(f.ctx, comment.ID)
+	require.NoError(t, err)
+	_, err = repo.UpdateComment(f.ctx, author.ID, comment.ID, "Edited")
+	assert.ErrorIs(t, err, repository.ErrConflict)
+	_, err = repo.CreateComment(f.ctx, author.ID, post.ID, comment.ID, "Reply")
+	assert.ErrorIs(t, err, repository.ErrConflict)
+}
+
+// Тест одновременного доступа: параллельные изменения одного поста не теряются,
+// а чтение во время изменений не завершается ошибкой
+func testConcurrentAccess(t *testing.T, repo repository.Repository) {
+	const (
+		writers  = 8
+		comments = 10
+	)
+	f := newFixture(t, repo)
+	post := f.post(f.user("author").ID, "Title", false)
+	voters := make([]*models.User, writers)
+	for i := range voters {
+		voters[i] = f.user(fmt.Sprintf("voter%d", i))
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*(comments+2)+writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+		go func(voter *models.User) {
+			defer wg.Done()
+			for j := 0; j < comments; j++ {
+				if _, err := repo.CreateComment(f.ctx, voter.ID, post.ID, "", "Concurrent comment"); err != nil {
+					errs <- err
+				}
+			}
+			if err := repo.Vote(f.ctx, repository.TargetPost, post.ID, voter.ID, repository.VoteUp); err != nil {
+				errs <- err
+			}
+			if _, err := repo.React(f.ctx, repository.TargetPost, post.ID, voter.ID, "👍"); err != nil {
+				errs <- err
+			}
+		}(voters[i])
+		go func() {
+			defer wg.Done()
+			for j := 0; j < comments; j++ {
+				if _, err := repo.GetCommentsByPostID(f.ctx, post.ID, 5, nil); err != nil {
+					errs <- err
+				}
+				if _, err := repo.GetPost(f.ctx, post.ID); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent operation failed: %v", err)
+	}
+
+	stats, err := repo.GetPost(f.ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, writers*comments, stats.CommentCount)
+	assert.Equal(t, writers, stats.Upvotes)
+	page, err := repo.GetCommentsByPostID(f.ctx, post.ID, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, writers*comments, page.TotalCount)
+	reactions, err := repo.GetReactions(f.ctx, repository.TargetPost, post.ID, "")
+	require.NoError(t, err)
+	if assert.Len(t, reactions, 1) {
+		assert.Equal(t, writers, reactions[0].Count)
+	}
+}
+
+// sortComments упорядочивает комментарии так же, как страницы комментариев: по (created_at, id)
+func sortComments(comments []*models.Comment) {
+	sort.Slice(comments, func(i, j int) bool {
+		return repository.CursorOf(comments[i]).After(comments[j].CreatedAt, comments[j].ID)
+	})
+}
+
+// commentIDs возвращает идентификаторы комментариев в том же порядке
+func commentIDs(comments []*models.Comment) []string {
+	ids := []string{}
+	for _, comment := range comments {
+		ids = append(ids, comment.ID)
+	}
+	return ids
+}
+
+// Тест проверки доступности: открытое хранилище доступно, а проверка с отменённым контекстом возвращает ошибку
+func testPing(t *testing.T, repo repository.Repository) {
+	require.NoError(t, repo.Ping(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, repo.Ping(ctx), context.Canceled)
+}
@@ -0,0 +1,52 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/nemopss/go-posts-comments-system/internal/diff"
+	"github.com/stretchr/testify/assert"
+)
+
+// apply восстанавливает обе версии текста по списку правок
+func apply(lines []diff.Line) (from, to []string) {
+	for _, line := range lines {
+		if line.Op != diff.Insert {
+			from = append(from, line.Text)
+		}
+		if line.Op != diff.Delete {
+			to = append(to, line.Text)
+		}
+	}
+	return from, to
+}
+
+func TestLines(t *testing.T) {
+	lines := diff.Lines("a\nb\nc\nd", "a\nc\nd\ne")
+	assert.Equal(t, []diff.Line{
+		{Op: diff.Equal, Text: "a"},
+		{Op: diff.Delete, Text: "b"},
+		{Op: diff.Equal, Text: "c"},
+		{Op: diff.Equal, Text: "d"},
+		{Op: diff.Insert, Text: "e"},
+	}, lines)
+
+	// Сравнение с пустым текстом
+	assert.Equal(t, []diff.Line{{Op: diff.Insert, Text: "a"}}, diff.Lines("", "a"))
+	assert.Equal(t, []diff.Line{{Op: diff.Delete, Text: "a"}}, diff.Lines("a", ""))
+	assert.Empty(t, diff.Lines("", ""))
+
+	// Список правок всегда восстанавливает обе версии, а число правок минимально
+	from, to := "x\na\nb\nc\ny\nb\na\nb", "c\nb\na\nb\na\nc\nz"
+	lines = diff.Lines(from, to)
+	gotFrom, gotTo := apply(lines)
+	assert.Equal(t, []string{"x", "a", "b", "c", "y", "b", "a", "b"}, gotFrom)
+	assert.Equal(t, []string{"c", "b", "a", "b", "a", "c", "z"}, gotTo)
+	changes := 0
+	for _, line := range lines {
+		if line.Op != diff.Equal {
+			changes++
+		}
+	}
+	// Наибольшая общая подпоследовательность имеет длину 4 (например, a b a b или c b a b)
+	assert.Equal(t, 8+7-2*4, changes)
+}
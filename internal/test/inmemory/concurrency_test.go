@@ -0,0 +1,232 @@
+package test
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nemopss/go-posts-comments-system/internal/models"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+	"github.com/nemopss/go-posts-comments-system/internal/repository/inmemory"
+)
+
+// Тест копий: изменение возвращённых объектов не затрагивает состояние хранилища
+func TestReturnedCopies_InMemory(t *testing.T) {
+	repo := inmemory.NewInMemoryRepository()
+	author := createUser(repo, "author")
+	post := createPost(repo, author.ID, "Title", "Content", false)
+	parent := createComment(repo, author.ID, post.ID, "", "Parent")
+	child := createComment(repo, author.ID, post.ID, parent.ID, "Child")
+
+	post.Title = "Changed"
+	fetchedPost, _ := repo.GetPost(ctx, post.ID)
+	fetchedPost.CommentCount = 100
+	if stored, _ := repo.GetPost(ctx, post.ID); stored.Title != "Title" || stored.CommentCount != 2 {
+		t.Errorf("expected stored post to be unchanged, got %+v", stored)
+	}
+
+	fetchedParent, _ := repo.GetComment(ctx, parent.ID)
+	if fetchedParent.Children != nil {
+		t.Errorf("expected returned comment without children, got %v", fetchedParent.Children)
+	}
+	fetchedParent.Content = "Changed"
+	fetchedParent.Children = append(fetchedParent.Children, &models.Comment{ID: "fake"})
+	children, _ := repo.GetCommentsByParentID(ctx, parent.ID, 10, nil)
+	if len(children.Comments) != 1 || children.Comments[0].ID != child.ID {
+		t.Errorf("expected stored children to be unchanged, got %v", children.Comments)
+	}
+	children.Comments[0].Content = "Changed"
+	if stored, _ := repo.GetComment(ctx, child.ID); stored.Content != "Child" {
+		t.Errorf("expected stored child to be unchanged, got %q", stored.Content)
+	}
+	if stored, _ := repo.GetComment(ctx, parent.ID); stored.Content != "Parent" {
+		t.Errorf("expected stored parent to be unchanged, got %q", stored.Content)
+	}
+
+	user, _ := repo.GetUserByUsername(ctx, "author")
+	user.Role = models.RoleAdmin
+	if stored, _ := repo.GetUser(ctx, author.ID); stored.Role != models.RoleUser {
+		t.Errorf("expected stored user role to be unchanged, got %q", stored.Role)
+	}
+}
+
+// Тест одновременного доступа: параллельные чтения и изменения не приводят к гонкам
+// (проверяется запуском с -race) и оставляют счётчики согласованными
+func TestParallelStress_InMemory(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(stderr)
+
+	repo := inmemory.NewInMemoryRepository()
+	author := createUser(repo, "author")
+	posts := make([]*models.Post, 4)
+	for i := range posts {
+		posts[i] = createPost(repo, author.ID, fmt.Sprintf("Post %d", i), "Content", false)
+	}
+
+	const workers = 8
+	const iterations = 200
+	var created int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			random := rand.New(rand.NewSource(int64(worker)))
+			mine := map[string][]string{} // Комментарии, созданные этим потоком, по постам
+			for i := 0; i < iterations; i++ {
+				post := posts[random.Intn(len(posts))]
+				switch random.Intn(6) {
+				case 0, 1:
+					parentId := ""
+					if len(mine[post.ID]) > 0 && random.Intn(2) == 0 {
+						parentId = mine[post.ID][random.Intn(len(mine[post.ID]))]
+					}
+					comment, err := repo.CreateComment(ctx, author.ID, post.ID, parentId, "Comment")
+					if err != nil {
+						t.Errorf("failed to create comment: %v", err)
+						return
+					}
+					atomic.AddInt64(&created, 1)
+					mine[post.ID] = append(mine[post.ID], comment.ID)
+				case 2:
+					repo.Vote(ctx, repository.TargetPost, post.ID, fmt.Sprintf("user-%d", worker), random.Intn(3)-1)
+				case 3:
+					repo.UpdatePost(ctx, author.ID, post.ID, post.Title, fmt.Sprintf("Content %d", i), false)
+				case 4:
+					page, err := repo.GetCommentsByPostID(ctx, post.ID, 5, nil)
+					if err != nil {
+						t.Errorf("failed to get comments: %v", err)
+						return
+					}
+					for _, comment := range page.Comments {
+						comment.Content = "Mutated by reader"
+						repo.GetCommentsByParentID(ctx, comment.ID, 5, nil)
+					}
+				case 5:
+					repo.ListPosts(ctx, repository.PostsQuery{First: 10, OrderBy: repository.PostOrderCommentCount})
+					repo.Search(ctx, repository.SearchQuery{Query: "content", First: 5})
+					repo.GetCommentThread(ctx, repository.ThreadQuery{PostID: post.ID, MaxDepth: repository.NoLimit, First: repository.NoLimit})
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// Сумма счётчиков комментариев постов совпадает с количеством комментариев в деревьях
+	total := 0
+	for _, post := range posts {
+		stored, err := repo.GetPost(ctx, post.ID)
+		if err != nil {
+			t.Fatalf("failed to get post: %v", err)
+		}
+		thread, err := repo.GetCommentThread(ctx, repository.ThreadQuery{PostID: post.ID, MaxDepth: repository.NoLimit, First: repository.NoLimit})
+		if err != nil {
+			t.Fatalf("failed to get thread: %v", err)
+		}
+		if stored.CommentCount != len(thread) {
+			t.Errorf("post %s: expected comment count %d, got %d", post.ID, len(thread), stored.CommentCount)
+		}
+		for _, entry := range thread {
+			if entry.Comment.Content != "Comment" {
+				t.Errorf("expected readers not to change stored comments, got %q", entry.Comment.Content)
+			}
+		}
+		total += stored.CommentCount
+	}
+	if int64(total) != created {
+		t.Errorf("expected %d comments, got %d", created, total)
+	}
+}
+
+// stderr - исходный вывод журнала, восстанавливаемый после тестов с отключённым журналом
+var stderr = log.Writer()
+
+// benchmarkRepository создаёт хранилище с постами и деревьями комментариев для измерений
+func benchmarkRepository(b *testing.B, repo *inmemory.InMemoryRepository) []*models.Post {
+	b.Helper()
+	author := createUser(repo, "author")
+	posts := make([]*models.Post, 20)
+	for i := range posts {
+		posts[i] = createPost(repo, author.ID, fmt.Sprintf("Post %d", i), "Content", false)
+		for j := 0; j < 20; j++ {
+			root := createComment(repo, author.ID, posts[i].ID, "", "Root")
+			createComment(repo, author.ID, posts[i].ID, root.ID, "Reply")
+		}
+	}
+	return posts
+}
+
+// benchmarkReads измеряет параллельное чтение страниц комментариев, пока writers потоков непрерывно
+// добавляют комментарии и голосуют
+func benchmarkReads(b *testing.B, repo *inmemory.InMemoryRepository, writers int) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(stderr)
+	posts := benchmarkRepository(b, repo)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	var writes int64
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				post := posts[i%len(posts)]
+				if i%2 == 0 {
+					repo.CreateComment(ctx, post.AuthorID, post.ID, "", "Concurrent comment")
+				} else {
+					repo.Vote(ctx, repository.TargetPost, post.ID, fmt.Sprintf("user-%d", worker), repository.VoteUp)
+				}
+				atomic.AddInt64(&writes, 1)
+			}
+		}(w)
+	}
+
+	var next int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			post := posts[atomic.AddInt64(&next, 1)%int64(len(posts))]
+			if _, err := repo.GetPost(ctx, post.ID); err != nil {
+				b.Error(err)
+			}
+			if _, err := repo.GetCommentsByPostID(ctx, post.ID, 10, nil); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+	b.StopTimer()
+	close(stop)
+	wg.Wait()
+	b.ReportMetric(float64(writes)/b.Elapsed().Seconds(), "writes/s")
+}
+
+// Чтение без одновременных изменений
+func BenchmarkReads_InMemory(b *testing.B) {
+	benchmarkReads(b, inmemory.NewInMemoryRepository(), 0)
+}
+
+// Чтение при непрерывных изменениях в памяти
+func BenchmarkReadsUnderWrites_InMemory(b *testing.B) {
+	benchmarkReads(b, inmemory.NewInMemoryRepository(), 2)
+}
+
+// Чтение при непрерывных изменениях, каждое из которых сбрасывается в журнал на диске:
+// запись журнала выполняется без блокировки состояния и не задерживает чтение
+func BenchmarkReadsUnderPersistentWrites_InMemory(b *testing.B) {
+	repo, err := inmemory.Open(inmemory.Options{Dir: b.TempDir(), Sync: inmemory.SyncAlways})
+	if err != nil {
+		b.Fatalf("failed to open repository: %v", err)
+	}
+	defer repo.Close()
+	benchmarkReads(b, repo, 2)
+}
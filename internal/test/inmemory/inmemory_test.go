@@ -1,16 +1,35 @@
 package test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"testing"
+	"time"
 
 	"github.com/nemopss/go-posts-comments-system/internal/models"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
 	"github.com/nemopss/go-posts-comments-system/internal/repository/inmemory"
+	"github.com/nemopss/go-posts-comments-system/internal/test/conformance"
 )
 
+// ctx - контекст вызовов хранилища в тестах
+var ctx = context.Background()
+
+// Функция для создания пользователя в in-memory хранилище
+func createUser(repo *inmemory.InMemoryRepository, username string) *models.User {
+	user, err := repo.CreateUser(ctx, username, "password-hash")
+	if err != nil {
+		panic(err)
+	}
+	return user
+}
+
 // Функция для создания поста в in-memory хранилище
-func createPost(repo *inmemory.InMemoryRepository, title, content string, commentsDisabled bool) *models.Post {
-	post, err := repo.CreatePost(title, content, commentsDisabled)
+func createPost(repo *inmemory.InMemoryRepository, authorId, title, content string, commentsDisabled bool) *models.Post {
+	post, err := repo.CreatePost(ctx, authorId, title, content, commentsDisabled)
 	if err != nil {
 		panic(err)
 	}
@@ -18,8 +37,8 @@ func createPost(repo *inmemory.InMemoryRepository, title, content string, commen
 }
 
 // Функция для создания комментария в in-memory хранилище
-func createComment(repo *inmemory.InMemoryRepository, postId, parentId, content string) *models.Comment {
-	comment, err := repo.CreateComment(postId, parentId, content)
+func createComment(repo *inmemory.InMemoryRepository, authorId, postId, parentId, content string) *models.Comment {
+	comment, err := repo.CreateComment(ctx, authorId, postId, parentId, content)
 	if err != nil {
 		panic(err)
 	}
@@ -29,12 +48,13 @@ func createComment(repo *inmemory.InMemoryRepository, postId, parentId, content
 // Тест GetPosts
 func TestGetPosts_InMemory(t *testing.T) {
 	repo := inmemory.NewInMemoryRepository()
+	author := createUser(repo, "author")
 
 	// Создание постов
-	createPost(repo, "Test Post 1", "This is the first test post", false)
-	createPost(repo, "Test Post 2", "This is the second test post", true)
+	createPost(repo, author.ID, "Test Post 1", "This is the first test post", false)
+	createPost(repo, author.ID, "Test Post 2", "This is the second test post", true)
 
-	posts, err := repo.GetPosts()
+	posts, err := repo.GetPosts(ctx)
 	if err != nil {
 		t.Errorf("failed to get posts: %v", err)
 	}
@@ -46,11 +66,12 @@ func TestGetPosts_InMemory(t *testing.T) {
 // Тест GetPost
 func TestGetPost_InMemory(t *testing.T) {
 	repo := inmemory.NewInMemoryRepository()
+	author := createUser(repo, "author")
 
 	// Создание поста
-	post := createPost(repo, "Test Post", "This is a test post", false)
+	post := createPost(repo, author.ID, "Test Post", "This is a test post", false)
 
-	fetchedPost, err := repo.GetPost(post.ID)
+	fetchedPost, err := repo.GetPost(ctx, post.ID)
 	if err != nil {
 		t.Errorf("failed to get post: %v", err)
 	}
@@ -62,9 +83,10 @@ func TestGetPost_InMemory(t *testing.T) {
 // Тест CreatePost
 func TestCreatePost_InMemory(t *testing.T) {
 	repo := inmemory.NewInMemoryRepository()
+	author := createUser(repo, "author")
 
 	// Создание поста
-	post := createPost(repo, "Test Post", "This is a test post", false)
+	post := createPost(repo, author.ID, "Test Post", "This is a test post", false)
 
 	if post.Title != "Test Post" {
 		t.Errorf("expected title 'Test Post', got %s", post.Title)
@@ -80,65 +102,552 @@ func TestCreatePost_InMemory(t *testing.T) {
 // Тест CreateComment
 func TestCreateComment_InMemory(t *testing.T) {
 	repo := inmemory.NewInMemoryRepository()
+	author := createUser(repo, "author")
 
 	// Создание поста и комментария к нему
-	post := createPost(repo, "Test Post", "This is a test post", false)
-	comment := createComment(repo, post.ID, "", "This is a test comment")
+	post := createPost(repo, author.ID, "Test Post", "This is a test post", false)
+	comment := createComment(repo, author.ID, post.ID, "", "This is a test comment")
 
 	if comment.Content != "This is a test comment" {
 		t.Errorf("expected content 'This is a test comment', got %s", comment.Content)
 	}
-	if *comment.ParentID != "" {
-		t.Errorf("expected parentId to be empty, got %s", *comment.ParentID)
+	if comment.ParentID != nil {
+		t.Errorf("expected no parentId, got %s", *comment.ParentID)
 	}
 }
 
 // Тест GetCommentsByPostID
 func TestGetCommentsByPostID_InMemory(t *testing.T) {
 	repo := inmemory.NewInMemoryRepository()
+	author := createUser(repo, "author")
 
 	// Создание поста и комментариев к нему
-	post := createPost(repo, "Test Post", "This is a test post", false)
-	createComment(repo, post.ID, "", "Comment 1")
-	createComment(repo, post.ID, "", "Comment 2")
+	post := createPost(repo, author.ID, "Test Post", "This is a test post", false)
+	createComment(repo, author.ID, post.ID, "", "Comment 1")
+	createComment(repo, author.ID, post.ID, "", "Comment 2")
 
-	comments, err := repo.GetCommentsByPostID(post.ID, 2, nil)
-	log.Println("LEN INMEM COMM:", len(comments))
+	page, err := repo.GetCommentsByPostID(ctx, post.ID, 2, nil)
+	log.Println("LEN INMEM COMM:", len(page.Comments))
 	if err != nil {
 		t.Errorf("failed to get comments: %v", err)
 	}
-	if len(comments) != 2 {
-		t.Errorf("expected 2 comments, got %d", len(comments))
+	if len(page.Comments) != 2 {
+		t.Errorf("expected 2 comments, got %d", len(page.Comments))
 	}
 }
 
 // Тест GetCommentsByParentID with с пагинацией
 func TestGetCommentsByParentID_InMemory(t *testing.T) {
 	repo := inmemory.NewInMemoryRepository()
+	author := createUser(repo, "author")
 
 	// Создание поста и нескольких вложенных комментариев
-	post := createPost(repo, "Test Post", "This is a test post", false)
-	comment1 := createComment(repo, post.ID, "", "Comment 1")
-	_ = createComment(repo, post.ID, comment1.ID, "Comment 2")
-	_ = createComment(repo, post.ID, comment1.ID, "Comment 3")
-	_ = createComment(repo, post.ID, comment1.ID, "Comment 4")
-	_ = createComment(repo, post.ID, comment1.ID, "Comment 5")
+	post := createPost(repo, author.ID, "Test Post", "This is a test post", false)
+	comment1 := createComment(repo, author.ID, post.ID, "", "Comment 1")
+	_ = createComment(repo, author.ID, post.ID, comment1.ID, "Comment 2")
+	_ = createComment(repo, author.ID, post.ID, comment1.ID, "Comment 3")
+	_ = createComment(repo, author.ID, post.ID, comment1.ID, "Comment 4")
+	_ = createComment(repo, author.ID, post.ID, comment1.ID, "Comment 5")
 
 	// Проверка полученных комментариев
-	comments, err := repo.GetCommentsByParentID(comment1.ID, 2, nil)
+	page, err := repo.GetCommentsByParentID(ctx, comment1.ID, 2, nil)
 	if err != nil {
 		t.Errorf("failed to get comments: %v", err)
 	}
-	if len(comments) != 2 {
-		t.Errorf("expected 2 comments, got %d", len(comments))
+	if len(page.Comments) != 2 {
+		t.Errorf("expected 2 comments, got %d", len(page.Comments))
 	}
 
-	after := comments[len(comments)-1].ID
-	comments, err = repo.GetCommentsByParentID(comment1.ID, 2, &after)
+	after := repository.CursorOf(page.Comments[len(page.Comments)-1])
+	page, err = repo.GetCommentsByParentID(ctx, comment1.ID, 2, &after)
 	if err != nil {
 		t.Errorf("failed to get comments: %v", err)
 	}
-	if len(comments) != 2 {
-		t.Errorf("expected 2 comments, got %d", len(comments))
+	if len(page.Comments) != 2 {
+		t.Errorf("expected 2 comments, got %d", len(page.Comments))
+	}
+	if page.HasNextPage {
+		t.Errorf("expected no next page")
+	}
+}
+
+// Тест keyset-пагинации: страницы не пропускают и не повторяют комментарии
+func TestCommentsPagination_InMemory(t *testing.T) {
+	repo := inmemory.NewInMemoryRepository()
+	author := createUser(repo, "author")
+
+	post := createPost(repo, author.ID, "Test Post", "This is a test post", false)
+	created := map[string]bool{}
+	for i := 0; i < 7; i++ {
+		created[createComment(repo, author.ID, post.ID, "", fmt.Sprintf("Comment %d", i)).ID] = true
+	}
+
+	// Курсор должен пережить кодирование в непрозрачную строку
+	fetched := []*models.Comment{}
+	var after *repository.Cursor
+	for {
+		page, err := repo.GetCommentsByPostID(ctx, post.ID, 3, after)
+		if err != nil {
+			t.Fatalf("failed to get comments: %v", err)
+		}
+		if page.TotalCount != len(created) {
+			t.Errorf("expected total count %d, got %d", len(created), page.TotalCount)
+		}
+		fetched = append(fetched, page.Comments...)
+		if !page.HasNextPage {
+			break
+		}
+		after, err = repository.DecodeCursor(repository.EncodeCursor(repository.CursorOf(page.Comments[len(page.Comments)-1])))
+		if err != nil {
+			t.Fatalf("failed to decode cursor: %v", err)
+		}
+	}
+
+	if len(fetched) != len(created) {
+		t.Fatalf("expected %d comments, got %d", len(created), len(fetched))
+	}
+	seen := map[string]bool{}
+	for i, comment := range fetched {
+		if !created[comment.ID] || seen[comment.ID] {
+			t.Errorf("unexpected or repeated comment %s", comment.ID)
+		}
+		seen[comment.ID] = true
+		if i > 0 && !repository.CursorOf(fetched[i-1]).After(comment.CreatedAt, comment.ID) {
+			t.Errorf("comments are not ordered by (created_at, id) at position %d", i)
+		}
+	}
+}
+
+// Тест ListPosts: фильтрация, сортировка и пагинация постов
+func TestListPosts_InMemory(t *testing.T) {
+	repo := inmemory.NewInMemoryRepository()
+	author := createUser(repo, "author")
+
+	quiet := createPost(repo, author.ID, "Quiet post", "Content", false)
+	busy := createPost(repo, author.ID, "Busy post", "Content", false)
+	closed := createPost(repo, author.ID, "Closed post", "Content", true)
+	createComment(repo, author.ID, busy.ID, "", "Comment 1")
+	createComment(repo, author.ID, busy.ID, "", "Comment 2")
+	createComment(repo, author.ID, quiet.ID, "", "Comment 3")
+
+	// Сортировка по количеству комментариев
+	page, err := repo.ListPosts(ctx, repository.PostsQuery{First: 10, OrderBy: repository.PostOrderCommentCount})
+	if err != nil {
+		t.Fatalf("failed to list posts: %v", err)
+	}
+	expected := []string{busy.ID, quiet.ID, closed.ID}
+	for i, post := range page.Posts {
+		if post.ID != expected[i] {
+			t.Errorf("expected post %s at position %d, got %s", expected[i], i, post.ID)
+		}
+	}
+
+	// Сортировка по последней активности: самый свежий комментарий оставлен к quiet
+	page, err = repo.ListPosts(ctx, repository.PostsQuery{First: 1, OrderBy: repository.PostOrderLastActivity})
+	if err != nil {
+		t.Fatalf("failed to list posts: %v", err)
+	}
+	if page.Posts[0].ID != quiet.ID || !page.HasNextPage {
+		t.Errorf("expected most recently active post %s first", quiet.ID)
+	}
+
+	// Фильтрация по подстроке заголовка и флагу отключения комментариев
+	title := "POST"
+	commentsDisabled := false
+	page, err = repo.ListPosts(ctx, repository.PostsQuery{
+		First:  1,
+		Filter: repository.PostFilter{TitleContains: &title, CommentsDisabled: &commentsDisabled},
+	})
+	if err != nil {
+		t.Fatalf("failed to list posts: %v", err)
+	}
+	if page.TotalCount != 2 {
+		t.Errorf("expected 2 matching posts, got %d", page.TotalCount)
+	}
+	if len(page.Posts) != 1 || page.Posts[0].ID != busy.ID {
+		t.Fatalf("expected newest matching post %s first", busy.ID)
+	}
+
+	// Следующая страница начинается после курсора
+	after := repository.PostCursorOf(page.Posts[0], repository.PostOrderCreatedAt)
+	page, err = repo.ListPosts(ctx, repository.PostsQuery{
+		First:  1,
+		After:  &after,
+		Filter: repository.PostFilter{TitleContains: &title, CommentsDisabled: &commentsDisabled},
+	})
+	if err != nil {
+		t.Fatalf("failed to list posts: %v", err)
+	}
+	if len(page.Posts) != 1 || page.Posts[0].ID != quiet.ID || page.HasNextPage {
+		t.Errorf("expected last page with post %s", quiet.ID)
+	}
+}
+
+// Тест CreateUser: имена пользователей уникальны
+func TestCreateUser_InMemory(t *testing.T) {
+	repo := inmemory.NewInMemoryRepository()
+	user := createUser(repo, "alice")
+
+	fetched, err := repo.GetUserByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatalf("failed to get user: %v", err)
+	}
+	if fetched.ID != user.ID {
+		t.Errorf("expected user ID %s, got %s", user.ID, fetched.ID)
+	}
+
+	_, err = repo.CreateUser(ctx, "alice", "another-hash")
+	if !errors.Is(err, repository.ErrUsernameTaken) {
+		t.Errorf("expected ErrUsernameTaken, got %v", err)
+	}
+}
+
+// Тест UpdatePost и UpdateComment: каждое изменение сохраняет новую версию
+func TestRevisions_InMemory(t *testing.T) {
+	repo := inmemory.NewInMemoryRepository()
+	author := createUser(repo, "author")
+	editor := createUser(repo, "editor")
+	post := createPost(repo, author.ID, "Title", "Content", false)
+	comment := createComment(repo, author.ID, post.ID, "", "Comment")
+
+	if post.EditedAt != nil {
+		t.Errorf("expected new post not to be edited")
+	}
+	updated, err := repo.UpdatePost(ctx, editor.ID, post.ID, "New title", "New content", true)
+	if err != nil {
+		t.Fatalf("failed to update post: %v", err)
+	}
+	if updated.Title != "New title" || updated.Content != "New content" || !updated.CommentsDisabled || updated.EditedAt == nil {
+		t.Errorf("unexpected updated post: %+v", updated)
+	}
+
+	revisions, err := repo.GetPostRevisions(ctx, post.ID)
+	if err != nil {
+		t.Fatalf("failed to get post revisions: %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("expected 2 revisions, got %d", len(revisions))
+	}
+	if revisions[0].Revision != 1 || revisions[0].Title != "Title" || revisions[0].EditorID != author.ID {
+		t.Errorf("unexpected first revision: %+v", revisions[0])
+	}
+	if revisions[1].Revision != 2 || revisions[1].Title != "New title" || revisions[1].EditorID != editor.ID {
+		t.Errorf("unexpected second revision: %+v", revisions[1])
+	}
+
+	if _, err := repo.UpdateComment(ctx, author.ID, comment.ID, "Edited comment"); err != nil {
+		t.Fatalf("failed to update comment: %v", err)
+	}
+	commentRevisions, err := repo.GetCommentRevisions(ctx, comment.ID)
+	if err != nil {
+		t.Fatalf("failed to get comment revisions: %v", err)
+	}
+	if len(commentRevisions) != 2 || commentRevisions[1].Content != "Edited comment" {
+		t.Errorf("unexpected comment revisions: %+v", commentRevisions)
+	}
+
+	if _, err := repo.UpdatePost(ctx, author.ID, "missing", "Title", "Content", false); err == nil {
+		t.Errorf("expected error when updating missing post")
+	}
+}
+
+// Тест SoftDeleteComment: надгробие сохраняет ответы
+func TestSoftDeleteComment_InMemory(t *testing.T) {
+	repo := inmemory.NewInMemoryRepository()
+	author := createUser(repo, "author")
+	post := createPost(repo, author.ID, "Title", "Content", false)
+	comment := createComment(repo, author.ID, post.ID, "", "Comment")
+	createComment(repo, author.ID, post.ID, comment.ID, "Reply")
+
+	tombstone, err := repo.SoftDeleteComment(ctx, comment.ID)
+	if err != nil {
+		t.Fatalf("failed to soft delete comment: %v", err)
+	}
+	if tombstone.DeletedAt == nil || tombstone.Content != "" {
+		t.Errorf("expected tombstone, got %+v", tombstone)
+	}
+
+	page, err := repo.GetCommentsByParentID(ctx, comment.ID, 10, nil)
+	if err != nil {
+		t.Fatalf("failed to get replies: %v", err)
+	}
+	if len(page.Comments) != 1 || page.Comments[0].Content != "Reply" {
+		t.Errorf("expected reply to survive, got %d comments", len(page.Comments))
+	}
+
+	if _, err := repo.UpdateComment(ctx, author.ID, comment.ID, "Edited"); !errors.Is(err, repository.ErrCommentDeleted) {
+		t.Errorf("expected ErrCommentDeleted on update, got %v", err)
+	}
+	if _, err := repo.CreateComment(ctx, author.ID, post.ID, comment.ID, "Reply"); !errors.Is(err, repository.ErrCommentDeleted) {
+		t.Errorf("expected ErrCommentDeleted on reply, got %v", err)
 	}
 }
+
+// Тест Vote и React: один голос на пользователя, счётчики обновляются при каждом голосе
+func TestVotesAndReactions_InMemory(t *testing.T) {
+	repo := inmemory.NewInMemoryRepository()
+	author := createUser(repo, "author")
+	voter := createUser(repo, "voter")
+	post := createPost(repo, author.ID, "Title", "Content", false)
+
+	votes := []struct {
+		userId string
+		value  int
+	}{
+		{author.ID, repository.VoteUp},
+		{voter.ID, repository.VoteUp},
+		{voter.ID, repository.VoteDown},
+	}
+	for _, vote := range votes {
+		if err := repo.Vote(ctx, repository.TargetPost, post.ID, vote.userId, vote.value); err != nil {
+			t.Fatalf("failed to vote: %v", err)
+		}
+	}
+	post, err := repo.GetPost(ctx, post.ID)
+	if err != nil {
+		t.Fatalf("failed to get post: %v", err)
+	}
+	if post.Upvotes != 1 || post.Downvotes != 1 {
+		t.Errorf("expected 1 upvote and 1 downvote, got %d and %d", post.Upvotes, post.Downvotes)
+	}
+	if err := repo.Vote(ctx, repository.TargetPost, post.ID, voter.ID, 2); !errors.Is(err, repository.ErrInvalidVote) {
+		t.Errorf("expected ErrInvalidVote, got %v", err)
+	}
+
+	for _, userId := range []string{author.ID, voter.ID} {
+		if _, err := repo.React(ctx, repository.TargetPost, post.ID, userId, "👍"); err != nil {
+			t.Fatalf("failed to react: %v", err)
+		}
+	}
+	if reacted, _ := repo.React(ctx, repository.TargetPost, post.ID, voter.ID, "👍"); reacted {
+		t.Errorf("expected second reaction to be removed")
+	}
+	reactions, err := repo.GetReactions(ctx, repository.TargetPost, post.ID, voter.ID)
+	if err != nil {
+		t.Fatalf("failed to get reactions: %v", err)
+	}
+	if len(reactions) != 1 || reactions[0].Count != 1 || reactions[0].ViewerHasReacted {
+		t.Errorf("unexpected reactions: %+v", reactions)
+	}
+}
+
+// Тест Search
+func TestSearch_InMemory(t *testing.T) {
+	repo := inmemory.NewInMemoryRepository()
+	author := createUser(repo, "author")
+	titlePost := createPost(repo, author.ID, "Кошки и собаки", "Текст про домашних животных", false)
+	contentPost := createPost(repo, author.ID, "Заметки", "Моя кошка любит спать. Кошка спит весь день.", false)
+	dogPost := createPost(repo, author.ID, "Про собак", "Собака громко лает", false)
+	comment := createComment(repo, author.ID, dogPost.ID, "", "Видел рыжую кошку во дворе")
+
+	// Совпадение в заголовке важнее совпадений в тексте, а больше вхождений - важнее одного
+	page, err := repo.Search(ctx, repository.SearchQuery{Query: "кошки", First: 10})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	expected := []string{titlePost.ID, contentPost.ID, comment.ID}
+	if page.TotalCount != 3 || len(page.Hits) != 3 {
+		t.Fatalf("expected 3 hits, got %d of %d", len(page.Hits), page.TotalCount)
+	}
+	for i, hit := range page.Hits {
+		if hit.ID() != expected[i] {
+			t.Errorf("expected hit %d to be %s, got %s", i, expected[i], hit.ID())
+		}
+	}
+	if page.Hits[1].Snippet != "Моя <b>кошка</b> любит спать. <b>Кошка</b> спит весь день." {
+		t.Errorf("unexpected snippet: %q", page.Hits[1].Snippet)
+	}
+
+	// Пагинация по курсору
+	page, err = repo.Search(ctx, repository.SearchQuery{Query: "кошки", First: 2})
+	if err != nil || !page.HasNextPage {
+		t.Fatalf("expected next page, got %v", err)
+	}
+	cursor := repository.SearchCursorOf(page.Hits[1])
+	page, err = repo.Search(ctx, repository.SearchQuery{Query: "кошки", First: 2, After: &cursor})
+	if err != nil || len(page.Hits) != 1 || page.Hits[0].ID() != comment.ID || page.HasNextPage {
+		t.Errorf("unexpected second page: %+v, %v", page, err)
+	}
+
+	// Найденные документы содержат все слова запроса
+	page, _ = repo.Search(ctx, repository.SearchQuery{Query: "кошка собака", First: 10})
+	if len(page.Hits) != 1 || page.Hits[0].ID() != titlePost.ID {
+		t.Errorf("expected only the post with both words, got %d hits", len(page.Hits))
+	}
+
+	// Поиск только среди комментариев
+	page, _ = repo.Search(ctx, repository.SearchQuery{Query: "кошки", First: 10, Kinds: []repository.TargetKind{repository.TargetComment}})
+	if len(page.Hits) != 1 || page.Hits[0].Kind != repository.TargetComment {
+		t.Errorf("expected only the comment, got %d hits", len(page.Hits))
+	}
+
+	// Запрос из одних стоп-слов ничего не находит
+	page, _ = repo.Search(ctx, repository.SearchQuery{Query: "и", First: 10})
+	if page.TotalCount != 0 {
+		t.Errorf("expected no hits for a stop word, got %d", page.TotalCount)
+	}
+
+	// Индекс обновляется при изменении и удалении
+	if _, err := repo.UpdatePost(ctx, author.ID, contentPost.ID, "Заметки", "Моя собака любит спать", false); err != nil {
+		t.Fatalf("failed to update post: %v", err)
+	}
+	if err := repo.DeleteComment(ctx, comment.ID); err != nil {
+		t.Fatalf("failed to delete comment: %v", err)
+	}
+	page, _ = repo.Search(ctx, repository.SearchQuery{Query: "кошки", First: 10})
+	if page.TotalCount != 1 || page.Hits[0].ID() != titlePost.ID {
+		t.Errorf("expected only the title post after changes, got %d hits", page.TotalCount)
+	}
+}
+
+// Тест отмены: операции с отменённым контекстом или истёкшим сроком не выполняются
+func TestCancellation_InMemory(t *testing.T) {
+	repo := inmemory.NewInMemoryRepository()
+	author := createUser(repo, "author")
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := repo.CreatePost(canceled, author.ID, "Title", "Content", false); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if posts, _ := repo.GetPosts(ctx); len(posts) != 0 {
+		t.Errorf("expected no posts, got %d", len(posts))
+	}
+
+	expired, cancel := context.WithTimeout(ctx, -time.Second)
+	defer cancel()
+	if _, err := repository.WithTimeout(repo, time.Minute).GetPosts(expired); !errors.Is(err, repository.ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+}
+
+// Тест типизированных ошибок хранилища
+func TestErrors_InMemory(t *testing.T) {
+	repo := inmemory.NewInMemoryRepository()
+	author := createUser(repo, "author")
+	post := createPost(repo, author.ID, "Title", "Content", true)
+
+	_, err := repo.GetPost(ctx, "missing")
+	var notFound *repository.NotFoundError
+	if !errors.As(err, &notFound) || notFound.Entity != repository.EntityPost || notFound.ID != "missing" {
+		t.Errorf("expected post NotFoundError, got %v", err)
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := repo.DeleteComment(ctx, "missing"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected ErrNotFound on delete, got %v", err)
+	}
+	if _, err := repo.CreateComment(ctx, author.ID, "missing", "", "Hello"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for missing post, got %v", err)
+	}
+	if _, err := repo.CreateComment(ctx, author.ID, post.ID, "", "Hello"); !errors.Is(err, repository.ErrCommentsDisabled) {
+		t.Errorf("expected ErrCommentsDisabled, got %v", err)
+	}
+
+	long := make([]byte, repository.MaxCommentLength+1)
+	for i := range long {
+		long[i] = 'a'
+	}
+	_, err = repo.CreateComment(ctx, author.ID, post.ID, "", string(long))
+	var invalid *repository.ValidationError
+	if !errors.As(err, &invalid) || invalid.Field != "content" || !errors.Is(err, repository.ErrValidation) {
+		t.Errorf("expected content ValidationError, got %v", err)
+	}
+	if _, err := repo.CreateUser(ctx, "author", "password-hash"); !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+}
+
+// Тест индексов комментариев: удаление поддеревьев и постов не затрагивает списки других постов и комментариев
+func TestCommentIndexes_InMemory(t *testing.T) {
+	repo := inmemory.NewInMemoryRepository()
+	author := createUser(repo, "author")
+	post := createPost(repo, author.ID, "Post", "Content", false)
+	other := createPost(repo, author.ID, "Other post", "Content", false)
+
+	first := createComment(repo, author.ID, post.ID, "", "First")
+	second := createComment(repo, author.ID, post.ID, "", "Second")
+	reply := createComment(repo, author.ID, post.ID, second.ID, "Reply")
+	nested := createComment(repo, author.ID, post.ID, reply.ID, "Nested")
+	third := createComment(repo, author.ID, post.ID, "", "Third")
+	kept := createComment(repo, author.ID, other.ID, "", "Kept")
+	keptReply := createComment(repo, author.ID, other.ID, kept.ID, "Kept reply")
+
+	// Удаление комментария из середины списка вместе с ответами на любой глубине
+	if err := repo.DeleteComment(ctx, second.ID); err != nil {
+		t.Fatalf("failed to delete comment: %v", err)
+	}
+	for _, id := range []string{second.ID, reply.ID, nested.ID} {
+		if _, err := repo.GetComment(ctx, id); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("expected comment %s to be deleted, got %v", id, err)
+		}
+	}
+	page, err := repo.GetCommentsByPostID(ctx, post.ID, 10, nil)
+	if err != nil {
+		t.Fatalf("failed to get comments: %v", err)
+	}
+	if len(page.Comments) != 2 || page.Comments[0].ID != first.ID || page.Comments[1].ID != third.ID || page.TotalCount != 2 {
+		t.Errorf("expected comments [%s %s], got %v", first.ID, third.ID, page.Comments)
+	}
+	if stored, _ := repo.GetPost(ctx, post.ID); stored.CommentCount != 2 {
+		t.Errorf("expected comment count 2, got %d", stored.CommentCount)
+	}
+
+	// Удаление поста удаляет все его комментарии, а комментарии другого поста остаются
+	if err := repo.DeletePost(ctx, post.ID); err != nil {
+		t.Fatalf("failed to delete post: %v", err)
+	}
+	for _, id := range []string{first.ID, third.ID} {
+		if _, err := repo.GetComment(ctx, id); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("expected comment %s to be deleted, got %v", id, err)
+		}
+	}
+	page, _ = repo.GetCommentsByPostID(ctx, post.ID, 10, nil)
+	if len(page.Comments) != 0 || page.TotalCount != 0 {
+		t.Errorf("expected no comments on deleted post, got %v", page.Comments)
+	}
+	page, _ = repo.GetCommentsByPostID(ctx, other.ID, 10, nil)
+	if len(page.Comments) != 1 || page.Comments[0].ID != kept.ID {
+		t.Errorf("expected comment %s on other post, got %v", kept.ID, page.Comments)
+	}
+	page, _ = repo.GetCommentsByParentID(ctx, kept.ID, 10, nil)
+	if len(page.Comments) != 1 || page.Comments[0].ID != keptReply.ID {
+		t.Errorf("expected reply %s on other post, got %v", keptReply.ID, page.Comments)
+	}
+}
+
+// Получение страницы комментариев поста не зависит от количества комментариев в других постах
+func BenchmarkCommentsPage_InMemory(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(stderr)
+	for _, total := range []int{1000, 10000, 100000} {
+		b.Run(fmt.Sprintf("comments=%d", total), func(b *testing.B) {
+			repo := inmemory.NewInMemoryRepository()
+			author := createUser(repo, "author")
+			posts := make([]*models.Post, total/100)
+			for i := range posts {
+				posts[i] = createPost(repo, author.ID, fmt.Sprintf("Post %d", i), "Content", false)
+			}
+			for i := 0; i < total; i++ {
+				createComment(repo, author.ID, posts[i%len(posts)].ID, "", "Comment")
+			}
+			page, _ := repo.GetCommentsByPostID(ctx, posts[0].ID, 10, nil)
+			after := repository.CursorOf(page.Comments[len(page.Comments)-1])
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.GetCommentsByPostID(ctx, posts[i%len(posts)].ID, 10, &after); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// Общий набор тестов контракта хранилища
+func TestConformance_InMemory(t *testing.T) {
+	conformance.Run(t, func(t *testing.T) repository.Repository {
+		return inmemory.NewInMemoryRepository()
+	})
+}
@@ -0,0 +1,284 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+	"github.com/nemopss/go-posts-comments-system/internal/repository/inmemory"
+	"github.com/nemopss/go-posts-comments-system/internal/test/conformance"
+)
+
+// Функция для открытия сохраняемого на диск in-memory хранилища
+func openRepository(t *testing.T, dir string, snapshotEvery int) *inmemory.InMemoryRepository {
+	t.Helper()
+	repo, err := inmemory.Open(inmemory.Options{Dir: dir, Sync: inmemory.SyncAlways, SnapshotEvery: snapshotEvery})
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	return repo
+}
+
+// Тест восстановления состояния из журнала после перезапуска без снимка
+func TestPersistence_ReplayLog(t *testing.T) {
+	dir := t.TempDir()
+	repo := openRepository(t, dir, 0)
+	author := createUser(repo, "author")
+	post := createPost(repo, author.ID, "Persistent post", "Content", false)
+	parent := createComment(repo, author.ID, post.ID, "", "Parent comment")
+	createComment(repo, author.ID, post.ID, parent.ID, "Child comment")
+	if _, err := repo.UpdatePost(ctx, author.ID, post.ID, "Edited post", "Edited content", true); err != nil {
+		t.Fatalf("failed to update post: %v", err)
+	}
+	if err := repo.Vote(ctx, repository.TargetPost, post.ID, author.ID, repository.VoteUp); err != nil {
+		t.Fatalf("failed to vote: %v", err)
+	}
+	if _, err := repo.React(ctx, repository.TargetComment, parent.ID, author.ID, "👍"); err != nil {
+		t.Fatalf("failed to react: %v", err)
+	}
+	// Журнал не закрывается: имитация падения процесса
+	if _, err := os.Stat(filepath.Join(dir, "snapshot")); !os.IsNotExist(err) {
+		t.Fatalf("expected no snapshot before close, got %v", err)
+	}
+
+	restored := openRepository(t, dir, 0)
+	defer restored.Close()
+
+	if _, err := restored.GetUserByUsername(ctx, "author"); err != nil {
+		t.Errorf("failed to get restored user: %v", err)
+	}
+	restoredPost, err := restored.GetPost(ctx, post.ID)
+	if err != nil {
+		t.Fatalf("failed to get restored post: %v", err)
+	}
+	if restoredPost.Title != "Edited post" || !restoredPost.CommentsDisabled || restoredPost.EditedAt == nil {
+		t.Errorf("unexpected restored post: %+v", restoredPost)
+	}
+	if restoredPost.CommentCount != 2 || restoredPost.Upvotes != 1 {
+		t.Errorf("expected 2 comments and 1 upvote, got %d and %d", restoredPost.CommentCount, restoredPost.Upvotes)
+	}
+	if !restoredPost.CreatedAt.Equal(post.CreatedAt) {
+		t.Errorf("expected created at %v, got %v", post.CreatedAt, restoredPost.CreatedAt)
+	}
+	revisions, err := restored.GetPostRevisions(ctx, post.ID)
+	if err != nil || len(revisions) != 2 {
+		t.Errorf("expected 2 revisions, got %d (%v)", len(revisions), err)
+	}
+	children, err := restored.GetCommentsByParentID(ctx, parent.ID, 10, nil)
+	if err != nil || len(children.Comments) != 1 {
+		t.Errorf("expected 1 child comment, got %v (%v)", children, err)
+	}
+	reactions, err := restored.GetReactions(ctx, repository.TargetComment, parent.ID, author.ID)
+	if err != nil || len(reactions) != 1 || !reactions[0].ViewerHasReacted {
+		t.Errorf("expected restored reaction, got %v (%v)", reactions, err)
+	}
+	page, err := restored.Search(ctx, repository.SearchQuery{Query: "edited", First: 10})
+	if err != nil || page.TotalCount != 1 {
+		t.Errorf("expected restored search index, got %v (%v)", page, err)
+	}
+}
+
+// Тест восстановления из снимка и операций журнала, записанных после него
+func TestPersistence_SnapshotAndTail(t *testing.T) {
+	dir := t.TempDir()
+	repo := openRepository(t, dir, 3)
+	author := createUser(repo, "author")
+	post := createPost(repo, author.ID, "Snapshot post", "Content", false)
+	first := createComment(repo, author.ID, post.ID, "", "First comment") // Третья операция вызывает снимок
+	second := createComment(repo, author.ID, post.ID, first.ID, "Second comment")
+	if err := repo.DeleteComment(ctx, second.ID); err != nil {
+		t.Fatalf("failed to delete comment: %v", err)
+	}
+	if _, err := repo.SoftDeleteComment(ctx, first.ID); err != nil {
+		t.Fatalf("failed to soft delete comment: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "snapshot")); err != nil {
+		t.Fatalf("expected snapshot to be written: %v", err)
+	}
+
+	restored := openRepository(t, dir, 3)
+	restoredPost, err := restored.GetPost(ctx, post.ID)
+	if err != nil {
+		t.Fatalf("failed to get restored post: %v", err)
+	}
+	if restoredPost.CommentCount != 1 {
+		t.Errorf("expected 1 comment, got %d", restoredPost.CommentCount)
+	}
+	if _, err := restored.GetComment(ctx, second.ID); err == nil {
+		t.Errorf("expected deleted comment to stay deleted")
+	}
+	tombstone, err := restored.GetComment(ctx, first.ID)
+	if err != nil || tombstone.DeletedAt == nil {
+		t.Errorf("expected tombstone, got %v (%v)", tombstone, err)
+	}
+
+	// После закрытия всё состояние хранится в снимке, а журнал пуст
+	createPost(restored, author.ID, "Another post", "Content", false)
+	if err := restored.Close(); err != nil {
+		t.Fatalf("failed to close repository: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "wal"))
+	if err != nil || info.Size() != 0 {
+		t.Errorf("expected empty log after close, got %v (%v)", info, err)
+	}
+	reopened := openRepository(t, dir, 3)
+	defer reopened.Close()
+	posts, _ := reopened.GetPosts(ctx)
+	if len(posts) != 2 {
+		t.Errorf("expected 2 posts, got %d", len(posts))
+	}
+}
+
+// Тест порядка комментариев после восстановления из снимка
+func TestPersistence_RestoresCommentOrder(t *testing.T) {
+	dir := t.TempDir()
+	repo := openRepository(t, dir, 0)
+	author := createUser(repo, "author")
+	post := createPost(repo, author.ID, "Post", "Content", false)
+	root := createComment(repo, author.ID, post.ID, "", "Root")
+	expected := map[string][]string{}
+	for i := 0; i < 5; i++ {
+		expected[post.ID] = append(expected[post.ID], createComment(repo, author.ID, post.ID, "", "Top").ID)
+		expected[root.ID] = append(expected[root.ID], createComment(repo, author.ID, post.ID, root.ID, "Reply").ID)
+	}
+	expected[post.ID] = append([]string{root.ID}, expected[post.ID]...)
+	if err := repo.Close(); err != nil {
+		t.Fatalf("failed to close repository: %v", err)
+	}
+
+	restored := openRepository(t, dir, 0)
+	defer restored.Close()
+	topLevel, err := restored.GetCommentsByPostID(ctx, post.ID, 10, nil)
+	if err != nil {
+		t.Fatalf("failed to get comments: %v", err)
+	}
+	replies, err := restored.GetCommentsByParentID(ctx, root.ID, 10, nil)
+	if err != nil {
+		t.Fatalf("failed to get replies: %v", err)
+	}
+	for key, page := range map[string]*repository.CommentPage{post.ID: topLevel, root.ID: replies} {
+		if len(page.Comments) != len(expected[key]) {
+			t.Fatalf("expected %d comments, got %d", len(expected[key]), len(page.Comments))
+		}
+		for i, comment := range page.Comments {
+			if comment.ID != expected[key][i] {
+				t.Errorf("expected comment %s at position %d, got %s", expected[key][i], i, comment.ID)
+			}
+		}
+	}
+}
+
+// Тест восстановления после сбоя во время записи последней операции
+func TestPersistence_TruncatedTail(t *testing.T) {
+	dir := t.TempDir()
+	repo := openRepository(t, dir, 0)
+	author := createUser(repo, "author")
+	post := createPost(repo, author.ID, "Kept post", "Content", false)
+	lost := createPost(repo, author.ID, "Lost post", "Content", false)
+
+	// Обрезание последней записи журнала
+	path := filepath.Join(dir, "wal")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat log: %v", err)
+	}
+	if err := os.Truncate(path, info.Size()-5); err != nil {
+		t.Fatalf("failed to truncate log: %v", err)
+	}
+
+	restored := openRepository(t, dir, 0)
+	if _, err := restored.GetPost(ctx, post.ID); err != nil {
+		t.Errorf("expected kept post, got %v", err)
+	}
+	if _, err := restored.GetPost(ctx, lost.ID); err == nil {
+		t.Errorf("expected truncated post to be discarded")
+	}
+
+	// Новые операции записываются после последней целой записи
+	added := createPost(restored, author.ID, "Added post", "Content", false)
+	reopened := openRepository(t, dir, 0)
+	defer reopened.Close()
+	if _, err := reopened.GetPost(ctx, added.ID); err != nil {
+		t.Errorf("expected post added after recovery, got %v", err)
+	}
+	posts, _ := reopened.GetPosts(ctx)
+	if len(posts) != 2 {
+		t.Errorf("expected 2 posts, got %d", len(posts))
+	}
+}
+
+// Тест повреждения журнала в середине: хранилище не открывается, а подтверждённые операции не отбрасываются
+func TestPersistence_CorruptMiddle(t *testing.T) {
+	dir := t.TempDir()
+	repo := openRepository(t, dir, 0)
+	author := createUser(repo, "author")
+	createPost(repo, author.ID, "First post", "Content", false)
+	createPost(repo, author.ID, "Second post", "Content", false)
+
+	// Порча данных первой записи журнала
+	path := filepath.Join(dir, "wal")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read log: %v", err)
+	}
+	data[10] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("failed to write log: %v", err)
+	}
+
+	if _, err := inmemory.Open(inmemory.Options{Dir: dir}); err == nil {
+		t.Fatalf("expected error for corrupt record in the middle of the log")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat log: %v", err)
+	}
+	if info.Size() != int64(len(data)) {
+		t.Errorf("expected log to be kept, size %d, got %d", len(data), info.Size())
+	}
+}
+
+// Тест проверки доступности: закрытое хранилище недоступно
+func TestPersistence_PingAfterClose(t *testing.T) {
+	repo := openRepository(t, t.TempDir(), 0)
+	if err := repo.Ping(ctx); err != nil {
+		t.Fatalf("expected open repository to be available, got %v", err)
+	}
+	if err := repo.Close(); err != nil {
+		t.Fatalf("failed to close repository: %v", err)
+	}
+	if err := repo.Ping(ctx); !errors.Is(err, inmemory.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+// Тест изменения после закрытия: операция не подтверждается, потому что её уже нельзя сохранить
+func TestPersistence_WriteAfterClose(t *testing.T) {
+	dir := t.TempDir()
+	repo := openRepository(t, dir, 0)
+	author := createUser(repo, "author")
+	if err := repo.Close(); err != nil {
+		t.Fatalf("failed to close repository: %v", err)
+	}
+	if _, err := repo.CreatePost(ctx, author.ID, "Late post", "Content", false); !errors.Is(err, inmemory.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+
+	reopened := openRepository(t, dir, 0)
+	defer reopened.Close()
+	posts, _ := reopened.GetPosts(ctx)
+	if len(posts) != 0 {
+		t.Errorf("expected no posts, got %d", len(posts))
+	}
+}
+
+// Общий набор тестов контракта для хранилища, сохраняющего изменения в журнал и снимки
+func TestConformance_PersistentInMemory(t *testing.T) {
+	conformance.Run(t, func(t *testing.T) repository.Repository {
+		repo := openRepository(t, t.TempDir(), 10)
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nemopss/go-posts-comments-system/internal/auth"
+	"github.com/nemopss/go-posts-comments-system/internal/gql"
+	"github.com/nemopss/go-posts-comments-system/internal/metrics"
+	"github.com/nemopss/go-posts-comments-system/internal/repository/inmemory"
+	"github.com/nemopss/go-posts-comments-system/internal/repository/sqlite"
+	"github.com/nemopss/go-posts-comments-system/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scrape возвращает метрики в текстовом формате Prometheus
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	return rec.Body.String()
+}
+
+// query выполняет GraphQL запрос по HTTP
+func query(t *testing.T, ts *httptest.Server, body map[string]interface{}) {
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+	resp, err := http.Post(ts.URL, "application/json", bytes.NewReader(data))
+	require.NoError(t, err)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+// Тест метрик GraphQL операций, резолверов полей и методов хранилища
+func TestGraphQLAndRepositoryMetrics(t *testing.T) {
+	ctx := context.Background()
+	m := metrics.New()
+	repo := inmemory.NewInMemoryRepository()
+	m.RegisterInMemory(repo)
+	author, err := repo.CreateUser(ctx, "author", "password-hash")
+	require.NoError(t, err)
+	post, err := repo.CreatePost(ctx, author.ID, "Post", "Content", false)
+	require.NoError(t, err)
+	_, err = repo.CreateComment(ctx, author.ID, post.ID, "", "Comment")
+	require.NoError(t, err)
+
+	tokens, err := auth.NewTokens(auth.Config{Algorithm: auth.AlgorithmHS256, Secret: []byte("test-secret"), TTL: time.Hour})
+	require.NoError(t, err)
+	srv := server.NewServer(metrics.Instrument(repo, m), tokens, gql.Options{Instrumentation: m})
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+
+	query(t, ts, map[string]interface{}{
+		"query": `query Feed { posts(first: 10) { edges { node { id comments(first: 5) { totalCount } } } } }`,
+	})
+	query(t, ts, map[string]interface{}{"query": `query Missing($id: ID!) { post(id: $id) { id } }`, "variables": map[string]interface{}{"id": "missing"}})
+	query(t, ts, map[string]interface{}{"query": `{ posts(`})
+	// Имя несуществующей операции из запроса клиента не попадает в метку
+	query(t, ts, map[string]interface{}{"query": `query Feed { posts(first: 1) { totalCount } }`, "operationName": "Injected"})
+
+	out := scrape(t, m)
+	// Операции
+	assert.Contains(t, out, `graphql_operation_duration_seconds_count{name="Feed",status="ok",type="query"} 1`)
+	assert.Contains(t, out, `graphql_operation_duration_seconds_count{name="Missing",status="error",type="query"} 1`)
+	assert.Contains(t, out, `graphql_operation_duration_seconds_count{name="",status="error",type="unknown"} 2`)
+	assert.NotContains(t, out, "Injected")
+	// Резолверы, включая отложенную загрузку комментариев
+	assert.Contains(t, out, `graphql_resolver_duration_seconds_count{field="posts",status="ok",type="Query"} 1`)
+	assert.Contains(t, out, `graphql_resolver_duration_seconds_count{field="comments",status="ok",type="Post"} 1`)
+	assert.Contains(t, out, `graphql_resolver_duration_seconds_count{field="post",status="not_found",type="Query"} 1`)
+	// Методы хранилища
+	assert.Contains(t, out, `repository_calls_total{method="ListPosts",status="ok"} 1`)
+	assert.Contains(t, out, `repository_calls_total{method="GetCommentsByPostIDs",status="ok"} 1`)
+	assert.Contains(t, out, `repository_calls_total{method="GetPost",status="not_found"} 1`)
+	assert.Contains(t, out, `repository_call_duration_seconds_count{method="ListPosts"} 1`)
+	// Размеры хранилища в памяти
+	assert.Contains(t, out, "inmemory_posts 1\n")
+	assert.Contains(t, out, "inmemory_comments 1\n")
+}
+
+// Тест метрик пула соединений с базой данных
+func TestDBStats(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "metrics.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	m := metrics.New()
+	m.RegisterDB(db, "sqlite")
+	out := scrape(t, m)
+	assert.Contains(t, out, `go_sql_open_connections{db_name="sqlite"} 1`)
+	assert.Contains(t, out, `go_sql_max_open_connections{db_name="sqlite"}`)
+	assert.Contains(t, out, `go_sql_wait_count_total{db_name="sqlite"}`)
+}
+
+// Тест ограничения количества различных имён операций
+func TestOperationNameLimit(t *testing.T) {
+	m := metrics.New()
+	for i := 0; i < metrics.MaxOperationNames+10; i++ {
+		m.OperationDone("query", fmt.Sprintf("Operation%d", i), time.Millisecond, false)
+	}
+	m.OperationDone("query", "Operation0", time.Millisecond, false)
+
+	out := scrape(t, m)
+	assert.Contains(t, out, `graphql_operation_duration_seconds_count{name="Operation0",status="ok",type="query"} 2`)
+	assert.Contains(t, out, fmt.Sprintf(`graphql_operation_duration_seconds_count{name="Operation%d",status="ok",type="query"} 1`, metrics.MaxOperationNames-1))
+	assert.NotContains(t, out, fmt.Sprintf(`name="Operation%d"`, metrics.MaxOperationNames))
+	assert.Contains(t, out, `graphql_operation_duration_seconds_count{name="other",status="ok",type="query"} 10`)
+}
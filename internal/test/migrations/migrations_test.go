@@ -0,0 +1,265 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/nemopss/go-posts-comments-system/internal/migrations"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+	"github.com/nemopss/go-posts-comments-system/internal/repository/postgres"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_tags.up.sql":   {Data: []byte("CREATE TABLE tags ();")},
+		"0002_add_tags.down.sql": {Data: []byte("DROP TABLE tags;")},
+		"0001_init.up.sql":       {Data: []byte("CREATE TABLE posts ();")},
+		"0001_init.down.sql":     {Data: []byte("DROP TABLE posts;")},
+		"README.md":              {Data: []byte("not a migration")},
+	}
+	loaded, err := migrations.Load(fsys)
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+	assert.Equal(t, migrations.Migration{Version: 1, Name: "init", Up: "CREATE TABLE posts ();", Down: "DROP TABLE posts;"}, *loaded[0])
+	assert.Equal(t, 2, loaded[1].Version)
+	assert.Equal(t, "add_tags", loaded[1].Name)
+
+	// У каждой миграции должны быть оба файла, а версии не должны повторяться
+	delete(fsys, "0002_add_tags.down.sql")
+	_, err = migrations.Load(fsys)
+	assert.Error(t, err)
+	fsys["0002_add_tags.down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE tags;")}
+	fsys["0002_other.up.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+	_, err = migrations.Load(fsys)
+	assert.Error(t, err)
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	// Встроенные миграции каждой СУБД начинаются с начальной схемы
+	for _, dialect := range []migrations.Dialect{migrations.Postgres, migrations.SQLite} {
+		loaded, err := migrations.Load(migrations.Files(dialect))
+		require.NoError(t, err, dialect)
+		require.NotEmpty(t, loaded, dialect)
+		assert.Equal(t, 1, loaded[0].Version, dialect)
+		assert.Contains(t, loaded[0].Up, "CREATE TABLE posts", dialect)
+	}
+}
+
+// testDSNEnv - переменная окружения со строкой подключения к тестовой базе данных
+const testDSNEnv = "TEST_POSTGRES_DSN"
+
+// testSchema - отдельная схема, чтобы откат миграций не мешал тестам хранилища PostgreSQL
+const testSchema = "migrations_test"
+
+// openTestDB подключается к пустой схеме testSchema тестовой базы данных.
+// Если строка подключения не задана, тест пропускается.
+func openTestDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set, skipping PostgreSQL tests", testDSNEnv)
+	}
+	admin, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	defer admin.Close()
+	_, err = admin.Exec("DROP SCHEMA IF EXISTS " + testSchema + " CASCADE")
+	require.NoError(t, err)
+	_, err = admin.Exec("CREATE SCHEMA " + testSchema)
+	require.NoError(t, err)
+
+	// Все соединения пула используют только схему testSchema
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		query := u.Query()
+		query.Set("search_path", testSchema)
+		u.RawQuery = query.Encode()
+		dsn = u.String()
+	} else {
+		dsn += " search_path=" + testSchema
+	}
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		db.Close()
+		if admin, err := sql.Open("postgres", os.Getenv(testDSNEnv)); err == nil {
+			admin.Exec("DROP SCHEMA IF EXISTS " + testSchema + " CASCADE")
+			admin.Close()
+		}
+	})
+	return db
+}
+
+// tableExists проверяет, что в схеме testSchema есть таблица name
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	var exists bool
+	require.NoError(t, db.QueryRow("SELECT to_regclass($1) IS NOT NULL", testSchema+"."+name).Scan(&exists))
+	return exists
+}
+
+// appliedVersions возвращает применённые версии схемы
+func appliedVersions(t *testing.T, migrator *migrations.Migrator) []int {
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	var versions []int
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			versions = append(versions, status.Version)
+		}
+	}
+	return versions
+}
+
+// Тест применения и отката всех миграций PostgreSQL
+func TestPostgresUpDown(t *testing.T) {
+	db := openTestDB(t)
+	migrator, err := migrations.NewMigrator(db, migrations.Postgres)
+	require.NoError(t, err)
+
+	require.NoError(t, migrator.Up())
+	latest := migrator.Latest()
+	assert.Len(t, appliedVersions(t, migrator), latest)
+	assert.True(t, tableExists(t, db, "posts"))
+	assert.True(t, tableExists(t, db, "comment_closure"))
+	assert.False(t, tableExists(t, db, "pairs"))
+
+	// Откат последней версии возвращает прежнюю таблицу иерархии
+	require.NoError(t, migrator.Down())
+	assert.Len(t, appliedVersions(t, migrator), latest-1)
+	assert.False(t, tableExists(t, db, "comment_closure"))
+	assert.True(t, tableExists(t, db, "pairs"))
+
+	require.NoError(t, migrator.To(0))
+	assert.Empty(t, appliedVersions(t, migrator))
+	assert.False(t, tableExists(t, db, "posts"))
+
+	// Схему можно снова создать после полного отката
+	require.NoError(t, migrator.Up())
+	assert.Len(t, appliedVersions(t, migrator), latest)
+	assert.Error(t, migrator.To(99))
+}
+
+// legacySchema - схема, которую до появления миграций создавал скрипт init.sql
+const legacySchema = `
+-- Создание таблицы posts
+CREATE TABLE posts (
+    id VARCHAR(100) PRIMARY KEY,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    comments_disabled BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Создание таблицы comments
+CREATE TABLE comments (
+    id VARCHAR(100) PRIMARY KEY,
+    post_id VARCHAR(100) REFERENCES posts(id) ON DELETE CASCADE,
+    parent_id VARCHAR(100) REFERENCES comments(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Создание таблицы pairs для иерархии комментариев
+CREATE TABLE pairs (
+    parent_id VARCHAR(100) REFERENCES comments(id) ON DELETE CASCADE,
+    child_id VARCHAR(100) REFERENCES comments(id) ON DELETE CASCADE,
+    PRIMARY KEY (parent_id, child_id)
+);
+`
+
+// legacyData - пост с комментарием и ответом на него, созданные до появления миграций
+const legacyData = `
+INSERT INTO posts (id, title, content, comments_disabled, created_at) VALUES ('post', 'Кошки', 'Про кошек', FALSE, '2024-01-01 10:00:00+00');
+INSERT INTO comments (id, post_id, parent_id, content, created_at) VALUES ('comment', 'post', NULL, 'Первый', '2024-01-01 11:00:00+00');
+INSERT INTO comments (id, post_id, parent_id, content, created_at) VALUES ('reply', 'post', 'comment', 'Ответ', '2024-01-01 12:00:00+00');
+INSERT INTO pairs (parent_id, child_id) VALUES ('comment', 'reply');
+`
+
+// Тест базы данных, созданной скриптом init.sql до появления миграций
+func TestPostgresExistingSchema(t *testing.T) {
+	db := openTestDB(t)
+	_, err := db.Exec(legacySchema)
+	require.NoError(t, err)
+	_, err = db.Exec(legacyData)
+	require.NoError(t, err)
+
+	migrator, err := migrations.NewMigrator(db, migrations.Postgres)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up())
+	versions := appliedVersions(t, migrator)
+	assert.Equal(t, migrator.Latest(), len(versions))
+	assert.True(t, tableExists(t, db, "comment_closure"))
+
+	// Существующие данные переносятся в новую схему
+	ctx := context.Background()
+	repo := postgres.NewPostgresRepository(db)
+	post, err := repo.GetPost(ctx, "post")
+	require.NoError(t, err)
+	assert.Equal(t, 2, post.CommentCount)
+	assert.True(t, post.LastActivityAt.Equal(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)))
+	author, err := repo.GetUser(ctx, post.AuthorID)
+	require.NoError(t, err)
+	assert.Equal(t, "(legacy)", author.Username)
+	revisions, err := repo.GetPostRevisions(ctx, "post")
+	require.NoError(t, err)
+	assert.Len(t, revisions, 1)
+	ancestors, err := repo.GetCommentAncestors(ctx, "reply")
+	require.NoError(t, err)
+	if assert.Len(t, ancestors, 1) {
+		assert.Equal(t, "comment", ancestors[0].ID)
+	}
+
+	// После миграций хранилище работает со схемой как с новой
+	user, err := repo.CreateUser(ctx, "alice", "password-hash")
+	require.NoError(t, err)
+	created, err := repo.CreatePost(ctx, user.ID, "Собаки", "Про собак", false)
+	require.NoError(t, err)
+	comment, err := repo.CreateComment(ctx, user.ID, created.ID, "", "Гав")
+	require.NoError(t, err)
+	_, err = repo.CreateComment(ctx, user.ID, "post", "reply", "Ответ на ответ")
+	require.NoError(t, err)
+	created, err = repo.GetPost(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, created.CommentCount)
+	assert.Equal(t, user.ID, comment.AuthorID)
+	page, err := repo.Search(ctx, repository.SearchQuery{Query: "собак", First: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, page.TotalCount)
+
+	// Полный откат возвращает пустую схему
+	require.NoError(t, migrator.To(0))
+	assert.False(t, tableExists(t, db, "posts"))
+	assert.False(t, tableExists(t, db, "users"))
+
+	// Отметить версию вручную можно только в базе данных без применённых миграций
+	require.NoError(t, migrator.Up())
+	assert.Error(t, migrator.Baseline(1))
+	require.NoError(t, migrator.To(0))
+	_, err = db.Exec(legacySchema)
+	require.NoError(t, err)
+	_, err = db.Exec("DROP TABLE schema_migrations")
+	require.NoError(t, err)
+	require.NoError(t, migrator.Baseline(1))
+	assert.Equal(t, []int{1}, appliedVersions(t, migrator))
+	assert.Error(t, migrator.Baseline(99))
+}
+
+// Тест базы данных, схема которой новее init.sql, но создана без миграций
+func TestPostgresUnknownSchema(t *testing.T) {
+	db := openTestDB(t)
+	_, err := db.Exec(legacySchema + "ALTER TABLE posts ADD COLUMN comment_count INTEGER NOT NULL DEFAULT 0;")
+	require.NoError(t, err)
+
+	// Такую схему нельзя отметить как версию 1 автоматически, её версию задают вручную
+	migrator, err := migrations.NewMigrator(db, migrations.Postgres)
+	require.NoError(t, err)
+	assert.Error(t, migrator.Up())
+	require.NoError(t, migrator.Baseline(2))
+	require.NoError(t, migrator.Up())
+	assert.True(t, tableExists(t, db, "users"))
+}
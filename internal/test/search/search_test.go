@@ -0,0 +1,48 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/nemopss/go-posts-comments-system/internal/search"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyze(t *testing.T) {
+	// Русские и английские слова приводятся к основам, стоп-слова отбрасываются
+	assert.Equal(t, []string{"кошк", "спят", "cat", "sleep"}, search.Analyze("Кошки и спят, the cats are sleeping"))
+	assert.Equal(t, search.Analyze("кошка"), search.Analyze("КОШКУ"))
+	assert.Empty(t, search.Analyze("и в на"))
+}
+
+func TestIndex(t *testing.T) {
+	ix := search.NewIndex()
+	ix.Add("1", search.Field{Text: "Кошка", Weight: search.WeightTitle})
+	ix.Add("2", search.Field{Text: "Кошка и кошка", Weight: search.WeightContent})
+	ix.Add("3", search.Field{Text: "Кошка", Weight: search.WeightContent})
+	ix.Add("4", search.Field{Text: "Собака", Weight: search.WeightTitle})
+
+	ids := func(matches []search.Match) []string {
+		result := []string{}
+		for _, match := range matches {
+			result = append(result, match.ID)
+		}
+		return result
+	}
+	assert.Equal(t, []string{"1", "2", "3"}, ids(ix.Search("кошки")))
+	assert.Empty(t, ix.Search("кошки собаки"))
+
+	// Повторное добавление заменяет документ, удалённый документ не находится
+	ix.Add("4", search.Field{Text: "Кошка и собака", Weight: search.WeightTitle})
+	ix.Remove("1")
+	assert.Equal(t, []string{"4"}, ids(ix.Search("кошки собаки")))
+	assert.Equal(t, []string{"4", "2", "3"}, ids(ix.Search("кошки")))
+}
+
+func TestSnippet(t *testing.T) {
+	assert.Equal(t, "Моя <b>кошка</b> спит.", search.Snippet("Моя кошка спит.", "кошки"))
+	// Текст экранируется, поэтому разметка пользователя не попадает во фрагмент
+	assert.Equal(t, "Моя &lt;script&gt;<b>кошка</b>&lt;/script&gt; &amp; пёс", search.Snippet("Моя <script>кошка</script> & пёс", "кошки"))
+	assert.Equal(t, "&lt;i&gt;<b>кошка</b>", search.EscapeHighlighted("<i>"+search.RawHighlightStart+"кошка"+search.RawHighlightStop))
+	assert.True(t, search.Matches("Моя кошка спит", "спит кошки"))
+	assert.False(t, search.Matches("Моя кошка", "кошки собаки"))
+}
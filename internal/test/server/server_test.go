@@ -0,0 +1,927 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nemopss/go-posts-comments-system/internal/auth"
+	"github.com/nemopss/go-posts-comments-system/internal/gql"
+	"github.com/nemopss/go-posts-comments-system/internal/models"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+	"github.com/nemopss/go-posts-comments-system/internal/repository/inmemory"
+	"github.com/nemopss/go-posts-comments-system/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wsMessage представляет сообщение протокола graphql-transport-ws
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// testTokensConfig задаёт конфигурацию токенов тестового сервера
+var testTokensConfig = auth.Config{Algorithm: auth.AlgorithmHS256, Secret: []byte("test-secret"), TTL: time.Hour}
+
+// newTestServer поднимает тестовый HTTP сервер с in-memory хранилищем
+func newTestServer(t *testing.T) *httptest.Server {
+	return newTestServerWith(t, testTokensConfig, gql.Options{})
+}
+
+// newTestServerWith поднимает тестовый HTTP сервер с заданной конфигурацией токенов и настройками API
+func newTestServerWith(t *testing.T, cfg auth.Config, options gql.Options) *httptest.Server {
+	tokens, err := auth.NewTokens(cfg)
+	require.NoError(t, err)
+	srv := server.NewServer(inmemory.NewInMemoryRepository(), tokens, options)
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// graphQLResponse представляет ответ GraphQL сервера
+type graphQLResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// postGraphQL выполняет GraphQL запрос по HTTP от имени пользователя с токеном token
+// (или анонимно, если токен пустой) и возвращает ответ
+func postGraphQL(t *testing.T, ts *httptest.Server, token, query string) graphQLResponse {
+	body, _ := json.Marshal(map[string]interface{}{"query": query})
+	req, err := http.NewRequest(http.MethodPost, ts.URL, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result graphQLResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	return result
+}
+
+// doGraphQL выполняет GraphQL запрос, проверяет отсутствие ошибок и возвращает поле data ответа
+func doGraphQL(t *testing.T, ts *httptest.Server, token, query string) map[string]interface{} {
+	result := postGraphQL(t, ts, token, query)
+	require.Empty(t, result.Errors)
+	return result.Data
+}
+
+// register регистрирует пользователя и возвращает его токен доступа
+func register(t *testing.T, ts *httptest.Server, username string) string {
+	data := doGraphQL(t, ts, "", `mutation { register(username: "`+username+`", password: "secret-password") { token } }`)
+	return data["register"].(map[string]interface{})["token"].(string)
+}
+
+// adminToken выпускает токен доступа администратора для тестового сервера
+func adminToken(t *testing.T) string {
+	tokens, err := auth.NewTokens(testTokensConfig)
+	require.NoError(t, err)
+	token, err := tokens.Issue(&models.User{ID: "admin", Role: models.RoleAdmin})
+	require.NoError(t, err)
+	return token
+}
+
+// dialWebSocket устанавливает и инициализирует WebSocket соединение
+func dialWebSocket(t *testing.T, ts *httptest.Server) *websocket.Conn {
+	conn := dialWebSocketRaw(t, ts)
+	require.NoError(t, conn.WriteJSON(wsMessage{Type: "connection_init"}))
+	ack := readMessage(t, conn)
+	require.Equal(t, "connection_ack", ack.Type)
+	return conn
+}
+
+// dialWebSocketRaw устанавливает WebSocket соединение без инициализации
+func dialWebSocketRaw(t *testing.T, ts *httptest.Server) *websocket.Conn {
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readMessage читает очередное сообщение из WebSocket соединения
+func readMessage(t *testing.T, conn *websocket.Conn) wsMessage {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg wsMessage
+	require.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+func TestCommentSubscriptions(t *testing.T) {
+	tokens, err := auth.NewTokens(testTokensConfig)
+	require.NoError(t, err)
+	srv := server.NewServer(inmemory.NewInMemoryRepository(), tokens, gql.Options{})
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	conn := dialWebSocket(t, ts)
+	token := register(t, ts, "alice")
+
+	post := doGraphQL(t, ts, token, `mutation { createPost(title: "Title", content: "Content", commentsDisabled: false) { id } }`)
+	postId := post["createPost"].(map[string]interface{})["id"].(string)
+
+	// Подписка на добавление и удаление комментариев
+	for id, field := range map[string]string{"added": "commentAdded", "deleted": "commentDeleted"} {
+		payload, _ := json.Marshal(map[string]interface{}{
+			"query":     `subscription($postId: ID!) { ` + field + `(postId: $postId) { id content } }`,
+			"variables": map[string]interface{}{"postId": postId},
+		})
+		require.NoError(t, conn.WriteJSON(wsMessage{ID: id, Type: "subscribe", Payload: payload}))
+	}
+	// Подписки регистрируются асинхронно, поэтому ждём появления обеих подписок в шине событий
+	require.Eventually(t, func() bool { return srv.Subscribers() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	comment := doGraphQL(t, ts, token, `mutation { createComment(postId: "`+postId+`", parentId: "", content: "Hello") { id } }`)
+	commentId := comment["createComment"].(map[string]interface{})["id"].(string)
+
+	msg := readMessage(t, conn)
+	assert.Equal(t, "next", msg.Type)
+	assert.Equal(t, "added", msg.ID)
+	assert.Contains(t, string(msg.Payload), commentId)
+	assert.Contains(t, string(msg.Payload), "Hello")
+
+	doGraphQL(t, ts, token, `mutation { deleteComment(id: "`+commentId+`") }`)
+
+	msg = readMessage(t, conn)
+	assert.Equal(t, "next", msg.Type)
+	assert.Equal(t, "deleted", msg.ID)
+	assert.Contains(t, string(msg.Payload), commentId)
+
+	// Отписка от событий
+	require.NoError(t, conn.WriteJSON(wsMessage{ID: "added", Type: "complete"}))
+	require.NoError(t, conn.WriteJSON(wsMessage{Type: "ping"}))
+	assert.Equal(t, "pong", readMessage(t, conn).Type)
+	assert.Eventually(t, func() bool { return srv.Subscribers() == 1 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestPostsQuery(t *testing.T) {
+	ts := newTestServer(t)
+	token := register(t, ts, "alice")
+
+	doGraphQL(t, ts, token, `mutation { createPost(title: "Go news", content: "Content", commentsDisabled: false) { id } }`)
+	doGraphQL(t, ts, token, `mutation { createPost(title: "Rust news", content: "Content", commentsDisabled: true) { id } }`)
+	busy := doGraphQL(t, ts, token, `mutation { createPost(title: "Go tips", content: "Content", commentsDisabled: false) { id } }`)
+	busyId := busy["createPost"].(map[string]interface{})["id"].(string)
+	doGraphQL(t, ts, token, `mutation { createComment(postId: "`+busyId+`", parentId: "", content: "First") { id } }`)
+
+	data := doGraphQL(t, ts, token, `{
+		posts(first: 1, orderBy: COMMENT_COUNT, filter: {titleContains: "go", commentsDisabled: false}) {
+			totalCount
+			pageInfo { hasNextPage endCursor }
+			edges { node { id commentCount } }
+		}
+	}`)
+	posts := data["posts"].(map[string]interface{})
+	assert.EqualValues(t, 2, posts["totalCount"])
+	edges := posts["edges"].([]interface{})
+	require.Len(t, edges, 1)
+	node := edges[0].(map[string]interface{})["node"].(map[string]interface{})
+	assert.Equal(t, busyId, node["id"])
+	assert.EqualValues(t, 1, node["commentCount"])
+
+	pageInfo := posts["pageInfo"].(map[string]interface{})
+	assert.Equal(t, true, pageInfo["hasNextPage"])
+	data = doGraphQL(t, ts, token, `{
+		posts(first: 1, after: "`+pageInfo["endCursor"].(string)+`", orderBy: COMMENT_COUNT, filter: {titleContains: "go"}) {
+			pageInfo { hasNextPage }
+			edges { node { title } }
+		}
+	}`)
+	posts = data["posts"].(map[string]interface{})
+	edges = posts["edges"].([]interface{})
+	require.Len(t, edges, 1)
+	assert.Equal(t, "Go news", edges[0].(map[string]interface{})["node"].(map[string]interface{})["title"])
+	assert.Equal(t, false, posts["pageInfo"].(map[string]interface{})["hasNextPage"])
+}
+
+func TestUserAccounts(t *testing.T) {
+	ts := newTestServer(t)
+	token := register(t, ts, "alice")
+
+	// Пользователь видит себя в поле me, анонимный запрос получает null
+	me := doGraphQL(t, ts, token, `{ me { username } }`)
+	assert.Equal(t, "alice", me["me"].(map[string]interface{})["username"])
+	anonymous := doGraphQL(t, ts, "", `{ me { username } }`)
+	assert.Nil(t, anonymous["me"])
+
+	// Создание поста требует входа в систему
+	result := postGraphQL(t, ts, "", `mutation { createPost(title: "Title", content: "Content", commentsDisabled: false) { id } }`)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, "authentication required", result.Errors[0].Message)
+	assert.Equal(t, gql.CodeUnauthenticated, result.Errors[0].Extensions["code"])
+
+	// Автор поста и комментария - пользователь, выполнивший мутацию
+	post := doGraphQL(t, ts, token, `mutation { createPost(title: "Title", content: "Content", commentsDisabled: false) { id author { username } } }`)
+	created := post["createPost"].(map[string]interface{})
+	assert.Equal(t, "alice", created["author"].(map[string]interface{})["username"])
+
+	// Вход с верным паролем выдаёт рабочий токен, с неверным - ошибку
+	login := doGraphQL(t, ts, "", `mutation { login(username: "alice", password: "secret-password") { token user { username } } }`)
+	loginToken := login["login"].(map[string]interface{})["token"].(string)
+	me = doGraphQL(t, ts, loginToken, `{ me { username } }`)
+	assert.Equal(t, "alice", me["me"].(map[string]interface{})["username"])
+
+	result = postGraphQL(t, ts, "", `mutation { login(username: "alice", password: "wrong-password") { token } }`)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, "invalid username or password", result.Errors[0].Message)
+	assert.Equal(t, gql.CodeUnauthenticated, result.Errors[0].Extensions["code"])
+
+	// Вход несуществующего пользователя не отличается от входа с неверным паролем
+	result = postGraphQL(t, ts, "", `mutation { login(username: "nobody", password: "secret-password") { token } }`)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, "invalid username or password", result.Errors[0].Message)
+	assert.Equal(t, gql.CodeUnauthenticated, result.Errors[0].Extensions["code"])
+
+	// Повторная регистрация того же имени невозможна
+	result = postGraphQL(t, ts, "", `mutation { register(username: "alice", password: "secret-password") { token } }`)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, gql.CodeConflict, result.Errors[0].Extensions["code"])
+}
+
+func TestInvalidToken(t *testing.T) {
+	ts := newTestServer(t)
+	req, err := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"query": "{ me { id } }"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer not-a-token")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestWebSocketQueryOperation(t *testing.T) {
+	ts := newTestServer(t)
+	conn := dialWebSocket(t, ts)
+
+	payload, _ := json.Marshal(map[string]interface{}{"query": `{ posts(first: 10) { totalCount } }`})
+	require.NoError(t, conn.WriteJSON(wsMessage{ID: "1", Type: "subscribe", Payload: payload}))
+
+	msg := readMessage(t, conn)
+	assert.Equal(t, "next", msg.Type)
+	assert.JSONEq(t, `{"data":{"posts":{"totalCount":0}}}`, string(msg.Payload))
+	assert.Equal(t, "complete", readMessage(t, conn).Type)
+}
+
+func TestWebSocketSubscribeBeforeInit(t *testing.T) {
+	ts := newTestServer(t)
+	conn := dialWebSocketRaw(t, ts)
+
+	payload, _ := json.Marshal(map[string]interface{}{"query": `{ posts(first: 10) { totalCount } }`})
+	require.NoError(t, conn.WriteJSON(wsMessage{ID: "1", Type: "subscribe", Payload: payload}))
+
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, 4401))
+}
+
+func TestDeleteAuthorization(t *testing.T) {
+	ts := newTestServer(t)
+	alice := register(t, ts, "alice")
+	bob := register(t, ts, "bob")
+
+	post := doGraphQL(t, ts, alice, `mutation { createPost(title: "Title", content: "Content", commentsDisabled: false) { id } }`)
+	postId := post["createPost"].(map[string]interface{})["id"].(string)
+	comment := doGraphQL(t, ts, alice, `mutation { createComment(postId: "`+postId+`", parentId: "", content: "Hello") { id } }`)
+	commentId := comment["createComment"].(map[string]interface{})["id"].(string)
+
+	// Анонимный пользователь и не автор не могут удалять чужие посты и комментарии
+	result := postGraphQL(t, ts, "", `mutation { deleteComment(id: "`+commentId+`") }`)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, "authentication required", result.Errors[0].Message)
+	for _, mutation := range []string{`deleteComment(id: "` + commentId + `")`, `deletePost(id: "` + postId + `")`} {
+		result = postGraphQL(t, ts, bob, `mutation { `+mutation+` }`)
+		require.NotEmpty(t, result.Errors)
+		assert.Equal(t, gql.CodeForbidden, result.Errors[0].Extensions["code"])
+	}
+
+	// Администратор может удалить чужой комментарий
+	doGraphQL(t, ts, adminToken(t), `mutation { deleteComment(id: "`+commentId+`") }`)
+
+	// Автор может удалить свой пост
+	doGraphQL(t, ts, alice, `mutation { deletePost(id: "`+postId+`") }`)
+	posts := doGraphQL(t, ts, "", `{ posts(first: 10) { totalCount } }`)
+	assert.Equal(t, float64(0), posts["posts"].(map[string]interface{})["totalCount"])
+}
+
+// Тест администратора, создаваемого при запуске: он входит по паролю из настроек и может удалять чужие посты
+func TestBootstrapAdmin(t *testing.T) {
+	ctx := context.Background()
+	repo := inmemory.NewInMemoryRepository()
+	require.NoError(t, gql.EnsureAdmin(ctx, repo, "root", "root-password"))
+	// Повторный запуск с тем же паролем не создаёт пользователя заново
+	require.NoError(t, gql.EnsureAdmin(ctx, repo, "root", "root-password"))
+	tokens, err := auth.NewTokens(testTokensConfig)
+	require.NoError(t, err)
+	ts := httptest.NewServer(server.NewServer(repo, tokens, gql.Options{AdminUsername: "root"}).Handler())
+	t.Cleanup(ts.Close)
+
+	alice := register(t, ts, "alice")
+	post := doGraphQL(t, ts, alice, `mutation { createPost(title: "Title", content: "Content", commentsDisabled: false) { id } }`)
+	postId := post["createPost"].(map[string]interface{})["id"].(string)
+
+	data := doGraphQL(t, ts, "", `mutation { login(username: "root", password: "root-password") { token user { role } } }`)
+	login := data["login"].(map[string]interface{})
+	assert.Equal(t, "ADMIN", login["user"].(map[string]interface{})["role"])
+	root := login["token"].(string)
+	me := doGraphQL(t, ts, root, `{ me { role } }`)
+	assert.Equal(t, "ADMIN", me["me"].(map[string]interface{})["role"])
+	doGraphQL(t, ts, root, `mutation { deletePost(id: "`+postId+`") }`)
+
+	// Имя, занятое пользователем с другим паролем, не становится администратором
+	assert.Error(t, gql.EnsureAdmin(ctx, repo, "alice", "root-password"))
+}
+
+func TestEdDSATokens(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ts := newTestServerWith(t, auth.Config{Algorithm: auth.AlgorithmEdDSA, PrivateKey: privateKey, Issuer: "test", TTL: time.Hour}, gql.Options{})
+
+	token := register(t, ts, "alice")
+	me := doGraphQL(t, ts, token, `{ me { username role } }`)
+	assert.Equal(t, map[string]interface{}{"username": "alice", "role": "USER"}, me["me"])
+
+	// Токен, подписанный другим ключом, не принимается
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	other, err := auth.NewTokens(auth.Config{Algorithm: auth.AlgorithmEdDSA, PrivateKey: otherKey, Issuer: "test", TTL: time.Hour})
+	require.NoError(t, err)
+	forged, err := other.Issue(&models.User{ID: "alice", Role: models.RoleAdmin})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"query": "{ me { id } }"}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+forged)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Сервер с одним открытым ключом проверяет токены, но не выпускает их
+	verifier, err := auth.NewTokens(auth.Config{Algorithm: auth.AlgorithmEdDSA, PublicKey: publicKey, Issuer: "test"})
+	require.NoError(t, err)
+	viewer, err := verifier.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleUser, viewer.Role)
+	_, err = verifier.Issue(&models.User{ID: "alice"})
+	assert.ErrorIs(t, err, auth.ErrSigningDisabled)
+}
+
+func TestWebSocketAuthentication(t *testing.T) {
+	ts := newTestServer(t)
+	token := register(t, ts, "alice")
+
+	// Токен из connection_init определяет пользователя для всех операций соединения
+	conn := dialWebSocketRaw(t, ts)
+	init, _ := json.Marshal(map[string]string{"Authorization": "Bearer " + token})
+	require.NoError(t, conn.WriteJSON(wsMessage{Type: "connection_init", Payload: init}))
+	require.Equal(t, "connection_ack", readMessage(t, conn).Type)
+	payload, _ := json.Marshal(map[string]interface{}{"query": `{ me { username } }`})
+	require.NoError(t, conn.WriteJSON(wsMessage{ID: "1", Type: "subscribe", Payload: payload}))
+	msg := readMessage(t, conn)
+	assert.Equal(t, "next", msg.Type)
+	assert.JSONEq(t, `{"data":{"me":{"username":"alice"}}}`, string(msg.Payload))
+
+	// Недействительный токен закрывает соединение
+	conn = dialWebSocketRaw(t, ts)
+	init, _ = json.Marshal(map[string]string{"Authorization": "Bearer not-a-token"})
+	require.NoError(t, conn.WriteJSON(wsMessage{Type: "connection_init", Payload: init}))
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, 4403))
+
+	// Полезная нагрузка, которая не является объектом, закрывает соединение, а не делает его анонимным
+	conn = dialWebSocketRaw(t, ts)
+	require.NoError(t, conn.WriteJSON(wsMessage{Type: "connection_init", Payload: json.RawMessage(`"Bearer ` + token + `"`)}))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, 4400))
+}
+
+func TestPostRevisions(t *testing.T) {
+	ts := newTestServer(t)
+	alice := register(t, ts, "alice")
+	bob := register(t, ts, "bob")
+
+	post := doGraphQL(t, ts, alice, `mutation { createPost(title: "Title", content: "one\ntwo", commentsDisabled: false) { id editedAt } }`)
+	created := post["createPost"].(map[string]interface{})
+	postId := created["id"].(string)
+	assert.Nil(t, created["editedAt"])
+
+	// Изменять пост может только автор
+	result := postGraphQL(t, ts, bob, `mutation { updatePost(id: "`+postId+`", title: "Hacked", content: "", commentsDisabled: true) { id } }`)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, gql.CodeForbidden, result.Errors[0].Extensions["code"])
+
+	updated := doGraphQL(t, ts, alice, `mutation { updatePost(id: "`+postId+`", title: "Title", content: "one\nthree", commentsDisabled: true) { content editedAt } }`)
+	edited := updated["updatePost"].(map[string]interface{})
+	assert.Equal(t, "one\nthree", edited["content"])
+	assert.NotNil(t, edited["editedAt"])
+
+	// Сравнение исходной и изменённой версий
+	data := doGraphQL(t, ts, "", `{ post(id: "`+postId+`") {
+		revisions { revision content commentsDisabled editor { username } }
+		diff(from: 1, to: 2) { from to title { op text } content { op text } }
+	} }`)
+	fetched := data["post"].(map[string]interface{})
+	revisions := fetched["revisions"].([]interface{})
+	require.Len(t, revisions, 2)
+	assert.Equal(t, map[string]interface{}{
+		"revision": float64(1), "content": "one\ntwo", "commentsDisabled": false, "editor": map[string]interface{}{"username": "alice"},
+	}, revisions[0])
+	assert.JSONEq(t, `{
+		"from": 1, "to": 2,
+		"title": [{"op": "EQUAL", "text": "Title"}],
+		"content": [{"op": "EQUAL", "text": "one"}, {"op": "DELETE", "text": "two"}, {"op": "INSERT", "text": "three"}]
+	}`, mustJSON(t, fetched["diff"]))
+
+	// Возврат к первой версии сохраняется как третья версия
+	reverted := doGraphQL(t, ts, alice, `mutation { revertPost(id: "`+postId+`", revision: 1) { content commentsDisabled revisions { revision } } }`)
+	post = reverted["revertPost"].(map[string]interface{})
+	assert.Equal(t, "one\ntwo", post["content"])
+	assert.Equal(t, false, post["commentsDisabled"])
+	assert.Len(t, post["revisions"], 3)
+
+	result = postGraphQL(t, ts, alice, `mutation { revertPost(id: "`+postId+`", revision: 10) { id } }`)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, "revision 10 not found", result.Errors[0].Message)
+	assert.Equal(t, gql.CodeNotFound, result.Errors[0].Extensions["code"])
+
+	// Изменение комментария
+	comment := doGraphQL(t, ts, bob, `mutation { createComment(postId: "`+postId+`", parentId: "", content: "Hello") { id } }`)
+	commentId := comment["createComment"].(map[string]interface{})["id"].(string)
+	data = doGraphQL(t, ts, bob, `mutation { updateComment(id: "`+commentId+`", content: "Hello, world") { content revisions { content } diff(from: 1, to: 2) { content { op text } } } }`)
+	assert.JSONEq(t, `{"updateComment": {
+		"content": "Hello, world",
+		"revisions": [{"content": "Hello"}, {"content": "Hello, world"}],
+		"diff": {"content": [{"op": "DELETE", "text": "Hello"}, {"op": "INSERT", "text": "Hello, world"}]}
+	}}`, mustJSON(t, data))
+}
+
+func TestSoftDeleteComments(t *testing.T) {
+	ts := newTestServerWith(t, testTokensConfig, gql.Options{SoftDeleteComments: true})
+	alice := register(t, ts, "alice")
+	bob := register(t, ts, "bob")
+
+	post := doGraphQL(t, ts, alice, `mutation { createPost(title: "Title", content: "Content", commentsDisabled: false) { id } }`)
+	postId := post["createPost"].(map[string]interface{})["id"].(string)
+	comment := doGraphQL(t, ts, alice, `mutation { createComment(postId: "`+postId+`", parentId: "", content: "Question") { id } }`)
+	commentId := comment["createComment"].(map[string]interface{})["id"].(string)
+	doGraphQL(t, ts, bob, `mutation { createComment(postId: "`+postId+`", parentId: "`+commentId+`", content: "Answer") { id } }`)
+
+	// Удалённый комментарий становится надгробием, ответы на него остаются доступными
+	doGraphQL(t, ts, alice, `mutation { deleteComment(id: "`+commentId+`") }`)
+	data := doGraphQL(t, ts, "", `{ post(id: "`+postId+`") { comments(first: 10) { edges { node {
+		content isDeleted deletedAt children(first: 10) { edges { node { content isDeleted } } }
+	} } } } }`)
+	edges := data["post"].(map[string]interface{})["comments"].(map[string]interface{})["edges"].([]interface{})
+	require.Len(t, edges, 1)
+	tombstone := edges[0].(map[string]interface{})["node"].(map[string]interface{})
+	assert.Nil(t, tombstone["content"])
+	assert.Equal(t, true, tombstone["isDeleted"])
+	assert.NotNil(t, tombstone["deletedAt"])
+	assert.JSONEq(t, `{"edges": [{"node": {"content": "Answer", "isDeleted": false}}]}`, mustJSON(t, tombstone["children"]))
+
+	// Надгробие нельзя изменить и на него нельзя ответить
+	result := postGraphQL(t, ts, alice, `mutation { updateComment(id: "`+commentId+`", content: "Edited") { id } }`)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, "conflict: comment is deleted", result.Errors[0].Message)
+	assert.Equal(t, gql.CodeConflict, result.Errors[0].Extensions["code"])
+	result = postGraphQL(t, ts, bob, `mutation { createComment(postId: "`+postId+`", parentId: "`+commentId+`", content: "Reply") { id } }`)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, "conflict: comment is deleted", result.Errors[0].Message)
+	assert.Equal(t, gql.CodeConflict, result.Errors[0].Extensions["code"])
+
+	// Безвозвратно удалить комментарий может только администратор
+	result = postGraphQL(t, ts, alice, `mutation { purgeComment(id: "`+commentId+`") }`)
+	require.NotEmpty(t, result.Errors)
+	assert.Contains(t, result.Errors[0].Message, "only an admin")
+	assert.Equal(t, gql.CodeForbidden, result.Errors[0].Extensions["code"])
+	doGraphQL(t, ts, adminToken(t), `mutation { purgeComment(id: "`+commentId+`") }`)
+	data = doGraphQL(t, ts, "", `{ post(id: "`+postId+`") { comments(first: 10) { totalCount } } }`)
+	assert.Equal(t, float64(0), data["post"].(map[string]interface{})["comments"].(map[string]interface{})["totalCount"])
+}
+
+func TestVotesAndReactions(t *testing.T) {
+	ts := newTestServer(t)
+	alice := register(t, ts, "alice")
+	bob := register(t, ts, "bob")
+
+	post := doGraphQL(t, ts, alice, `mutation { createPost(title: "Title", content: "Content", commentsDisabled: false) { id } }`)
+	postId := post["createPost"].(map[string]interface{})["id"].(string)
+	comment := doGraphQL(t, ts, alice, `mutation { createComment(postId: "`+postId+`", parentId: "", content: "Hello") { id } }`)
+	commentId := comment["createComment"].(map[string]interface{})["id"].(string)
+
+	// Повторный голос заменяет прежний
+	doGraphQL(t, ts, alice, `mutation { vote(targetId: "`+postId+`", value: UP) { id } }`)
+	doGraphQL(t, ts, bob, `mutation { vote(targetId: "`+postId+`", value: UP) { id } }`)
+	data := doGraphQL(t, ts, bob, `mutation { vote(targetId: "`+postId+`", value: DOWN) { ... on Post { title } score upvotes downvotes viewerVote } }`)
+	assert.JSONEq(t, `{"vote": {"title": "Title", "score": 0, "upvotes": 1, "downvotes": 1, "viewerVote": "DOWN"}}`, mustJSON(t, data))
+	data = doGraphQL(t, ts, bob, `mutation { vote(targetId: "`+postId+`", value: NONE) { score viewerVote } }`)
+	assert.JSONEq(t, `{"vote": {"score": 1, "viewerVote": "NONE"}}`, mustJSON(t, data))
+
+	// Голосовать и реагировать можно и на комментарии
+	data = doGraphQL(t, ts, bob, `mutation { vote(targetId: "`+commentId+`", value: DOWN) { ... on Comment { content } score } }`)
+	assert.JSONEq(t, `{"vote": {"content": "Hello", "score": -1}}`, mustJSON(t, data))
+
+	// Реакции переключаются повторным вызовом
+	doGraphQL(t, ts, alice, `mutation { react(targetId: "`+commentId+`", emoji: "👍") { id } }`)
+	doGraphQL(t, ts, bob, `mutation { react(targetId: "`+commentId+`", emoji: "👍") { id } }`)
+	doGraphQL(t, ts, bob, `mutation { react(targetId: "`+commentId+`", emoji: "🎉") { id } }`)
+	data = doGraphQL(t, ts, bob, `mutation { react(targetId: "`+commentId+`", emoji: "🎉") { reactions { emoji count viewerHasReacted } } }`)
+	assert.JSONEq(t, `{"react": {"reactions": [{"emoji": "👍", "count": 2, "viewerHasReacted": true}]}}`, mustJSON(t, data))
+	data = doGraphQL(t, ts, "", `{ post(id: "`+postId+`") { comments(first: 1) { edges { node { reactions { count viewerHasReacted } } } } } }`)
+	assert.Contains(t, mustJSON(t, data), `"reactions":[{"count":2,"viewerHasReacted":false}]`)
+
+	// Голосовать можно только после входа, реакцией может быть только эмодзи
+	result := postGraphQL(t, ts, "", `mutation { vote(targetId: "`+postId+`", value: UP) { id } }`)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, "authentication required", result.Errors[0].Message)
+	result = postGraphQL(t, ts, alice, `mutation { react(targetId: "`+postId+`", emoji: "lol") { id } }`)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, "emoji: reaction must be a single emoji", result.Errors[0].Message)
+	assert.Equal(t, map[string]interface{}{"code": gql.CodeValidation, "field": "emoji"}, result.Errors[0].Extensions)
+	result = postGraphQL(t, ts, alice, `mutation { vote(targetId: "missing", value: UP) { id } }`)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, gql.CodeNotFound, result.Errors[0].Extensions["code"])
+}
+
+func TestSearch(t *testing.T) {
+	ts := newTestServer(t)
+	alice := register(t, ts, "alice")
+
+	post := doGraphQL(t, ts, alice, `mutation { createPost(title: "Кошки", content: "Про домашних животных", commentsDisabled: false) { id } }`)
+	postId := post["createPost"].(map[string]interface{})["id"].(string)
+	doGraphQL(t, ts, alice, `mutation { createComment(postId: "`+postId+`", parentId: "", content: "У меня тоже есть кошка") { id } }`)
+
+	// Результаты поиска - посты и комментарии с выделенными словами запроса
+	query := `{ search(query: "кошка", first: 10) {
+		totalCount
+		edges { snippet node { __typename ... on Post { title } ... on Comment { content } } }
+	} }`
+	data := doGraphQL(t, ts, "", query)
+	assert.JSONEq(t, `{"search": {"totalCount": 2, "edges": [
+		{"snippet": "<b>Кошки</b>", "node": {"__typename": "Post", "title": "Кошки"}},
+		{"snippet": "У меня тоже есть <b>кошка</b>", "node": {"__typename": "Comment", "content": "У меня тоже есть кошка"}}
+	]}}`, mustJSON(t, data))
+
+	// Поиск по страницам и только среди комментариев
+	data = doGraphQL(t, ts, "", `{ search(query: "кошка", first: 1) { pageInfo { hasNextPage endCursor } } }`)
+	pageInfo := data["search"].(map[string]interface{})["pageInfo"].(map[string]interface{})
+	assert.Equal(t, true, pageInfo["hasNextPage"])
+	data = doGraphQL(t, ts, "", `{ search(query: "кошка", first: 1, after: "`+pageInfo["endCursor"].(string)+`") { edges { node { __typename } } pageInfo { hasNextPage hasPreviousPage } } }`)
+	assert.JSONEq(t, `{"search": {"edges": [{"node": {"__typename": "Comment"}}], "pageInfo": {"hasNextPage": false, "hasPreviousPage": true}}}`, mustJSON(t, data))
+	data = doGraphQL(t, ts, "", `{ search(query: "кошка", first: 10, in: [COMMENTS]) { totalCount } }`)
+	assert.JSONEq(t, `{"search": {"totalCount": 1}}`, mustJSON(t, data))
+
+	result := postGraphQL(t, ts, "", `{ search(query: "кошка", first: 10, after: "bad") { totalCount } }`)
+	require.NotEmpty(t, result.Errors)
+}
+
+func TestCommentThread(t *testing.T) {
+	ts := newTestServer(t)
+	alice := register(t, ts, "alice")
+
+	post := doGraphQL(t, ts, alice, `mutation { createPost(title: "Title", content: "Content", commentsDisabled: false) { id } }`)
+	postId := post["createPost"].(map[string]interface{})["id"].(string)
+	create := func(parentId, content string) string {
+		comment := doGraphQL(t, ts, alice, `mutation { createComment(postId: "`+postId+`", parentId: "`+parentId+`", content: "`+content+`") { id } }`)
+		return comment["createComment"].(map[string]interface{})["id"].(string)
+	}
+	question := create("", "Question")
+	answer := create(question, "Answer")
+	thanks := create(answer, "Thanks")
+	another := create("", "Another question")
+
+	// Всё обсуждение одним запросом: ответы следуют сразу за родителем
+	data := doGraphQL(t, ts, "", `{ commentThread(postId: "`+postId+`") { depth path comment { content } } }`)
+	assert.JSONEq(t, `{"commentThread": [
+		{"depth": 0, "path": ["`+question+`"], "comment": {"content": "Question"}},
+		{"depth": 1, "path": ["`+question+`", "`+answer+`"], "comment": {"content": "Answer"}},
+		{"depth": 2, "path": ["`+question+`", "`+answer+`", "`+thanks+`"], "comment": {"content": "Thanks"}},
+		{"depth": 0, "path": ["`+another+`"], "comment": {"content": "Another question"}}
+	]}`, mustJSON(t, data))
+
+	// Ограничение глубины и количества комментариев
+	data = doGraphQL(t, ts, "", `{ commentThread(postId: "`+postId+`", maxDepth: 1, first: 2) { depth comment { content } } }`)
+	assert.JSONEq(t, `{"commentThread": [
+		{"depth": 0, "comment": {"content": "Question"}},
+		{"depth": 1, "comment": {"content": "Answer"}}
+	]}`, mustJSON(t, data))
+
+	result := postGraphQL(t, ts, "", `{ commentThread(postId: "`+postId+`", maxDepth: -1) { depth } }`)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, map[string]interface{}{"code": gql.CodeValidation, "field": "maxDepth"}, result.Errors[0].Extensions)
+	result = postGraphQL(t, ts, "", `{ commentThread(postId: "missing") { depth } }`)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, gql.CodeNotFound, result.Errors[0].Extensions["code"])
+}
+
+// Тест кодов ошибок в extensions.code
+func TestErrorCodes(t *testing.T) {
+	ts := newTestServer(t)
+	alice := register(t, ts, "alice")
+
+	post := doGraphQL(t, ts, alice, `mutation { createPost(title: "Title", content: "Content", commentsDisabled: true) { id } }`)
+	postId := post["createPost"].(map[string]interface{})["id"].(string)
+
+	for _, tc := range []struct {
+		name       string
+		query      string
+		extensions map[string]interface{}
+	}{
+		{"missing post", `{ post(id: "missing") { id } }`, map[string]interface{}{"code": gql.CodeNotFound, "entity": "post"}},
+		{"comments disabled", `mutation { createComment(postId: "` + postId + `", parentId: "", content: "Hello") { id } }`, map[string]interface{}{"code": gql.CodeCommentsDisabled}},
+		{"negative first", `{ posts(first: -1) { totalCount } }`, map[string]interface{}{"code": gql.CodeValidation, "field": "first"}},
+		{"invalid cursor", `{ posts(first: 1, after: "bad") { totalCount } }`, map[string]interface{}{"code": gql.CodeValidation, "field": "after"}},
+		{"short password", `mutation { register(username: "bob", password: "short") { token } }`, map[string]interface{}{"code": gql.CodeValidation, "field": "password"}},
+		{"unknown field", `{ posts(first: 1) { unknown } }`, map[string]interface{}{"code": gql.CodeInvalidQuery}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result := postGraphQL(t, ts, alice, tc.query)
+			require.NotEmpty(t, result.Errors)
+			assert.Equal(t, tc.extensions, result.Errors[0].Extensions)
+		})
+	}
+}
+
+// Тест настраиваемых ограничений длины постов и комментариев: длина считается в символах Unicode
+func TestContentLimits(t *testing.T) {
+	ts := newTestServerWith(t, testTokensConfig, gql.Options{MaxCommentLength: 5, MaxPostTitleLength: 5, MaxPostLength: 10})
+	alice := register(t, ts, "alice")
+
+	result := postGraphQL(t, ts, alice, `mutation { createPost(title: "Слишком длинный", content: "Текст", commentsDisabled: false) { id } }`)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, map[string]interface{}{"code": gql.CodeValidation, "field": "title"}, result.Errors[0].Extensions)
+
+	post := doGraphQL(t, ts, alice, `mutation { createPost(title: "Пост", content: "Текст", commentsDisabled: false) { id } }`)
+	postId := post["createPost"].(map[string]interface{})["id"].(string)
+	result = postGraphQL(t, ts, alice, `mutation { updatePost(id: "`+postId+`", title: "Пост", content: "Слишком длинный текст", commentsDisabled: false) { id } }`)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, map[string]interface{}{"code": gql.CodeValidation, "field": "content"}, result.Errors[0].Extensions)
+
+	// Пять кириллических символов занимают десять байт, но укладываются в ограничение
+	comment := doGraphQL(t, ts, alice, `mutation { createComment(postId: "`+postId+`", parentId: "", content: "Ответ") { id } }`)
+	assert.NotNil(t, comment["createComment"])
+}
+
+// Тест создания комментария верхнего уровня без необязательного аргумента parentId
+func TestCreateCommentWithoutParent(t *testing.T) {
+	ts := newTestServer(t)
+	alice := register(t, ts, "alice")
+	post := doGraphQL(t, ts, alice, `mutation { createPost(title: "Title", content: "Content", commentsDisabled: false) { id } }`)
+	postId := post["createPost"].(map[string]interface{})["id"].(string)
+
+	comment := doGraphQL(t, ts, alice, `mutation { createComment(postId: "`+postId+`", content: "Hello") { id } }`)
+	commentId := comment["createComment"].(map[string]interface{})["id"].(string)
+
+	data := doGraphQL(t, ts, "", `{ post(id: "`+postId+`") { comments(first: 10) { edges { node { id } } } } }`)
+	edges := data["post"].(map[string]interface{})["comments"].(map[string]interface{})["edges"].([]interface{})
+	require.Len(t, edges, 1)
+	assert.Equal(t, commentId, edges[0].(map[string]interface{})["node"].(map[string]interface{})["id"])
+}
+
+// Тест отключения среды GraphiQL
+func TestGraphiQLToggle(t *testing.T) {
+	tokens, err := auth.NewTokens(testTokensConfig)
+	require.NoError(t, err)
+	srv := server.NewServer(inmemory.NewInMemoryRepository(), tokens, gql.Options{})
+
+	getPage := func() string {
+		ts := httptest.NewServer(srv.Handler())
+		defer ts.Close()
+		req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", "text/html")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var body bytes.Buffer
+		_, err = body.ReadFrom(resp.Body)
+		require.NoError(t, err)
+		return body.String()
+	}
+	assert.Contains(t, getPage(), "graphiql")
+	srv.GraphiQL = false
+	assert.NotContains(t, getPage(), "graphiql")
+}
+
+// slowRepository имитирует хранилище, чей выбор постов не завершается до отмены контекста
+type slowRepository struct {
+	*inmemory.InMemoryRepository
+}
+
+func (repo slowRepository) ListPosts(ctx context.Context, query repository.PostsQuery) (*repository.PostPage, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// Тест ограничения времени: операция хранилища, прерванная по сроку запроса или операции, возвращает ошибку таймаута
+func TestTimeouts(t *testing.T) {
+	tokens, err := auth.NewTokens(testTokensConfig)
+	require.NoError(t, err)
+	query := `{ posts(first: 10) { totalCount } }`
+
+	// Срок запроса короче срока операции
+	srv := server.NewServer(repository.WithTimeout(slowRepository{inmemory.NewInMemoryRepository()}, time.Minute), tokens, gql.Options{})
+	ts := httptest.NewServer(server.TimeoutMiddleware(50*time.Millisecond, srv.Handler()))
+	t.Cleanup(ts.Close)
+	result := postGraphQL(t, ts, "", query)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, repository.ErrTimeout.Error(), result.Errors[0].Message)
+	assert.Equal(t, gql.CodeTimeout, result.Errors[0].Extensions["code"])
+
+	// Срок операции короче срока запроса
+	srv = server.NewServer(repository.WithTimeout(slowRepository{inmemory.NewInMemoryRepository()}, 50*time.Millisecond), tokens, gql.Options{})
+	ts = httptest.NewServer(server.TimeoutMiddleware(time.Minute, srv.Handler()))
+	t.Cleanup(ts.Close)
+	result = postGraphQL(t, ts, "", query)
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, repository.ErrTimeout.Error(), result.Errors[0].Message)
+	assert.Equal(t, gql.CodeTimeout, result.Errors[0].Extensions["code"])
+}
+
+// slowTargetRepository имитирует хранилище, чтение поста из которого прерывается по сроку операции
+type slowTargetRepository struct {
+	*inmemory.InMemoryRepository
+}
+
+func (repo slowTargetRepository) GetPost(ctx context.Context, id string) (*models.Post, error) {
+	return nil, repository.ErrTimeout
+}
+
+// Тест ошибок хранилища при поиске объекта голоса и реакции: они не превращаются в ошибку "не найдено"
+func TestTargetLookupErrors(t *testing.T) {
+	tokens, err := auth.NewTokens(testTokensConfig)
+	require.NoError(t, err)
+	ts := httptest.NewServer(server.NewServer(slowTargetRepository{inmemory.NewInMemoryRepository()}, tokens, gql.Options{}).Handler())
+	t.Cleanup(ts.Close)
+	alice := register(t, ts, "alice")
+
+	for _, query := range []string{
+		`mutation { vote(targetId: "some-id", value: UP) { id } }`,
+		`mutation { react(targetId: "some-id", emoji: "👍") { id } }`,
+	} {
+		result := postGraphQL(t, ts, alice, query)
+		require.NotEmpty(t, result.Errors, query)
+		assert.Equal(t, gql.CodeTimeout, result.Errors[0].Extensions["code"], query)
+	}
+}
+
+// countingRepository подсчитывает обращения к хранилищу за страницами комментариев
+type countingRepository struct {
+	*inmemory.InMemoryRepository
+	mu    sync.Mutex
+	calls []string // Вызванные методы и количество идентификаторов в пакетных вызовах
+}
+
+func (repo *countingRepository) record(call string) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.calls = append(repo.calls, call)
+}
+
+func (repo *countingRepository) GetCommentsByPostID(ctx context.Context, postId string, first int64, after *repository.Cursor) (*repository.CommentPage, error) {
+	repo.record("GetCommentsByPostID")
+	return repo.InMemoryRepository.GetCommentsByPostID(ctx, postId, first, after)
+}
+
+func (repo *countingRepository) GetCommentsByParentID(ctx context.Context, parentId string, first int64, after *repository.Cursor) (*repository.CommentPage, error) {
+	repo.record("GetCommentsByParentID")
+	return repo.InMemoryRepository.GetCommentsByParentID(ctx, parentId, first, after)
+}
+
+func (repo *countingRepository) GetCommentsByPostIDs(ctx context.Context, postIds []string, first int64, after *repository.Cursor) (map[string]*repository.CommentPage, error) {
+	repo.record(fmt.Sprintf("GetCommentsByPostIDs(%d)", len(postIds)))
+	return repo.InMemoryRepository.GetCommentsByPostIDs(ctx, postIds, first, after)
+}
+
+func (repo *countingRepository) GetCommentsByParentIDs(ctx context.Context, parentIds []string, first int64, after *repository.Cursor) (map[string]*repository.CommentPage, error) {
+	repo.record(fmt.Sprintf("GetCommentsByParentIDs(%d)", len(parentIds)))
+	return repo.InMemoryRepository.GetCommentsByParentIDs(ctx, parentIds, first, after)
+}
+
+// Тест пакетной загрузки: вложенные комментарии всех постов запрашиваются одним обращением на уровень вложенности
+func TestBatchedCommentLoading(t *testing.T) {
+	tokens, err := auth.NewTokens(testTokensConfig)
+	require.NoError(t, err)
+	repo := &countingRepository{InMemoryRepository: inmemory.NewInMemoryRepository()}
+	ts := httptest.NewServer(server.NewServer(repo, tokens, gql.Options{}).Handler())
+	t.Cleanup(ts.Close)
+	alice := register(t, ts, "alice")
+
+	for _, title := range []string{"First", "Second"} {
+		post := doGraphQL(t, ts, alice, `mutation { createPost(title: "`+title+`", content: "Content", commentsDisabled: false) { id } }`)
+		postId := post["createPost"].(map[string]interface{})["id"].(string)
+		for i := 0; i < 2; i++ {
+			root := doGraphQL(t, ts, alice, `mutation { createComment(postId: "`+postId+`", parentId: "", content: "Root") { id } }`)
+			rootId := root["createComment"].(map[string]interface{})["id"].(string)
+			doGraphQL(t, ts, alice, `mutation { createComment(postId: "`+postId+`", parentId: "`+rootId+`", content: "Reply") { id } }`)
+		}
+	}
+
+	repo.calls = nil
+	data := doGraphQL(t, ts, "", `{
+		posts(first: 10) { edges { node {
+			comments(first: 5) { totalCount edges { node {
+				children(first: 5) { totalCount edges { node {
+					children(first: 5) { totalCount }
+				} } }
+			} } }
+		} } }
+	}`)
+	assert.Equal(t, []string{"GetCommentsByPostIDs(2)", "GetCommentsByParentIDs(4)", "GetCommentsByParentIDs(4)"}, repo.calls)
+
+	for _, edge := range data["posts"].(map[string]interface{})["edges"].([]interface{}) {
+		comments := edge.(map[string]interface{})["node"].(map[string]interface{})["comments"].(map[string]interface{})
+		assert.Equal(t, 2.0, comments["totalCount"])
+		for _, root := range comments["edges"].([]interface{}) {
+			children := root.(map[string]interface{})["node"].(map[string]interface{})["children"].(map[string]interface{})
+			assert.Equal(t, 1.0, children["totalCount"])
+			reply := children["edges"].([]interface{})[0].(map[string]interface{})["node"].(map[string]interface{})
+			assert.Equal(t, 0.0, reply["children"].(map[string]interface{})["totalCount"])
+		}
+	}
+}
+
+// unavailableRepository имитирует хранилище, потерявшее соединение с базой данных
+type unavailableRepository struct {
+	*inmemory.InMemoryRepository
+}
+
+func (repo unavailableRepository) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+// newHealthServer поднимает тестовый HTTP сервер с обработчиками GraphQL и проверок состояния
+func newHealthServer(t *testing.T, repo repository.Repository) (*server.Server, *httptest.Server) {
+	tokens, err := auth.NewTokens(testTokensConfig)
+	require.NoError(t, err)
+	srv := server.NewServer(repo, tokens, gql.Options{})
+	mux := http.NewServeMux()
+	mux.Handle("/", srv.Handler())
+	mux.Handle("/healthz", srv.LivenessHandler())
+	mux.Handle("/readyz", srv.ReadinessHandler())
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return srv, ts
+}
+
+// getStatus выполняет GET запрос и возвращает код ответа
+func getStatus(t *testing.T, url string) int {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// Тест проверок жизнеспособности и готовности: готовность зависит от доступности хранилища, жизнеспособность - нет
+func TestHealthChecks(t *testing.T) {
+	_, ts := newHealthServer(t, repository.WithTimeout(inmemory.NewInMemoryRepository(), time.Second))
+	assert.Equal(t, http.StatusOK, getStatus(t, ts.URL+"/healthz"))
+	assert.Equal(t, http.StatusOK, getStatus(t, ts.URL+"/readyz"))
+
+	_, ts = newHealthServer(t, unavailableRepository{inmemory.NewInMemoryRepository()})
+	assert.Equal(t, http.StatusOK, getStatus(t, ts.URL+"/healthz"))
+	assert.Equal(t, http.StatusServiceUnavailable, getStatus(t, ts.URL+"/readyz"))
+}
+
+// Тест остановки сервера: проверка готовности отвечает 503, а WebSocket соединения закрываются с кодом 1001
+func TestShutdown(t *testing.T) {
+	srv, ts := newHealthServer(t, inmemory.NewInMemoryRepository())
+	conn := dialWebSocket(t, ts)
+
+	srv.Shutdown()
+	srv.Shutdown() // Повторный вызов ничего не делает
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "expected going away close, got %v", err)
+
+	assert.Equal(t, http.StatusServiceUnavailable, getStatus(t, ts.URL+"/readyz"))
+	assert.Equal(t, http.StatusOK, getStatus(t, ts.URL+"/healthz"))
+	// Начатые и новые HTTP запросы по-прежнему обслуживаются, пока их не остановит http.Server.Shutdown
+	assert.NotNil(t, doGraphQL(t, ts, "", `{ posts(first: 1) { totalCount } }`)["posts"])
+}
+
+// mustJSON сериализует значение в JSON
+func mustJSON(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(data)
+}
@@ -0,0 +1,529 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nemopss/go-posts-comments-system/internal/migrations"
+	"github.com/nemopss/go-posts-comments-system/internal/repository"
+	"github.com/nemopss/go-posts-comments-system/internal/repository/sqlite"
+	"github.com/nemopss/go-posts-comments-system/internal/test/conformance"
+	"github.com/stretchr/testify/assert"
+)
+
+// cleanDatabase очищает все таблицы перед запуском теста. Комментарии, голоса, реакции
+// и записи поискового индекса удаляются вместе с постами и пользователями
+func cleanDatabase(db *sql.DB) {
+	tables := []string{"posts", "users"}
+	for _, table := range tables {
+		_, err := db.Exec("DELETE FROM " + table)
+		if err != nil {
+			log.Fatalf("failed to clean table %s: %v", table, err)
+		}
+	}
+}
+
+// openTestDB создаёт новый файл базы данных для теста и приводит его схему к последней версии
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrator, err := migrations.NewMigrator(db, migrations.SQLite)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+	return db
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	// Каждый запуск теста работает с новым файлом базы данных
+	testDB := openTestDB(t)
+	repo := sqlite.NewSQLiteRepository(testDB)
+	ctx := context.Background()
+
+	// createAuthor создаёт автора постов и комментариев для теста
+	createAuthor := func(t *testing.T) string {
+		user, err := repo.CreateUser(ctx, "author", "password-hash")
+		assert.NoError(t, err)
+		return user.ID
+	}
+
+	// Тест GetCommentsByPostID
+	t.Run("TestGetCommentsByPostID_SQLite", func(t *testing.T) {
+		cleanDatabase(testDB)
+		author := createAuthor(t)
+		//Создание поста
+		post, err := repo.CreatePost(ctx, author, "Title", "Content", false)
+		assert.NoError(t, err)
+		// Создание комментариев
+		_, err = repo.CreateComment(ctx, author, post.ID, "", "Comment 1")
+		assert.NoError(t, err)
+		_, err = repo.CreateComment(ctx, author, post.ID, "", "Comment 2")
+		assert.NoError(t, err)
+		// Проверка получения комментариев
+		page, err := repo.GetCommentsByPostID(ctx, post.ID, 10, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(page.Comments))
+		assert.Equal(t, 2, page.TotalCount)
+		assert.False(t, page.HasNextPage)
+	})
+	// Тест GetCommentsByParentID
+	t.Run("TestGetCommentsByParentID_SQLite", func(t *testing.T) {
+		cleanDatabase(testDB)
+		author := createAuthor(t)
+		//Создание поста
+		post, err := repo.CreatePost(ctx, author, "Title", "Content", false)
+		assert.NoError(t, err)
+
+		// Создание комментариев
+		comment1, err := repo.CreateComment(ctx, author, post.ID, "", "Comment 1")
+		assert.NoError(t, err)
+		_, err = repo.CreateComment(ctx, author, post.ID, comment1.ID, "Comment 1.1")
+		assert.NoError(t, err)
+		_, err = repo.CreateComment(ctx, author, post.ID, comment1.ID, "Comment 1.2")
+		assert.NoError(t, err)
+
+		// Проверка получения комментариев
+		page, err := repo.GetCommentsByParentID(ctx, comment1.ID, 10, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(page.Comments))
+	})
+
+	// Тест keyset-пагинации
+	t.Run("TestCommentsPagination_SQLite", func(t *testing.T) {
+		cleanDatabase(testDB)
+		author := createAuthor(t)
+		post, err := repo.CreatePost(ctx, author, "Title", "Content", false)
+		assert.NoError(t, err)
+
+		created := []string{}
+		for i := 0; i < 5; i++ {
+			comment, err := repo.CreateComment(ctx, author, post.ID, "", "Comment")
+			assert.NoError(t, err)
+			created = append(created, comment.ID)
+		}
+
+		page, err := repo.GetCommentsByPostID(ctx, post.ID, 3, nil)
+		assert.NoError(t, err)
+		assert.True(t, page.HasNextPage)
+		assert.Equal(t, 5, page.TotalCount)
+
+		after := repository.CursorOf(page.Comments[len(page.Comments)-1])
+		next, err := repo.GetCommentsByPostID(ctx, post.ID, 3, &after)
+		assert.NoError(t, err)
+		assert.False(t, next.HasNextPage)
+
+		fetched := []string{}
+		for _, comment := range append(page.Comments, next.Comments...) {
+			fetched = append(fetched, comment.ID)
+		}
+		assert.ElementsMatch(t, created, fetched)
+	})
+
+	// Тест ListPosts
+	t.Run("TestListPosts_SQLite", func(t *testing.T) {
+		cleanDatabase(testDB)
+		author := createAuthor(t)
+		quiet, err := repo.CreatePost(ctx, author, "Quiet post", "Content", false)
+		assert.NoError(t, err)
+		busy, err := repo.CreatePost(ctx, author, "Busy post", "Content", false)
+		assert.NoError(t, err)
+		_, err = repo.CreatePost(ctx, author, "Closed post", "Content", true)
+		assert.NoError(t, err)
+		_, err = repo.CreateComment(ctx, author, busy.ID, "", "Comment 1")
+		assert.NoError(t, err)
+		_, err = repo.CreateComment(ctx, author, busy.ID, "", "Comment 2")
+		assert.NoError(t, err)
+
+		page, err := repo.ListPosts(ctx, repository.PostsQuery{First: 1, OrderBy: repository.PostOrderCommentCount})
+		assert.NoError(t, err)
+		assert.Equal(t, busy.ID, page.Posts[0].ID)
+		assert.Equal(t, 2, page.Posts[0].CommentCount)
+		assert.True(t, page.HasNextPage)
+
+		title := "post"
+		commentsDisabled := false
+		filter := repository.PostFilter{TitleContains: &title, CommentsDisabled: &commentsDisabled}
+		page, err = repo.ListPosts(ctx, repository.PostsQuery{First: 1, Filter: filter})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, page.TotalCount)
+		assert.Equal(t, busy.ID, page.Posts[0].ID)
+
+		after := repository.PostCursorOf(page.Posts[0], repository.PostOrderCreatedAt)
+		page, err = repo.ListPosts(ctx, repository.PostsQuery{First: 1, After: &after, Filter: filter})
+		assert.NoError(t, err)
+		assert.Equal(t, quiet.ID, page.Posts[0].ID)
+		assert.False(t, page.HasNextPage)
+	})
+
+	// Тест CreateUser
+	t.Run("TestCreateUser_SQLite", func(t *testing.T) {
+		cleanDatabase(testDB)
+		user, err := repo.CreateUser(ctx, "alice", "password-hash")
+		assert.NoError(t, err)
+
+		fetched, err := repo.GetUserByUsername(ctx, "alice")
+		assert.NoError(t, err)
+		assert.Equal(t, user.ID, fetched.ID)
+
+		_, err = repo.CreateUser(ctx, "alice", "another-hash")
+		assert.ErrorIs(t, err, repository.ErrUsernameTaken)
+	})
+
+	// Тест UpdatePost и UpdateComment
+	t.Run("TestRevisions_SQLite", func(t *testing.T) {
+		cleanDatabase(testDB)
+		author := createAuthor(t)
+		post, err := repo.CreatePost(ctx, author, "Title", "Content", false)
+		assert.NoError(t, err)
+		comment, err := repo.CreateComment(ctx, author, post.ID, "", "Comment")
+		assert.NoError(t, err)
+
+		updated, err := repo.UpdatePost(ctx, author, post.ID, "New title", "New content", true)
+		assert.NoError(t, err)
+		assert.Equal(t, "New title", updated.Title)
+		assert.NotNil(t, updated.EditedAt)
+
+		revisions, err := repo.GetPostRevisions(ctx, post.ID)
+		assert.NoError(t, err)
+		if assert.Len(t, revisions, 2) {
+			assert.Equal(t, "Title", revisions[0].Title)
+			assert.Equal(t, 2, revisions[1].Revision)
+			assert.True(t, revisions[1].CommentsDisabled)
+		}
+
+		_, err = repo.UpdateComment(ctx, author, comment.ID, "Edited comment")
+		assert.NoError(t, err)
+		commentRevisions, err := repo.GetCommentRevisions(ctx, comment.ID)
+		assert.NoError(t, err)
+		if assert.Len(t, commentRevisions, 2) {
+			assert.Equal(t, "Edited comment", commentRevisions[1].Content)
+		}
+	})
+
+	// Тест SoftDeleteComment
+	t.Run("TestSoftDeleteComment_SQLite", func(t *testing.T) {
+		cleanDatabase(testDB)
+		author := createAuthor(t)
+		post, err := repo.CreatePost(ctx, author, "Title", "Content", false)
+		assert.NoError(t, err)
+		comment, err := repo.CreateComment(ctx, author, post.ID, "", "Comment")
+		assert.NoError(t, err)
+		_, err = repo.CreateComment(ctx, author, post.ID, comment.ID, "Reply")
+		assert.NoError(t, err)
+
+		tombstone, err := repo.SoftDeleteComment(ctx, comment.ID)
+		assert.NoError(t, err)
+		assert.NotNil(t, tombstone.DeletedAt)
+		assert.Empty(t, tombstone.Content)
+
+		page, err := repo.GetCommentsByParentID(ctx, comment.ID, 10, nil)
+		assert.NoError(t, err)
+		if assert.Len(t, page.Comments, 1) {
+			assert.Equal(t, "Reply", page.Comments[0].Content)
+		}
+
+		_, err = repo.UpdateComment(ctx, author, comment.ID, "Edited")
+		assert.ErrorIs(t, err, repository.ErrCommentDeleted)
+		_, err = repo.CreateComment(ctx, author, post.ID, comment.ID, "Reply")
+		assert.ErrorIs(t, err, repository.ErrCommentDeleted)
+	})
+
+	// Тест Vote и React
+	t.Run("TestVotesAndReactions_SQLite", func(t *testing.T) {
+		cleanDatabase(testDB)
+		author := createAuthor(t)
+		voter, err := repo.CreateUser(ctx, "voter", "password-hash")
+		assert.NoError(t, err)
+		post, err := repo.CreatePost(ctx, author, "Title", "Content", false)
+		assert.NoError(t, err)
+
+		assert.NoError(t, repo.Vote(ctx, repository.TargetPost, post.ID, author, repository.VoteUp))
+		assert.NoError(t, repo.Vote(ctx, repository.TargetPost, post.ID, voter.ID, repository.VoteUp))
+		assert.NoError(t, repo.Vote(ctx, repository.TargetPost, post.ID, voter.ID, repository.VoteDown))
+		post, err = repo.GetPost(ctx, post.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, post.Upvotes)
+		assert.Equal(t, 1, post.Downvotes)
+		value, err := repo.GetVote(ctx, repository.TargetPost, post.ID, voter.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, repository.VoteDown, value)
+
+		reacted, err := repo.React(ctx, repository.TargetPost, post.ID, voter.ID, "👍")
+		assert.NoError(t, err)
+		assert.True(t, reacted)
+		reactions, err := repo.GetReactions(ctx, repository.TargetPost, post.ID, voter.ID)
+		assert.NoError(t, err)
+		if assert.Len(t, reactions, 1) {
+			assert.Equal(t, 1, reactions[0].Count)
+			assert.True(t, reactions[0].ViewerHasReacted)
+		}
+		reacted, err = repo.React(ctx, repository.TargetPost, post.ID, voter.ID, "👍")
+		assert.NoError(t, err)
+		assert.False(t, reacted)
+		reactions, err = repo.GetReactions(ctx, repository.TargetPost, post.ID, voter.ID)
+		assert.NoError(t, err)
+		assert.Empty(t, reactions)
+	})
+
+	// Тест Search
+	t.Run("TestSearch_SQLite", func(t *testing.T) {
+		cleanDatabase(testDB)
+		author := createAuthor(t)
+		titlePost, err := repo.CreatePost(ctx, author, "Кошки и собаки", "Текст про домашних животных", false)
+		assert.NoError(t, err)
+		contentPost, err := repo.CreatePost(ctx, author, "Заметки", "Моя кошка любит спать. Кошка спит весь день.", false)
+		assert.NoError(t, err)
+		dogPost, err := repo.CreatePost(ctx, author, "Про собак", "Собака громко лает", false)
+		assert.NoError(t, err)
+		comment, err := repo.CreateComment(ctx, author, dogPost.ID, "", "Видел рыжую кошку во дворе")
+		assert.NoError(t, err)
+
+		// Совпадение в заголовке важнее совпадений в тексте, а больше вхождений - важнее одного
+		page, err := repo.Search(ctx, repository.SearchQuery{Query: "кошки", First: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, page.TotalCount)
+		ids := []string{}
+		for _, hit := range page.Hits {
+			ids = append(ids, hit.ID())
+		}
+		assert.Equal(t, []string{titlePost.ID, contentPost.ID, comment.ID}, ids)
+		assert.Contains(t, page.Hits[1].Snippet, "<b>кошка</b>")
+
+		// Пагинация по курсору
+		page, err = repo.Search(ctx, repository.SearchQuery{Query: "кошки", First: 2})
+		assert.NoError(t, err)
+		assert.True(t, page.HasNextPage)
+		cursor := repository.SearchCursorOf(page.Hits[1])
+		page, err = repo.Search(ctx, repository.SearchQuery{Query: "кошки", First: 2, After: &cursor})
+		assert.NoError(t, err)
+		if assert.Len(t, page.Hits, 1) {
+			assert.Equal(t, comment.ID, page.Hits[0].ID())
+		}
+		assert.False(t, page.HasNextPage)
+
+		// Найденные документы содержат все слова запроса
+		page, err = repo.Search(ctx, repository.SearchQuery{Query: "кошка собака", First: 10})
+		assert.NoError(t, err)
+		if assert.Len(t, page.Hits, 1) {
+			assert.Equal(t, titlePost.ID, page.Hits[0].ID())
+		}
+
+		// Поиск только среди комментариев
+		page, err = repo.Search(ctx, repository.SearchQuery{Query: "кошки", First: 10, Kinds: []repository.TargetKind{repository.TargetComment}})
+		assert.NoError(t, err)
+		if assert.Len(t, page.Hits, 1) {
+			assert.Equal(t, repository.TargetComment, page.Hits[0].Kind)
+		}
+
+		// Запрос из одних стоп-слов ничего не находит
+		page, err = repo.Search(ctx, repository.SearchQuery{Query: "и", First: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, page.TotalCount)
+
+		// Поисковые векторы обновляются при изменении и удалении
+		_, err = repo.UpdatePost(ctx, author, contentPost.ID, "Заметки", "Моя собака любит спать", false)
+		assert.NoError(t, err)
+		assert.NoError(t, repo.DeleteComment(ctx, comment.ID))
+		page, err = repo.Search(ctx, repository.SearchQuery{Query: "кошки", First: 10})
+		assert.NoError(t, err)
+		if assert.Equal(t, 1, page.TotalCount) {
+			assert.Equal(t, titlePost.ID, page.Hits[0].ID())
+		}
+	})
+
+	// Проверка удаления поста
+	t.Run("TestDeletePost_SQLite", func(t *testing.T) {
+		cleanDatabase(testDB)
+		author := createAuthor(t)
+		//Создание поста
+		post, err := repo.CreatePost(ctx, author, "Title", "Content", false)
+		assert.NoError(t, err)
+
+		// Создание комментариев
+		_, err = repo.CreateComment(ctx, author, post.ID, "", "Comment 1")
+		assert.NoError(t, err)
+		_, err = repo.CreateComment(ctx, author, post.ID, "", "Comment 2")
+		assert.NoError(t, err)
+
+		// Удаление поста
+		err = repo.DeletePost(ctx, post.ID)
+		assert.NoError(t, err)
+
+		_, err = repo.GetPost(ctx, post.ID)
+		assert.Error(t, err)
+		// Проверка на отсутствие поста
+		page, err := repo.GetCommentsByPostID(ctx, post.ID, 10, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(page.Comments))
+	})
+
+	t.Run("TestDeleteComment_SQLite", func(t *testing.T) {
+		cleanDatabase(testDB)
+		author := createAuthor(t)
+
+		//Создание поста
+		post, err := repo.CreatePost(ctx, author, "Title", "Content", false)
+		assert.NoError(t, err)
+
+		// Создание дерева комментариев и соседнего комментария верхнего уровня
+		comment1, err := repo.CreateComment(ctx, author, post.ID, "", "Comment 1")
+		assert.NoError(t, err)
+		reply1, err := repo.CreateComment(ctx, author, post.ID, comment1.ID, "Comment 1.1")
+		assert.NoError(t, err)
+		reply2, err := repo.CreateComment(ctx, author, post.ID, comment1.ID, "Comment 1.2")
+		assert.NoError(t, err)
+		nested, err := repo.CreateComment(ctx, author, post.ID, reply1.ID, "Comment 1.1.1")
+		assert.NoError(t, err)
+		comment2, err := repo.CreateComment(ctx, author, post.ID, "", "Comment 2")
+		assert.NoError(t, err)
+
+		fetched, err := repo.GetPost(ctx, post.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, fetched.CommentCount)
+
+		err = repo.DeleteComment(ctx, comment1.ID)
+		assert.NoError(t, err)
+
+		// Проверка на удаление комментария вместе со всеми ответами
+		for _, id := range []string{comment1.ID, reply1.ID, reply2.ID, nested.ID} {
+			_, err = repo.GetComment(ctx, id)
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+		}
+		replies, err := repo.GetCommentsByParentID(ctx, reply1.ID, 10, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(replies.Comments))
+		page, err := repo.GetCommentsByPostID(ctx, post.ID, 10, nil)
+		assert.NoError(t, err)
+		if assert.Equal(t, 1, len(page.Comments)) {
+			assert.Equal(t, comment2.ID, page.Comments[0].ID)
+		}
+
+		// Счётчик комментариев уменьшается на размер удалённого поддерева
+		fetched, err = repo.GetPost(ctx, post.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, fetched.CommentCount)
+	})
+
+	// Тест типизированных ошибок хранилища
+	t.Run("TestErrors_SQLite", func(t *testing.T) {
+		cleanDatabase(testDB)
+		author := createAuthor(t)
+		post, err := repo.CreatePost(ctx, author, "Title", "Content", true)
+		assert.NoError(t, err)
+
+		_, err = repo.GetPost(ctx, "00000000-0000-0000-0000-000000000000")
+		var notFound *repository.NotFoundError
+		if assert.ErrorAs(t, err, &notFound) {
+			assert.Equal(t, repository.EntityPost, notFound.Entity)
+		}
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+		assert.ErrorIs(t, repo.DeletePost(ctx, "00000000-0000-0000-0000-000000000000"), repository.ErrNotFound)
+		_, err = repo.CreateComment(ctx, author, post.ID, "", "Hello")
+		assert.ErrorIs(t, err, repository.ErrCommentsDisabled)
+		_, err = repo.CreateComment(ctx, author, post.ID, "", strings.Repeat("a", repository.MaxCommentLength+1))
+		var invalid *repository.ValidationError
+		if assert.ErrorAs(t, err, &invalid) {
+			assert.Equal(t, "content", invalid.Field)
+		}
+		assert.ErrorIs(t, err, repository.ErrValidation)
+		_, err = repo.CreateUser(ctx, "author", "password-hash")
+		assert.ErrorIs(t, err, repository.ErrConflict)
+	})
+
+	// Тест отмены: операции с отменённым контекстом или истёкшим сроком не выполняются
+	t.Run("TestCancellation_SQLite", func(t *testing.T) {
+		cleanDatabase(testDB)
+		author := createAuthor(t)
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := repo.CreatePost(canceled, author, "Title", "Content", false)
+		assert.ErrorIs(t, err, context.Canceled)
+		posts, err := repo.GetPosts(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(posts))
+
+		expired, cancel := context.WithTimeout(ctx, -time.Second)
+		defer cancel()
+		_, err = repository.WithTimeout(repo, time.Minute).GetPosts(expired)
+		assert.ErrorIs(t, err, repository.ErrTimeout)
+	})
+}
+
+// Общий набор тестов контракта хранилища, каждый тест работает с новым файлом базы данных
+func TestConformance_SQLite(t *testing.T) {
+	conformance.Run(t, func(t *testing.T) repository.Repository {
+		return sqlite.NewSQLiteRepository(openTestDB(t))
+	})
+}
+
+// Тест миграции таблицы замыкания: иерархия существующих комментариев переносится из таблицы pairs и обратно
+func TestCommentClosureMigration_SQLite(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrator, err := migrations.NewMigrator(db, migrations.SQLite)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if err := migrator.To(1); err != nil {
+		t.Fatalf("failed to apply initial schema: %v", err)
+	}
+
+	// Данные в начальной схеме: цепочка из трёх комментариев и отдельный комментарий верхнего уровня
+	for _, statement := range []string{
+		"INSERT INTO users (id, username, password_hash) VALUES ('author', 'author', 'password-hash')",
+		"INSERT INTO posts (id, author_id, title, content, comment_count) VALUES ('post', 'author', 'Title', 'Content', 4)",
+		"INSERT INTO comments (id, post_id, parent_id, author_id, content, created_at) VALUES ('root', 'post', NULL, 'author', 'Root', '2024-01-01 00:00:00')",
+		"INSERT INTO comments (id, post_id, parent_id, author_id, content, created_at) VALUES ('child', 'post', 'root', 'author', 'Child', '2024-01-01 00:00:01')",
+		"INSERT INTO comments (id, post_id, parent_id, author_id, content, created_at) VALUES ('grandchild', 'post', 'child', 'author', 'Grandchild', '2024-01-01 00:00:02')",
+		"INSERT INTO comments (id, post_id, parent_id, author_id, content, created_at) VALUES ('other', 'post', NULL, 'author', 'Other', '2024-01-01 00:00:03')",
+		"INSERT INTO pairs (parent_id, child_id) VALUES ('root', 'child'), ('child', 'grandchild')",
+	} {
+		_, err := db.Exec(statement)
+		assert.NoError(t, err, statement)
+	}
+
+	assert.NoError(t, migrator.Up())
+	var count int
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM comment_closure").Scan(&count))
+	assert.Equal(t, 7, count) // 4 пары с самим собой, 2 пары с родителем и 1 с предком второго уровня
+
+	repo := sqlite.NewSQLiteRepository(db)
+	ctx := context.Background()
+	ancestors, err := repo.GetCommentAncestors(ctx, "grandchild")
+	assert.NoError(t, err)
+	if assert.Len(t, ancestors, 2) {
+		assert.Equal(t, "root", ancestors[0].ID)
+		assert.Equal(t, "child", ancestors[1].ID)
+	}
+
+	// Откат восстанавливает прямые связи
+	assert.NoError(t, migrator.To(1))
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM pairs").Scan(&count))
+	assert.Equal(t, 2, count)
+
+	// Поддерево удаляется целиком по заполненной таблице замыкания
+	assert.NoError(t, migrator.Up())
+	assert.NoError(t, repo.DeleteComment(ctx, "child"))
+	post, err := repo.GetPost(ctx, "post")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, post.CommentCount)
+	_, err = repo.GetComment(ctx, "grandchild")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM comment_closure").Scan(&count))
+	assert.Equal(t, 2, count)
+}
@@ -0,0 +1,159 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nemopss/go-posts-comments-system/internal/auth"
+	"github.com/nemopss/go-posts-comments-system/internal/gql"
+	"github.com/nemopss/go-posts-comments-system/internal/repository/inmemory"
+	"github.com/nemopss/go-posts-comments-system/internal/server"
+	"github.com/nemopss/go-posts-comments-system/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Контекст трассировки клиента в заголовке traceparent
+const (
+	clientTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	clientSpanID  = "00f067aa0ba902b7"
+)
+
+// recordSpans устанавливает TracerProvider, сохраняющий span в памяти, и возвращает функцию,
+// которая отправляет накопленные span и возвращает их
+func recordSpans(t *testing.T) func() tracetest.SpanStubs {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.Install(exporter, tracing.Options{ServiceName: "test", SampleRatio: 1})
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return func() tracetest.SpanStubs {
+		require.NoError(t, provider.ForceFlush(context.Background()))
+		return exporter.GetSpans()
+	}
+}
+
+// findSpan возвращает span с именем name
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	require.Failf(t, "span not found", "no span named %q", name)
+	return tracetest.SpanStub{}
+}
+
+// newTestServer поднимает HTTP сервер с трассировкой запросов и постом с комментарием в хранилище
+func newTestServer(t *testing.T) *httptest.Server {
+	ctx := context.Background()
+	repo := inmemory.NewInMemoryRepository()
+	author, err := repo.CreateUser(ctx, "author", "password-hash")
+	require.NoError(t, err)
+	post, err := repo.CreatePost(ctx, author.ID, "Post", "Content", false)
+	require.NoError(t, err)
+	_, err = repo.CreateComment(ctx, author.ID, post.ID, "", "Comment")
+	require.NoError(t, err)
+
+	tokens, err := auth.NewTokens(auth.Config{Algorithm: auth.AlgorithmHS256, Secret: []byte("test-secret"), TTL: time.Hour})
+	require.NoError(t, err)
+	srv := server.NewServer(repo, tokens, gql.Options{})
+	ts := httptest.NewServer(server.TracingMiddleware(srv.Handler()))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// query выполняет GraphQL запрос с заголовком traceparent
+func query(t *testing.T, ts *httptest.Server, traceparent, q string) {
+	body, err := json.Marshal(map[string]interface{}{"query": q})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/graphql", bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", traceparent)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+// Тест трассы запроса: span HTTP запроса продолжает трассу клиента, span операции вложен в него,
+// а span резолверов - в span операции
+func TestRequestTrace(t *testing.T) {
+	spans := recordSpans(t)
+	ts := newTestServer(t)
+	query(t, ts, "00-"+clientTraceID+"-"+clientSpanID+"-01",
+		`query Feed { posts(first: 10) { edges { node { id comments(first: 5) { totalCount } } } } }`)
+	recorded := spans()
+
+	request := findSpan(t, recorded, "POST /graphql")
+	assert.Equal(t, clientTraceID, request.SpanContext.TraceID().String())
+	assert.Equal(t, clientSpanID, request.Parent.SpanID().String())
+	assert.True(t, request.Parent.IsRemote())
+
+	operation := findSpan(t, recorded, "query Feed")
+	assert.Equal(t, request.SpanContext.SpanID(), operation.Parent.SpanID())
+	assert.Equal(t, codes.Unset, operation.Status.Code)
+
+	for _, name := range []string{"Query.posts", "Post.comments"} {
+		resolver := findSpan(t, recorded, name)
+		assert.Equal(t, operation.SpanContext.SpanID(), resolver.Parent.SpanID(), name)
+		assert.Equal(t, clientTraceID, resolver.SpanContext.TraceID().String(), name)
+	}
+}
+
+// Тест ошибок: span резолвера и операции отмечаются ошибкой
+func TestErrorTrace(t *testing.T) {
+	spans := recordSpans(t)
+	ts := newTestServer(t)
+	query(t, ts, "00-"+clientTraceID+"-"+clientSpanID+"-01", `query Missing { post(id: "missing") { id } }`)
+	recorded := spans()
+
+	resolver := findSpan(t, recorded, "Query.post")
+	assert.Equal(t, codes.Error, resolver.Status.Code)
+	assert.Equal(t, "post missing not found", resolver.Status.Description)
+	assert.Equal(t, codes.Error, findSpan(t, recorded, "query Missing").Status.Code)
+}
+
+// Тест выборки: трасса, которую клиент решил не записывать, не записывается и сервером
+func TestUnsampledTrace(t *testing.T) {
+	spans := recordSpans(t)
+	ts := newTestServer(t)
+	query(t, ts, "00-"+clientTraceID+"-"+clientSpanID+"-00", `query Feed { posts(first: 10) { totalCount } }`)
+	assert.Empty(t, spans())
+}
+
+// Тест экспорта span в stdout
+func TestStdoutExporter(t *testing.T) {
+	var out bytes.Buffer
+	shutdown, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    tracing.ExporterStdout,
+		ServiceName: "posts-test",
+		SampleRatio: 1,
+		Writer:      &out,
+	})
+	require.NoError(t, err)
+	_, span := otel.Tracer("test").Start(context.Background(), "test-span")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	assert.Contains(t, out.String(), `"Name":"test-span"`)
+	assert.Contains(t, out.String(), "posts-test")
+}
+
+// Тест настройки экспорта
+func TestSetup(t *testing.T) {
+	shutdown, err := tracing.Setup(context.Background(), tracing.Options{Exporter: tracing.ExporterNone})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = tracing.Setup(context.Background(), tracing.Options{Exporter: "jaeger"})
+	assert.ErrorContains(t, err, "jaeger")
+}
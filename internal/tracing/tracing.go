@@ -0,0 +1,79 @@
+// Package tracing настраивает распределённую трассировку OpenTelemetry: экспорт span,
+// выборку трасс и распространение контекста трассировки в заголовках W3C Trace Context.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Способы экспорта span
+const (
+	ExporterNone   = "none"   // Span не экспортируются, но контекст трассировки передаётся дальше
+	ExporterStdout = "stdout" // Span записываются в формате JSON
+	ExporterOTLP   = "otlp"   // Span отправляются коллектору по протоколу OTLP/HTTP
+)
+
+// Options задаёт параметры трассировки
+type Options struct {
+	Exporter    string    // Способ экспорта: ExporterNone, ExporterStdout или ExporterOTLP
+	Endpoint    string    // URL коллектора OTLP/HTTP, например http://localhost:4318
+	ServiceName string    // Имя сервиса в ресурсе трасс
+	SampleRatio float64   // Доля трасс, начинаемых сервером, от 0 до 1
+	Writer      io.Writer // Назначение span при экспорте в stdout, по умолчанию os.Stdout
+}
+
+// Setup создаёт экспорт span согласно options и устанавливает глобальные TracerProvider и распространение контекста.
+// Возвращает функцию, которая отправляет накопленные span и останавливает экспорт; её нужно вызвать при остановке сервера.
+func Setup(ctx context.Context, options Options) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch options.Exporter {
+	case ExporterNone, "":
+		otel.SetTextMapPropagator(propagator())
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		writer := options.Writer
+		if writer == nil {
+			writer = os.Stdout
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(writer))
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(options.Endpoint))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", options.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Install(exporter, options).Shutdown, nil
+}
+
+// Install устанавливает глобальные TracerProvider, передающий span в exporter, и распространение контекста.
+// Тесты передают сюда экспорт в память, чтобы проверять трассы без сети.
+func Install(exporter sdktrace.SpanExporter, options Options) *sdktrace.TracerProvider {
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		// Решение о записи трассы, начатой клиентом, принимает клиент
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(options.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(options.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator())
+	return provider
+}
+
+// propagator возвращает распространение контекста трассировки и baggage в заголовках W3C
+func propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}